@@ -2,16 +2,19 @@
 package baidupcs
 
 import (
+	"context"
 	"errors"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/expires/cachemap"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/internal/panhome"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 const (
@@ -110,7 +113,17 @@ const (
 )
 
 var (
-	baiduPCSVerbose = pcsverbose.New("BAIDUPCS")
+	// DefaultRequestRetryPolicy 默认的请求重试策略, 网络错误, 被限流或服务器暂时不可用时重试
+	DefaultRequestRetryPolicy = &retry.Policy{
+		MaxRetry:  2,
+		BaseDelay: 500 * time.Millisecond,
+		MaxDelay:  5 * time.Second,
+		Jitter:    0.2,
+		Classify:  pcserror.Classify,
+	}
+
+	baiduPCSVerbose = pcslog.New("BAIDUPCS")
+	baiduPCSTracer  = pcslog.New("BAIDUPCS_TRACE")
 
 	baiduComURL = &url.URL{
 		Scheme: "http",
@@ -133,8 +146,13 @@ type (
 		pcsUA      string
 		panUA      string
 		isSetPanUA bool
+		pcsProxy   string // PCS 请求使用的代理
+		panProxy   string // Pan 请求使用的代理
 		ph         *panhome.PanHome
-		cacheOpMap cachemap.CacheOpMap
+		cacheOpMap *cachemap.CacheOpMap
+		ctx        context.Context // 请求使用的 context, 为空则使用 context.Background()
+
+		retryPolicy *retry.Policy // 请求的重试策略, 为空则使用 DefaultRequestRetryPolicy
 	}
 
 	userInfoJSON struct {
@@ -158,16 +176,18 @@ func NewPCS(appID int, bduss string) *BaiduPCS {
 	})
 
 	return &BaiduPCS{
-		appID:  appID,
-		client: client,
+		appID:      appID,
+		client:     client,
+		cacheOpMap: &cachemap.CacheOpMap{},
 	}
 }
 
 // NewPCSWithClient 提供app_id, 自定义客户端, 返回 BaiduPCS 对象
 func NewPCSWithClient(appID int, client *requester.HTTPClient) *BaiduPCS {
 	pcs := &BaiduPCS{
-		appID:  appID,
-		client: client,
+		appID:      appID,
+		client:     client,
+		cacheOpMap: &cachemap.CacheOpMap{},
 	}
 	return pcs
 }
@@ -175,8 +195,9 @@ func NewPCSWithClient(appID int, client *requester.HTTPClient) *BaiduPCS {
 // NewPCSWithCookieStr 提供app_id, cookie 字符串, 返回 BaiduPCS 对象
 func NewPCSWithCookieStr(appID int, cookieStr string) *BaiduPCS {
 	pcs := &BaiduPCS{
-		appID:  appID,
-		client: requester.NewHTTPClient(),
+		appID:      appID,
+		client:     requester.NewHTTPClient(),
+		cacheOpMap: &cachemap.CacheOpMap{},
 	}
 
 	cookies := requester.ParseCookieStr(cookieStr)
@@ -198,6 +219,9 @@ func (pcs *BaiduPCS) lazyInit() {
 	if pcs.ph == nil {
 		pcs.ph = panhome.NewPanHome(pcs.client)
 	}
+	if pcs.cacheOpMap == nil {
+		pcs.cacheOpMap = &cachemap.CacheOpMap{}
+	}
 	if !pcs.isSetPanUA {
 		pcs.panUA = NetdiskUA
 	}
@@ -209,6 +233,38 @@ func (pcs *BaiduPCS) GetClient() *requester.HTTPClient {
 	return pcs.client
 }
 
+// WithContext 返回使用 ctx 发起请求的 BaiduPCS 浅拷贝, 与 pcs 共用 http 客户端和缓存.
+// ctx 取消后, 进行中和之后的请求均返回错误
+func (pcs *BaiduPCS) WithContext(ctx context.Context) *BaiduPCS {
+	if ctx == nil {
+		panic("nil context")
+	}
+	pcs.lazyInit()
+	pcs2 := *pcs
+	pcs2.ctx = ctx
+	return &pcs2
+}
+
+// SetRetryPolicy 设置请求的重试策略, 只重试可以重复发送的 GET 和 HEAD 请求
+func (pcs *BaiduPCS) SetRetryPolicy(p *retry.Policy) {
+	pcs.retryPolicy = p
+}
+
+func (pcs *BaiduPCS) requestRetryPolicy() *retry.Policy {
+	if pcs.retryPolicy == nil {
+		return DefaultRequestRetryPolicy
+	}
+	return pcs.retryPolicy
+}
+
+// Context 返回请求使用的 context
+func (pcs *BaiduPCS) Context() context.Context {
+	if pcs.ctx == nil {
+		return context.Background()
+	}
+	return pcs.ctx
+}
+
 // GetBDUSS 获取BDUSS
 func (pcs *BaiduPCS) GetBDUSS() (bduss string) {
 	if pcs.client == nil || pcs.client.Jar == nil {
@@ -261,6 +317,16 @@ func (pcs *BaiduPCS) SetPanUserAgent(ua string) {
 	pcs.isSetPanUA = true
 }
 
+// SetPCSProxy 设置 PCS 请求使用的代理, 为空则使用客户端的代理
+func (pcs *BaiduPCS) SetPCSProxy(proxyAddr string) {
+	pcs.pcsProxy = proxyAddr
+}
+
+// SetPanProxy 设置 Pan 请求使用的代理, 为空则使用客户端的代理
+func (pcs *BaiduPCS) SetPanProxy(proxyAddr string) {
+	pcs.panProxy = proxyAddr
+}
+
 // SetHTTPS 是否启用https连接
 func (pcs *BaiduPCS) SetHTTPS(https bool) {
 	pcs.isHTTPS = https
@@ -210,6 +210,10 @@ func (pcs *BaiduPCS) Search(targetPath, keyword string, recursive bool) (fdl Fil
 }
 
 func (pcs *BaiduPCS) recurseList(path string, depth int, options *OrderOptions, handleFileDirectoryFunc HandleFileDirectoryFunc) (fdl FileDirectoryList, ok bool) {
+	if pcs.Context().Err() != nil { // 已取消
+		return nil, false
+	}
+
 	fdl, pcsError := pcs.FilesDirectoriesList(path, options)
 	if pcsError != nil {
 		ok := handleFileDirectoryFunc(depth, path, nil, pcsError) // 传递错误
@@ -0,0 +1,11 @@
+package baidupcs
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/metrics"
+)
+
+var (
+	apiRequestDuration = metrics.NewHistogramVec("baidupcs_api_request_duration_seconds", "百度网盘 API 请求的耗时", nil, "op")
+	quotaTotalBytes    = metrics.NewGauge("baidupcs_quota_total_bytes", "网盘总空间")
+	quotaUsedBytes     = metrics.NewGauge("baidupcs_quota_used_bytes", "网盘已用空间")
+)
@@ -0,0 +1,109 @@
+package pcserror
+
+import (
+	"context"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"net/url"
+	"strings"
+)
+
+var (
+	// remoteErrClasses 远端服务器错误代码的类别, 未列出的错误代码视为永久错误
+	remoteErrClasses = map[int]retry.Class{
+		// PCS
+		31021: retry.ClassRetryable, // network error
+		31023: retry.ClassPermanent, // param error
+		31034: retry.ClassThrottled, // hit frequence control
+		31045: retry.ClassAuth,      // user not exists
+		31061: retry.ClassPermanent, // file already exists
+		31062: retry.ClassPermanent, // file name is invalid
+		31066: retry.ClassPermanent, // file does not exist
+		31079: retry.ClassPermanent, // file md5 not found, 秒传文件失败
+		31112: retry.ClassQuota,     // exceed quota
+		31200: retry.ClassRetryable, // internal server error
+		31218: retry.ClassQuota,     // storage exceed limit
+		31298: retry.ClassRetryable, // internal error
+		31299: retry.ClassRetryable, // server busy
+		31352: retry.ClassRetryable, // commit file failed
+		31363: retry.ClassRetryable, // block missing in superfile2, 分片可能尚未同步
+		31364: retry.ClassRetryable, // superfile create failed
+		31626: retry.ClassRetryable, // user is not authorized, 可能是 User-Agent 不对, 可以重试
+
+		// Pan
+		-3:  retry.ClassPermanent, // 文件不存在
+		-4:  retry.ClassAuth,      // 登录信息有误
+		-6:  retry.ClassAuth,      // 请重新登录
+		-7:  retry.ClassPermanent, // 该分享已删除或已取消
+		-8:  retry.ClassPermanent, // 该分享已经过期
+		-9:  retry.ClassPermanent, // 文件不存在
+		-10: retry.ClassQuota,     // 超出上限
+		-11: retry.ClassAuth,      // 验证cookie无效
+		-12: retry.ClassPermanent, // 访问密码错误
+		-30: retry.ClassPermanent, // 文件已存在
+		-31: retry.ClassRetryable, // 文件保存失败
+		-62: retry.ClassThrottled, // 可能需要输入验证码, 通常由请求过于频繁引起
+		2:   retry.ClassPermanent, // 参数错误
+		3:   retry.ClassAuth,      // 未登录或帐号无效
+		4:   retry.ClassRetryable, // 存储好像出问题了，请稍候再试
+		110: retry.ClassQuota,     // 分享次数超出限制
+	}
+)
+
+// Classify 按错误类型和远端服务器错误代码, 判断错误的类别.
+// 网络错误和 json 数据解析失败视为临时错误, 已取消视为永久错误, err 不是 Error 时按网络错误处理
+func Classify(err error) retry.Class {
+	if err == nil {
+		return retry.ClassPermanent
+	}
+
+	pcsError, ok := err.(Error)
+	if !ok {
+		return classifyNetError(err)
+	}
+
+	switch pcsError.GetErrType() {
+	case ErrTypeRemoteError:
+		class, ok := remoteErrClasses[pcsError.GetRemoteErrCode()]
+		if !ok {
+			return retry.ClassPermanent
+		}
+		return class
+	case ErrTypeNetError:
+		return classifyNetError(pcsError.GetError())
+	case ErrTypeJSONParseError:
+		// 服务器可能返回了错误页面
+		return retry.ClassRetryable
+	case ErrTypeInternalError:
+		return retry.ClassPermanent
+	default:
+		if pcsError.GetError() == nil {
+			return retry.ClassPermanent
+		}
+		return classifyNetError(pcsError.GetError())
+	}
+}
+
+// classifyNetError 判断网络错误的类别
+func classifyNetError(err error) retry.Class {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if err == nil {
+		return retry.ClassRetryable
+	}
+
+	switch err {
+	case context.Canceled, context.DeadlineExceeded:
+		return retry.ClassPermanent
+	}
+
+	// http 响应错误, 参见 handleRespStatusError
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "429 Too Many Requests"):
+		return retry.ClassThrottled
+	case strings.Contains(msg, "413 Request Entity Too Large"):
+		return retry.ClassPermanent
+	}
+	return retry.ClassRetryable
+}
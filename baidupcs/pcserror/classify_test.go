@@ -0,0 +1,49 @@
+package pcserror_test
+
+import (
+	"context"
+	"errors"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"net/url"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	remote := func(code int) pcserror.Error {
+		errInfo := pcserror.NewPCSErrorInfo("test")
+		errInfo.ErrCode = code
+		errInfo.SetRemoteError()
+		return errInfo
+	}
+	netErr := func(err error) pcserror.Error {
+		errInfo := pcserror.NewPanErrorInfo("test")
+		errInfo.SetNetError(err)
+		return errInfo
+	}
+	jsonErr := pcserror.NewPCSErrorInfo("test")
+	jsonErr.SetJSONError(errors.New("invalid character"))
+
+	for k, c := range []struct {
+		err   error
+		class retry.Class
+	}{
+		{remote(31066), retry.ClassPermanent},
+		{remote(31045), retry.ClassAuth},
+		{remote(31112), retry.ClassQuota},
+		{remote(31034), retry.ClassThrottled},
+		{remote(31200), retry.ClassRetryable},
+		{remote(-31), retry.ClassRetryable},
+		{remote(99999), retry.ClassPermanent}, // 未知的错误代码
+		{netErr(errors.New("connection reset by peer")), retry.ClassRetryable},
+		{netErr(&url.Error{Op: "Get", URL: "http://example.com", Err: context.Canceled}), retry.ClassPermanent},
+		{netErr(errors.New("http 响应错误, 429 Too Many Requests")), retry.ClassThrottled},
+		{jsonErr, retry.ClassRetryable},
+		{context.Canceled, retry.ClassPermanent},
+		{errors.New("EOF"), retry.ClassRetryable},
+	} {
+		if class := pcserror.Classify(c.err); class != c.class {
+			t.Errorf("%d: %v, class: %s, want: %s", k, c.err, class, c.class)
+		}
+	}
+}
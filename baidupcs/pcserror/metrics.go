@@ -0,0 +1,36 @@
+package pcserror
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/metrics"
+	"strconv"
+)
+
+var (
+	apiErrorsTotal = metrics.NewCounterVec("baidupcs_api_errors_total", "百度网盘 API 的错误次数", "op", "type", "code")
+
+	errTypeNames = map[ErrType]string{
+		ErrTypeInternalError:  "internal",
+		ErrTypeRemoteError:    "remote",
+		ErrTypeNetError:       "net",
+		ErrTypeJSONParseError: "json",
+		ErrTypeOthers:         "others",
+	}
+)
+
+// RecordMetrics 记录错误到监控指标, 远端服务器错误会附带错误代码
+func RecordMetrics(pcsError Error) {
+	if pcsError == nil {
+		return
+	}
+
+	typeName, ok := errTypeNames[pcsError.GetErrType()]
+	if !ok {
+		return
+	}
+
+	var code string
+	if pcsError.GetErrType() == ErrTypeRemoteError {
+		code = strconv.Itoa(pcsError.GetRemoteErrCode())
+	}
+	apiErrorsTotal.WithLabelValues(pcsError.GetOperation(), typeName, code).Inc()
+}
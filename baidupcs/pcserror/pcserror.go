@@ -77,12 +77,14 @@ func HandleJSONParse(op string, data io.Reader, info interface{}) (pcsError Erro
 
 	if err != nil {
 		errInfo.SetJSONError(err)
+		RecordMetrics(errInfo)
 		return errInfo
 	}
 
 	// 设置出错类型为远程错误
 	if errInfo.GetRemoteErrCode() != 0 {
 		errInfo.SetRemoteError()
+		RecordMetrics(errInfo)
 		return errInfo
 	}
 
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/netdisksign"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"github.com/felixonmars/BaiduPCS-Go/requester/multipartreader"
 	"github.com/felixonmars/baidu-tools/tieba"
 	"github.com/json-iterator/go"
@@ -14,6 +17,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -52,40 +56,105 @@ func (pcs *BaiduPCS) sendReqReturnResp(rt reqType, op, method, urlStr string, po
 	}
 
 	var (
-		_, uaok = header["User-Agent"]
+		_, uaok   = header["User-Agent"]
+		proxyAddr string
 	)
 
-	if !uaok {
-		switch rt {
-		case reqTypePCS:
+	switch rt {
+	case reqTypePCS:
+		if !uaok {
 			header["User-Agent"] = pcs.pcsUA
-		case reqTypePan:
+		}
+		proxyAddr = pcs.pcsProxy
+	case reqTypePan:
+		if !uaok {
 			header["User-Agent"] = pcs.panUA
 		}
+		proxyAddr = pcs.panProxy
 	}
 
-	resp, err := pcs.client.Req(method, urlStr, post, header)
+	// 只重试可以重复发送的请求
+	canRetry := post == nil && (method == http.MethodGet || method == http.MethodHead)
+	for attempt := 1; ; attempt++ {
+		resp, pcsError = pcs.sendReq(rt, op, method, urlStr, post, header, proxyAddr)
+		if !canRetry {
+			return
+		}
+
+		retryErr := pcsError
+		if retryErr == nil {
+			retryErr = handleRetryStatus(op, resp)
+			if retryErr == nil {
+				return
+			}
+		}
+		wait, ok := pcs.requestRetryPolicy().Next(retryErr, attempt)
+		if !ok || pcs.Context().Err() != nil {
+			return
+		}
+		handleRespClose(resp)
+		baiduPCSTracer.Debug("retry request", "op", op, "attempt", attempt, "wait", wait, "err", retryErr)
+		if retry.Sleep(pcs.Context(), wait) != nil {
+			return nil, retryErr
+		}
+	}
+}
+
+// sendReq 发送一次请求
+func (pcs *BaiduPCS) sendReq(rt reqType, op, method, urlStr string, post interface{}, header map[string]string, proxyAddr string) (resp *http.Response, pcsError pcserror.Error) {
+	startTime := time.Now()
+	resp, err := pcs.client.ReqWithContext(requester.ContextWithProxy(pcs.Context(), proxyAddr), method, urlStr, post, header)
+	elapsed := time.Since(startTime)
+	pcs.traceReq(op, method, urlStr, header, resp, err, elapsed)
+	apiRequestDuration.WithLabelValues(op).Observe(elapsed.Seconds())
 	if err != nil {
 		handleRespClose(resp)
 		switch rt {
 		case reqTypePCS:
-			return nil, &pcserror.PCSErrInfo{
+			pcsError = &pcserror.PCSErrInfo{
 				Operation: op,
 				ErrType:   pcserror.ErrTypeNetError,
 				Err:       err,
 			}
 		case reqTypePan:
-			return nil, &pcserror.PanErrorInfo{
+			pcsError = &pcserror.PanErrorInfo{
 				Operation: op,
 				ErrType:   pcserror.ErrTypeNetError,
 				Err:       err,
 			}
+		default:
+			panic("unreachable")
 		}
-		panic("unreachable")
+		pcserror.RecordMetrics(pcsError)
+		return nil, pcsError
 	}
 	return resp, nil
 }
 
+// handleRetryStatus 被限流或服务器暂时不可用时, 返回可以重试的错误, 不关闭 resp
+func handleRetryStatus(opreation string, resp *http.Response) pcserror.Error {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		errInfo := pcserror.NewPCSErrorInfo(opreation)
+		errInfo.SetNetError(fmt.Errorf("http 响应错误, %s", resp.Status))
+		return errInfo
+	}
+	return nil
+}
+
+// traceReq 记录请求详情, 敏感信息由 pcslog 隐藏
+func (pcs *BaiduPCS) traceReq(op, method, urlStr string, header map[string]string, resp *http.Response, err error, elapsed time.Duration) {
+	if !baiduPCSTracer.Enabled(pcslog.LevelDebug) {
+		return
+	}
+
+	var statusCode int
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	baiduPCSTracer.Debug("send request", "op", op, "method", method, "url", urlStr, "header", header, "status", statusCode, "elapsed", elapsed, "err", err)
+}
+
 func (pcs *BaiduPCS) sendReqReturnReadCloser(rt reqType, op, method, urlStr string, post interface{}, header map[string]string) (readCloser io.ReadCloser, pcsError pcserror.Error) {
 	resp, pcsError := pcs.sendReqReturnResp(rt, op, method, urlStr, post, header)
 	if pcsError != nil {
@@ -267,7 +336,7 @@ func (pcs *BaiduPCS) PrepareMove(cpmvJSON ...*CpMvJSON) (dataReadCloser io.ReadC
 }
 
 // prepareRapidUpload 秒传文件, 不进行文件夹检查
-func (pcs *BaiduPCS) prepareRapidUpload(targetPath, contentMD5, sliceMD5, crc32 string, length int64) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
+func (pcs *BaiduPCS) prepareRapidUpload(targetPath, ondup, contentMD5, sliceMD5, crc32 string, length int64) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
 	pcs.lazyInit()
 	pcsURL := pcs.generatePCSURL("file", "rapidupload", map[string]string{
 		"path":           targetPath,                    // 上传文件的全路径名
@@ -275,7 +344,7 @@ func (pcs *BaiduPCS) prepareRapidUpload(targetPath, contentMD5, sliceMD5, crc32
 		"content-md5":    contentMD5,                    // 待秒传的文件的MD5
 		"slice-md5":      sliceMD5,                      // 待秒传的文件前256kb的MD5
 		"content-crc32":  crc32,                         // 待秒传文件CRC32
-		"ondup":          "overwrite",                   // overwrite: 表示覆盖同名文件; newcopy: 表示生成文件副本并进行重命名，命名规则为“文件名_日期.后缀”
+		"ondup":          ondupParam(ondup),             // 同名文件已存在时的处理方式
 	})
 	baiduPCSVerbose.Infof("%s URL: %s\n", OperationRapidUpload, pcsURL)
 
@@ -284,14 +353,14 @@ func (pcs *BaiduPCS) prepareRapidUpload(targetPath, contentMD5, sliceMD5, crc32
 }
 
 // PrepareRapidUpload 秒传文件, 只返回服务器响应数据和错误信息
-func (pcs *BaiduPCS) PrepareRapidUpload(targetPath, contentMD5, sliceMD5, crc32 string, length int64) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
+func (pcs *BaiduPCS) PrepareRapidUpload(targetPath, ondup, contentMD5, sliceMD5, crc32 string, length int64) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
 	pcs.lazyInit()
 	pcsError = pcs.checkIsdir(OperationRapidUpload, targetPath)
 	if pcsError != nil {
 		return nil, pcsError
 	}
 
-	return pcs.prepareRapidUpload(targetPath, contentMD5, sliceMD5, crc32, length)
+	return pcs.prepareRapidUpload(targetPath, ondup, contentMD5, sliceMD5, crc32, length)
 }
 
 // PrepareLocateDownload 获取下载链接, 只返回服务器响应数据和错误信息
@@ -358,7 +427,7 @@ func (pcs *BaiduPCS) PrepareLocatePanAPIDownload(fidList ...int64) (dataReadClos
 }
 
 // PrepareUpload 上传单个文件, 只返回服务器响应数据和错误信息
-func (pcs *BaiduPCS) PrepareUpload(targetPath string, uploadFunc UploadFunc) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
+func (pcs *BaiduPCS) PrepareUpload(targetPath, ondup string, uploadFunc UploadFunc) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
 	pcs.lazyInit()
 	pcsError = pcs.checkIsdir(OperationUpload, targetPath)
 	if pcsError != nil {
@@ -367,7 +436,7 @@ func (pcs *BaiduPCS) PrepareUpload(targetPath string, uploadFunc UploadFunc) (da
 
 	pcsURL := pcs.generatePCSURL("file", "upload", map[string]string{
 		"path":  targetPath,
-		"ondup": "overwrite",
+		"ondup": ondupParam(ondup),
 	})
 	baiduPCSVerbose.Infof("%s URL: %s\n", OperationUpload, pcsURL)
 
@@ -416,7 +485,7 @@ func (pcs *BaiduPCS) PrepareUploadTmpFile(uploadFunc UploadFunc) (dataReadCloser
 }
 
 // PrepareUploadCreateSuperFile 分片上传—合并分片文件, 只返回服务器响应数据和错误信息
-func (pcs *BaiduPCS) PrepareUploadCreateSuperFile(checkDir bool, targetPath string, blockList ...string) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
+func (pcs *BaiduPCS) PrepareUploadCreateSuperFile(checkDir bool, targetPath, ondup string, blockList ...string) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
 	pcs.lazyInit()
 
 	if checkDir {
@@ -438,7 +507,7 @@ func (pcs *BaiduPCS) PrepareUploadCreateSuperFile(checkDir bool, targetPath stri
 
 	pcsURL := pcs.generatePCSURL("file", "createsuperfile", map[string]string{
 		"path":  targetPath,
-		"ondup": "overwrite",
+		"ondup": ondupParam(ondup),
 	})
 	baiduPCSVerbose.Infof("%s URL: %s\n", OperationUploadCreateSuperFile, pcsURL)
 
@@ -28,5 +28,7 @@ func (pcs *BaiduPCS) QuotaInfo() (quota, used int64, pcsError pcserror.Error) {
 		return
 	}
 
+	quotaTotalBytes.Set(float64(quotaInfo.Quota))
+	quotaUsedBytes.Set(float64(quotaInfo.Used))
 	return quotaInfo.Quota, quotaInfo.Used, nil
 }
@@ -21,6 +21,13 @@ const (
 	SliceMD5Size = 256 * converter.KB
 	// EmptyContentMD5 空串的md5
 	EmptyContentMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
+	// OndupOverwrite 同名文件已存在时, 覆盖同名文件
+	OndupOverwrite = "overwrite"
+	// OndupNewCopy 同名文件已存在时, 生成文件副本并进行重命名, 命名规则为 "文件名_日期.后缀"
+	OndupNewCopy = "newcopy"
+	// OndupFail 同名文件已存在时, 返回错误
+	OndupFail = "fail"
 )
 
 var (
@@ -82,9 +89,17 @@ type (
 	}
 )
 
-// RapidUpload 秒传文件
-func (pcs *BaiduPCS) RapidUpload(targetPath, contentMD5, sliceMD5, crc32 string, length int64) (pcsError pcserror.Error) {
-	dataReadCloser, pcsError := pcs.PrepareRapidUpload(targetPath, contentMD5, sliceMD5, crc32, length)
+// ondupParam 同名文件已存在时的处理方式, 默认覆盖
+func ondupParam(ondup string) string {
+	if ondup == "" {
+		return OndupOverwrite
+	}
+	return ondup
+}
+
+// RapidUpload 秒传文件, ondup 为同名文件已存在时的处理方式, 为空则覆盖
+func (pcs *BaiduPCS) RapidUpload(targetPath, ondup, contentMD5, sliceMD5, crc32 string, length int64) (pcsError pcserror.Error) {
+	dataReadCloser, pcsError := pcs.PrepareRapidUpload(targetPath, ondup, contentMD5, sliceMD5, crc32, length)
 	if pcsError != nil {
 		return
 	}
@@ -103,7 +118,7 @@ func (pcs *BaiduPCS) RapidUpload(targetPath, contentMD5, sliceMD5, crc32 string,
 
 // RapidUploadNoCheckDir 秒传文件, 不进行目录检查, 会覆盖掉同名的目录!
 func (pcs *BaiduPCS) RapidUploadNoCheckDir(targetPath, contentMD5, sliceMD5, crc32 string, length int64) (pcsError pcserror.Error) {
-	dataReadCloser, pcsError := pcs.prepareRapidUpload(targetPath, contentMD5, sliceMD5, crc32, length)
+	dataReadCloser, pcsError := pcs.prepareRapidUpload(targetPath, OndupOverwrite, contentMD5, sliceMD5, crc32, length)
 	if pcsError != nil {
 		return
 	}
@@ -118,9 +133,9 @@ func (pcs *BaiduPCS) RapidUploadNoCheckDir(targetPath, contentMD5, sliceMD5, crc
 	return nil
 }
 
-// Upload 上传单个文件
-func (pcs *BaiduPCS) Upload(targetPath string, uploadFunc UploadFunc) (pcsError pcserror.Error) {
-	dataReadCloser, pcsError := pcs.PrepareUpload(targetPath, uploadFunc)
+// Upload 上传单个文件, ondup 为同名文件已存在时的处理方式, 为空则覆盖
+func (pcs *BaiduPCS) Upload(targetPath, ondup string, uploadFunc UploadFunc) (pcsError pcserror.Error) {
+	dataReadCloser, pcsError := pcs.PrepareUpload(targetPath, ondup, uploadFunc)
 	if pcsError != nil {
 		return pcsError
 	}
@@ -177,9 +192,9 @@ func (pcs *BaiduPCS) UploadTmpFile(uploadFunc UploadFunc) (md5 string, pcsError
 	return jsonData.MD5, nil
 }
 
-// UploadCreateSuperFile 分片上传—合并分片文件
-func (pcs *BaiduPCS) UploadCreateSuperFile(checkDir bool, targetPath string, blockList ...string) (pcsError pcserror.Error) {
-	dataReadCloser, pcsError := pcs.PrepareUploadCreateSuperFile(checkDir, targetPath, blockList...)
+// UploadCreateSuperFile 分片上传—合并分片文件, ondup 为同名文件已存在时的处理方式, 为空则覆盖
+func (pcs *BaiduPCS) UploadCreateSuperFile(checkDir bool, targetPath, ondup string, blockList ...string) (pcsError pcserror.Error) {
+	dataReadCloser, pcsError := pcs.PrepareUploadCreateSuperFile(checkDir, targetPath, ondup, blockList...)
 	if pcsError != nil {
 		return pcsError
 	}
@@ -16,10 +16,12 @@ require (
 	github.com/iikira/Baidu-Login v1.2.2 // indirect
 	github.com/iikira/BaiduPCS-Go v3.5.6+incompatible // indirect
 	github.com/iikira/baidu-tools v0.0.0-20190609113215-4dd64618064d // indirect
-	github.com/json-iterator/go v1.1.7
+	github.com/json-iterator/go v1.1.12
 	github.com/kardianos/osext v0.0.0-20170510131534-ae77be60afb1
+	github.com/klauspost/compress v1.10.0
 	github.com/mattn/go-runewidth v0.0.5-0.20181218000649-703b5e6b11ae
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/oleiade/lane v0.0.0-20160817071224-3053869314bb
 	github.com/olekukonko/tablewriter v0.0.2-0.20190618033246-cc27d85e17ce
 	github.com/peterh/liner v1.1.1-0.20190305032635-6f820f8f90ce
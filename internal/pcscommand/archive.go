@@ -0,0 +1,218 @@
+package pcscommand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/archive"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
+	"github.com/json-iterator/go"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// archiveRangeReader 多线程下载压缩包的一个范围
+	archiveRangeReader struct {
+		*io.PipeReader
+		cancel context.CancelFunc
+	}
+)
+
+func (ar *archiveRangeReader) Close() error {
+	ar.cancel()
+	return ar.PipeReader.Close()
+}
+
+// runUploadArchive 将本地目录打包为压缩包, 边打包边上传到网盘目录 savePath,
+// 同时上传成员的索引, 用于只下载单个成员
+func runUploadArchive(localPaths []string, savePath string, opt *UploadOptions) {
+	format, err := archive.ParseFormat(opt.Archive)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	pcs := GetBaiduPCS()
+	for _, localPath := range localPaths {
+		info, err := os.Stat(localPath)
+		if err != nil {
+			fmt.Printf("警告: %s\n", err)
+			continue
+		}
+		if !info.IsDir() {
+			fmt.Printf("警告: %s 不是目录, 打包上传只支持目录\n", localPath)
+			continue
+		}
+
+		target := path.Join(savePath, filepath.Base(localPath)+format.Ext())
+		fmt.Printf("打包上传: %s -> %s\n", localPath, target)
+
+		var (
+			pr, pw  = io.Pipe()
+			idxChan = make(chan *archive.Index, 1)
+		)
+		go func(localPath string) {
+			idx, err := archive.WriteDir(pw, localPath, format)
+			pw.CloseWithError(err)
+			idxChan <- idx
+		}(localPath)
+
+		result, err := pcsupload.StreamUpload(pcs, pr, target, &pcsupload.StreamUploadOptions{
+			SpillDir: opt.SpillDir,
+			Parallel: opt.Parallel,
+			MaxRetry: opt.MaxRetry,
+		})
+		pr.Close() // 上传出错时, 结束打包
+		idx := <-idxChan
+		if err != nil {
+			fmt.Printf("打包上传失败, %s\n", err)
+			continue
+		}
+
+		data, err := jsoniter.Marshal(idx)
+		if err != nil {
+			fmt.Printf("生成索引失败, %s\n", err)
+			continue
+		}
+		_, err = pcsupload.StreamUpload(pcs, bytes.NewReader(data), target+archive.IndexSuffix, &pcsupload.StreamUploadOptions{
+			SpillDir: opt.SpillDir,
+			MaxRetry: opt.MaxRetry,
+		})
+		if err != nil {
+			fmt.Printf("上传索引失败, %s\n", err)
+			continue
+		}
+
+		fmt.Printf("打包上传成功, 成员数量: %d, 大小: %s, md5: %x, 保存到网盘路径: %s\n", len(idx.Members), converter.ConvertFileSize(result.Size, 2), result.MD5, target)
+	}
+}
+
+// newArchiveRangeOpener 多线程下载网盘文件的指定范围
+func newArchiveRangeOpener(pcs *baidupcs.BaiduPCS, fd *baidupcs.FileDirectory, dlinks []string, cfg *downloader.Config) archive.RangeOpenFunc {
+	return func(begin, end int64) (io.ReadCloser, error) {
+		var (
+			pr, pw      = io.Pipe()
+			ctx, cancel = context.WithCancel(pcs.Context())
+		)
+		go func() {
+			err := pcsdownload.StreamDownload(ctx, pw, &pcsdownload.StreamOptions{
+				DownloadURL: dlinks[0],
+				Mirrors:     dlinks[1:],
+				RefreshFunc: func() ([]string, error) {
+					return pcsdownload.GetLocateDownloadLinkStrings(pcs, fd.Path)
+				},
+				Size:   fd.Size,
+				Begin:  begin,
+				End:    end,
+				Config: cfg,
+			})
+			pw.CloseWithError(err)
+		}()
+		return &archiveRangeReader{
+			PipeReader: pr,
+			cancel:     cancel,
+		}, nil
+	}
+}
+
+// getArchiveIndex 下载压缩包的索引
+func getArchiveIndex(pcs *baidupcs.BaiduPCS, archivePath string, cfg *downloader.Config) (*archive.Index, error) {
+	indexPath := archivePath + archive.IndexSuffix
+	fd, pcsError := pcs.FilesDirectoriesMeta(indexPath)
+	if pcsError != nil {
+		return nil, pcsError
+	}
+	dlinks, err := pcsdownload.GetLocateDownloadLinkStrings(pcs, indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, _ := newArchiveRangeOpener(pcs, fd, dlinks, cfg)(0, fd.Size)
+	defer rc.Close()
+
+	idx := &archive.Index{}
+	err = jsoniter.NewDecoder(rc).Decode(idx)
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// runDownloadExtract 根据索引, 只下载压缩包中指定成员所在的范围, 并解压,
+// 未指定成员时, 解压全部成员
+func runDownloadExtract(paths []string, options *DownloadOptions) {
+	paths, err := matchPathByShellPattern(paths...)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var (
+		pcs = GetBaiduPCS()
+		cfg = streamDownloadConfig(options.Parallel)
+	)
+	for _, pcspath := range paths {
+		if strings.HasSuffix(pcspath, archive.IndexSuffix) {
+			continue
+		}
+
+		fd, pcsError := pcs.FilesDirectoriesMeta(pcspath)
+		if pcsError != nil {
+			fmt.Printf("%s: %s\n", pcspath, pcsError)
+			continue
+		}
+		if fd.Isdir {
+			fmt.Printf("%s: 是一个目录\n", pcspath)
+			continue
+		}
+
+		idx, err := getArchiveIndex(pcs, pcspath, cfg)
+		if err != nil {
+			fmt.Printf("%s: 获取索引失败, %s\n", pcspath, err)
+			continue
+		}
+		if idx.Size != fd.Size {
+			fmt.Printf("%s: %s\n", pcspath, archive.ErrCorruptIndex)
+			continue
+		}
+
+		members := idx.Members
+		if len(options.Members) > 0 {
+			var notFound []string
+			members, notFound = idx.Select(options.Members...)
+			for _, name := range notFound {
+				fmt.Printf("%s: 未找到成员: %s\n", pcspath, name)
+			}
+			if len(members) == 0 {
+				continue
+			}
+		}
+
+		dlinks, err := pcsdownload.GetLocateDownloadLinkStrings(pcs, pcspath)
+		if err != nil {
+			fmt.Printf("%s: %s, %s\n", pcspath, pcsdownload.StrDownloadGetDlinkFailed, err)
+			continue
+		}
+
+		saveDir := options.SaveTo
+		if saveDir == "" {
+			saveDir = filepath.Dir(GetActiveUser().GetSavePath(pcspath))
+		}
+		fmt.Printf("解压 %s, 成员数量: %d, 保存到: %s\n", pcspath, len(members), saveDir)
+
+		err = idx.Extract(newArchiveRangeOpener(pcs, fd, dlinks, cfg), saveDir, members)
+		if err != nil {
+			fmt.Printf("%s: 解压失败, %s\n", pcspath, err)
+			continue
+		}
+		fmt.Printf("%s: 解压完成\n", pcspath)
+	}
+}
@@ -0,0 +1,295 @@
+package pcscommand
+
+import (
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"github.com/felixonmars/BaiduPCS-Go/requester/aria2"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// Aria2PollInterval 查询 aria2 任务状态的时间间隔
+	Aria2PollInterval = 5 * time.Second
+	// DefaultAria2MaxRefresh 默认的单个任务最大刷新链接次数
+	DefaultAria2MaxRefresh = 5
+	// Aria2MaxStatusErrors 连续查询任务状态失败的最大次数, 超过后视为下载失败
+	Aria2MaxStatusErrors = 5
+)
+
+var (
+	// aria2RefreshErrorCodes aria2 的错误码中, 可能由链接失效引起的错误, 需要刷新链接
+	aria2RefreshErrorCodes = map[string]bool{
+		"1":  true, // 未知错误
+		"2":  true, // 超时
+		"3":  true, // 资源不存在
+		"6":  true, // 网络错误
+		"19": true, // 域名解析失败
+		"22": true, // http 响应头错误, 如 403
+		"24": true, // http 认证失败
+		"29": true, // 服务器过载
+	}
+)
+
+type (
+	// Aria2Options 导出到 aria2 可选参数
+	Aria2Options struct {
+		OutputFile string // 输入文件的保存路径, 为 - 时输出到标准输出
+		RPCURL     string // aria2 JSON-RPC 地址
+		RPCSecret  string // aria2 rpc-secret
+		SaveTo     string // 保存的目录, 为空则使用默认的保存路径
+		MaxRefresh int    // 单个任务最大刷新链接次数
+	}
+
+	aria2Job struct {
+		pcspath      string
+		task         *aria2.Task
+		gid          string
+		refreshed    int
+		statusErrors int // 连续查询任务状态失败的次数
+	}
+)
+
+// newAria2Task 获取下载链接, 生成 aria2 下载任务
+func newAria2Task(pcs *baidupcs.BaiduPCS, fd *baidupcs.FileDirectory, savePath string) (*aria2.Task, error) {
+	info, pcsError := pcs.LocateDownload(fd.Path)
+	if pcsError != nil {
+		return nil, pcsError
+	}
+
+	urls := info.URLStrings(pcsconfig.Config.EnableHTTPS)
+	if len(urls) == 0 {
+		return nil, pcsdownload.ErrDlinkNotFound
+	}
+
+	task := &aria2.Task{
+		URIs: make([]string, 0, len(urls)),
+		Out:  filepath.Base(savePath),
+		Dir:  filepath.Dir(savePath),
+		Headers: []string{
+			"Cookie: BDUSS=" + GetActiveUser().BDUSS,
+		},
+		Options: map[string]string{
+			"user-agent": pcsconfig.Config.PanUA,
+			"continue":   "true",
+		},
+	}
+	for _, u := range urls {
+		pcsdownload.FixHTTPLinkURL(u)
+		task.URIs = append(task.URIs, u.String())
+	}
+
+	// 只有一个分片时, md5 才是准确的
+	if len(fd.BlockList) == 1 {
+		task.MD5 = fd.MD5
+	}
+	return task, nil
+}
+
+// RunAria2 导出网盘文件的下载链接到 aria2 的输入文件, 或提交到 aria2 JSON-RPC
+func RunAria2(paths []string, opt *Aria2Options) {
+	if opt == nil {
+		opt = &Aria2Options{}
+	}
+	if opt.MaxRefresh <= 0 {
+		opt.MaxRefresh = DefaultAria2MaxRefresh
+	}
+
+	// 输入文件输出到标准输出时, 提示信息输出到标准错误
+	var msgOut io.Writer = os.Stdout
+	if opt.OutputFile == "-" || (opt.OutputFile == "" && opt.RPCURL == "") {
+		opt.OutputFile = "-"
+		msgOut = os.Stderr
+	}
+
+	paths, err := matchPathByShellPattern(paths...)
+	if err != nil {
+		fmt.Fprintln(msgOut, err)
+		return
+	}
+
+	var (
+		pcs  = GetBaiduPCS()
+		jobs []*aria2Job
+	)
+	for _, p := range paths {
+		pcs.FilesDirectoriesRecurseList(p, baidupcs.DefaultOrderOptions, func(depth int, _ string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+			if pcsError != nil {
+				fmt.Fprintf(msgOut, "%s\n", pcsError)
+				return true
+			}
+			if fd.Isdir {
+				return true
+			}
+
+			// 保持目录结构
+			var savePath string
+			if opt.SaveTo != "" {
+				// 网盘路径使用 path 处理, 再转换为本地路径
+				rel := strings.TrimPrefix(strings.TrimPrefix(fd.Path, path.Dir(p)), "/")
+				savePath = filepath.Join(opt.SaveTo, filepath.FromSlash(rel))
+			} else {
+				savePath = GetActiveUser().GetSavePath(fd.Path)
+			}
+
+			task, err := newAria2Task(pcs, fd, savePath)
+			if err != nil {
+				fmt.Fprintf(msgOut, "%s, %s, 路径: %s\n", pcsdownload.StrDownloadGetDlinkFailed, err, fd.Path)
+				return true
+			}
+			jobs = append(jobs, &aria2Job{
+				pcspath: fd.Path,
+				task:    task,
+			})
+			return true
+		})
+	}
+
+	if len(jobs) == 0 {
+		fmt.Fprintf(msgOut, "没有可以下载的文件\n")
+		return
+	}
+
+	if opt.OutputFile != "" {
+		tasks := make([]*aria2.Task, 0, len(jobs))
+		for _, job := range jobs {
+			tasks = append(tasks, job.task)
+		}
+		err = writeAria2InputFile(opt.OutputFile, tasks)
+		if err != nil {
+			fmt.Fprintf(msgOut, "导出 aria2 输入文件失败, %s\n", err)
+			return
+		}
+		if opt.OutputFile != "-" {
+			fmt.Fprintf(msgOut, "已导出 %d 个文件到: %s, 使用 aria2c -i %s 下载, 链接有效期较短, 请尽快下载\n", len(jobs), opt.OutputFile, opt.OutputFile)
+		}
+	}
+
+	if opt.RPCURL != "" {
+		runAria2RPC(pcs, jobs, opt)
+	}
+}
+
+func writeAria2InputFile(outputFile string, tasks []*aria2.Task) error {
+	if outputFile == "-" {
+		return aria2.WriteInputFile(os.Stdout, tasks)
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // 包含 BDUSS, 只允许自己读写
+	if err != nil {
+		return err
+	}
+	err = aria2.WriteInputFile(f, tasks)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runAria2RPC 提交任务到 aria2, 并等待任务结束, 链接失效时刷新链接
+func runAria2RPC(pcs *baidupcs.BaiduPCS, jobs []*aria2Job, opt *Aria2Options) {
+	client := aria2.NewClient(opt.RPCURL, opt.RPCSecret)
+
+	pending := make([]*aria2Job, 0, len(jobs))
+	for i, job := range jobs {
+		gid, err := client.AddTask(job.task)
+		if err != nil {
+			fmt.Printf("[%d] 提交到 aria2 失败, %s, 路径: %s\n", i, err, job.pcspath)
+			continue
+		}
+		job.gid = gid
+		pending = append(pending, job)
+		fmt.Printf("[%d] 已提交到 aria2, gid: %s, 路径: %s\n", i, gid, job.pcspath)
+	}
+
+	var succeed, failed int
+	failed = len(jobs) - len(pending)
+	for len(pending) > 0 {
+		if retry.Sleep(Context(), Aria2PollInterval) != nil {
+			// 已取消, aria2 中的任务继续下载
+			fmt.Printf("\n已停止等待, 未结束的任务 %d 个仍在 aria2 中继续下载\n", len(pending))
+			break
+		}
+
+		next := pending[:0]
+		for _, job := range pending {
+			status, err := client.TellStatus(job.gid)
+			if err != nil {
+				// 可能是暂时的网络错误, 继续查询
+				job.statusErrors++
+				if job.statusErrors >= Aria2MaxStatusErrors {
+					fmt.Printf("[%s] 查询任务状态失败, %s, 路径: %s\n", job.gid, err, job.pcspath)
+					failed++
+					continue
+				}
+				fmt.Printf("[%s] 查询任务状态失败, %s, 重试 %d/%d, 路径: %s\n", job.gid, err, job.statusErrors, Aria2MaxStatusErrors, job.pcspath)
+				next = append(next, job)
+				continue
+			}
+			job.statusErrors = 0
+
+			switch status.Status {
+			case aria2.StatusComplete:
+				fmt.Printf("[%s] 下载完成, 数据总量: %s, 路径: %s\n", job.gid, converter.ConvertFileSize(status.Int64(status.TotalLength), 2), job.pcspath)
+				client.RemoveDownloadResult(job.gid)
+				succeed++
+			case aria2.StatusRemoved:
+				fmt.Printf("[%s] 任务已被移除, 路径: %s\n", job.gid, job.pcspath)
+				failed++
+			case aria2.StatusError:
+				if !aria2RefreshErrorCodes[status.ErrorCode] || job.refreshed >= opt.MaxRefresh {
+					fmt.Printf("[%s] 下载失败, %s: %s, 路径: %s\n", job.gid, status.ErrorCode, status.ErrorMessage, job.pcspath)
+					failed++
+					continue
+				}
+				if !refreshAria2Job(pcs, client, job) {
+					failed++
+					continue
+				}
+				next = append(next, job)
+			default:
+				next = append(next, job)
+			}
+		}
+		pending = next
+	}
+
+	fmt.Printf("\naria2 任务结束, 成功: %d, 失败: %d\n", succeed, failed)
+}
+
+// refreshAria2Job 重新获取下载链接, 重新提交任务, aria2 会继续下载已下载的部分
+func refreshAria2Job(pcs *baidupcs.BaiduPCS, client *aria2.Client, job *aria2Job) bool {
+	job.refreshed++
+	fd, pcsError := pcs.FilesDirectoriesMeta(job.pcspath)
+	if pcsError != nil {
+		fmt.Printf("[%s] 刷新下载链接失败, %s, 路径: %s\n", job.gid, pcsError, job.pcspath)
+		return false
+	}
+
+	task, err := newAria2Task(pcs, fd, filepath.Join(job.task.Dir, job.task.Out))
+	if err != nil {
+		fmt.Printf("[%s] 刷新下载链接失败, %s, 路径: %s\n", job.gid, err, job.pcspath)
+		return false
+	}
+
+	client.RemoveDownloadResult(job.gid)
+	gid, err := client.AddTask(task)
+	if err != nil {
+		fmt.Printf("[%s] 重新提交到 aria2 失败, %s, 路径: %s\n", job.gid, err, job.pcspath)
+		return false
+	}
+
+	fmt.Printf("[%s] 已刷新下载链接, 新的 gid: %s, 路径: %s\n", job.gid, gid, job.pcspath)
+	job.task, job.gid = task, gid
+	return true
+}
@@ -0,0 +1,85 @@
+package pcscommand
+
+import (
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
+	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
+	"os"
+)
+
+type (
+	// CatOptions 输出网盘文件内容可选参数
+	CatOptions struct {
+		Parallel   int // 下载最大并发量
+		BufferSize int // 重排缓冲区大小
+	}
+)
+
+// streamDownloadConfig 流式下载的配置
+func streamDownloadConfig(parallel int) *downloader.Config {
+	if parallel < 1 {
+		parallel = pcsconfig.Config.MaxParallel
+	}
+	return &downloader.Config{
+		Mode:        transfer.RangeGenMode_BlockSize,
+		MaxParallel: parallel,
+		CacheSize:   pcsconfig.Config.CacheSize,
+		BlockSize:   baidupcs.MaxDownloadRangeSize,
+		MaxRate:     pcsconfig.Config.MaxDownloadRate,
+		TryHTTP:     !pcsconfig.Config.EnableHTTPS,
+	}
+}
+
+// RunCat 多线程下载网盘文件, 按顺序输出到标准输出, 提示信息输出到标准错误
+func RunCat(paths []string, options *CatOptions) {
+	if options == nil {
+		options = &CatOptions{}
+	}
+
+	paths, err := matchPathByShellPattern(paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	var (
+		pcs = GetBaiduPCS()
+		cfg = streamDownloadConfig(options.Parallel)
+	)
+	for _, pcspath := range paths {
+		fd, pcsError := pcs.FilesDirectoriesMeta(pcspath)
+		if pcsError != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", pcspath, pcsError)
+			continue
+		}
+		if fd.Isdir {
+			fmt.Fprintf(os.Stderr, "%s: 是一个目录\n", pcspath)
+			continue
+		}
+
+		dlinks, err := pcsdownload.GetLocateDownloadLinkStrings(pcs, pcspath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s, %s\n", pcspath, pcsdownload.StrDownloadGetDlinkFailed, err)
+			continue
+		}
+
+		err = pcsdownload.StreamDownload(pcs.Context(), os.Stdout, &pcsdownload.StreamOptions{
+			DownloadURL: dlinks[0],
+			Mirrors:     dlinks[1:],
+			RefreshFunc: func() ([]string, error) {
+				return pcsdownload.GetLocateDownloadLinkStrings(pcs, pcspath)
+			},
+			Size:       fd.Size,
+			End:        -1,
+			BufferSize: options.BufferSize,
+			Config:     cfg,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s, %s\n", pcspath, pcsdownload.StrDownloadFailed, err)
+			return
+		}
+	}
+}
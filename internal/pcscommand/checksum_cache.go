@@ -0,0 +1,29 @@
+package pcscommand
+
+import (
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"time"
+)
+
+// RunChecksumCachePrune 清除本地文件摘要值缓存中, 文件已不存在或已改变的缓存项,
+// olderThan 不为 0 时, 同时清除早于 olderThan 之前计算的缓存项
+func RunChecksumCachePrune(olderThan time.Duration) {
+	cache := pcsfunctions.ChecksumCache()
+	if cache == nil {
+		fmt.Printf("打开缓存文件失败: %s\n", pcsfunctions.ChecksumCacheFilePath())
+		return
+	}
+
+	var before time.Time
+	if olderThan > 0 {
+		before = time.Now().Add(-olderThan)
+	}
+
+	pruned, err := cache.Prune(before)
+	if err != nil {
+		fmt.Printf("清理缓存失败, %s\n", err)
+		return
+	}
+	fmt.Printf("已清理缓存项: %d, 剩余缓存项: %d\n", pruned, cache.Len())
+}
@@ -0,0 +1,328 @@
+package pcscommand
+
+import (
+	"errors"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil"
+	"github.com/json-iterator/go"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CompleteCacheName 补全网盘路径的缓存文件名
+	CompleteCacheName = "pcs_complete_cache.json"
+	// CompleteCacheTTL 补全网盘路径的缓存有效期
+	CompleteCacheTTL = 30 * time.Second
+)
+
+type (
+	completeEntry struct {
+		Name  string `json:"name"`
+		IsDir bool   `json:"isdir"`
+	}
+
+	completeCacheItem struct {
+		Time    int64            `json:"time"`
+		Entries []*completeEntry `json:"entries"`
+	}
+
+	// completeCache 补全网盘路径的缓存, 在多次调用程序之间共享, key 为 uid:目录
+	completeCache map[string]*completeCacheItem
+)
+
+var (
+	// ErrUnsupportedShell 不支持的 shell
+	ErrUnsupportedShell = errors.New("unsupported shell, available: bash, zsh, fish")
+)
+
+const bashCompletionScript = `# %[1]s bash completion
+# 加载方法: source <(%[1]s completion bash)
+
+_%[2]s_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($(%[1]s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    # 由 bash 转义空格等特殊字符
+    compopt -o filenames
+    if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == */ ]]; then
+        compopt -o nospace
+    fi
+}
+
+complete -o default -F _%[2]s_complete %[1]s
+`
+
+const zshCompletionScript = `#compdef %[1]s
+# %[1]s zsh completion
+# 加载方法: source <(%[1]s completion zsh)
+
+_%[2]s_complete() {
+    local -a candidates dirs others
+    local candidate
+    candidates=("${(@f)$(%[1]s __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    for candidate in "${candidates[@]}"; do
+        [[ -z $candidate ]] && continue
+        if [[ $candidate == */ ]]; then
+            dirs+=("$candidate")
+        else
+            others+=("$candidate")
+        fi
+    done
+    if (( ${#dirs} + ${#others} == 0 )); then
+        _files
+        return
+    fi
+    (( ${#dirs} )) && compadd -S '' -- "${dirs[@]}"
+    (( ${#others} )) && compadd -- "${others[@]}"
+}
+
+compdef _%[2]s_complete %[1]s
+`
+
+const fishCompletionScript = `# %[1]s fish completion
+# 加载方法: %[1]s completion fish | source
+
+function __%[2]s_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    %[1]s __complete $tokens[2..-1] 2>/dev/null
+end
+
+complete -c %[1]s -a '(__%[2]s_complete)'
+`
+
+// CompletionScript 生成 shell 补全脚本, prog 为程序名称
+func CompletionScript(shell, prog string) (string, error) {
+	funcName := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, prog)
+
+	switch strings.ToLower(shell) {
+	case "bash":
+		return fmt.Sprintf(bashCompletionScript, prog, funcName), nil
+	case "zsh":
+		return fmt.Sprintf(zshCompletionScript, prog, funcName), nil
+	case "fish":
+		return fmt.Sprintf(fishCompletionScript, prog, funcName), nil
+	}
+	return "", ErrUnsupportedShell
+}
+
+// RunCompletion 输出 shell 补全脚本
+func RunCompletion(shell, prog string) {
+	script, err := CompletionScript(shell, prog)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return
+	}
+	fmt.Print(script)
+}
+
+// UnescapeShellWord 去除 shell 中正在输入的参数的引号和转义
+func UnescapeShellWord(word string) string {
+	var (
+		builder strings.Builder
+		quote   rune
+		escaped bool
+	)
+	for _, r := range word {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			continue
+		case quote == 0 && (r == '\'' || r == '"'):
+			quote = r
+			continue
+		case r == quote:
+			quote = 0
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// CompleteArgs 补全命令行参数, words 为程序名称之后的参数, 最后一个为正在输入的参数,
+// fileCommands 为支持补全网盘路径的命令
+func CompleteArgs(app *cli.App, words, fileCommands []string) (candidates []string) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+
+	var (
+		current  = UnescapeShellWord(words[len(words)-1])
+		commands = app.Commands
+		flags    = app.Flags
+		cmd      *cli.Command
+	)
+
+	// 查找命令和子命令
+	for _, word := range words[:len(words)-1] {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		if cmd != nil && len(cmd.Subcommands) == 0 {
+			break
+		}
+
+		var found *cli.Command
+		for k := range commands {
+			if commands[k].HasName(word) {
+				found = &commands[k]
+				break
+			}
+		}
+		if found == nil {
+			break
+		}
+		cmd, commands, flags = found, found.Subcommands, found.Flags
+	}
+
+	switch {
+	case strings.HasPrefix(current, "-"):
+		for _, flag := range flags {
+			for _, name := range strings.Split(flag.GetName(), ",") {
+				name = "-" + strings.TrimSpace(name)
+				if strings.HasPrefix(name, current) {
+					candidates = append(candidates, name)
+				}
+			}
+		}
+	case cmd == nil || len(cmd.Subcommands) > 0:
+		for _, command := range commands {
+			if command.Hidden {
+				continue
+			}
+			for _, name := range command.Names() {
+				if strings.HasPrefix(name, current) {
+					candidates = append(candidates, name)
+				}
+			}
+		}
+		// 有子命令, 也接受路径参数的命令, 如 upload
+		if cmd != nil && pcsutil.ContainsString(fileCommands, cmd.Name) {
+			candidates = append(candidates, CompleteRemotePath(current)...)
+		}
+	case pcsutil.ContainsString(fileCommands, cmd.Name):
+		candidates = CompleteRemotePath(current)
+	}
+	return
+}
+
+// CompleteRemotePath 补全网盘路径, 目录以 / 结尾
+func CompleteRemotePath(word string) (candidates []string) {
+	activeUser := GetActiveUser()
+	if activeUser.BDUSS == "" {
+		return nil
+	}
+
+	var dirPart string
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		dirPart = word[:i+1]
+	}
+	var (
+		base = word[len(dirPart):]
+		dir  = activeUser.PathJoin(dirPart)
+	)
+	if dirPart == "" {
+		dir = activeUser.Workdir
+	}
+
+	for _, e := range listRemoteDirWithCache(activeUser, dir) {
+		if !strings.HasPrefix(e.Name, base) {
+			continue
+		}
+		candidate := dirPart + e.Name
+		if e.IsDir {
+			candidate += "/"
+		}
+		candidates = append(candidates, candidate)
+	}
+	return
+}
+
+// listRemoteDirWithCache 获取网盘目录, 优先读取补全缓存
+func listRemoteDirWithCache(activeUser *pcsconfig.Baidu, dir string) []*completeEntry {
+	var (
+		cacheFilePath = filepath.Join(pcsconfig.GetConfigDir(), CompleteCacheName)
+		cache         = loadCompleteCache(cacheFilePath)
+		key           = strconv.FormatUint(activeUser.UID, 10) + ":" + path.Clean(dir)
+	)
+	if item, ok := cache[key]; ok {
+		return item.Entries
+	}
+
+	fdl, pcsError := GetBaiduPCS().CacheFilesDirectoriesList(dir, baidupcs.DefaultOrderOptions)
+	if pcsError != nil {
+		pcsCommandVerbose.Debug("complete list dir failed", "dir", dir, "err", pcsError)
+		return nil
+	}
+
+	item := &completeCacheItem{
+		Time:    time.Now().Unix(),
+		Entries: make([]*completeEntry, 0, len(fdl)),
+	}
+	for _, fd := range fdl {
+		item.Entries = append(item.Entries, &completeEntry{
+			Name:  fd.Filename,
+			IsDir: fd.Isdir,
+		})
+	}
+	cache[key] = item
+
+	err := cache.save(cacheFilePath)
+	if err != nil {
+		pcsCommandVerbose.Debug("save complete cache failed", "err", err)
+	}
+	return item.Entries
+}
+
+// loadCompleteCache 读取补全缓存, 忽略已过期的缓存
+func loadCompleteCache(cacheFilePath string) completeCache {
+	cache := completeCache{}
+	data, err := ioutil.ReadFile(cacheFilePath)
+	if err != nil {
+		return cache
+	}
+
+	err = jsoniter.Unmarshal(data, &cache)
+	if err != nil {
+		return completeCache{}
+	}
+
+	expired := time.Now().Add(-CompleteCacheTTL).Unix()
+	for key, item := range cache {
+		if item == nil || item.Time < expired {
+			delete(cache, key)
+		}
+	}
+	return cache
+}
+
+func (cache completeCache) save(cacheFilePath string) error {
+	data, err := jsoniter.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件, 防止并发补全时读到不完整的数据
+	tmpPath := cacheFilePath + ".tmp" + strconv.Itoa(os.Getpid())
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, cacheFilePath)
+}
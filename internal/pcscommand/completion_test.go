@@ -0,0 +1,128 @@
+package pcscommand
+
+import (
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestUnescapeShellWord(t *testing.T) {
+	for _, c := range []struct {
+		word, want string
+	}{
+		{"abc", "abc"},
+		{`a\ b`, "a b"},
+		{`"a b`, "a b"},
+		{`"a b"c`, "a bc"},
+		{`'a\ b'`, `a\ b`},
+		{`"a\"b"`, `a"b`},
+		{`'a"b'`, `a"b`},
+		{`a\\b`, `a\b`},
+		{`a\`, "a"},
+		{"", ""},
+	} {
+		if got := UnescapeShellWord(c.word); got != c.want {
+			t.Fatalf("%s: got %s, want %s", c.word, got, c.want)
+		}
+	}
+}
+
+func TestCompleteArgs(t *testing.T) {
+	app := cli.NewApp()
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{Name: "verbose"},
+	}
+	app.Commands = []cli.Command{
+		{Name: "ls", Aliases: []string{"l", "ll"}},
+		{Name: "login"},
+		{Name: "__complete", Hidden: true},
+		{
+			Name: "config",
+			Subcommands: []cli.Command{
+				{Name: "set", Flags: []cli.Flag{
+					cli.StringFlag{Name: "proxy"},
+					cli.IntFlag{Name: "max_parallel, p"},
+				}},
+				{Name: "reset"},
+			},
+		},
+	}
+
+	for _, c := range []struct {
+		words []string
+		want  []string
+	}{
+		{nil, []string{"config", "l", "ll", "login", "ls"}},
+		{[]string{"l"}, []string{"l", "ll", "login", "ls"}},
+		{[]string{"lo"}, []string{"login"}},
+		{[]string{"_"}, nil},
+		{[]string{"--verbose", "co"}, []string{"config"}},
+		{[]string{"-"}, []string{"-verbose"}},
+		{[]string{"config", ""}, []string{"reset", "set"}},
+		{[]string{"config", "set", "-"}, []string{"-max_parallel", "-p", "-proxy"}},
+		{[]string{"config", "set", "-pr"}, []string{"-proxy"}},
+		{[]string{"config", "set", ""}, nil},
+		{[]string{"unknown", ""}, []string{"config", "l", "ll", "login", "ls"}},
+		// 未登录时, 不补全网盘路径
+		{[]string{"ls", ""}, nil},
+	} {
+		got := CompleteArgs(app, c.words, []string{"ls"})
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: got %q, want %q", c.words, got, c.want)
+		}
+	}
+}
+
+func TestCompleteCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "complete_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cacheFilePath := filepath.Join(dir, CompleteCacheName)
+
+	// 文件不存在, 或格式错误时, 返回空的缓存
+	if cache := loadCompleteCache(cacheFilePath); len(cache) != 0 {
+		t.Fatalf("cache: %d", len(cache))
+	}
+	err = ioutil.WriteFile(cacheFilePath, []byte("{"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache := loadCompleteCache(cacheFilePath); len(cache) != 0 {
+		t.Fatalf("cache: %d", len(cache))
+	}
+
+	now := time.Now()
+	cache := completeCache{
+		"1:/fresh": &completeCacheItem{
+			Time: now.Unix(),
+			Entries: []*completeEntry{
+				{Name: "a", IsDir: true},
+				{Name: "b.txt"},
+			},
+		},
+		"1:/expired": &completeCacheItem{
+			Time: now.Add(-CompleteCacheTTL - time.Second).Unix(),
+		},
+	}
+	err = cache.save(cacheFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 忽略已过期的缓存
+	loaded := loadCompleteCache(cacheFilePath)
+	if len(loaded) != 1 || loaded["1:/expired"] != nil {
+		t.Fatalf("loaded: %v", loaded)
+	}
+	if !reflect.DeepEqual(loaded["1:/fresh"], cache["1:/fresh"]) {
+		t.Fatalf("fresh: %v", loaded["1:/fresh"])
+	}
+}
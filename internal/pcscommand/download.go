@@ -5,6 +5,7 @@ import (
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
 	"github.com/felixonmars/BaiduPCS-Go/pcstable"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
@@ -29,6 +30,10 @@ type (
 		Load                 int
 		MaxRetry             int
 		NoCheck              bool
+		Repair               bool                           // 校验失败时, 只重新下载损坏的部分
+		Extract              bool                           // 根据索引解压打包上传的压缩包
+		Members              []string                       // 解压时, 只下载并解压指定的成员
+		Statistic            *pcsdownload.DownloadStatistic // 下载统计, 为空则新建
 	}
 
 	// LocateDownloadOption 获取下载链接可选参数
@@ -50,6 +55,11 @@ func RunDownload(paths []string, options *DownloadOptions) {
 		options = &DownloadOptions{}
 	}
 
+	if options.Extract {
+		runDownloadExtract(paths, options)
+		return
+	}
+
 	if options.Load <= 0 {
 		options.Load = pcsconfig.Config.MaxDownloadLoad
 	}
@@ -72,6 +82,11 @@ func RunDownload(paths []string, options *DownloadOptions) {
 		InstanceStateStorageFormat: downloader.InstanceStateStorageFormatProto3,
 		IsTest:                     options.IsTest,
 		TryHTTP:                    !pcsconfig.Config.EnableHTTPS,
+		MinParallel:                pcsconfig.Config.MinParallel,
+		AdaptiveParallel:           pcsconfig.Config.AdaptiveParallel,
+		RangeChecksum:              options.Repair && !options.NoCheck,
+		DirectIO:                   pcsconfig.Config.DirectIO,
+		WriteBlockSize:             pcsconfig.Config.WriteBlockSize,
 	}
 
 	// 设置下载最大并发量
@@ -130,8 +145,12 @@ func RunDownload(paths []string, options *DownloadOptions) {
 		executor = taskframework.TaskExecutor{
 			IsFailedDeque: true, // 统计失败的列表
 		}
-		statistic = &pcsdownload.DownloadStatistic{}
+		statistic = options.Statistic
 	)
+	if statistic == nil {
+		statistic = &pcsdownload.DownloadStatistic{}
+	}
+	executor.SetRetryPolicy(pcsfunctions.RetryPolicy)
 	// 处理队列
 	for k := range paths {
 		newCfg := *cfg
@@ -165,7 +184,10 @@ func RunDownload(paths []string, options *DownloadOptions) {
 	statistic.StartTimer()
 
 	// 开始执行
-	executor.Execute()
+	executor.ExecuteContext(Context())
+	if Context().Err() != nil {
+		fmt.Printf("\n下载已取消, 已保存断点信息, 再次执行相同的命令可继续下载\n")
+	}
 
 	fmt.Printf("\n下载结束, 时间: %s, 数据总量: %s\n", statistic.Elapsed()/1e6*1e6, converter.ConvertFileSize(statistic.TotalSize()))
 
@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
-	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsrapid"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/pcstime"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
 	"os"
 	"path"
 	"strings"
-	"time"
 )
 
 type (
@@ -28,6 +29,7 @@ type (
 		SavePath  string // 输出路径
 		MaxRetry  int
 		Recursive bool
+		Format    string // 导出的格式, 为空则为 command
 	}
 )
 
@@ -48,12 +50,12 @@ func (task *etask) handleExportTaskError(l *list.List, failedList *list.List) {
 		return
 	}
 
-	// 未达到失败重试最大次数, 将任务推送到队列末尾
-	if task.retry < task.MaxRetry {
+	// 可以重试且未达到失败重试最大次数, 将任务推送到队列末尾
+	if task.retry < task.MaxRetry && pcsfunctions.IsRetryable(task.err) {
 		task.retry++
 		fmt.Printf("[%d] - [%s] 导出错误, %s, 重试 %d/%d\n", task.ID, task.path, task.err, task.retry, task.MaxRetry)
 		l.PushBack(task)
-		time.Sleep(3 * time.Duration(task.retry) * time.Second)
+		retry.Sleep(Context(), pcsfunctions.RetryWaitErr(task.retry, task.err))
 	} else {
 		fmt.Printf("[%d] - [%s] 导出错误, %s\n", task.ID, task.path, task.err)
 		failedList.PushBack(task)
@@ -72,23 +74,44 @@ func GetExportFilename() string {
 	return "BaiduPCS-Go_export_" + pcstime.BeijingTimeOption("") + ".txt"
 }
 
+// getExportFilenameByFormat 获取导出路径, json 格式使用 .json 扩展名
+func getExportFilenameByFormat(format string) string {
+	if format == pcsrapid.FormatPanDL {
+		return strings.TrimSuffix(GetExportFilename(), ".txt") + ".json"
+	}
+	return GetExportFilename()
+}
+
 // RunExport 执行导出文件和目录
 func RunExport(pcspaths []string, opt *ExportOptions) {
 	if opt == nil {
 		opt = &ExportOptions{}
 	}
 
+	if opt.Format == "" {
+		opt.Format = pcsrapid.FormatCommand
+	}
+	err := pcsrapid.CheckFormat(opt.Format)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	if opt.SavePath == "" {
-		opt.SavePath = GetExportFilename()
+		opt.SavePath = getExportFilenameByFormat(opt.Format)
 	}
 
-	pcspaths, err := matchPathByShellPattern(pcspaths...)
+	pcspaths, err = matchPathByShellPattern(pcspaths...)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
-	saveFile, err := os.OpenFile(opt.SavePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	// json 格式无法追加
+	flag := os.O_CREATE | os.O_RDWR | os.O_APPEND
+	if opt.Format == pcsrapid.FormatPanDL {
+		flag = os.O_CREATE | os.O_RDWR | os.O_TRUNC
+	}
+	saveFile, err := os.OpenFile(opt.SavePath, flag, 0644)
 	if err != nil { // 不可写
 		fmt.Printf("%s\n", err)
 		return
@@ -96,6 +119,14 @@ func RunExport(pcspaths []string, opt *ExportOptions) {
 	defer saveFile.Close()
 	fmt.Printf("导出的信息将保存在: %s\n", opt.SavePath)
 
+	ew, _ := pcsrapid.NewWriter(saveFile, opt.Format)
+	defer func() {
+		closeErr := ew.Close()
+		if closeErr != nil {
+			fmt.Printf("写入文件失败: %s\n", closeErr)
+		}
+	}()
+
 	var (
 		au         = GetActiveUser()
 		pcs        = GetBaiduPCS()
@@ -128,6 +159,10 @@ func RunExport(pcspaths []string, opt *ExportOptions) {
 		if e == nil { // 结束
 			break
 		}
+		if pcs.Context().Err() != nil {
+			fmt.Printf("导出已取消, 未导出的路径数量: %d\n", l.Len())
+			break
+		}
 
 		l.Remove(e) // 载入任务后, 移除队列
 
@@ -158,7 +193,10 @@ func RunExport(pcspaths []string, opt *ExportOptions) {
 			}
 
 			if len(fds) == 0 {
-				_, writeErr = saveFile.Write(converter.ToBytes(fmt.Sprintf("BaiduPCS-Go mkdir \"%s\"\n", changeRootPath(task.rootPath, task.path, opt.RootPath))))
+				writeErr = ew.Write(&pcsrapid.Entry{
+					Path: changeRootPath(task.rootPath, task.path, opt.RootPath),
+					Dir:  true,
+				})
 				if writeErr != nil {
 					fmt.Printf("写入文件失败: %s\n", writeErr)
 					return // 直接返回
@@ -191,7 +229,13 @@ func RunExport(pcspaths []string, opt *ExportOptions) {
 			continue
 		}
 
-		_, writeErr = saveFile.Write(converter.ToBytes(fmt.Sprintf("BaiduPCS-Go rapidupload -length=%d -md5=%s -slicemd5=%s -crc32=%s \"%s\"\n", rinfo.ContentLength, rinfo.ContentMD5, rinfo.SliceMD5, rinfo.ContentCrc32, changeRootPath(task.rootPath, task.path, opt.RootPath))))
+		writeErr = ew.Write(&pcsrapid.Entry{
+			Path:     changeRootPath(task.rootPath, task.path, opt.RootPath),
+			Length:   rinfo.ContentLength,
+			MD5:      rinfo.ContentMD5,
+			SliceMD5: rinfo.SliceMD5,
+			CRC32:    rinfo.ContentCrc32,
+		})
 		if writeErr != nil {
 			fmt.Printf("写入文件失败: %s\n", writeErr)
 			return // 直接返回
@@ -0,0 +1,198 @@
+package pcscommand
+
+import (
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsrapid"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/taskframework"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// ImportResultNotFound 服务器上不存在文件的内容, 无法秒传
+	ImportResultNotFound = 1 + iota
+	// ImportResultFailed 其他错误
+	ImportResultFailed
+
+	// RemoteErrCodeRapidUploadNotFound 秒传时, 服务器上不存在文件的内容
+	RemoteErrCodeRapidUploadNotFound = 31079
+)
+
+type (
+	// ImportOptions 导入可选项
+	ImportOptions struct {
+		RootPath string // 保存的根目录, 为空则为当前工作目录
+		Parallel int
+		MaxRetry int
+	}
+
+	// importTaskUnit 秒传一条导入的信息
+	importTaskUnit struct {
+		pcs        *baidupcs.BaiduPCS
+		entry      *pcsrapid.Entry
+		targetPath string
+		taskInfo   *taskframework.TaskInfo
+		result     *taskframework.TaskUnitRunResult // 失败的结果
+	}
+)
+
+func (itu *importTaskUnit) SetTaskInfo(taskInfo *taskframework.TaskInfo) {
+	itu.taskInfo = taskInfo
+}
+
+func (itu *importTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
+	result = &taskframework.TaskUnitRunResult{}
+	if itu.entry.Dir {
+		fd, pcsError := itu.pcs.FilesDirectoriesMeta(itu.targetPath)
+		if pcsError == nil && fd.Isdir {
+			result.Succeed = true
+			return
+		}
+		pcsError = itu.pcs.Mkdir(itu.targetPath)
+		if pcsError != nil {
+			result.ResultCode = ImportResultFailed
+			result.ResultMessage = "创建目录失败"
+			result.Err = pcsError
+			result.NeedRetry = pcsError.GetErrType() != pcserror.ErrTypeRemoteError
+			return
+		}
+		result.Succeed = true
+		return
+	}
+
+	pcsError := itu.pcs.RapidUpload(itu.targetPath, baidupcs.OndupOverwrite, itu.entry.MD5, itu.entry.SliceMD5, itu.entry.CRC32, itu.entry.Length)
+	if pcsError != nil {
+		result.Err = pcsError
+		if pcsError.GetRemoteErrCode() == RemoteErrCodeRapidUploadNotFound {
+			// 重试也不会成功
+			result.ResultCode = ImportResultNotFound
+			result.ResultMessage = "服务器上不存在该文件的内容"
+			return
+		}
+		result.ResultCode = ImportResultFailed
+		result.ResultMessage = "秒传失败"
+		result.NeedRetry = pcsError.GetErrType() != pcserror.ErrTypeRemoteError
+		return
+	}
+	result.Succeed = true
+	return
+}
+
+func (itu *importTaskUnit) OnRetry(lastRunResult *taskframework.TaskUnitRunResult) {
+	fmt.Printf("[%s] - [%s] %s, %s, 重试 %d/%d\n", itu.taskInfo.Id(), itu.targetPath, lastRunResult.ResultMessage, lastRunResult.Err, itu.taskInfo.Retry(), itu.taskInfo.MaxRetry())
+}
+
+func (itu *importTaskUnit) OnSuccess(lastRunResult *taskframework.TaskUnitRunResult) {
+	fmt.Printf("[%s] - [%s] 导入成功\n", itu.taskInfo.Id(), itu.targetPath)
+}
+
+func (itu *importTaskUnit) OnFailed(lastRunResult *taskframework.TaskUnitRunResult) {
+	itu.result = lastRunResult
+	fmt.Printf("[%s] - [%s] %s, %s\n", itu.taskInfo.Id(), itu.targetPath, lastRunResult.ResultMessage, lastRunResult.Err)
+}
+
+func (itu *importTaskUnit) OnComplete(lastRunResult *taskframework.TaskUnitRunResult) {
+}
+
+func (itu *importTaskUnit) RetryWait() time.Duration {
+	return pcsfunctions.RetryWait(itu.taskInfo.Retry())
+}
+
+// importTargetPath 导入的网盘路径, 保持目录结构
+func importTargetPath(rootPath, entryPath string) string {
+	entryPath = strings.Replace(entryPath, "\\", "/", -1)
+	if rootPath == "" {
+		return GetActiveUser().PathJoin(entryPath)
+	}
+	// 不超出根目录
+	return path.Join(rootPath, path.Clean("/"+entryPath))
+}
+
+// RunImport 导入秒传信息, 支持 export 导出的命令, bdpan:// 链接, md5#slicemd5#size#name 链接和 PanDL json,
+// 在网盘保持原有的目录结构
+func RunImport(filenames []string, opt *ImportOptions) {
+	if opt == nil {
+		opt = &ImportOptions{}
+	}
+	if opt.RootPath != "" {
+		opt.RootPath = GetActiveUser().PathJoin(opt.RootPath)
+	}
+
+	var (
+		pcs      = GetBaiduPCS()
+		executor = &taskframework.TaskExecutor{
+			IsFailedDeque: true,
+		}
+		total int
+	)
+	executor.SetParallel(opt.Parallel)
+	executor.SetRetryPolicy(pcsfunctions.RetryPolicy)
+
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		entries, improper, err := pcsrapid.Parse(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("%s: 解析失败, %s\n", filename, err)
+			continue
+		}
+		for _, line := range improper {
+			fmt.Printf("%s: 无法识别: %s\n", filename, line)
+		}
+
+		for _, entry := range entries {
+			executor.Append(&importTaskUnit{
+				pcs:        pcs,
+				entry:      entry,
+				targetPath: importTargetPath(opt.RootPath, entry.Path),
+			}, opt.MaxRetry)
+			total++
+		}
+	}
+	if total == 0 {
+		fmt.Printf("没有要导入的秒传信息\n")
+		return
+	}
+
+	fmt.Printf("导入的秒传信息数量: %d\n", total)
+	executor.ExecuteContext(Context())
+
+	var notFound, failed []string
+	for {
+		e := executor.FailedDeque().Shift()
+		if e == nil {
+			break
+		}
+		itu := e.(*taskframework.TaskInfoItem).Unit.(*importTaskUnit)
+		if itu.result != nil && itu.result.ResultCode == ImportResultNotFound {
+			notFound = append(notFound, itu.targetPath)
+			continue
+		}
+		failed = append(failed, itu.targetPath)
+	}
+
+	fmt.Printf("\n导入完成, 成功: %d, 失败: %d\n", total-len(notFound)-len(failed), len(notFound)+len(failed))
+	if len(notFound) > 0 {
+		fmt.Printf("\n以下文件导入失败, 服务器上不存在该文件的内容, 无法秒传: \n")
+		fmt.Printf("%s\n", strings.Repeat("-", 100))
+		for _, p := range notFound {
+			fmt.Println(p)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Printf("\n以下文件或目录导入失败: \n")
+		fmt.Printf("%s\n", strings.Repeat("-", 100))
+		for _, p := range failed {
+			fmt.Println(p)
+		}
+	}
+}
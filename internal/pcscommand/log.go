@@ -0,0 +1,177 @@
+package pcscommand
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type (
+	// LogOptions 查看日志可选项
+	LogOptions struct {
+		Lines   int    // 输出最后的行数, 0 为全部
+		Follow  bool   // 持续输出新的日志
+		Level   string // 最低日志等级
+		Module  string // 模块名称, 多个用逗号隔开
+		Grep    string // 包含的关键字
+		Backups bool   // 包含旧日志文件
+	}
+
+	logFilter struct {
+		level   pcslog.Level
+		modules []string
+		grep    string
+	}
+)
+
+func newLogFilter(opt *LogOptions) (lf *logFilter, err error) {
+	lf = &logFilter{
+		level: pcslog.LevelDebug,
+		grep:  opt.Grep,
+	}
+	if opt.Level != "" {
+		lf.level, err = pcslog.ParseLevel(opt.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, module := range strings.Split(opt.Module, ",") {
+		module = strings.TrimSpace(module)
+		if module != "" {
+			lf.modules = append(lf.modules, module)
+		}
+	}
+	return lf, nil
+}
+
+func (lf *logFilter) match(line string) bool {
+	if lf.grep != "" && !strings.Contains(line, lf.grep) {
+		return false
+	}
+	if lf.level == pcslog.LevelDebug && len(lf.modules) == 0 {
+		return true
+	}
+
+	entry, ok := pcslog.ParseLine(line)
+	if !ok {
+		return false
+	}
+	if entry.Level < lf.level {
+		return false
+	}
+	if len(lf.modules) == 0 {
+		return true
+	}
+	for _, module := range lf.modules {
+		if strings.EqualFold(module, entry.Module) {
+			return true
+		}
+	}
+	return false
+}
+
+// readLogLines 读取日志文件中匹配的行, 返回读取结束的位置
+func readLogLines(filePath string, offset int64, lf *logFilter, fn func(line string)) (end int64, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return offset, err
+	}
+
+	end = offset
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			// 未写完的行, 下次再读
+			return end, nil
+		}
+		end += int64(len(line))
+		line = strings.TrimRight(line, "\r\n")
+		if lf.match(line) {
+			fn(line)
+		}
+	}
+}
+
+// RunLog 查看日志
+func RunLog(opt *LogOptions) {
+	if opt == nil {
+		opt = &LogOptions{}
+	}
+
+	lf, err := newLogFilter(opt)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return
+	}
+
+	logPath := pcsconfig.Config.LogFilePath()
+	filePaths := []string{logPath}
+	if opt.Backups {
+		for n := pcsconfig.Config.LogMaxBackups; n >= 1; n-- {
+			filePaths = append([]string{pcslog.BackupPath(logPath, n)}, filePaths...)
+		}
+	}
+
+	var (
+		lines  []string
+		offset int64
+	)
+	for _, filePath := range filePaths {
+		end, err := readLogLines(filePath, 0, lf, func(line string) {
+			lines = append(lines, line)
+			if opt.Lines > 0 && len(lines) > opt.Lines {
+				lines = lines[1:]
+			}
+		})
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			fmt.Printf("读取日志文件错误, %s\n", err)
+			return
+		}
+		if filePath == logPath {
+			offset = end
+		}
+	}
+
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+
+	if !opt.Follow {
+		if len(lines) == 0 {
+			fmt.Printf("没有匹配的日志, 日志文件: %s\n", logPath)
+		}
+		return
+	}
+
+	for {
+		time.Sleep(500 * time.Millisecond)
+
+		info, err := os.Stat(logPath)
+		if err != nil {
+			continue
+		}
+		if info.Size() < offset {
+			// 日志已轮转, 从头读取
+			offset = 0
+		}
+
+		offset, _ = readLogLines(logPath, offset, lf, func(line string) {
+			fmt.Println(line)
+		})
+	}
+}
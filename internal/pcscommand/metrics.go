@@ -0,0 +1,61 @@
+package pcscommand
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/metrics"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// MetricsQuotaInterval 刷新网盘配额指标的时间间隔
+	MetricsQuotaInterval = 10 * time.Minute
+)
+
+var (
+	metricsServerOnce sync.Once
+)
+
+// StartMetricsServer 在后台启动监控指标的 http 服务, 路径为 /metrics,
+// 只启动一次, 重复调用无效, 监听失败只记录警告, 不影响程序运行
+func StartMetricsServer(addr string) {
+	metricsServerOnce.Do(func() {
+		startMetricsServer(addr)
+	})
+}
+
+func startMetricsServer(addr string) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		pcsCommandVerbose.Warn("metrics server listen failed", "addr", addr, "err", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	go func() {
+		err := http.Serve(ln, mux)
+		if err != nil {
+			pcsCommandVerbose.Warn("metrics server stopped", "addr", addr, "err", err)
+		}
+	}()
+	go refreshQuotaMetrics()
+
+	pcsCommandVerbose.Info("metrics server started", "addr", ln.Addr().String())
+}
+
+// refreshQuotaMetrics 定时获取网盘配额, 更新监控指标
+func refreshQuotaMetrics() {
+	for {
+		if GetActiveUser().BDUSS != "" {
+			// 不使用命令的 context, 命令结束后继续刷新
+			_, _, pcsError := pcsconfig.Config.ActiveUserBaiduPCS().QuotaInfo()
+			if pcsError != nil {
+				pcsCommandVerbose.Warn("refresh quota metrics failed", "err", pcsError)
+			}
+		}
+		time.Sleep(MetricsQuotaInterval)
+	}
+}
@@ -0,0 +1,431 @@
+package pcscommand
+
+import (
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsorganize"
+	"github.com/felixonmars/BaiduPCS-Go/pcstable"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/pcstime"
+	"github.com/json-iterator/go"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// OrganizeBatchSize 每次批量移动的文件数量
+	OrganizeBatchSize = 100
+	// OrganizeUndoFileName 整理的撤销记录文件名
+	OrganizeUndoFileName = "pcs_organize_undo.json"
+)
+
+type (
+	// OrganizeOptions 整理可选项
+	OrganizeOptions struct {
+		RulesFile string
+		DryRun    bool
+		Yes       bool
+	}
+
+	// organizeUndoLog 上一次整理成功移动的文件, 用于撤销
+	organizeUndoLog struct {
+		Time    int64               `json:"time"`
+		Moves   []*pcsorganize.Move `json:"moves"`
+		Dirs    []string            `json:"dirs"`    // 整理时创建的目录, 包括上级目录, 撤销后为空则删除
+		Removed []*organizeRemoved  `json:"removed"` // 覆盖时移到回收站的目标, 撤销时还原
+	}
+
+	// organizeRemoved 覆盖时移到回收站的文件
+	organizeRemoved struct {
+		Path string `json:"path"`
+		FsID int64  `json:"fs_id"`
+	}
+
+	// organizeExists 检测网盘路径是否存在, 缓存目录的列表
+	organizeExists struct {
+		pcs  *baidupcs.BaiduPCS
+		dirs map[string]map[string]bool // 目录 => 文件名, 目录不存在则为 nil
+	}
+)
+
+func newOrganizeExists(pcs *baidupcs.BaiduPCS) *organizeExists {
+	return &organizeExists{
+		pcs:  pcs,
+		dirs: map[string]map[string]bool{},
+	}
+}
+
+// list 获取目录下的文件名, 目录不存在返回 nil
+func (oe *organizeExists) list(dir string) map[string]bool {
+	names, ok := oe.dirs[dir]
+	if ok {
+		return names
+	}
+
+	fdl, pcsError := oe.pcs.FilesDirectoriesList(dir, baidupcs.DefaultOrderOptions)
+	if pcsError == nil {
+		names = make(map[string]bool, len(fdl))
+		for _, fd := range fdl {
+			names[fd.Filename] = true
+		}
+	}
+	oe.dirs[dir] = names
+	return names
+}
+
+// Exists 路径是否存在
+func (oe *organizeExists) Exists(pcspath string) bool {
+	dir, name := path.Split(pcspath)
+	return oe.list(path.Clean(dir))[name]
+}
+
+// DirExists 目录是否存在
+func (oe *organizeExists) DirExists(dir string) bool {
+	return oe.list(dir) != nil
+}
+
+func organizeUndoFilePath() string {
+	return filepath.Join(pcsconfig.GetConfigDir(), OrganizeUndoFileName)
+}
+
+// RunOrganize 执行 根据规则整理网盘文件
+func RunOrganize(paths []string, opt *OrganizeOptions) {
+	if opt == nil {
+		opt = &OrganizeOptions{}
+	}
+
+	rules, err := pcsorganize.LoadRules(opt.RulesFile)
+	if err != nil {
+		fmt.Printf("读取整理规则失败, %s\n", err)
+		return
+	}
+
+	paths, err = matchPathByShellPattern(paths...)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var (
+		pcs     = GetBaiduPCS()
+		exists  = newOrganizeExists(pcs)
+		planner = pcsorganize.NewPlanner(rules, exists.Exists)
+	)
+	for _, p := range paths {
+		pcs.FilesDirectoriesRecurseList(p, baidupcs.DefaultOrderOptions, func(depth int, _ string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+			if pcsError != nil {
+				pcsCommandVerbose.Warnf("%s\n", pcsError)
+				return true
+			}
+			if fd.Isdir {
+				return true
+			}
+
+			planner.Add(&pcsorganize.File{
+				Path:  fd.Path,
+				Size:  fd.Size,
+				Mtime: fd.Mtime,
+				Ctime: fd.Ctime,
+				MD5:   fd.MD5,
+			})
+			return true
+		})
+	}
+
+	plan := planner.Plan()
+	printOrganizePlan(plan)
+	if len(plan.Moves) == 0 {
+		fmt.Println("没有需要整理的文件")
+		return
+	}
+	if opt.DryRun {
+		return
+	}
+
+	if !opt.Yes {
+		var confirm string
+		fmt.Printf("确认移动以上 %d 个文件? (y/n) > ", len(plan.Moves))
+		_, err = fmt.Scanln(&confirm)
+		if err != nil || (confirm != "y" && confirm != "Y") {
+			return
+		}
+	}
+
+	undo := &organizeUndoLog{
+		Time: time.Now().Unix(),
+	}
+
+	// 创建目标目录, 记录不存在的上级目录, Mkdir 会一并创建
+	created := map[string]bool{}
+	for _, move := range plan.Moves {
+		var (
+			dir     = path.Dir(move.To)
+			missing []string
+		)
+		for d := dir; d != "/" && d != "." && !created[d] && !exists.DirExists(d); d = path.Dir(d) {
+			missing = append(missing, d)
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		pcsError := pcs.Mkdir(dir)
+		if pcsError != nil {
+			fmt.Printf("创建目录 %s 失败, %s\n", dir, pcsError)
+			continue
+		}
+		for _, d := range missing {
+			created[d] = true
+			undo.Dirs = append(undo.Dirs, d)
+		}
+	}
+
+	// 将已存在的目标移到回收站, 记录 fs_id, 撤销时还原
+	var removes []string
+	for _, move := range plan.Moves {
+		if move.Overwrite {
+			removes = append(removes, move.To)
+		}
+	}
+	if len(removes) > 0 {
+		fdl, pcsError := pcs.FilesDirectoriesBatchMeta(removes...)
+		if pcsError != nil {
+			fmt.Printf("获取已存在的目标信息失败, %s\n", pcsError)
+			return
+		}
+		pcsError = pcs.Remove(removes...)
+		if pcsError != nil {
+			fmt.Printf("删除已存在的目标失败, %s\n", pcsError)
+			return
+		}
+		for _, fd := range fdl {
+			undo.Removed = append(undo.Removed, &organizeRemoved{
+				Path: fd.Path,
+				FsID: fd.FsID,
+			})
+		}
+		fmt.Printf("已删除 %d 个已存在的目标, 可在网盘文件回收站找回, 撤销时自动还原\n", len(removes))
+	}
+
+	moved := runOrganizeMoves(pcs, plan.Moves)
+	undo.Moves = moved
+	fmt.Printf("整理完成, 成功移动 %d 个文件, 失败 %d 个\n", len(moved), len(plan.Moves)-len(moved))
+	if len(moved) == 0 && len(undo.Removed) == 0 {
+		return
+	}
+
+	err = saveOrganizeUndoLog(undo)
+	if err != nil {
+		fmt.Printf("保存撤销记录失败, %s\n", err)
+		return
+	}
+	fmt.Println("可使用 organize --undo 撤销本次整理")
+}
+
+// runOrganizeMoves 按顺序批量移动文件, 批量移动失败时逐个移动, 返回移动成功的
+func runOrganizeMoves(pcs *baidupcs.BaiduPCS, moves []*pcsorganize.Move) (moved []*pcsorganize.Move) {
+	for _, batch := range pcsorganize.Batches(moves, OrganizeBatchSize) {
+		list := make(baidupcs.CpMvJSONList, len(batch))
+		for k, move := range batch {
+			list[k] = &baidupcs.CpMvJSON{
+				From: move.From,
+				To:   move.To,
+			}
+		}
+
+		pcsError := pcs.Move(list...)
+		if pcsError == nil {
+			fmt.Println((&baidupcs.CpMvListJSON{List: list}).String())
+			moved = append(moved, batch...)
+			continue
+		}
+
+		pcsCommandVerbose.Warnf("批量移动失败, %s, 逐个移动\n", pcsError)
+		for _, move := range batch {
+			// 批量移动失败时, 部分文件可能已经移动
+			if organizeMoveDone(pcs, move) {
+				fmt.Printf("移动成功: %s -> %s\n", move.From, move.To)
+				moved = append(moved, move)
+				continue
+			}
+
+			if path.Dir(move.From) == path.Dir(move.To) {
+				pcsError = pcs.Rename(move.From, move.To)
+			} else {
+				pcsError = pcs.Move(&baidupcs.CpMvJSON{
+					From: move.From,
+					To:   move.To,
+				})
+			}
+			if pcsError != nil {
+				fmt.Printf("移动失败: %s -> %s, %s\n", move.From, move.To, pcsError)
+				continue
+			}
+			fmt.Printf("移动成功: %s -> %s\n", move.From, move.To)
+			moved = append(moved, move)
+		}
+	}
+	return
+}
+
+// organizeMoveDone 源路径已不存在, 且目标路径已存在, 即已移动
+func organizeMoveDone(pcs *baidupcs.BaiduPCS, move *pcsorganize.Move) bool {
+	_, pcsError := pcs.FilesDirectoriesMeta(move.From)
+	if pcsError == nil || pcsError.GetErrType() != pcserror.ErrTypeRemoteError || pcsError.GetRemoteErrCode() != 31066 {
+		return false
+	}
+	_, pcsError = pcs.FilesDirectoriesMeta(move.To)
+	return pcsError == nil
+}
+
+func printOrganizePlan(plan *pcsorganize.Plan) {
+	if len(plan.Moves) > 0 {
+		tb := pcstable.NewTable(os.Stdout)
+		tb.SetHeader([]string{"#", "规则", "源路径", "目标路径", "备注"})
+		for k, move := range plan.Moves {
+			note := ""
+			if move.Overwrite {
+				note = "覆盖"
+			}
+			tb.Append([]string{strconv.Itoa(k), move.Rule, move.From, move.To, note})
+		}
+		tb.Render()
+	}
+
+	if len(plan.Skipped) > 0 {
+		fmt.Printf("\n以下 %d 个文件将跳过:\n", len(plan.Skipped))
+		tb := pcstable.NewTable(os.Stdout)
+		tb.SetHeader([]string{"#", "规则", "路径", "原因"})
+		for k, skip := range plan.Skipped {
+			tb.Append([]string{strconv.Itoa(k), skip.Rule, skip.Path, skip.Reason})
+		}
+		tb.Render()
+	}
+}
+
+func saveOrganizeUndoLog(undo *organizeUndoLog) error {
+	data, err := jsoniter.MarshalIndent(undo, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(organizeUndoFilePath(), data, 0600)
+}
+
+func loadOrganizeUndoLog() (*organizeUndoLog, error) {
+	data, err := ioutil.ReadFile(organizeUndoFilePath())
+	if err != nil {
+		return nil, err
+	}
+	undo := &organizeUndoLog{}
+	err = jsoniter.Unmarshal(data, undo)
+	if err != nil {
+		return nil, err
+	}
+	return undo, nil
+}
+
+// RunOrganizeUndo 执行 撤销上一次整理
+func RunOrganizeUndo(yes bool) {
+	undo, err := loadOrganizeUndoLog()
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("没有可撤销的整理记录")
+			return
+		}
+		fmt.Printf("读取撤销记录失败, %s\n", err)
+		return
+	}
+
+	// 倒序还原
+	reverse := make([]*pcsorganize.Move, 0, len(undo.Moves))
+	for k := len(undo.Moves) - 1; k >= 0; k-- {
+		reverse = append(reverse, &pcsorganize.Move{
+			From: undo.Moves[k].To,
+			To:   undo.Moves[k].From,
+			Rule: undo.Moves[k].Rule,
+		})
+	}
+
+	fmt.Printf("上一次整理时间: %s, 将还原 %d 个文件\n", pcstime.FormatTime(undo.Time), len(reverse))
+	printOrganizePlan(&pcsorganize.Plan{Moves: reverse})
+	if !yes {
+		var confirm string
+		fmt.Printf("确认撤销? (y/n) > ")
+		_, err = fmt.Scanln(&confirm)
+		if err != nil || (confirm != "y" && confirm != "Y") {
+			return
+		}
+	}
+
+	moved := runOrganizeMoves(GetBaiduPCS(), reverse)
+	if len(moved) != len(reverse) {
+		// 保留未还原的记录, 可再次撤销
+		done := make(map[*pcsorganize.Move]bool, len(moved))
+		for _, move := range moved {
+			done[move] = true
+		}
+		undo.Moves = undo.Moves[:0]
+		for k := len(reverse) - 1; k >= 0; k-- {
+			if !done[reverse[k]] {
+				undo.Moves = append(undo.Moves, &pcsorganize.Move{
+					From: reverse[k].To,
+					To:   reverse[k].From,
+					Rule: reverse[k].Rule,
+				})
+			}
+		}
+		err = saveOrganizeUndoLog(undo)
+		if err != nil {
+			fmt.Printf("保存撤销记录失败, %s\n", err)
+		}
+		fmt.Printf("撤销未完成, %d 个文件还原失败, 可再次执行撤销\n", len(reverse)-len(moved))
+		return
+	}
+
+	// 还原覆盖时移到回收站的目标
+	if len(undo.Removed) > 0 {
+		fidList := make([]int64, 0, len(undo.Removed))
+		for _, removed := range undo.Removed {
+			fidList = append(fidList, removed.FsID)
+		}
+		_, pcsError := GetBaiduPCS().RecycleRestore(fidList...)
+		if pcsError != nil {
+			undo.Moves = nil
+			err = saveOrganizeUndoLog(undo)
+			if err != nil {
+				fmt.Printf("保存撤销记录失败, %s\n", err)
+			}
+			fmt.Printf("还原被覆盖的 %d 个文件失败, %s, 可再次执行撤销, 或在网盘文件回收站找回\n", len(undo.Removed), pcsError)
+			return
+		}
+		fmt.Printf("已从回收站还原 %d 个被覆盖的文件\n", len(undo.Removed))
+	}
+
+	removeOrganizeEmptyDirs(GetBaiduPCS(), undo.Dirs)
+	os.Remove(organizeUndoFilePath())
+	fmt.Printf("撤销完成, 已还原 %d 个文件\n", len(moved))
+}
+
+// removeOrganizeEmptyDirs 删除整理时创建的, 撤销后为空的目录, 从最深的目录开始逐个删除
+func removeOrganizeEmptyDirs(pcs *baidupcs.BaiduPCS, dirs []string) {
+	sorted := append([]string(nil), dirs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.Count(sorted[i], "/") > strings.Count(sorted[j], "/")
+	})
+	for _, dir := range sorted {
+		fdl, pcsError := pcs.FilesDirectoriesList(dir, baidupcs.DefaultOrderOptions)
+		if pcsError != nil || len(fdl) != 0 {
+			continue
+		}
+		pcsError = pcs.Remove(dir)
+		if pcsError != nil {
+			pcsCommandVerbose.Warnf("删除整理时创建的目录 %s 失败, %s\n", dir, pcsError)
+		}
+	}
+}
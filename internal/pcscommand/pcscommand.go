@@ -2,21 +2,38 @@
 package pcscommand
 
 import (
+	"context"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 )
 
 var (
-	pcsCommandVerbose = pcsverbose.New("PCSCOMMAND")
+	pcsCommandVerbose = pcslog.New("PCSCOMMAND")
+
+	commandCtx = context.Background()
 )
 
+// SetContext 设置执行命令的 context, 取消后, 进行中的请求中断,
+// 上传和下载保存断点信息后停止, 不再执行新的任务
+func SetContext(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	commandCtx = ctx
+}
+
+// Context 返回执行命令的 context
+func Context() context.Context {
+	return commandCtx
+}
+
 // GetActiveUser 获取当前登录的百度帐号
 func GetActiveUser() *pcsconfig.Baidu {
 	return pcsconfig.Config.ActiveUser()
 }
 
-// GetBaiduPCS 从配置读取BaiduPCS
+// GetBaiduPCS 从配置读取BaiduPCS, 请求使用执行命令的 context
 func GetBaiduPCS() *baidupcs.BaiduPCS {
-	return pcsconfig.Config.ActiveUserBaiduPCS()
+	return pcsconfig.Config.ActiveUserBaiduPCS().WithContext(commandCtx)
 }
@@ -0,0 +1,220 @@
+package pcscommand
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/pcstable"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/pcstime"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+)
+
+const (
+	// RapidProbeHit 可以秒传
+	RapidProbeHit = "hit"
+	// RapidProbeMiss 无法秒传, 需要上传
+	RapidProbeMiss = "miss"
+	// RapidProbeTooLarge 文件超过 20GB, 无法秒传
+	RapidProbeTooLarge = "too-large"
+	// RapidProbeError 无法读取文件, 或秒传时出错
+	RapidProbeError = "error"
+)
+
+type (
+	// RapidProbeOptions rapidprobe 的选项
+	RapidProbeOptions struct {
+		ScratchDir string // 试探秒传的网盘临时目录, 结束后删除
+		Parallel   int
+		MaxRetry   int
+		Output     string // 报告的保存路径
+	}
+
+	// rapidProbeResult 一个文件的试探结果
+	rapidProbeResult struct {
+		localPath string
+		length    int64
+		status    string
+		err       error
+	}
+
+	// rapidProbeStat 按状态统计
+	rapidProbeStat struct {
+		count int
+		size  int64
+	}
+)
+
+// rapidProbeOne 秒传到临时目录, 服务器上不存在文件的内容时为 miss
+func rapidProbeOne(pcs *baidupcs.BaiduPCS, lfm *checksum.LocalFileMeta, target string, maxRetry int) (status string, err error) {
+	if lfm.Length > baidupcs.MaxRapidUploadSize {
+		return RapidProbeTooLarge, nil
+	}
+	if lfm.Length == 0 {
+		// 空文件不需要上传数据
+		return RapidProbeHit, nil
+	}
+
+	for times := 0; ; times++ {
+		pcsError := pcs.RapidUploadNoCheckDir(target, hex.EncodeToString(lfm.MD5), hex.EncodeToString(lfm.SliceMD5), strconv.FormatUint(uint64(lfm.CRC32), 10), lfm.Length)
+		if pcsError == nil {
+			return RapidProbeHit, nil
+		}
+		if pcsError.GetRemoteErrCode() == RemoteErrCodeRapidUploadNotFound {
+			return RapidProbeMiss, nil
+		}
+		if times >= maxRetry || !pcsfunctions.IsRetryable(pcsError) {
+			return RapidProbeError, pcsError
+		}
+		if retry.Sleep(pcs.Context(), pcsfunctions.RetryWaitErr(times+1, pcsError)) != nil { // 已取消
+			return RapidProbeError, pcsError
+		}
+	}
+}
+
+// RunRapidProbe 计算本地文件的秒传信息, 并秒传到网盘临时目录, 统计可以秒传的文件,
+// 以及需要实际上传的数据量, 结束后删除临时目录
+func RunRapidProbe(localPaths []string, opt *RapidProbeOptions) {
+	if opt == nil {
+		opt = &RapidProbeOptions{}
+	}
+	if opt.Parallel <= 0 {
+		opt.Parallel = 4
+	}
+	if opt.ScratchDir == "" {
+		opt.ScratchDir = "/BaiduPCS-Go_rapidprobe_" + pcstime.BeijingTimeOption("")
+	}
+	opt.ScratchDir = GetActiveUser().PathJoin(opt.ScratchDir)
+
+	var reportWriter io.Writer
+	if opt.Output != "" {
+		f, err := os.Create(opt.Output)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		reportWriter = f
+	}
+
+	pcs := GetBaiduPCS()
+	_, pcsError := pcs.FilesDirectoriesMeta(opt.ScratchDir)
+	if pcsError == nil {
+		fmt.Printf("临时目录 %s 已存在, 请指定其他目录\n", opt.ScratchDir)
+		return
+	}
+	fmt.Printf("临时目录: %s\n", opt.ScratchDir)
+
+	var (
+		hashed = runSumFileTasks(opt.Parallel, func(emit func(t *sumFileTask)) {
+			walkSumFiles(localPaths, checksum.CHECKSUM_MD5|checksum.CHECKSUM_SLICE_MD5|checksum.CHECKSUM_CRC32, func(t *sumFileTask) {
+				if t.size > baidupcs.MaxRapidUploadSize {
+					t.flag = 0 // 超过 20GB, 无法秒传, 不计算摘要值
+				}
+				emit(t)
+			})
+		})
+		results = make(chan *rapidProbeResult, opt.Parallel)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		n       int
+	)
+	for i := 0; i < opt.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range hashed {
+				<-t.done
+				res := &rapidProbeResult{
+					localPath: t.localPath,
+				}
+				if t.err != nil {
+					res.status, res.err = RapidProbeError, t.err
+					results <- res
+					continue
+				}
+				if t.flag == 0 {
+					res.status, res.length = RapidProbeTooLarge, t.size
+					results <- res
+					continue
+				}
+				res.length = t.lfc.Length
+
+				// 每个文件使用不同的临时文件名, 避免路径中的特殊字符
+				mu.Lock()
+				n++
+				target := path.Join(opt.ScratchDir, strconv.Itoa(n))
+				mu.Unlock()
+				res.status, res.err = rapidProbeOne(pcs, &t.lfc.LocalFileMeta, target, opt.MaxRetry)
+				results <- res
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stats := map[string]*rapidProbeStat{}
+	for _, status := range []string{RapidProbeHit, RapidProbeMiss, RapidProbeTooLarge, RapidProbeError} {
+		stats[status] = &rapidProbeStat{}
+	}
+	for res := range results {
+		stat := stats[res.status]
+		stat.count++
+		stat.size += res.length
+
+		switch res.status {
+		case RapidProbeHit:
+			fmt.Printf("[秒传] %s, 大小: %s\n", res.localPath, converter.ConvertFileSize(res.length, 2))
+		case RapidProbeMiss, RapidProbeTooLarge:
+			fmt.Printf("[需要上传] %s, 大小: %s\n", res.localPath, converter.ConvertFileSize(res.length, 2))
+		default:
+			fmt.Printf("[错误] %s, %s\n", res.localPath, res.err)
+		}
+		if reportWriter != nil {
+			fmt.Fprintf(reportWriter, "%s\t%d\t%s\n", res.status, res.length, res.localPath)
+		}
+	}
+
+	// 清理临时目录
+	if n > 0 {
+		pcsError = pcs.Remove(opt.ScratchDir)
+		if pcsError != nil {
+			fmt.Printf("删除临时目录 %s 失败, 请手动删除, %s\n", opt.ScratchDir, pcsError)
+		}
+	}
+
+	var (
+		hit        = stats[RapidProbeHit]
+		miss       = stats[RapidProbeMiss]
+		tooLarge   = stats[RapidProbeTooLarge]
+		errored    = stats[RapidProbeError]
+		uploadSize = miss.size + tooLarge.size
+	)
+	fmt.Println()
+	tb := pcstable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"状态", "文件数量", "大小"})
+	tb.AppendBulk([][]string{
+		[]string{"可以秒传", strconv.Itoa(hit.count), converter.ConvertFileSize(hit.size, 2)},
+		[]string{"无法秒传", strconv.Itoa(miss.count), converter.ConvertFileSize(miss.size, 2)},
+		[]string{"超过20GB", strconv.Itoa(tooLarge.count), converter.ConvertFileSize(tooLarge.size, 2)},
+		[]string{"出错", strconv.Itoa(errored.count), converter.ConvertFileSize(errored.size, 2)},
+	})
+	tb.Render()
+
+	total := hit.size + uploadSize
+	if total > 0 {
+		fmt.Printf("需要实际上传的数据量: %s, 占 %.2f%%\n", converter.ConvertFileSize(uploadSize, 2), float64(uploadSize)*100/float64(total))
+	}
+	if reportWriter != nil {
+		fmt.Printf("报告已保存到: %s\n", opt.Output)
+	}
+}
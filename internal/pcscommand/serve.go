@@ -0,0 +1,279 @@
+package pcscommand
+
+import (
+	"errors"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"html"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ServeLinkCacheTTL 文件信息和下载链接的缓存有效期
+	ServeLinkCacheTTL = 5 * time.Minute
+)
+
+var (
+	// ErrRangeNotSatisfiable Range 超出文件范围
+	ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+)
+
+type (
+	// ServeOptions 网盘文件 http 服务可选参数
+	ServeOptions struct {
+		Addr       string // 监听地址
+		Parallel   int    // 每个请求的下载最大并发量
+		BufferSize int    // 每个请求的重排缓冲区大小
+	}
+
+	serveLink struct {
+		fd      *baidupcs.FileDirectory
+		dlinks  []string
+		expires time.Time
+	}
+
+	// serveHandler 将 http 请求的路径映射到网盘文件,
+	// 将请求的 Range 转换为多线程下载的范围
+	serveHandler struct {
+		pcs   *baidupcs.BaiduPCS
+		root  string
+		opt   *ServeOptions
+		links map[string]*serveLink
+		mu    sync.Mutex
+	}
+)
+
+// RunServe 启动 http 服务, 提供网盘目录 root 中的文件, 支持 Range 请求
+func RunServe(root string, options *ServeOptions) {
+	if options == nil {
+		options = &ServeOptions{}
+	}
+	if options.Addr == "" {
+		options.Addr = "127.0.0.1:8080"
+	}
+
+	ln, err := net.Listen("tcp", options.Addr)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("网盘目录 %s 已映射到 http://%s/, 按 Ctrl+C 停止\n", root, ln.Addr())
+	srv := &http.Server{
+		Handler: &serveHandler{
+			pcs:   GetBaiduPCS(),
+			root:  root,
+			opt:   options,
+			links: map[string]*serveLink{},
+		},
+	}
+
+	// 命令取消时, 关闭监听和全部连接, 进行中的请求的 context 随连接关闭而取消
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-Context().Done():
+			srv.Close()
+		case <-stopped:
+		}
+	}()
+
+	err = srv.Serve(ln)
+	if err == http.ErrServerClosed {
+		fmt.Printf("\n已停止 http 服务\n")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// getLink 获取文件信息和下载链接, 优先读取缓存, 目录没有下载链接
+func (sh *serveHandler) getLink(pcspath string) (*serveLink, error) {
+	sh.mu.Lock()
+	link, ok := sh.links[pcspath]
+	sh.mu.Unlock()
+	if ok && time.Now().Before(link.expires) {
+		return link, nil
+	}
+
+	fd, pcsError := sh.pcs.FilesDirectoriesMeta(pcspath)
+	if pcsError != nil {
+		return nil, pcsError
+	}
+
+	link = &serveLink{
+		fd:      fd,
+		expires: time.Now().Add(ServeLinkCacheTTL),
+	}
+	if !fd.Isdir {
+		dlinks, err := pcsdownload.GetLocateDownloadLinkStrings(sh.pcs, pcspath)
+		if err != nil {
+			return nil, err
+		}
+		link.dlinks = dlinks
+	}
+
+	sh.mu.Lock()
+	sh.links[pcspath] = link
+	sh.mu.Unlock()
+	return link, nil
+}
+
+func (sh *serveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	pcspath := path.Join(sh.root, path.Clean("/"+r.URL.Path))
+	link, err := sh.getLink(pcspath)
+	if err != nil {
+		pcsCommandVerbose.Warn("serve get file failed", "path", pcspath, "err", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if link.fd.Isdir {
+		sh.serveDir(w, r, pcspath)
+		return
+	}
+
+	var (
+		fd         = link.fd
+		header     = w.Header()
+		begin, end = int64(0), fd.Size
+		statusCode = http.StatusOK
+	)
+	contentType := mime.TypeByExtension(path.Ext(fd.Filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Last-Modified", time.Unix(fd.Mtime, 0).UTC().Format(http.TimeFormat))
+
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		var ok bool
+		begin, end, ok, err = parseHTTPRange(rangeHeader, fd.Size)
+		if err != nil {
+			header.Set("Content-Range", "bytes */"+strconv.FormatInt(fd.Size, 10))
+			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if ok {
+			statusCode = http.StatusPartialContent
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", begin, end-1, fd.Size))
+		}
+	}
+
+	header.Set("Content-Length", strconv.FormatInt(end-begin, 10))
+	w.WriteHeader(statusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	pcsCommandVerbose.Info("serve file", "path", pcspath, "begin", begin, "end", end)
+	err = pcsdownload.StreamDownload(r.Context(), w, &pcsdownload.StreamOptions{
+		DownloadURL: link.dlinks[0],
+		Mirrors:     link.dlinks[1:],
+		RefreshFunc: func() ([]string, error) {
+			return pcsdownload.GetLocateDownloadLinkStrings(sh.pcs, pcspath)
+		},
+		Size:       fd.Size,
+		Begin:      begin,
+		End:        end,
+		BufferSize: sh.opt.BufferSize,
+		Config:     streamDownloadConfig(sh.opt.Parallel),
+	})
+	if err != nil {
+		// 响应头已发送, 只能中断连接
+		pcsCommandVerbose.Warn("serve file failed", "path", pcspath, "err", err)
+
+		// 下载链接可能已失效
+		sh.mu.Lock()
+		delete(sh.links, pcspath)
+		sh.mu.Unlock()
+	}
+}
+
+// serveDir 输出目录列表
+func (sh *serveHandler) serveDir(w http.ResponseWriter, r *http.Request, pcspath string) {
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	fdl, pcsError := sh.pcs.CacheFilesDirectoriesList(pcspath, baidupcs.DefaultOrderOptions)
+	if pcsError != nil {
+		http.Error(w, pcsError.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<pre>\n", html.EscapeString(pcspath))
+	fmt.Fprint(w, "<a href=\"../\">../</a>\n")
+	for _, fd := range fdl {
+		name := fd.Filename
+		if fd.Isdir {
+			name += "/"
+		}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", (&url.URL{Path: name}).String(), html.EscapeString(name))
+	}
+	fmt.Fprint(w, "</pre>\n")
+}
+
+// parseHTTPRange 解析请求头的 Range, 返回 [begin, end),
+// 只支持单个范围, 多个范围时 ok 为 false, 应返回整个文件
+func parseHTTPRange(s string, size int64) (begin, end int64, ok bool, err error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) || strings.Contains(s, ",") {
+		return 0, size, false, nil
+	}
+
+	spec := strings.TrimSpace(s[len(prefix):])
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return 0, 0, false, ErrRangeNotSatisfiable
+	}
+
+	startStr, endStr := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+	if startStr == "" {
+		// bytes=-n, 最后 n 个字节, 空文件无法满足
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 || size <= 0 {
+			return 0, 0, false, ErrRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size, true, nil
+	}
+
+	begin, err = strconv.ParseInt(startStr, 10, 64)
+	if err != nil || begin < 0 || begin >= size {
+		return 0, 0, false, ErrRangeNotSatisfiable
+	}
+
+	end = size
+	if endStr != "" {
+		last, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || last < begin {
+			return 0, 0, false, ErrRangeNotSatisfiable
+		}
+		if last+1 < size {
+			end = last + 1
+		}
+	}
+	return begin, end, true, nil
+}
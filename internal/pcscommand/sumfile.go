@@ -0,0 +1,257 @@
+package pcscommand
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/pcstable"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"github.com/olekukonko/tablewriter"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+const (
+	// SumFileFormatTable 以表格输出秒传信息
+	SumFileFormatTable = "table"
+)
+
+type (
+	// SumFileOptions sumfile 的选项
+	SumFileOptions struct {
+		Format   string // 输出格式, table, md5, sha256, rapid
+		Parallel int    // 同时计算的文件数量
+		Output   string // 清单的保存路径, 为空则输出到标准输出
+		Quiet    bool   // 校验时, 不输出校验通过的文件
+	}
+
+	// sumFileTask 计算一个文件的摘要值
+	sumFileTask struct {
+		localPath string
+		size      int64                   // 遍历时获取的文件大小
+		flag      int                     // 为 0 时不计算摘要值
+		entry     *checksum.ManifestEntry // 校验模式下, 清单的一行
+		lfc       *checksum.LocalFileChecksum
+		err       error
+		done      chan struct{}
+	}
+)
+
+func (opt *SumFileOptions) fix() {
+	if opt.Format == "" {
+		opt.Format = SumFileFormatTable
+	}
+	if opt.Parallel <= 0 {
+		opt.Parallel = runtime.NumCPU()
+	}
+}
+
+// runSumFileTasks 并发计算 produce 生成的任务, 按生成的顺序返回结果.
+// 同一文件的多个摘要值在一次读取中计算
+func runSumFileTasks(parallel int, produce func(emit func(t *sumFileTask))) <-chan *sumFileTask {
+	var (
+		cache   = pcsfunctions.ChecksumCache()
+		ordered = make(chan *sumFileTask, parallel*4)
+		jobs    = make(chan *sumFileTask)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				if t.err == nil && t.flag != 0 {
+					t.lfc, t.err = checksum.GetFileSumWithCache(cache, t.localPath, t.flag)
+				}
+				close(t.done)
+			}
+		}()
+	}
+
+	go func() {
+		produce(func(t *sumFileTask) {
+			t.done = make(chan struct{})
+			ordered <- t
+			jobs <- t
+		})
+		close(jobs)
+		wg.Wait()
+		close(ordered)
+	}()
+	return ordered
+}
+
+// walkSumFiles 生成本地文件和目录下全部文件的任务
+func walkSumFiles(paths []string, flag int, emit func(t *sumFileTask)) {
+	for _, localPath := range paths {
+		info, err := os.Stat(localPath)
+		if err != nil {
+			emit(&sumFileTask{localPath: localPath, err: err})
+			continue
+		}
+		if !info.IsDir() {
+			emit(&sumFileTask{localPath: localPath, size: info.Size(), flag: flag})
+			continue
+		}
+
+		filepath.Walk(localPath, func(walkPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				emit(&sumFileTask{localPath: walkPath, err: err})
+				return nil
+			}
+			if info.Mode().IsRegular() {
+				emit(&sumFileTask{localPath: walkPath, size: info.Size(), flag: flag})
+			}
+			return nil
+		})
+	}
+}
+
+func openSumFileOutput(output string) (w io.Writer, closeFunc func() error, err error) {
+	if output == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// RunSumFile 获取本地文件的秒传信息, 目录则递归获取目录下全部文件的,
+// 输出为表格, 或 md5sum/sha256sum 兼容的清单, 或秒传信息清单
+func RunSumFile(paths []string, opt *SumFileOptions) {
+	if opt == nil {
+		opt = &SumFileOptions{}
+	}
+	opt.fix()
+
+	flag := checksum.CHECKSUM_MD5 | checksum.CHECKSUM_SLICE_MD5 | checksum.CHECKSUM_CRC32
+	if opt.Format != SumFileFormatTable {
+		var err error
+		flag, err = checksum.ManifestFlag(opt.Format)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	w, closeFunc, err := openSumFileOutput(opt.Output)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer closeFunc()
+
+	var (
+		results = runSumFileTasks(opt.Parallel, func(emit func(t *sumFileTask)) {
+			walkSumFiles(paths, flag, emit)
+		})
+		k = 0
+	)
+	for t := range results {
+		<-t.done
+		k++
+		if t.err != nil {
+			fmt.Fprintf(os.Stderr, "[%d] %s\n", k, t.err)
+			continue
+		}
+
+		if opt.Format == SumFileFormatTable {
+			printSumFileTable(w, k, t.localPath, &t.lfc.LocalFileMeta)
+			continue
+		}
+		line, _ := checksum.ManifestLine(opt.Format, &t.lfc.LocalFileMeta, t.localPath)
+		_, err = fmt.Fprintln(w, line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "写入清单失败, %s\n", err)
+			break
+		}
+	}
+	for range results {
+		// 写入失败时, 等待剩余的任务结束
+	}
+}
+
+func printSumFileTable(w io.Writer, k int, localPath string, lfm *checksum.LocalFileMeta) {
+	fmt.Fprintf(w, "[%d] - [%s]:\n", k, localPath)
+
+	strLength, strMd5, strSliceMd5, strCrc32 := strconv.FormatInt(lfm.Length, 10), hex.EncodeToString(lfm.MD5), hex.EncodeToString(lfm.SliceMD5), strconv.FormatUint(uint64(lfm.CRC32), 10)
+	fileName := filepath.Base(localPath)
+
+	tb := pcstable.NewTable(w)
+	tb.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
+	tb.AppendBulk([][]string{
+		[]string{"文件大小", strLength},
+		[]string{"md5", strMd5},
+		[]string{"前256KB切片的md5", strSliceMd5},
+		[]string{"crc32", strCrc32},
+		[]string{"秒传命令", "BaiduPCS-Go rapidupload -length=" + strLength + " -md5=" + strMd5 + " -slicemd5=" + strSliceMd5 + " -crc32=" + strCrc32 + " " + fileName},
+	})
+	tb.Render()
+	fmt.Fprintf(w, "\n")
+}
+
+// RunSumFileCheck 按清单校验本地文件, 清单可以是 md5sum/sha256sum 的输出, 或 sumfile 生成的清单
+func RunSumFileCheck(manifests []string, opt *SumFileOptions) {
+	if opt == nil {
+		opt = &SumFileOptions{}
+	}
+	opt.fix()
+
+	for _, manifest := range manifests {
+		f, err := os.Open(manifest)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		entries, improper, err := checksum.ParseManifest(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("%s: 读取清单失败, %s\n", manifest, err)
+			continue
+		}
+		if len(entries) == 0 {
+			fmt.Printf("%s: 未找到有效的校验行\n", manifest)
+			continue
+		}
+
+		results := runSumFileTasks(opt.Parallel, func(emit func(t *sumFileTask)) {
+			for _, me := range entries {
+				emit(&sumFileTask{localPath: me.Path, flag: me.Flag(), entry: me})
+			}
+		})
+
+		var failed, unreadable int
+		for t := range results {
+			<-t.done
+			switch {
+			case t.err != nil:
+				unreadable++
+				fmt.Printf("%s: FAILED open or read\n", t.localPath)
+			case !t.entry.Match(&t.lfc.LocalFileMeta):
+				failed++
+				fmt.Printf("%s: FAILED\n", t.localPath)
+			case !opt.Quiet:
+				fmt.Printf("%s: OK\n", t.localPath)
+			}
+		}
+
+		if improper > 0 {
+			fmt.Printf("%s: 警告: %d 行格式错误\n", manifest, improper)
+		}
+		if unreadable > 0 {
+			fmt.Printf("%s: 警告: %d 个文件无法读取\n", manifest, unreadable)
+		}
+		if failed > 0 {
+			fmt.Printf("%s: 警告: %d 个文件校验失败\n", manifest, failed)
+		}
+		if improper == 0 && unreadable == 0 && failed == 0 {
+			fmt.Printf("%s: 全部 %d 个文件校验通过\n", manifest, len(entries))
+		}
+	}
+}
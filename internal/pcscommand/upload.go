@@ -4,30 +4,42 @@ import (
 	"fmt"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
 	"github.com/felixonmars/BaiduPCS-Go/pcstable"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/taskframework"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
 	// DefaultUploadMaxRetry 默认上传失败最大重试次数
 	DefaultUploadMaxRetry = 3
+	// SmallUploadFileSize 不超过此大小的文件为小文件, 只有一个分片, 多个小文件同时上传
+	SmallUploadFileSize = baidupcs.MinUploadBlockSize
 )
 
 type (
 	// UploadOptions 上传可选项
 	UploadOptions struct {
 		Parallel      int
+		Load          int // 同时上传的最大文件数
 		MaxRetry      int
 		NoRapidUpload bool
-		NoSplitFile   bool // 禁用分片上传
+		NoSplitFile   bool                       // 禁用分片上传
+		Statistic     *pcsupload.UploadStatistic // 上传统计, 为空则新建
+		OnConflict    string                     // 网盘文件已存在时的处理方式
+		SpillDir      string                     // 从标准输入上传或打包上传时, 暂存分片的目录
+		Archive       string                     // 将目录打包为 tar, zip 或 tar.zst 上传
+		SplitSize     int64                      // 超过此大小的文件分割为多个部分上传, 为 0 则使用配置 split_size, 小于 0 则不分割
 	}
 )
 
@@ -38,7 +50,7 @@ func RunRapidUpload(targetPath, contentMD5, sliceMD5, crc32 string, length int64
 		fmt.Printf("警告: %s, 获取网盘路径 %s 错误, %s\n", baidupcs.OperationRapidUpload, targetPath, err)
 	}
 
-	err = GetBaiduPCS().RapidUpload(targetPath, contentMD5, sliceMD5, crc32, length)
+	err = GetBaiduPCS().RapidUpload(targetPath, baidupcs.OndupOverwrite, contentMD5, sliceMD5, crc32, length)
 	if err != nil {
 		fmt.Printf("%s失败, 消息: %s\n", baidupcs.OperationRapidUpload, err)
 		return
@@ -55,7 +67,7 @@ func RunCreateSuperFile(targetPath string, blockList ...string) {
 		fmt.Printf("警告: %s, 获取网盘路径 %s 错误, %s\n", baidupcs.OperationUploadCreateSuperFile, targetPath, err)
 	}
 
-	err = GetBaiduPCS().UploadCreateSuperFile(true, targetPath, blockList...)
+	err = GetBaiduPCS().UploadCreateSuperFile(true, targetPath, baidupcs.OndupOverwrite, blockList...)
 	if err != nil {
 		fmt.Printf("%s失败, 消息: %s\n", baidupcs.OperationUploadCreateSuperFile, err)
 		return
@@ -76,11 +88,25 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 		opt.Parallel = pcsconfig.Config.MaxUploadParallel
 	}
 
+	if opt.Load <= 0 {
+		opt.Load = pcsconfig.Config.MaxUploadLoad
+	}
+
 	if opt.MaxRetry < 0 {
 		opt.MaxRetry = DefaultUploadMaxRetry
 	}
 
-	err := matchPathByShellPatternOnce(&savePath)
+	if opt.SplitSize == 0 {
+		opt.SplitSize = pcsconfig.Config.SplitSize
+	}
+
+	err := pcsupload.CheckOnConflict(opt.OnConflict)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	err = matchPathByShellPatternOnce(&savePath)
 	if err != nil {
 		fmt.Printf("警告: 上传文件, 获取网盘路径 %s 错误, %s\n", savePath, err)
 	}
@@ -89,6 +115,16 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 	case 0:
 		fmt.Printf("本地路径为空\n")
 		return
+	case 1:
+		if localPaths[0] == "-" {
+			runUploadStream(os.Stdin, savePath, opt)
+			return
+		}
+	}
+
+	if opt.Archive != "" {
+		runUploadArchive(localPaths, savePath, opt)
+		return
 	}
 
 	// 打开上传状态
@@ -100,15 +136,15 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 	defer uploadDatabase.Close()
 
 	var (
-		pcs = GetBaiduPCS()
-		// 使用 task framework
-		executor = &taskframework.TaskExecutor{
-			IsFailedDeque: true, // 失败统计
-		}
+		pcs         = GetBaiduPCS()
+		units       []*pcsupload.UploadTaskUnit
 		subSavePath string
 		// 统计
-		statistic = &pcsupload.UploadStatistic{}
+		statistic = opt.Statistic
 	)
+	if statistic == nil {
+		statistic = &pcsupload.UploadStatistic{}
+	}
 
 	statistic.StartTimer() // 开始计时
 
@@ -136,41 +172,234 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 
 			subSavePath = strings.TrimPrefix(walkedFiles[k3], localPathDir)
 
-			info := executor.Append(&pcsupload.UploadTaskUnit{
+			units = append(units, &pcsupload.UploadTaskUnit{
 				LocalFileChecksum: checksum.NewLocalFileChecksum(walkedFiles[k3], int(baidupcs.SliceMD5Size)),
 				SavePath:          path.Clean(savePath + baidupcs.PathSeparator + subSavePath),
 				PCS:               pcs,
 				UploadingDatabase: uploadDatabase,
-				Parallel:          opt.Parallel,
+				Parallel:          1,
 				NoRapidUpload:     opt.NoRapidUpload,
 				NoSplitFile:       opt.NoSplitFile,
+				OnConflict:        opt.OnConflict,
 				UploadStatistic:   statistic,
-			}, opt.MaxRetry)
-			fmt.Printf("[%s] 加入上传队列: %s\n", info.Id(), walkedFiles[k3])
+				PrintFormat:       uploadPrintFormat(opt.Load),
+			})
 		}
 	}
 
+	// 超过 split_size 的文件分割上传, 单独检测网盘文件是否已存在
+	units, splitUnits := partitionSplitUploads(units, opt.SplitSize)
+
+	// 检测网盘文件是否已存在
+	units, rejected := uploadPreflight(pcs, units, opt.OnConflict)
+
 	// 没有添加任何任务
-	if executor.Count() == 0 {
-		fmt.Printf("未检测到上传的文件.\n")
+	if len(units) == 0 && len(splitUnits) == 0 {
+		if len(rejected) == 0 {
+			fmt.Printf("未检测到上传的文件.\n")
+		}
+		printUploadFailed(rejected)
 		return
 	}
 
-	// 执行上传任务
-	executor.Execute()
+	var failedItems []*taskframework.TaskInfoItem
+	if len(units) > 0 {
+		failedItems = executeUploadUnits(units, opt)
+	}
+	splitFailed := runSplitUploads(splitUnits, opt, statistic)
+	if Context().Err() != nil {
+		fmt.Printf("\n上传已取消, 已保存断点信息, 再次执行相同的命令可继续上传\n")
+	}
 
 	fmt.Printf("\n")
 	fmt.Printf("上传结束, 时间: %s, 总大小: %s\n", statistic.Elapsed()/1e6*1e6, converter.ConvertFileSize(statistic.TotalSize()))
 
 	// 输出上传失败的文件列表
-	failedList := executor.FailedDeque()
-	if failedList.Size() != 0 {
-		fmt.Printf("以下文件上传失败: \n")
-		tb := pcstable.NewTable(os.Stdout)
+	failed := rejected
+	for _, item := range failedItems {
+		failed = append(failed, []string{item.Info.Id(), item.Unit.(*pcsupload.UploadTaskUnit).LocalFileChecksum.Path})
+	}
+	failed = append(failed, splitFailed...)
+	printUploadFailed(failed)
+}
+
+// executeUploadUnits 执行上传任务, 小文件和大文件分别排队, 同时上传, 同时上传的文件数不超过 Load, 返回上传失败的任务
+func executeUploadUnits(units []*pcsupload.UploadTaskUnit, opt *UploadOptions) (failed []*taskframework.TaskInfoItem) {
+	var (
+		// 使用 task framework, 小文件和大文件分别排队
+		smallExecutor = &taskframework.TaskExecutor{
+			IsFailedDeque: true, // 失败统计
+		}
+		largeExecutor = &taskframework.TaskExecutor{
+			IsFailedDeque: true,
+		}
+		largeUnits []*pcsupload.UploadTaskUnit
+	)
+	largeExecutor.ShareIdWith(smallExecutor)
+
+	for _, unit := range units {
+		executor := smallExecutor
+		if !isSmallUploadFile(unit.LocalFileChecksum.Path) {
+			executor = largeExecutor
+			largeUnits = append(largeUnits, unit)
+		}
+		info := executor.Append(unit, opt.MaxRetry)
+		fmt.Printf("[%s] 加入上传队列: %s\n", info.Id(), unit.LocalFileChecksum.Path)
+	}
+
+	// 大文件平分单个文件上传的最大线程数
+	largeLoad := opt.Load
+	if largeLoad > len(largeUnits) {
+		largeLoad = len(largeUnits)
+	}
+	for _, unit := range largeUnits {
+		unit.Parallel = pcsconfig.AverageParallel(opt.Parallel, largeLoad)
+	}
+	smallExecutor.SetParallel(opt.Load)
+	largeExecutor.SetParallel(largeLoad)
+	// 小文件和大文件共用同时上传的最大文件数
+	largeExecutor.ShareParallelWith(smallExecutor)
+	smallExecutor.SetRetryPolicy(pcsfunctions.RetryPolicy)
+	largeExecutor.SetRetryPolicy(pcsfunctions.RetryPolicy)
+
+	fmt.Printf("[0] 提示: 当前同时上传最大文件数为: %d, 单个大文件上传最大线程数为: %d\n", opt.Load, pcsconfig.AverageParallel(opt.Parallel, largeLoad))
+
+	// 执行上传任务, 小文件和大文件同时上传
+	var wg sync.WaitGroup
+	for _, executor := range []*taskframework.TaskExecutor{smallExecutor, largeExecutor} {
+		if executor.Count() == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(executor *taskframework.TaskExecutor) {
+			defer wg.Done()
+			executor.ExecuteContext(Context())
+		}(executor)
+	}
+	wg.Wait()
+
+	for _, executor := range []*taskframework.TaskExecutor{smallExecutor, largeExecutor} {
+		failedList := executor.FailedDeque()
+		if failedList == nil {
+			continue
+		}
 		for e := failedList.Shift(); e != nil; e = failedList.Shift() {
-			item := e.(*taskframework.TaskInfoItem)
-			tb.Append([]string{item.Info.Id(), item.Unit.(*pcsupload.UploadTaskUnit).LocalFileChecksum.Path})
+			failed = append(failed, e.(*taskframework.TaskInfoItem))
 		}
-		tb.Render()
 	}
+	return
+}
+
+func printUploadFailed(failed [][]string) {
+	if len(failed) == 0 {
+		return
+	}
+	fmt.Printf("以下文件上传失败: \n")
+	tb := pcstable.NewTable(os.Stdout)
+	tb.AppendBulk(failed)
+	tb.Render()
+}
+
+// uploadPreflight 上传前检测网盘文件是否已存在, 输出各状态的文件数量,
+// 返回按处理方式需要上传的, 以及不上传且视为失败的文件
+func uploadPreflight(pcs *baidupcs.BaiduPCS, units []*pcsupload.UploadTaskUnit, onConflict string) (uploads []*pcsupload.UploadTaskUnit, rejected [][]string) {
+	if len(units) == 0 {
+		return nil, nil
+	}
+
+	var (
+		compare = onConflict == "" || onConflict == pcsupload.OnConflictSkipIdentical
+		checker = pcsupload.NewConflictChecker(pcs, compare)
+		counts  = map[pcsupload.Conflict]int{}
+		actions = map[pcsupload.Conflict]string{}
+	)
+	fmt.Printf("检测网盘文件是否已存在, 文件数量: %d ...\n", len(units))
+	for _, unit := range units {
+		c, err := checker.Check(unit.LocalFileChecksum.Path, unit.SavePath)
+		if err != nil {
+			pcsCommandVerbose.Warn("check conflict failed", "path", unit.SavePath, "err", err)
+		}
+		counts[c]++
+
+		switch {
+		case pcsupload.ShouldUpload(onConflict, c):
+			actions[c] = "上传"
+			uploads = append(uploads, unit)
+		case c == pcsupload.ConflictDir || onConflict == pcsupload.OnConflictFail:
+			actions[c] = "失败"
+			rejected = append(rejected, []string{"-", unit.LocalFileChecksum.Path + " (" + c.String() + ")"})
+		default:
+			actions[c] = "跳过"
+		}
+	}
+
+	tb := pcstable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"网盘文件状态", "数量", "处理"})
+	for c := pcsupload.ConflictNone; c <= pcsupload.ConflictUnknown; c++ {
+		if counts[c] == 0 {
+			continue
+		}
+		tb.Append([]string{c.String(), strconv.Itoa(counts[c]), actions[c]})
+	}
+	tb.Render()
+	return
+}
+
+func uploadPrintFormat(load int) string {
+	if load <= 1 {
+		return pcsupload.DefaultPrintFormat
+	}
+	return "[%s] ↑ %s/%s %s/s in %s ...\n"
+}
+
+// isSmallUploadFile 是否为小文件, 获取文件信息失败的, 按大文件处理
+func isSmallUploadFile(localPath string) bool {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return false
+	}
+	return info.Size() <= SmallUploadFileSize
+}
+
+// runUploadStream 上传数据流到网盘文件 savePath, 数据流的长度未知,
+// 分片暂存到 opt.SpillDir, 边读取边上传
+func runUploadStream(r io.Reader, savePath string, opt *UploadOptions) {
+	pcs := GetBaiduPCS()
+	fd, pcsError := pcs.FilesDirectoriesMeta(savePath)
+	if pcsError == nil && fd.Isdir {
+		fmt.Printf("网盘路径 %s 是目录, 从标准输入上传时, 请指定保存的文件路径\n", savePath)
+		return
+	}
+
+	fmt.Printf("从标准输入上传到: %s\n", savePath)
+	var (
+		statistic = opt.Statistic
+		uploaded  int64
+		mu        sync.Mutex
+	)
+	if statistic == nil {
+		statistic = &pcsupload.UploadStatistic{}
+	}
+	statistic.StartTimer()
+
+	result, err := pcsupload.StreamUpload(pcs, r, savePath, &pcsupload.StreamUploadOptions{
+		SpillDir: opt.SpillDir,
+		Parallel: opt.Parallel,
+		MaxRetry: opt.MaxRetry,
+		BlockUploaded: func(seq int, size int64) {
+			mu.Lock()
+			uploaded += size
+			fmt.Printf("[%d] 分片上传完成, 大小: %s, 已上传: %s\n", seq, converter.ConvertFileSize(size, 2), converter.ConvertFileSize(uploaded, 2))
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		fmt.Printf("上传失败, %s\n", err)
+		return
+	}
+	statistic.AddTotalSize(result.Size)
+
+	fmt.Printf("\n")
+	fmt.Printf("上传结束, 时间: %s, 总大小: %s, 分片数量: %d, md5: %x\n", statistic.Elapsed()/1e6*1e6, converter.ConvertFileSize(result.Size), result.Blocks, result.MD5)
+	fmt.Printf("保存到网盘路径: %s\n", savePath)
 }
@@ -0,0 +1,165 @@
+package pcscommand
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcssplit"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"os"
+	"path"
+)
+
+var (
+	// errSplitUploadExists 网盘文件已存在, 按处理方式上传失败
+	errSplitUploadExists = errors.New("网盘文件已存在")
+	// errSplitUploadNewCopy 分割上传不支持 newcopy
+	errSplitUploadNewCopy = errors.New("分割上传的文件不支持 newcopy, 请使用其他处理方式")
+	// errSplitUploadDir 网盘路径为目录
+	errSplitUploadDir = errors.New("网盘路径为目录")
+)
+
+// partitionSplitUploads 按 splitSize 分出需要分割上传的文件, splitSize 不大于 0 时不分割
+func partitionSplitUploads(units []*pcsupload.UploadTaskUnit, splitSize int64) (normal, split []*pcsupload.UploadTaskUnit) {
+	if splitSize <= 0 {
+		return units, nil
+	}
+	for _, unit := range units {
+		info, err := os.Stat(unit.LocalFileChecksum.Path)
+		if err == nil && info.Size() > splitSize {
+			split = append(split, unit)
+			continue
+		}
+		normal = append(normal, unit)
+	}
+	return
+}
+
+// runSplitUploads 依次分割上传文件, 返回上传失败的文件
+func runSplitUploads(units []*pcsupload.UploadTaskUnit, opt *UploadOptions, statistic *pcsupload.UploadStatistic) (failed [][]string) {
+	if len(units) == 0 {
+		return nil
+	}
+
+	pcs := GetBaiduPCS()
+	fmt.Printf("\n[0] 提示: %d 个文件超过 %s, 将分割为多个部分上传\n", len(units), converter.ConvertFileSize(opt.SplitSize, 2))
+	for _, unit := range units {
+		if pcs.Context().Err() != nil { // 已取消
+			break
+		}
+		err := runSplitUpload(pcs, unit.LocalFileChecksum.Path, unit.SavePath, opt, statistic)
+		if err != nil {
+			fmt.Printf("[分割] 上传文件失败: %s, %s\n", unit.LocalFileChecksum.Path, err)
+			failed = append(failed, []string{"-", unit.LocalFileChecksum.Path + " (" + err.Error() + ")"})
+		}
+	}
+	return
+}
+
+// runSplitUpload 将本地文件分割上传到网盘的 savePath 所在目录, 各部分为 <文件名>.part001 ..., 清单为 <文件名>.pcssplit.json.
+// 网盘文件或清单已存在时, 按 opt.OnConflict 处理, 内容相同的判断依据为清单记录的 md5
+func runSplitUpload(pcs *baidupcs.BaiduPCS, localPath, savePath string, opt *UploadOptions, statistic *pcsupload.UploadStatistic) error {
+	var (
+		dir, name    = path.Dir(savePath), path.Base(savePath)
+		manifestPath = savePath + pcssplit.ManifestSuffix
+		oldManifest  *pcssplit.Manifest
+	)
+	fmt.Printf("[分割] 准备上传: %s\n", localPath)
+
+	// 检测网盘文件是否已存在, 普通文件或分割上传的清单
+	plainFd, plainErr := pcs.FilesDirectoriesMeta(savePath)
+	if plainErr == nil && plainFd.Isdir {
+		return errSplitUploadDir
+	}
+	manifestFd, manifestErr := pcs.FilesDirectoriesMeta(manifestPath)
+	if plainErr == nil || manifestErr == nil {
+		switch opt.OnConflict {
+		case pcsupload.OnConflictSkip:
+			fmt.Printf("[分割] 目标文件, %s, 已存在, 跳过...\n", savePath)
+			return nil
+		case pcsupload.OnConflictFail:
+			return errSplitUploadExists
+		case pcsupload.OnConflictNewCopy:
+			return errSplitUploadNewCopy
+		}
+	}
+	if manifestErr == nil && !manifestFd.Isdir {
+		var err error
+		oldManifest, err = pcsdownload.GetSplitManifest(pcs, manifestPath, manifestFd.Size, nil)
+		if err != nil {
+			pcsCommandVerbose.Warn("get split manifest failed", "path", manifestPath, "err", err)
+		}
+	}
+	if plainErr != nil && oldManifest != nil && (opt.OnConflict == "" || opt.OnConflict == pcsupload.OnConflictSkipIdentical) {
+		lfc, err := checksum.GetFileSumWithCache(pcsfunctions.ChecksumCache(), localPath, checksum.CHECKSUM_MD5)
+		if err == nil && lfc.Length == oldManifest.Size && hex.EncodeToString(lfc.MD5) == oldManifest.MD5 {
+			fmt.Printf("[分割] 目标文件, %s, 已存在, 内容相同, 跳过...\n", savePath)
+			return nil
+		}
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[分割] 计算各部分的秒传信息, 请稍候...\n")
+	m, err := pcssplit.Sum(f, name, info.Size(), opt.SplitSize)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[分割] 文件大小: %s, 分为 %d 个部分, md5: %s\n", converter.ConvertFileSize(m.Size, 2), len(m.Parts), m.MD5)
+
+	err = pcsupload.SplitUpload(pcs, f, m, dir, &pcsupload.SplitUploadOptions{
+		Parallel:      opt.Parallel,
+		MaxRetry:      opt.MaxRetry,
+		NoRapidUpload: opt.NoRapidUpload,
+		PartUploaded: func(seq int, part *pcssplit.Part, rapid bool) {
+			how := "上传完成"
+			if rapid {
+				how = "秒传成功"
+			}
+			fmt.Printf("[分割] [%d/%d] %s: %s, 大小: %s\n", seq+1, len(m.Parts), how, part.Name, converter.ConvertFileSize(part.Size, 2))
+		},
+	})
+	if err != nil {
+		return err
+	}
+	statistic.AddTotalSize(m.Size)
+
+	// 删除旧的普通文件和旧清单中多余的部分, 避免下载时读取到旧的内容
+	var stale []string
+	if plainErr == nil {
+		stale = append(stale, savePath)
+	}
+	if oldManifest != nil {
+		current := make(map[string]bool, len(m.Parts))
+		for _, part := range m.Parts {
+			current[part.Name] = true
+		}
+		for _, part := range oldManifest.Parts {
+			if !current[part.Name] {
+				stale = append(stale, path.Join(dir, part.Name))
+			}
+		}
+	}
+	if len(stale) > 0 {
+		pcsError := pcs.Remove(stale...)
+		if pcsError != nil {
+			fmt.Printf("[分割] 警告, 删除旧的文件失败, %s\n", pcsError)
+		}
+	}
+
+	fmt.Printf("[分割] 上传文件成功, 保存到网盘路径: %s, 清单: %s\n", savePath, manifestPath)
+	return nil
+}
@@ -0,0 +1,113 @@
+package pcscommand
+
+import (
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/felixonmars/BaiduPCS-Go/pcstable"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/pcstime"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// uploadingStatus 未完成上传的本地文件状态
+func uploadingStatus(uploading *pcsupload.Uploading) string {
+	info, err := os.Stat(uploading.Path)
+	switch {
+	case err != nil:
+		if uploading.Fingerprint != "" {
+			return "文件不存在, 移动后可续传"
+		}
+		return "文件不存在"
+	case info.Size() != uploading.Length:
+		return "文件大小已改变"
+	case info.ModTime().Unix() != uploading.ModTime:
+		return "已修改, 续传时校验分片"
+	}
+	return "可续传"
+}
+
+// RunUploadStatus 列出未完成的上传, discard 为要放弃的序号, 为 all 时放弃全部
+func RunUploadStatus(discard []string) {
+	uploadDatabase, err := pcsupload.NewUploadingDatabase()
+	if err != nil {
+		fmt.Printf("打开上传未完成数据库错误: %s\n", err)
+		return
+	}
+	defer uploadDatabase.Close()
+
+	if len(discard) > 0 {
+		runUploadDiscard(uploadDatabase, discard)
+		return
+	}
+
+	list := uploadDatabase.List()
+	if len(list) == 0 {
+		fmt.Printf("没有未完成的上传\n")
+		return
+	}
+
+	tb := pcstable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "本地路径", "网盘路径", "已上传", "更新时间", "状态"})
+	for k, uploading := range list {
+		if uploading.LocalFileMeta == nil {
+			continue
+		}
+		updated := "-"
+		if uploading.Timestamp > 0 {
+			updated = pcstime.FormatTime(uploading.Timestamp)
+		}
+		tb.Append([]string{
+			strconv.Itoa(k),
+			uploading.Path,
+			uploading.SavePath,
+			converter.ConvertFileSize(uploading.Uploaded(), 2) + "/" + converter.ConvertFileSize(uploading.Length, 2),
+			updated,
+			uploadingStatus(uploading),
+		})
+	}
+	tb.Render()
+}
+
+func runUploadDiscard(uploadDatabase *pcsupload.UploadingDatabase, discard []string) {
+	var indexes []int
+	for _, s := range discard {
+		if s == "all" {
+			n := len(uploadDatabase.List())
+			indexes = indexes[:0]
+			for k := 0; k < n; k++ {
+				indexes = append(indexes, k)
+			}
+			break
+		}
+
+		k, err := strconv.Atoi(s)
+		if err != nil {
+			fmt.Printf("序号 %s 解析失败\n", s)
+			return
+		}
+		indexes = append(indexes, k)
+	}
+
+	// 从后往前删除, 序号不变
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	var discarded int
+	for i, k := range indexes {
+		if i > 0 && k == indexes[i-1] {
+			continue
+		}
+		if !uploadDatabase.DeleteIndex(k) {
+			fmt.Printf("序号 %d 不存在\n", k)
+			continue
+		}
+		discarded++
+	}
+
+	err := uploadDatabase.Save()
+	if err != nil {
+		fmt.Printf("保存上传未完成数据库错误: %s\n", err)
+		return
+	}
+	fmt.Printf("已放弃 %d 个未完成的上传\n", discarded)
+}
@@ -0,0 +1,463 @@
+package pcscommand
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/watcher"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultWatchSettle 默认的文件稳定时间, 文件大小和修改时间在此时间内不变, 才开始上传
+	DefaultWatchSettle = 10 * time.Second
+	// MaxWatchFailures 文件连续上传失败的最大次数, 超过后, 文件再次改变时才重新上传
+	MaxWatchFailures = 3
+)
+
+type (
+	// WatchOptions 监听上传可选项
+	WatchOptions struct {
+		UploadOptions
+		Settle       time.Duration // 文件稳定时间
+		PollInterval time.Duration // 不支持 inotify 时, 重新扫描目录的间隔
+		Delete       bool          // 上传并校验成功后, 删除本地文件
+		MoveTo       string        // 上传并校验成功后, 将本地文件移动到此目录
+		Exclude      []string      // 忽略的文件名通配符
+	}
+
+	// watchSnapshot 文件的大小和修改时间
+	watchSnapshot struct {
+		size  int64
+		mtime int64
+	}
+
+	watchPending struct {
+		watchSnapshot
+		changed time.Time // 最后一次变化的时间
+	}
+
+	watchResult struct {
+		localPath string
+		watchSnapshot
+		ok bool
+	}
+
+	// watchState 监听上传的状态, 只在主循环中访问
+	watchState struct {
+		root     string
+		opt      *WatchOptions
+		pending  map[string]*watchPending
+		inflight map[string]bool
+		known    map[string]watchSnapshot // 已上传或放弃上传的文件
+		failures map[string]int
+	}
+)
+
+func statWatchSnapshot(localPath string) (watchSnapshot, bool) {
+	info, err := os.Stat(localPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return watchSnapshot{}, false
+	}
+	return watchSnapshot{
+		size:  info.Size(),
+		mtime: info.ModTime().UnixNano(),
+	}, true
+}
+
+// ignored 是否忽略文件
+func (ws *watchState) ignored(localPath string) bool {
+	if ws.opt.MoveTo != "" && strings.HasPrefix(localPath, ws.opt.MoveTo+string(os.PathSeparator)) {
+		return true
+	}
+	name := filepath.Base(localPath)
+	for _, pattern := range ws.opt.Exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// touch 文件可能发生了变化
+func (ws *watchState) touch(localPath string, now time.Time) {
+	if ws.inflight[localPath] || ws.ignored(localPath) {
+		return
+	}
+	snap, ok := statWatchSnapshot(localPath)
+	if !ok {
+		delete(ws.pending, localPath)
+		return
+	}
+	if known, ok := ws.known[localPath]; ok && known == snap {
+		return
+	}
+
+	p := ws.pending[localPath]
+	if p == nil {
+		ws.pending[localPath] = &watchPending{
+			watchSnapshot: snap,
+			changed:       now,
+		}
+		return
+	}
+	if p.watchSnapshot != snap {
+		p.watchSnapshot = snap
+		p.changed = now
+	}
+}
+
+// rescan 重新扫描目录, 找出未上传或已改变的文件
+func (ws *watchState) rescan(now time.Time) {
+	err := filepath.Walk(ws.root, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			pcsCommandVerbose.Warn("watch rescan", "path", localPath, "err", err)
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			ws.touch(localPath, now)
+		}
+		return nil
+	})
+	if err != nil {
+		pcsCommandVerbose.Warn("watch rescan", "err", err)
+	}
+}
+
+// stable 返回已稳定的文件, 并标记为上传中
+func (ws *watchState) stable(now time.Time) (files []string) {
+	for localPath, p := range ws.pending {
+		snap, ok := statWatchSnapshot(localPath)
+		if !ok {
+			delete(ws.pending, localPath)
+			continue
+		}
+		if snap != p.watchSnapshot {
+			p.watchSnapshot = snap
+			p.changed = now
+			continue
+		}
+		if now.Sub(p.changed) < ws.opt.Settle {
+			continue
+		}
+
+		files = append(files, localPath)
+		ws.inflight[localPath] = true
+		delete(ws.pending, localPath)
+	}
+	return
+}
+
+// done 处理上传结果
+func (ws *watchState) done(res *watchResult, now time.Time) {
+	delete(ws.inflight, res.localPath)
+	if res.ok {
+		ws.known[res.localPath] = res.watchSnapshot
+		delete(ws.failures, res.localPath)
+		return
+	}
+
+	ws.failures[res.localPath]++
+	if ws.failures[res.localPath] >= MaxWatchFailures {
+		fmt.Printf("文件 %s 连续上传失败 %d 次, 文件改变后再重新上传\n", res.localPath, ws.failures[res.localPath])
+		ws.known[res.localPath] = res.watchSnapshot
+		delete(ws.failures, res.localPath)
+		return
+	}
+	ws.touch(res.localPath, now)
+}
+
+// RunWatch 执行 监听本地目录, 将新增和改变的文件上传到网盘目录 savePath
+func RunWatch(localDir, savePath string, opt *WatchOptions) {
+	if opt == nil {
+		opt = &WatchOptions{}
+	}
+	if opt.Settle <= 0 {
+		opt.Settle = DefaultWatchSettle
+	}
+	if opt.Parallel <= 0 {
+		opt.Parallel = pcsconfig.Config.MaxUploadParallel
+	}
+	if opt.Load <= 0 {
+		opt.Load = pcsconfig.Config.MaxUploadLoad
+	}
+	if opt.MaxRetry < 0 {
+		opt.MaxRetry = DefaultUploadMaxRetry
+	}
+	err := pcsupload.CheckOnConflict(opt.OnConflict)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	root, err := filepath.Abs(localDir)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if opt.MoveTo != "" {
+		opt.MoveTo, err = filepath.Abs(opt.MoveTo)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	err = matchPathByShellPatternOnce(&savePath)
+	if err != nil {
+		fmt.Printf("警告: 获取网盘路径 %s 错误, %s\n", savePath, err)
+	}
+
+	w, err := watcher.New(root, opt.PollInterval)
+	if err != nil {
+		fmt.Printf("监听目录 %s 失败, %s\n", root, err)
+		return
+	}
+	defer w.Close()
+
+	uploadDatabase, err := pcsupload.NewUploadingDatabase()
+	if err != nil {
+		fmt.Printf("打开上传未完成数据库错误: %s\n", err)
+		return
+	}
+	defer uploadDatabase.Close()
+
+	var (
+		ws = &watchState{
+			root:     root,
+			opt:      opt,
+			pending:  map[string]*watchPending{},
+			inflight: map[string]bool{},
+			known:    map[string]watchSnapshot{},
+			failures: map[string]int{},
+		}
+		batchChan  = make(chan []string)
+		resultChan = make(chan *watchResult, 64)
+		uploadDone = make(chan struct{})
+		ticker     = time.NewTicker(time.Second)
+		queue      []string
+	)
+	defer ticker.Stop()
+
+	go func() {
+		defer close(uploadDone)
+		for files := range batchChan {
+			for _, res := range runWatchUpload(root, savePath, files, uploadDatabase, opt) {
+				resultChan <- res
+			}
+		}
+	}()
+
+	fmt.Printf("开始监听目录: %s, 上传到网盘目录: %s\n", root, savePath)
+
+	// 启动时重新扫描, 上传监听之前的文件
+	ws.rescan(time.Now())
+	for {
+		var (
+			sendChan chan []string
+			batch    []string
+		)
+		if len(queue) > 0 {
+			sendChan, batch = batchChan, queue
+		}
+
+		select {
+		case ev := <-w.Events:
+			if ev.Rescan {
+				ws.rescan(time.Now())
+				continue
+			}
+			ws.touch(ev.Path, time.Now())
+		case err := <-w.Errors:
+			pcsCommandVerbose.Warn("watch", "err", err)
+		case now := <-ticker.C:
+			queue = append(queue, ws.stable(now)...)
+		case sendChan <- batch:
+			queue = nil
+		case res := <-resultChan:
+			ws.done(res, time.Now())
+		case <-Context().Done():
+			// 停止上传, 等待进行中的上传保存断点信息
+			close(batchChan)
+			for {
+				select {
+				case <-resultChan:
+				case <-uploadDone:
+					fmt.Printf("\n已停止监听, 未完成的上传已保存断点信息\n")
+					return
+				}
+			}
+		}
+	}
+}
+
+// runWatchUpload 上传一批文件, 并校验上传结果, 按设置删除或移动本地文件
+func runWatchUpload(root, savePath string, files []string, uploadDatabase *pcsupload.UploadingDatabase, opt *WatchOptions) (results []*watchResult) {
+	var (
+		pcs       = GetBaiduPCS()
+		units     = make([]*pcsupload.UploadTaskUnit, 0, len(files))
+		snapshots = make(map[string]watchSnapshot, len(files))
+		statistic = &pcsupload.UploadStatistic{}
+	)
+	statistic.StartTimer()
+	for _, localPath := range files {
+		snap, ok := statWatchSnapshot(localPath)
+		if !ok {
+			results = append(results, &watchResult{localPath: localPath})
+			continue
+		}
+		snapshots[localPath] = snap
+
+		rel, err := filepath.Rel(root, localPath)
+		if err != nil {
+			results = append(results, &watchResult{localPath: localPath, watchSnapshot: snap})
+			continue
+		}
+		units = append(units, &pcsupload.UploadTaskUnit{
+			LocalFileChecksum: checksum.NewLocalFileChecksum(localPath, int(baidupcs.SliceMD5Size)),
+			SavePath:          path.Join(savePath, filepath.ToSlash(rel)),
+			PCS:               pcs,
+			UploadingDatabase: uploadDatabase,
+			Parallel:          1,
+			NoRapidUpload:     opt.NoRapidUpload,
+			NoSplitFile:       opt.NoSplitFile,
+			OnConflict:        opt.OnConflict,
+			UploadStatistic:   statistic,
+			PrintFormat:       uploadPrintFormat(opt.Load),
+		})
+	}
+	if len(units) == 0 {
+		return
+	}
+
+	failed := map[string]bool{}
+	for _, item := range executeUploadUnits(units, &opt.UploadOptions) {
+		failed[item.Unit.(*pcsupload.UploadTaskUnit).LocalFileChecksum.Path] = true
+	}
+	if Context().Err() != nil {
+		// 已取消, 未执行的上传留在队列中, 不校验, 也不删除或移动本地文件
+		for _, unit := range units {
+			localPath := unit.LocalFileChecksum.Path
+			results = append(results, &watchResult{localPath: localPath, watchSnapshot: snapshots[localPath]})
+		}
+		return
+	}
+
+	for _, unit := range units {
+		localPath := unit.LocalFileChecksum.Path
+		res := &watchResult{
+			localPath:     localPath,
+			watchSnapshot: snapshots[localPath],
+		}
+		results = append(results, res)
+		if failed[localPath] {
+			continue
+		}
+
+		err := verifyWatchUpload(pcs, localPath, unit.SavePath, res.watchSnapshot)
+		if err != nil {
+			fmt.Printf("校验上传失败: %s, %s\n", localPath, err)
+			continue
+		}
+		res.ok = true
+		if !opt.Delete && opt.MoveTo == "" {
+			continue
+		}
+
+		// 删除或移动前, 确认网盘文件的内容与本地文件相同, 无法确认时保留本地文件
+		err = verifyWatchContent(pcs, localPath, unit.SavePath, res.watchSnapshot)
+		if err != nil {
+			fmt.Printf("无法确认网盘文件的内容, 保留本地文件: %s, %s\n", localPath, err)
+			continue
+		}
+
+		switch {
+		case opt.Delete:
+			err = os.Remove(localPath)
+			if err != nil {
+				fmt.Printf("删除本地文件失败: %s, %s\n", localPath, err)
+				continue
+			}
+			fmt.Printf("已删除本地文件: %s\n", localPath)
+		case opt.MoveTo != "":
+			rel, _ := filepath.Rel(root, localPath)
+			target := filepath.Join(opt.MoveTo, rel)
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err == nil {
+				err = os.Rename(localPath, target)
+			}
+			if err != nil {
+				fmt.Printf("移动本地文件失败: %s, %s\n", localPath, err)
+				continue
+			}
+			fmt.Printf("已移动本地文件: %s -> %s\n", localPath, target)
+		}
+	}
+	return
+}
+
+// verifyWatchUpload 校验网盘文件的大小, 以及本地文件在上传过程中未改变
+func verifyWatchUpload(pcs *baidupcs.BaiduPCS, localPath, savePath string, snap watchSnapshot) error {
+	fd, pcsError := pcs.FilesDirectoriesMeta(savePath)
+	if pcsError != nil {
+		return pcsError
+	}
+	if fd.Isdir || fd.Size != snap.size {
+		return fmt.Errorf("网盘文件大小不一致, 本地: %d, 网盘: %d", snap.size, fd.Size)
+	}
+	current, ok := statWatchSnapshot(localPath)
+	if !ok || current != snap {
+		return fmt.Errorf("本地文件在上传过程中被修改")
+	}
+	return nil
+}
+
+// verifyWatchContent 校验网盘文件的内容与本地文件相同.
+// 网盘记录的 md5 不一致时 (分片上传的文件, 网盘记录的 md5 可能不是文件内容的 md5),
+// 以本地文件的 md5 和 slice-md5 秒传到网盘路径, 秒传成功则网盘文件的内容与本地文件相同
+func verifyWatchContent(pcs *baidupcs.BaiduPCS, localPath, savePath string, snap watchSnapshot) error {
+	lfc := checksum.NewLocalFileChecksum(localPath, int(baidupcs.SliceMD5Size))
+	err := lfc.OpenPath()
+	if err != nil {
+		return err
+	}
+	defer lfc.Close()
+	err = lfc.SumWithCache(pcsfunctions.ChecksumCache(), checksum.CHECKSUM_MD5|checksum.CHECKSUM_SLICE_MD5)
+	if err != nil {
+		return err
+	}
+
+	// 计算过程中文件被修改
+	current, ok := statWatchSnapshot(localPath)
+	if !ok || current != snap || lfc.Length != snap.size {
+		return fmt.Errorf("本地文件在校验过程中被修改")
+	}
+
+	fd, pcsError := pcs.FilesDirectoriesMeta(savePath)
+	if pcsError != nil {
+		return pcsError
+	}
+	if fd.Isdir || fd.Size != lfc.Length {
+		return fmt.Errorf("网盘文件大小不一致, 本地: %d, 网盘: %d", lfc.Length, fd.Size)
+	}
+	if lfc.Length == 0 || strings.EqualFold(fd.MD5, hex.EncodeToString(lfc.MD5)) {
+		return nil
+	}
+
+	if lfc.Length > baidupcs.MaxRapidUploadSize {
+		return fmt.Errorf("网盘文件的 md5 不一致, 文件超过20GB, 无法使用秒传校验")
+	}
+	pcsError = pcs.RapidUpload(savePath, baidupcs.OndupOverwrite, hex.EncodeToString(lfc.MD5), hex.EncodeToString(lfc.SliceMD5), fmt.Sprint(lfc.CRC32), lfc.Length)
+	if pcsError != nil {
+		return fmt.Errorf("网盘文件的 md5 不一致, 秒传校验失败, %s", pcsError)
+	}
+	return nil
+}
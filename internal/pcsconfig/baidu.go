@@ -47,6 +47,8 @@ func (baidu *Baidu) BaiduPCS() *baidupcs.BaiduPCS {
 	pcs.SetHTTPS(Config.EnableHTTPS)
 	pcs.SetPCSUserAgent(Config.PCSUA)
 	pcs.SetPanUserAgent(Config.PanUA)
+	pcs.SetPCSProxy(Config.ProxyPCS)
+	pcs.SetPanProxy(Config.ProxyPan)
 	pcs.SetUID(baidu.UID)
 	return pcs
 }
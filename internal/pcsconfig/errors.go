@@ -15,4 +15,6 @@ var (
 	ErrConfigFileNoPermission = errors.New("config file permission denied")
 	//ErrConfigContentsParseError 解析Config数据错误
 	ErrConfigContentsParseError = errors.New("config contents parse error")
+	//ErrSplitSizeTooSmall 分割上传的大小过小
+	ErrSplitSizeTooSmall = errors.New("split size must be 0 or at least 4MB")
 )
@@ -49,6 +49,20 @@ func (c *PCSConfig) PanHTTPClient() *requester.HTTPClient {
 	return c.httpClientWithUA(c.PanUA)
 }
 
+// DownloadHTTPClient 返回设置好下载代理的 HTTPClient, ua 为浏览器标识
+func (c *PCSConfig) DownloadHTTPClient(ua string) *requester.HTTPClient {
+	client := c.httpClientWithUA(ua)
+	client.SetProxy(c.ProxyDownload)
+	return client
+}
+
+// UploadHTTPClient 返回设置好上传代理的 HTTPClient, ua 为浏览器标识
+func (c *PCSConfig) UploadHTTPClient(ua string) *requester.HTTPClient {
+	client := c.httpClientWithUA(ua)
+	client.SetProxy(c.ProxyUpload)
+	return client
+}
+
 // NumLogins 获取登录的用户数量
 func (c *PCSConfig) NumLogins() int {
 	return len(c.BaiduUserList)
@@ -71,15 +85,31 @@ func (c *PCSConfig) PrintTable() {
 		[]string{"max_parallel", strconv.Itoa(c.MaxParallel), "1 ~ 64", "下载最大并发量"},
 		[]string{"max_upload_parallel", strconv.Itoa(c.MaxUploadParallel), "1 ~ 100", "上传最大并发量"},
 		[]string{"max_download_load", strconv.Itoa(c.MaxDownloadLoad), "1 ~ 5", "同时进行下载文件的最大数量"},
+		[]string{"max_upload_load", strconv.Itoa(c.MaxUploadLoad), "1 ~ 32", "同时进行上传文件的最大数量, 小文件同时上传, 大文件平分 max_upload_parallel"},
+		[]string{"adaptive_parallel", fmt.Sprint(c.AdaptiveParallel), "", "根据下载速度, 错误率和服务器限流, 自动调整下载并发量, 在 min_parallel 和 max_parallel 之间调整"},
+		[]string{"min_parallel", strconv.Itoa(c.MinParallel), "1 ~ 8", "自适应下载并发量的最小值"},
+		[]string{"direct_io", fmt.Sprint(c.DirectIO), "", "下载写入文件时使用 O_DIRECT, 绕过系统缓存, 只支持 linux, 适合向机械硬盘写入超大文件"},
+		[]string{"write_block_size", converter.ConvertFileSize(int64(c.WriteBlockSize), 2), "0, 1MB ~ 16MB", "下载写入文件时, 将零散的写入合并为按区块对齐的大块写入, 0 为不合并"},
 		[]string{"max_download_rate", showMaxRate(c.MaxDownloadRate), "", "限制最大下载速度, 0代表不限制"},
 		[]string{"max_upload_rate", showMaxRate(c.MaxUploadRate), "", "限制最大上传速度, 0代表不限制"},
+		[]string{"split_size", showSplitSize(c.SplitSize), "4GB", "上传时超过此大小的文件分割为多个部分上传, 下载时自动合并, 0 为不分割"},
 		[]string{"savedir", c.SaveDir, "", "下载文件的储存目录"},
 		[]string{"enable_https", fmt.Sprint(c.EnableHTTPS), "true", "启用 https"},
 		[]string{"user_agent", c.UserAgent, requester.DefaultUserAgent, "浏览器标识"},
 		[]string{"pcs_ua", c.PCSUA, "", "PCS 浏览器标识"},
 		[]string{"pan_ua", c.PanUA, baidupcs.NetdiskUA, "Pan 浏览器标识"},
 		[]string{"proxy", c.Proxy, "", "设置代理, 支持 http/socks5 代理"},
+		[]string{"proxy_pcs", c.ProxyPCS, "", "PCS API 请求使用的代理, 为空则使用 proxy"},
+		[]string{"proxy_pan", c.ProxyPan, "", "Pan API 请求使用的代理, 为空则使用 proxy"},
+		[]string{"proxy_download", c.ProxyDownload, "", "下载使用的代理, 为空则使用 proxy"},
+		[]string{"proxy_upload", c.ProxyUpload, "", "上传使用的代理, 为空则使用 proxy"},
+		[]string{"proxy_rules", c.ProxyRules, "", "按主机名匹配的代理规则, 优先级最高, 多条规则用分号隔开"},
 		[]string{"local_addrs", c.LocalAddrs, "", "设置本地网卡地址, 多个地址用逗号隔开"},
+		[]string{"log_level", c.LogLevel, "info", "日志等级, 可选 debug, info, warn, error, off, 可按模块设置, 如 info,BAIDUPCS=debug"},
+		[]string{"log_format", c.LogFormat, "text", "日志格式, 可选 text, json, logfmt"},
+		[]string{"log_max_size", converter.ConvertFileSize(c.LogMaxSize, 2), "", "单个日志文件的最大大小, 超过后轮转"},
+		[]string{"log_max_backups", strconv.Itoa(c.LogMaxBackups), "", "保留的旧日志文件数量"},
+		[]string{"metrics_addr", c.MetricsAddr, "", "Prometheus 监控指标的监听地址, 如 127.0.0.1:9090, 为空则不启用"},
 	})
 	tb.Render()
 }
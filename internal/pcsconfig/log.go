@@ -0,0 +1,75 @@
+package pcsconfig
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"path/filepath"
+)
+
+// LogFilePath 返回日志文件路径
+func (c *PCSConfig) LogFilePath() string {
+	return filepath.Join(filepath.Dir(c.configFilePath), LogName)
+}
+
+// initLog 按配置设置日志等级, 格式, 以及轮转的日志文件
+func (c *PCSConfig) initLog() {
+	err := pcslog.SetLevelSpec(c.LogLevel)
+	if err != nil {
+		pcsConfigVerbose.Warnf("parse log_level error: %s\n", err)
+	}
+
+	format, err := pcslog.ParseFormat(c.LogFormat)
+	if err != nil {
+		pcsConfigVerbose.Warnf("parse log_format error: %s\n", err)
+	}
+	pcslog.SetFormat(format)
+
+	if c.logWriter == nil {
+		c.logWriter = pcslog.NewRotateWriter(c.LogFilePath(), c.LogMaxSize, c.LogMaxBackups)
+		pcslog.SetFileOutput(c.logWriter)
+		return
+	}
+	c.logWriter.SetLimit(c.LogMaxSize, c.LogMaxBackups)
+}
+
+// SetLogLevel 设置日志等级, 例如: info,BAIDUPCS=debug
+func (c *PCSConfig) SetLogLevel(spec string) error {
+	err := pcslog.SetLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+	c.LogLevel = spec
+	return nil
+}
+
+// SetLogFormat 设置日志格式
+func (c *PCSConfig) SetLogFormat(formatStr string) error {
+	format, err := pcslog.ParseFormat(formatStr)
+	if err != nil {
+		return err
+	}
+	c.LogFormat = string(format)
+	pcslog.SetFormat(format)
+	return nil
+}
+
+// SetLogMaxSizeByStr 设置 log_max_size
+func (c *PCSConfig) SetLogMaxSizeByStr(sizeStr string) error {
+	size, err := converter.ParseFileSizeStr(sizeStr)
+	if err != nil {
+		return err
+	}
+	c.LogMaxSize = size
+	if c.logWriter != nil {
+		c.logWriter.SetLimit(c.LogMaxSize, c.LogMaxBackups)
+	}
+	return nil
+}
+
+// SetLogMaxBackups 设置 log_max_backups
+func (c *PCSConfig) SetLogMaxBackups(n int) {
+	c.LogMaxBackups = n
+	if c.logWriter != nil {
+		c.logWriter.SetLimit(c.LogMaxSize, c.LogMaxBackups)
+	}
+}
@@ -1,6 +1,7 @@
 package pcsconfig
 
 import (
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"strings"
@@ -151,6 +152,16 @@ func (c *PCSConfig) SetCacheSizeByStr(sizeStr string) error {
 	return nil
 }
 
+// SetWriteBlockSizeByStr 设置 write_block_size
+func (c *PCSConfig) SetWriteBlockSizeByStr(sizeStr string) error {
+	size, err := converter.ParseFileSizeStr(sizeStr)
+	if err != nil {
+		return err
+	}
+	c.WriteBlockSize = int(size)
+	return nil
+}
+
 // SetMaxDownloadRateByStr 设置 max_download_rate
 func (c *PCSConfig) SetMaxDownloadRateByStr(sizeStr string) error {
 	size, err := converter.ParseFileSizeStr(stripPerSecond(sizeStr))
@@ -171,6 +182,19 @@ func (c *PCSConfig) SetMaxUploadRateByStr(sizeStr string) error {
 	return nil
 }
 
+// SetSplitSizeByStr 设置 split_size
+func (c *PCSConfig) SetSplitSizeByStr(sizeStr string) error {
+	size, err := converter.ParseFileSizeStr(sizeStr)
+	if err != nil {
+		return err
+	}
+	if size != 0 && size < baidupcs.MinUploadBlockSize {
+		return ErrSplitSizeTooSmall
+	}
+	c.SplitSize = size
+	return nil
+}
+
 // SetUserAgent 设置User-Agent
 func (c *PCSConfig) SetUserAgent(userAgent string) {
 	c.UserAgent = userAgent
@@ -202,9 +226,70 @@ func (c *PCSConfig) SetEnableHTTPS(https bool) {
 }
 
 // SetProxy 设置代理
-func (c *PCSConfig) SetProxy(proxy string) {
+func (c *PCSConfig) SetProxy(proxy string) error {
+	err := requester.CheckProxyAddr(proxy)
+	if err != nil {
+		return err
+	}
 	c.Proxy = proxy
 	requester.SetGlobalProxy(proxy)
+	return nil
+}
+
+// SetProxyPCS 设置 PCS API 请求使用的代理
+func (c *PCSConfig) SetProxyPCS(proxy string) error {
+	err := requester.CheckProxyAddr(proxy)
+	if err != nil {
+		return err
+	}
+	c.ProxyPCS = proxy
+	if c.pcs != nil {
+		c.pcs.SetPCSProxy(proxy)
+	}
+	return nil
+}
+
+// SetProxyPan 设置 Pan API 请求使用的代理
+func (c *PCSConfig) SetProxyPan(proxy string) error {
+	err := requester.CheckProxyAddr(proxy)
+	if err != nil {
+		return err
+	}
+	c.ProxyPan = proxy
+	if c.pcs != nil {
+		c.pcs.SetPanProxy(proxy)
+	}
+	return nil
+}
+
+// SetProxyDownload 设置下载使用的代理
+func (c *PCSConfig) SetProxyDownload(proxy string) error {
+	err := requester.CheckProxyAddr(proxy)
+	if err != nil {
+		return err
+	}
+	c.ProxyDownload = proxy
+	return nil
+}
+
+// SetProxyUpload 设置上传使用的代理
+func (c *PCSConfig) SetProxyUpload(proxy string) error {
+	err := requester.CheckProxyAddr(proxy)
+	if err != nil {
+		return err
+	}
+	c.ProxyUpload = proxy
+	return nil
+}
+
+// SetProxyRules 设置代理规则
+func (c *PCSConfig) SetProxyRules(rules string) error {
+	err := requester.SetGlobalProxyRules(rules)
+	if err != nil {
+		return err
+	}
+	c.ProxyRules = rules
+	return nil
 }
 
 // SetLocalAddrs 设置localAddrs
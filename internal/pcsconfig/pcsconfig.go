@@ -3,9 +3,10 @@ package pcsconfig
 
 import (
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/jsonhelper"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"github.com/json-iterator/go"
 	"os"
@@ -20,10 +21,12 @@ const (
 	EnvConfigDir = "BAIDUPCS_GO_CONFIG_DIR"
 	// ConfigName 配置文件名
 	ConfigName = "pcs_config.json"
+	// LogName 日志文件名, 储存于配置目录
+	LogName = "pcs_log.log"
 )
 
 var (
-	pcsConfigVerbose = pcsverbose.New("PCSCONFIG")
+	pcsConfigVerbose = pcslog.New("PCSCONFIG")
 	configFilePath   = filepath.Join(GetConfigDir(), ConfigName)
 
 	// Config 配置信息, 由外部调用
@@ -41,10 +44,19 @@ type PCSConfig struct {
 	MaxParallel       int `json:"max_parallel"`        // 最大下载并发量
 	MaxUploadParallel int `json:"max_upload_parallel"` // 最大上传并发量
 	MaxDownloadLoad   int `json:"max_download_load"`   // 同时进行下载文件的最大数量
+	MaxUploadLoad     int `json:"max_upload_load"`     // 同时进行上传文件的最大数量
+
+	AdaptiveParallel bool `json:"adaptive_parallel"` // 自适应下载并发量
+	MinParallel      int  `json:"min_parallel"`      // 自适应下载并发量的最小值
+
+	DirectIO       bool `json:"direct_io"`        // 下载写入文件时使用 O_DIRECT
+	WriteBlockSize int  `json:"write_block_size"` // 下载写入文件时合并写入的区块大小, 0 为不合并
 
 	MaxDownloadRate int64 `json:"max_download_rate"` // 限制最大下载速度
 	MaxUploadRate   int64 `json:"max_upload_rate"`   // 限制最大上传速度
 
+	SplitSize int64 `json:"split_size"` // 上传时超过此大小的文件分割为多个部分上传, 0 为不分割
+
 	UserAgent   string `json:"user_agent"`   // 浏览器标识
 	PCSUA       string `json:"pcs_ua"`       // PCS浏览器标识
 	PanUA       string `json:"pan_ua"`       // PAN浏览器标识
@@ -53,11 +65,25 @@ type PCSConfig struct {
 	Proxy       string `json:"proxy"`        // 代理
 	LocalAddrs  string `json:"local_addrs"`  // 本地网卡地址
 
+	ProxyPCS      string `json:"proxy_pcs"`      // PCS API 请求使用的代理
+	ProxyPan      string `json:"proxy_pan"`      // Pan API 请求使用的代理
+	ProxyDownload string `json:"proxy_download"` // 下载使用的代理
+	ProxyUpload   string `json:"proxy_upload"`   // 上传使用的代理
+	ProxyRules    string `json:"proxy_rules"`    // 按主机名匹配的代理规则
+
+	LogLevel      string `json:"log_level"`       // 日志等级, 可按模块设置
+	LogFormat     string `json:"log_format"`      // 日志格式
+	LogMaxSize    int64  `json:"log_max_size"`    // 单个日志文件的最大大小
+	LogMaxBackups int    `json:"log_max_backups"` // 保留的旧日志文件数量
+
+	MetricsAddr string `json:"metrics_addr"` // 监控指标的监听地址, 为空则不启用
+
 	configFilePath string
 	configFile     *os.File
 	fileMu         sync.Mutex
 	activeUser     *Baidu
 	pcs            *baidupcs.BaiduPCS
+	logWriter      *pcslog.RotateWriter
 }
 
 // NewConfig 返回 PCSConfig 指针对象
@@ -80,6 +106,9 @@ func (c *PCSConfig) Reload() error {
 
 // Close 关闭配置文件
 func (c *PCSConfig) Close() error {
+	if c.logWriter != nil {
+		c.logWriter.Close()
+	}
 	if c.configFile != nil {
 		err := c.configFile.Close()
 		c.configFile = nil
@@ -137,6 +166,9 @@ func (c *PCSConfig) init() error {
 		return err
 	}
 
+	// 设置日志
+	c.initLog()
+
 	// 载入配置
 	// 如果 activeUser 已初始化, 则跳过
 	if c.activeUser != nil && c.activeUser.UID == c.BaiduActiveUID {
@@ -155,6 +187,10 @@ func (c *PCSConfig) init() error {
 	requester.UserAgent = c.UserAgent
 	// 设置全局代理
 	requester.SetGlobalProxy(c.Proxy)
+	err = requester.SetGlobalProxyRules(c.ProxyRules)
+	if err != nil {
+		pcsConfigVerbose.Warnf("parse proxy_rules error: %s\n", err)
+	}
 	// 设置本地网卡地址
 	requester.SetLocalTCPAddrList(strings.Split(c.LocalAddrs, ",")...)
 
@@ -223,10 +259,17 @@ func (c *PCSConfig) initDefaultConfig() {
 	c.MaxParallel = 1
 	c.MaxUploadParallel = 8
 	c.MaxDownloadLoad = 1
+	c.MaxUploadLoad = 1
+	c.MinParallel = 1
+	c.SplitSize = 4 * converter.GB
 	c.UserAgent = requester.UserAgent
 	c.PCSUA = ""
 	c.PanUA = baidupcs.NetdiskUA
 	c.EnableHTTPS = true
+	c.LogLevel = "info"
+	c.LogFormat = string(pcslog.FormatText)
+	c.LogMaxSize = pcslog.DefaultMaxSize
+	c.LogMaxBackups = pcslog.DefaultMaxBackups
 
 	// 设置默认的下载路径
 	switch runtime.GOOS {
@@ -305,4 +348,22 @@ func (c *PCSConfig) fix() {
 	if c.MaxDownloadLoad < 1 {
 		c.MaxDownloadLoad = 1
 	}
+	if c.MaxUploadLoad < 1 {
+		c.MaxUploadLoad = 1
+	}
+	if c.MinParallel < 1 {
+		c.MinParallel = 1
+	}
+	if c.WriteBlockSize < 0 {
+		c.WriteBlockSize = 0
+	}
+	if c.SplitSize < 0 {
+		c.SplitSize = 0
+	}
+	if c.LogMaxSize <= 0 {
+		c.LogMaxSize = pcslog.DefaultMaxSize
+	}
+	if c.LogMaxBackups < 0 {
+		c.LogMaxBackups = 0
+	}
 }
@@ -32,3 +32,10 @@ func showMaxRate(size int64) string {
 	}
 	return converter.ConvertFileSize(size, 2) + "/s"
 }
+
+func showSplitSize(size int64) string {
+	if size <= 0 {
+		return "不分割"
+	}
+	return converter.ConvertFileSize(size, 2)
+}
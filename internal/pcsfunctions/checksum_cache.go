@@ -0,0 +1,36 @@
+package pcsfunctions
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// ChecksumCacheFileName 本地文件摘要值缓存的文件名
+	ChecksumCacheFileName = "pcs_checksum_cache.jsonl"
+)
+
+var (
+	checksumCache     *checksum.Cache
+	checksumCacheOnce sync.Once
+)
+
+// ChecksumCacheFilePath 本地文件摘要值缓存的路径
+func ChecksumCacheFilePath() string {
+	return filepath.Join(pcsconfig.GetConfigDir(), ChecksumCacheFileName)
+}
+
+// ChecksumCache 获取本地文件摘要值缓存, 打开失败时返回 nil, 即不使用缓存
+func ChecksumCache() *checksum.Cache {
+	checksumCacheOnce.Do(func() {
+		c, err := checksum.OpenCache(ChecksumCacheFilePath())
+		if err != nil {
+			pcsFunctionsVerbose.Warn("open checksum cache failed", "path", ChecksumCacheFilePath(), "err", err)
+			return
+		}
+		checksumCache = c
+	})
+	return checksumCache
+}
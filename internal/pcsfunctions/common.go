@@ -1,11 +1,36 @@
 package pcsfunctions
 
-import "time"
+import (
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"time"
+)
 
-// RetryWait 失败重试等待事件
-func RetryWait(retry int) time.Duration {
-	if retry < 3 {
-		return 2 * time.Duration(retry) * time.Second
+var (
+	pcsFunctionsVerbose = pcslog.New("PCSFUNCTIONS")
+
+	// RetryPolicy 上传, 下载, 导出等任务失败重试的策略, 按 pcserror 的错误类别判断是否重试
+	RetryPolicy = &retry.Policy{
+		MaxRetry:  3,
+		BaseDelay: 2 * time.Second,
+		MaxDelay:  30 * time.Second,
+		Jitter:    0.2,
+		Classify:  pcserror.Classify,
 	}
-	return 6 * time.Second
+)
+
+// RetryWait 失败重试等待时间, 按重试次数指数增长
+func RetryWait(times int) time.Duration {
+	return RetryPolicy.Backoff(times, retry.ClassRetryable)
+}
+
+// RetryWaitErr 因 err 失败重试的等待时间, 被限流时等待更长的时间
+func RetryWaitErr(times int, err error) time.Duration {
+	return RetryPolicy.Backoff(times, RetryPolicy.ClassOf(err))
+}
+
+// IsRetryable 因 err 失败时, 是否可以重试
+func IsRetryable(err error) bool {
+	return RetryPolicy.ClassOf(err).Retryable()
 }
@@ -19,3 +19,27 @@ func GetLocateDownloadLinks(pcs *baidupcs.BaiduPCS, pcspath string) (dlinks []*u
 
 	return us, nil
 }
+
+// GetLocateDownloadLink 获取第一个下载链接, 并根据配置更新链接的协议
+func GetLocateDownloadLink(pcs *baidupcs.BaiduPCS, pcspath string) (string, error) {
+	dlinks, err := GetLocateDownloadLinkStrings(pcs, pcspath)
+	if err != nil {
+		return "", err
+	}
+	return dlinks[0], nil
+}
+
+// GetLocateDownloadLinkStrings 获取所有的下载链接 (镜像), 并根据配置更新链接的协议
+func GetLocateDownloadLinkStrings(pcs *baidupcs.BaiduPCS, pcspath string) ([]string, error) {
+	rawDlinks, err := GetLocateDownloadLinks(pcs, pcspath)
+	if err != nil {
+		return nil, err
+	}
+
+	dlinks := make([]string, 0, len(rawDlinks))
+	for _, rawDlink := range rawDlinks {
+		FixHTTPLinkURL(rawDlink)
+		dlinks = append(dlinks, rawDlink.String())
+	}
+	return dlinks, nil
+}
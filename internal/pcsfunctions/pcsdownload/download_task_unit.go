@@ -1,22 +1,23 @@
 package pcsdownload
 
 import (
-	"errors"
 	"fmt"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcssplit"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 	"github.com/felixonmars/BaiduPCS-Go/pcstable"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/taskframework"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
 	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -38,7 +39,7 @@ type (
 		DownloadStatistic *DownloadStatistic // 下载统计
 
 		// 可选项
-		VerbosePrinter       *pcsverbose.PCSVerbose
+		VerbosePrinter       *pcslog.Logger
 		PrintFormat          string
 		IsPrintStatus        bool // 是否输出各个下载线程的详细信息
 		IsExecutedPermission bool // 下载成功后是否加上执行权限
@@ -50,7 +51,10 @@ type (
 		PcsPath  string // 要下载的网盘文件路径
 		SavePath string // 保存的路径
 
-		fileInfo *baidupcs.FileDirectory // 文件或目录详情
+		fileInfo       *baidupcs.FileDirectory   // 文件或目录详情
+		rangeChecksums []*transfer.RangeChecksum // 下载时记录的校验值, 用于修复损坏的范围
+
+		splitManifest *baidupcs.FileDirectory // 分割上传的清单, 不为空时下载各部分并合并
 	}
 )
 
@@ -67,6 +71,8 @@ const (
 	StrDownloadGetDlinkFailed = "获取下载链接失败"
 	// StrDownloadChecksumFailed 检测文件有效性失败
 	StrDownloadChecksumFailed = "检测文件有效性失败"
+	// StrDownloadCanceled 下载已取消
+	StrDownloadCanceled = "下载已取消"
 	// DefaultDownloadMaxRetry 默认下载失败最大重试次数
 	DefaultDownloadMaxRetry = 3
 )
@@ -88,7 +94,8 @@ func (dtu *DownloadTaskUnit) verboseInfof(format string, a ...interface{}) {
 }
 
 // download 执行下载
-func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTPClient) (err error) {
+// download 下载, mirrors 为其他镜像的下载地址
+func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTPClient, mirrors ...string) (err error) {
 	var (
 		writer downloader.Writer
 		file   *os.File
@@ -124,6 +131,14 @@ func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTP
 	der := downloader.NewDownloader(downloadURL, writer, dtu.Cfg)
 	der.SetClient(client)
 	der.SetDURLCheckFunc(BaiduPCSURLCheckFunc)
+	der.AddLoadBalanceServer(mirrors...)
+	if dtu.DownloadMode == DownloadModeLocate {
+		// 链接过期时, 重新获取下载链接, 继续下载
+		der.SetMirrorRefreshFunc(func() ([]string, error) {
+			dtu.verboseInfof("[%s] 刷新下载链接\n", dtu.taskInfo.Id())
+			return GetLocateDownloadLinkStrings(dtu.PCS, dtu.PcsPath)
+		})
+	}
 	der.SetStatusCodeBodyCheckFunc(func(respBody io.Reader) error {
 		// 返回的错误可能是pcs的json
 		// 解析错误
@@ -185,9 +200,10 @@ func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTP
 		}
 	})
 
-	err = der.Execute()
+	err = der.ExecuteContext(dtu.taskInfo.Context())
 	isComplete = true
 	fmt.Print("\n")
+	dtu.rangeChecksums = der.RangeChecksums()
 
 	if err != nil {
 		// 下载发生错误
@@ -226,53 +242,32 @@ func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTP
 
 //panHTTPClient 获取包含特定User-Agent的HTTPClient
 func (dtu *DownloadTaskUnit) panHTTPClient() (client *requester.HTTPClient) {
-	client = pcsconfig.Config.PanHTTPClient()
-	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		// 去掉 Referer
-		if !pcsconfig.Config.EnableHTTPS {
-			req.Header.Del("Referer")
-		}
-		if len(via) >= 10 {
-			return errors.New("stopped after 10 redirects")
-		}
-		return nil
-	}
-	client.SetTimeout(20 * time.Minute)
-	client.SetKeepAlive(true)
-	return client
+	return NewPanHTTPClient()
 }
 
 func (dtu *DownloadTaskUnit) handleError(result *taskframework.TaskUnitRunResult) {
-	switch value := result.Err.(type) {
-	case pcserror.Error: // pcserror 接口
-		switch value.GetErrType() {
-		case pcserror.ErrTypeRemoteError:
-		// 远程服务器错误
-		case 31045: // user not exists
-			fallthrough
-		case 31066: // file does not exist
-			result.NeedRetry = false
-		case 31626: // user is not authorized
-			//可能是User-Agent不对
-			//重试
-			fallthrough
-		default:
-			result.NeedRetry = true
-		}
+	if dtu.taskInfo.Context().Err() != nil {
+		// 已取消, 断点信息已保存, 不重试
+		result.ResultMessage = StrDownloadCanceled
+		result.NeedRetry = false
+		return
+	}
+
+	switch result.Err.(type) {
 	case *os.PathError:
 		// 系统级别的错误, 可能是权限问题
 		result.NeedRetry = false
 	default:
-		// 其他错误, 需要重试
-		result.NeedRetry = true
+		// 按错误的类别判断是否重试, 如文件不存在, 登录状态错误时不重试
+		result.NeedRetry = pcsfunctions.IsRetryable(result.Err)
 	}
 }
 
-func (dtu *DownloadTaskUnit) execPanDownload(dlink string, result *taskframework.TaskUnitRunResult, okPtr *bool) {
-	dtu.verboseInfof("[%s] 获取到下载链接: %s\n", dtu.taskInfo.Id(), dlink)
+func (dtu *DownloadTaskUnit) execPanDownload(dlink string, result *taskframework.TaskUnitRunResult, okPtr *bool, mirrors ...string) {
+	dtu.verboseInfof("[%s] 获取到下载链接: %s, 镜像数量: %d\n", dtu.taskInfo.Id(), dlink, len(mirrors))
 
 	client := dtu.panHTTPClient()
-	err := dtu.download(dlink, client)
+	err := dtu.download(dlink, client, mirrors...)
 	if err != nil {
 		result.ResultMessage = StrDownloadFailed
 		result.Err = err
@@ -283,7 +278,7 @@ func (dtu *DownloadTaskUnit) execPanDownload(dlink string, result *taskframework
 }
 
 func (dtu *DownloadTaskUnit) locateDownload(result *taskframework.TaskUnitRunResult) (ok bool) {
-	rawDlinks, err := GetLocateDownloadLinks(dtu.PCS, dtu.PcsPath)
+	dlinks, err := GetLocateDownloadLinkStrings(dtu.PCS, dtu.PcsPath)
 	if err != nil {
 		result.ResultMessage = StrDownloadGetDlinkFailed
 		result.Err = err
@@ -291,17 +286,13 @@ func (dtu *DownloadTaskUnit) locateDownload(result *taskframework.TaskUnitRunRes
 		return
 	}
 
-	// 更新链接的协议
-	FixHTTPLinkURL(rawDlinks[0])
-	dlink := rawDlinks[0].String()
-
-	dtu.execPanDownload(dlink, result, &ok)
+	dtu.execPanDownload(dlinks[0], result, &ok, dlinks[1:]...)
 	return
 }
 
 func (dtu *DownloadTaskUnit) pcsOrStreamingDownload(mode DownloadMode, result *taskframework.TaskUnitRunResult) (ok bool) {
 	dfunc := func(downloadURL string, jar http.CookieJar) error {
-		client := pcsconfig.Config.PCSHTTPClient()
+		client := pcsconfig.Config.DownloadHTTPClient(pcsconfig.Config.PCSUA)
 		client.SetCookiejar(jar)
 		client.SetKeepAlive(true)
 		client.SetTimeout(10 * time.Minute)
@@ -357,6 +348,16 @@ func (dtu *DownloadTaskUnit) checkFileValid(result *taskframework.TaskUnitRunRes
 			result.NeedRetry = false
 			return
 		case ErrDownloadChecksumFailed:
+			// 校验失败, 先尝试修复损坏的范围
+			if dtu.Cfg.RangeChecksum {
+				fmt.Printf("[%s] %s, 尝试修复...\n", dtu.taskInfo.Id(), err)
+				repairErr := dtu.repair()
+				if repairErr == nil {
+					fmt.Printf("[%s] 修复文件成功: %s\n", dtu.taskInfo.Id(), dtu.SavePath)
+					return true
+				}
+				fmt.Printf("[%s] 修复文件失败, %s\n", dtu.taskInfo.Id(), repairErr)
+			}
 			// 校验失败, 需要重新下载
 			result.NeedRetry = true
 			// 设置允许覆盖
@@ -403,6 +404,13 @@ func (dtu *DownloadTaskUnit) RetryWait() time.Duration {
 }
 
 func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
+	// 取消任务时, 中断进行中的请求
+	dtu.PCS = dtu.PCS.WithContext(dtu.taskInfo.Context())
+
+	if dtu.splitManifest != nil {
+		return dtu.splitDownload()
+	}
+
 	result = &taskframework.TaskUnitRunResult{}
 	// 获取文件信息
 	var err error
@@ -412,6 +420,12 @@ func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 		// 如果该任务重试过, 则应该再获取一次文件信息
 		dtu.fileInfo, err = dtu.PCS.FilesDirectoriesMeta(dtu.PcsPath)
 		if err != nil {
+			// 文件不存在时, 可能是分割上传的文件
+			dtu.splitManifest = dtu.findSplitManifest(err)
+			if dtu.splitManifest != nil {
+				return dtu.splitDownload()
+			}
+
 			// 如果不是未登录或文件不存在, 则不重试
 			result.ResultMessage = "获取下载路径信息错误"
 			result.Err = err
@@ -439,18 +453,37 @@ func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 			return
 		}
 
+		// 分割上传的文件, 按清单合并下载, 不单独下载各部分和清单
+		splitManifests := splitManifestsInList(fileList)
+
 		for k := range fileList {
+			var (
+				filename     = fileList[k].Filename
+				manifestInfo *baidupcs.FileDirectory
+			)
+			if name, ok := pcssplit.ParsePartName(filename); ok && !fileList[k].Isdir && splitManifests[name] != nil {
+				continue
+			}
+			if name, ok := pcssplit.TrimManifestSuffix(filename); ok && splitManifests[name] == fileList[k] {
+				filename, manifestInfo = name, fileList[k]
+			}
+
 			// 添加子任务
 			subUnit := *dtu
 			newCfg := *dtu.Cfg
 			subUnit.Cfg = &newCfg
 			subUnit.fileInfo = fileList[k] // 保存文件信息
 			subUnit.PcsPath = fileList[k].Path
-			subUnit.SavePath = filepath.Join(dtu.SavePath, fileList[k].Filename) // 保存位置
+			subUnit.SavePath = filepath.Join(dtu.SavePath, filename) // 保存位置
+			if manifestInfo != nil {
+				subUnit.fileInfo = nil
+				subUnit.splitManifest = manifestInfo
+				subUnit.PcsPath = path.Join(path.Dir(manifestInfo.Path), filename)
+			}
 
 			// 加入父队列
 			info := dtu.ParentTaskExecutor.Append(&subUnit, dtu.taskInfo.MaxRetry())
-			fmt.Printf("[%s] 加入下载队列: %s\n", info.Id(), fileList[k].Path)
+			fmt.Printf("[%s] 加入下载队列: %s\n", info.Id(), subUnit.PcsPath)
 		}
 
 		result.Succeed = true // 执行成功
@@ -7,8 +7,14 @@ var (
 	ErrDownloadNotSupportChecksum = errors.New("该文件不支持校验")
 	// ErrDownloadChecksumFailed 文件校验失败
 	ErrDownloadChecksumFailed = errors.New("该文件校验失败, 文件md5值与服务器记录的不匹配")
+	// ErrSplitPartChecksumFailed 分割上传的部分校验失败
+	ErrSplitPartChecksumFailed = errors.New("分割上传的部分校验失败, md5值与清单记录的不匹配")
+	// ErrSplitManifestTooLarge 分割上传的清单过大
+	ErrSplitManifestTooLarge = errors.New("分割上传的清单过大")
 	// ErrDownloadFileBanned 违规文件
 	ErrDownloadFileBanned = errors.New("该文件可能是违规文件, 不支持校验")
+	// ErrDownloadNothingToRepair 没有找到损坏的范围, 无法修复
+	ErrDownloadNothingToRepair = errors.New("没有找到损坏的范围, 无法修复")
 	// ErrDlinkNotFound 未取得下载链接
 	ErrDlinkNotFound = errors.New("未取得下载链接")
 	// ErrShareInfoNotFound 未在已分享列表中找到分享信息
@@ -0,0 +1,63 @@
+package pcsdownload
+
+import (
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
+	"io"
+	"os"
+)
+
+// repair 检测已下载文件中可能损坏的范围, 只重新下载这些范围, 然后重新校验文件
+func (dtu *DownloadTaskUnit) repair() error {
+	file, err := os.OpenFile(dtu.SavePath, os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	corrupt, err := downloader.FindCorruptRanges(file, dtu.fileInfo.Size, dtu.rangeChecksums)
+	if err != nil {
+		return err
+	}
+	if len(corrupt) == 0 {
+		return ErrDownloadNothingToRepair
+	}
+	fmt.Printf("[%s] 找到 %d 个可能损坏的范围, 共 %s, 重新下载...\n", dtu.taskInfo.Id(), len(corrupt), converter.ConvertFileSize(corrupt.Len(), 2))
+
+	dlinks, err := GetLocateDownloadLinkStrings(dtu.PCS, dtu.PcsPath)
+	if err != nil {
+		return err
+	}
+
+	cfg := dtu.Cfg.Copy()
+	cfg.InstanceStatePath = "" // 不记录断点
+	cfg.RangeChecksum = false
+	for _, r := range corrupt {
+		dtu.verboseInfof("[%s] repair range: %s\n", dtu.taskInfo.Id(), r.ShowDetails())
+
+		der := downloader.NewDownloader(dlinks[0], file, cfg)
+		der.SetClient(dtu.panHTTPClient())
+		der.AddLoadBalanceServer(dlinks[1:]...)
+		der.SetFirstInfo(&downloader.DownloadFirstInfo{
+			ContentLength: dtu.fileInfo.Size,
+			AcceptRanges:  downloader.DefaultAcceptRanges,
+		})
+		der.SetStatusCodeBodyCheckFunc(func(respBody io.Reader) error {
+			return pcserror.DecodePCSJSONError(baidupcs.OperationDownloadFile, respBody)
+		})
+		der.SetDownloadRange(r.Begin, r.End)
+		err = der.ExecuteContext(dtu.taskInfo.Context())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return err
+	}
+	return CheckFileValid(dtu.SavePath, dtu.fileInfo)
+}
@@ -0,0 +1,315 @@
+package pcsdownload
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcssplit"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/taskframework"
+	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// SplitJoinSuffix 合并分割上传的文件时, 临时文件的后缀
+	SplitJoinSuffix = ".BaiduPCS-Go-joining"
+	// MaxSplitManifestSize 分割上传的清单的最大大小
+	MaxSplitManifestSize = 16 * converter.MB
+)
+
+type (
+	// splitProgress 统计合并下载的数据量
+	splitProgress struct {
+		downloaded int64
+	}
+)
+
+func (sp *splitProgress) Write(p []byte) (int, error) {
+	atomic.AddInt64(&sp.downloaded, int64(len(p)))
+	return len(p), nil
+}
+
+// streamLocateFile 通过 locate 下载链接, 下载网盘文件并按顺序写入 w
+func streamLocateFile(pcs *baidupcs.BaiduPCS, pcspath string, size int64, w io.Writer, cfg *downloader.Config) error {
+	dlinks, err := GetLocateDownloadLinkStrings(pcs, pcspath)
+	if err != nil {
+		return err
+	}
+	return StreamDownload(pcs.Context(), w, &StreamOptions{
+		DownloadURL: dlinks[0],
+		Mirrors:     dlinks[1:],
+		RefreshFunc: func() ([]string, error) {
+			return GetLocateDownloadLinkStrings(pcs, pcspath)
+		},
+		Size:   size,
+		End:    -1,
+		Config: cfg,
+	})
+}
+
+// GetSplitManifest 下载并解析分割上传的清单, size 为清单文件的大小
+func GetSplitManifest(pcs *baidupcs.BaiduPCS, manifestPath string, size int64, cfg *downloader.Config) (*pcssplit.Manifest, error) {
+	if size > MaxSplitManifestSize {
+		return nil, ErrSplitManifestTooLarge
+	}
+	buf := &bytes.Buffer{}
+	err := streamLocateFile(pcs, manifestPath, size, buf, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return pcssplit.ParseManifest(buf.Bytes())
+}
+
+// splitManifestsInList 目录下分割上传的清单, 原文件名 => 清单, 与清单同名的文件存在时, 按普通文件下载
+func splitManifestsInList(fileList baidupcs.FileDirectoryList) map[string]*baidupcs.FileDirectory {
+	var (
+		names     = make(map[string]bool, len(fileList))
+		manifests = map[string]*baidupcs.FileDirectory{}
+	)
+	for _, fd := range fileList {
+		names[fd.Filename] = true
+	}
+	for _, fd := range fileList {
+		name, ok := pcssplit.TrimManifestSuffix(fd.Filename)
+		if ok && !fd.Isdir && !names[name] {
+			manifests[name] = fd
+		}
+	}
+	return manifests
+}
+
+// findSplitManifest 网盘文件不存在时, 查找分割上传的清单
+func (dtu *DownloadTaskUnit) findSplitManifest(err error) *baidupcs.FileDirectory {
+	pcsError, ok := err.(pcserror.Error)
+	if !ok || pcsError.GetErrType() != pcserror.ErrTypeRemoteError || pcsError.GetRemoteErrCode() != 31066 {
+		return nil
+	}
+	fd, pcsError := dtu.PCS.FilesDirectoriesMeta(dtu.PcsPath + pcssplit.ManifestSuffix)
+	if pcsError != nil || fd.Isdir {
+		return nil
+	}
+	return fd
+}
+
+// splitDownload 下载分割上传的文件的各部分, 合并为原文件, 并校验原文件的 md5
+func (dtu *DownloadTaskUnit) splitDownload() (result *taskframework.TaskUnitRunResult) {
+	result = &taskframework.TaskUnitRunResult{}
+	m, err := GetSplitManifest(dtu.PCS, dtu.splitManifest.Path, dtu.splitManifest.Size, dtu.Cfg)
+	if err != nil {
+		result.ResultMessage = "获取分割上传的清单错误"
+		result.Err = err
+		dtu.handleError(result)
+		if err == pcssplit.ErrInvalidManifest || err == ErrSplitManifestTooLarge {
+			// 清单的内容无效, 不重试
+			result.NeedRetry = false
+		}
+		return
+	}
+
+	fmt.Print("\n")
+	fmt.Printf("[%s] ----\n分割上传的文件: %s, 大小: %s, 部分数量: %d, md5: %s\n", dtu.taskInfo.Id(), dtu.PcsPath, converter.ConvertFileSize(m.Size, 2), len(m.Parts), m.MD5)
+	fmt.Printf("[%s] 准备下载: %s\n", dtu.taskInfo.Id(), dtu.PcsPath)
+
+	if !dtu.Cfg.IsTest && !dtu.IsOverwrite && FileExist(dtu.SavePath) {
+		fmt.Printf("[%s] 文件已经存在: %s, 跳过...\n", dtu.taskInfo.Id(), dtu.SavePath)
+		result.Succeed = true
+		return
+	}
+
+	if !dtu.Cfg.IsTest {
+		fmt.Printf("[%s] 将会下载到路径: %s\n\n", dtu.taskInfo.Id(), dtu.SavePath)
+	}
+
+	err = dtu.joinSplitParts(m)
+	if err != nil {
+		result.ResultMessage = StrDownloadFailed
+		result.Err = err
+		dtu.handleError(result)
+		return
+	}
+
+	dtu.DownloadStatistic.AddTotalSize(m.Size)
+	result.Succeed = true
+	return
+}
+
+// joinSplitParts 依次下载各部分, 写入临时文件, 校验通过后重命名为 SavePath.
+// 临时文件中已下载且校验通过的部分, 不再重新下载
+func (dtu *DownloadTaskUnit) joinSplitParts(m *pcssplit.Manifest) (err error) {
+	var (
+		dir      = path.Dir(dtu.splitManifest.Path)
+		joinPath = dtu.SavePath + SplitJoinSuffix
+		w        io.Writer
+		file     *os.File
+		start    int
+	)
+	if dtu.Cfg.IsTest {
+		w = ioutil.Discard
+	} else {
+		err = os.MkdirAll(filepath.Dir(dtu.SavePath), 0777)
+		if err != nil {
+			return err
+		}
+		file, err = os.OpenFile(joinPath, os.O_CREATE|os.O_RDWR, 0666)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		start = verifyJoinedParts(file, m)
+		if start > 0 {
+			fmt.Printf("[%s] 已下载 %d/%d 个部分, 继续下载...\n", dtu.taskInfo.Id(), start, len(m.Parts))
+		}
+		offset := m.Size
+		if start < len(m.Parts) {
+			offset = m.Parts[start].Offset
+		}
+		err = file.Truncate(offset)
+		if err != nil {
+			return err
+		}
+		_, err = file.Seek(offset, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		w = file
+	}
+
+	var (
+		progress = &splitProgress{}
+		done     = make(chan struct{})
+		total    int64
+	)
+	for _, part := range m.Parts[start:] {
+		total += part.Size
+	}
+	if dtu.PrintFormat == "" {
+		dtu.PrintFormat = DefaultPrintFormat
+	}
+	go dtu.printSplitProgress(progress, total, done)
+
+	for seq := start; seq < len(m.Parts); seq++ {
+		part := m.Parts[seq]
+		dtu.verboseInfof("[%s] 下载第 %d/%d 个部分: %s\n", dtu.taskInfo.Id(), seq+1, len(m.Parts), part.Name)
+
+		h := md5.New()
+		err = streamLocateFile(dtu.PCS, path.Join(dir, part.Name), part.Size, io.MultiWriter(w, h, progress), dtu.Cfg)
+		if err == nil && hex.EncodeToString(h.Sum(nil)) != part.MD5 {
+			err = ErrSplitPartChecksumFailed
+		}
+		if err != nil {
+			close(done)
+			if file != nil {
+				// 丢弃未完成的部分
+				file.Truncate(part.Offset)
+			}
+			return err
+		}
+	}
+	close(done)
+	fmt.Print("\n")
+
+	if dtu.Cfg.IsTest {
+		fmt.Printf("[%s] 测试下载结束\n", dtu.taskInfo.Id())
+		return nil
+	}
+
+	// 重新读取合并后的文件, 校验原文件的 md5
+	if !dtu.NoCheck {
+		fmt.Printf("[%s] 开始检验文件有效性, 请稍候...\n", dtu.taskInfo.Id())
+		h := md5.New()
+		_, err = io.Copy(h, io.NewSectionReader(file, 0, m.Size))
+		if err != nil {
+			return err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != m.MD5 {
+			file.Close()
+			os.Remove(joinPath)
+			return ErrDownloadChecksumFailed
+		}
+		fmt.Printf("[%s] 检验文件有效性成功\n", dtu.taskInfo.Id())
+	}
+
+	if dtu.IsExecutedPermission {
+		err = file.Chmod(0766)
+		if err != nil {
+			fmt.Printf("[%s] 警告, 加执行权限错误: %s\n", dtu.taskInfo.Id(), err)
+		}
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	if dtu.IsOverwrite {
+		// windows 下重命名不能覆盖已存在的文件
+		os.Remove(dtu.SavePath)
+	}
+	err = os.Rename(joinPath, dtu.SavePath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[%s] 下载完成, 保存位置: %s\n", dtu.taskInfo.Id(), dtu.SavePath)
+	return nil
+}
+
+// printSplitProgress 每秒输出一次合并下载的进度, 直到 done 关闭
+func (dtu *DownloadTaskUnit) printSplitProgress(progress *splitProgress, total int64, done <-chan struct{}) {
+	var (
+		ticker    = time.NewTicker(1 * time.Second)
+		startTime = time.Now()
+		last      int64
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		downloaded := atomic.LoadInt64(&progress.downloaded)
+		speed := downloaded - last
+		last = downloaded
+
+		leftStr := "-"
+		if speed > 0 {
+			leftStr = (time.Duration((total-downloaded)/speed) * time.Second).String()
+		}
+		fmt.Printf(dtu.PrintFormat, dtu.taskInfo.Id(),
+			converter.ConvertFileSize(downloaded, 2),
+			converter.ConvertFileSize(total, 2),
+			converter.ConvertFileSize(speed, 2),
+			time.Since(startTime)/1e7*1e7, leftStr,
+		)
+	}
+}
+
+// verifyJoinedParts 校验临时文件中已下载的部分, 返回连续校验通过的部分数量
+func verifyJoinedParts(file *os.File, m *pcssplit.Manifest) int {
+	info, err := file.Stat()
+	if err != nil {
+		return 0
+	}
+	for k, part := range m.Parts {
+		if part.Offset+part.Size > info.Size() {
+			return k
+		}
+		h := md5.New()
+		_, err = io.Copy(h, io.NewSectionReader(file, part.Offset, part.Size))
+		if err != nil || hex.EncodeToString(h.Sum(nil)) != part.MD5 {
+			return k
+		}
+	}
+	return len(m.Parts)
+}
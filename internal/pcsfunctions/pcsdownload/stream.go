@@ -0,0 +1,102 @@
+package pcsdownload
+
+import (
+	"context"
+	"errors"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/requester"
+	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
+	"io"
+	"net/http"
+	"time"
+)
+
+type (
+	// StreamOptions 流式下载的参数
+	StreamOptions struct {
+		DownloadURL string
+		Mirrors     []string                     // 其他镜像的下载地址
+		RefreshFunc downloader.MirrorRefreshFunc // 链接过期时, 重新获取下载链接
+		Size        int64                        // 文件大小
+		Begin       int64                        // 下载范围的开始位置
+		End         int64                        // 下载范围的结束位置 (不包含), 小于 0 时下载到文件末尾
+		BufferSize  int                          // 重排缓冲区大小
+		Config      *downloader.Config
+	}
+)
+
+// NewPanHTTPClient 获取包含特定User-Agent的HTTPClient
+func NewPanHTTPClient() (client *requester.HTTPClient) {
+	client = pcsconfig.Config.DownloadHTTPClient(pcsconfig.Config.PanUA)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		// 去掉 Referer
+		if !pcsconfig.Config.EnableHTTPS {
+			req.Header.Del("Referer")
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	client.SetTimeout(20 * time.Minute)
+	client.SetKeepAlive(true)
+	return client
+}
+
+// StreamDownload 多线程下载文件的一部分, 按顺序写入 w, ctx 结束时停止下载
+func StreamDownload(ctx context.Context, w io.Writer, opt *StreamOptions) error {
+	begin, end := opt.Begin, opt.End
+	if end < 0 || end > opt.Size {
+		end = opt.Size
+	}
+	if begin >= end {
+		return nil
+	}
+
+	cfg := opt.Config
+	if cfg == nil {
+		cfg = downloader.NewConfig()
+	} else {
+		cfg = cfg.Copy()
+	}
+	cfg.InstanceStatePath = "" // 不记录断点
+	cfg.IsTest = false
+
+	sw := downloader.NewSequentialWriter(w, begin, opt.BufferSize)
+	der := downloader.NewDownloader(opt.DownloadURL, sw, cfg)
+	der.SetClient(NewPanHTTPClient())
+	der.AddLoadBalanceServer(opt.Mirrors...)
+	der.SetMirrorRefreshFunc(opt.RefreshFunc)
+	der.SetFirstInfo(&downloader.DownloadFirstInfo{
+		ContentLength: opt.Size,
+		AcceptRanges:  downloader.DefaultAcceptRanges,
+	})
+	der.SetStatusCodeBodyCheckFunc(func(respBody io.Reader) error {
+		return pcserror.DecodePCSJSONError(baidupcs.OperationDownloadFile, respBody)
+	})
+	der.SetDownloadRange(begin, end)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// 关闭后, 所有线程的写入都会出错, 下载随之停止
+			sw.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	err := der.ExecuteContext(ctx)
+	offset := sw.Offset()
+	sw.Close() // 未退出的线程不能再写入
+	if err != nil {
+		return err
+	}
+	if offset != end {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
 	"net/url"
 	"os"
@@ -22,7 +23,7 @@ func CheckFileValid(filePath string, fileInfo *baidupcs.FileDirectory) error {
 	}
 	defer f.Close()
 
-	err = f.Sum(checksum.CHECKSUM_MD5)
+	err = f.SumWithCache(pcsfunctions.ChecksumCache(), checksum.CHECKSUM_MD5)
 	if err != nil {
 		return err
 	}
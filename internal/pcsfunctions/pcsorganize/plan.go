@@ -0,0 +1,211 @@
+package pcsorganize
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxRenameAttempts 冲突处理方式为 rename 时, 最多尝试的序号
+	MaxRenameAttempts = 1000
+)
+
+type (
+	// ExistsFunc 检测网盘路径是否已存在
+	ExistsFunc func(pcspath string) bool
+
+	// Move 整理计划中的一次移动
+	Move struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Rule      string `json:"rule"`
+		Overwrite bool   `json:"overwrite,omitempty"` // 目标已存在, 须先移到回收站
+	}
+
+	// Skip 整理计划中跳过的文件
+	Skip struct {
+		Path   string
+		Rule   string
+		Reason string
+	}
+
+	// Plan 整理计划
+	Plan struct {
+		Moves   []*Move
+		Skipped []*Skip
+	}
+
+	// Planner 根据规则生成整理计划, 处理目标路径的冲突
+	Planner struct {
+		rules   *Rules
+		exists  ExistsFunc
+		targets map[string]bool // 计划中已使用的目标路径
+		sources map[string]bool // 计划中将被移走的路径
+		plan    *Plan
+	}
+)
+
+// NewPlanner 初始化 Planner, exists 用于检测目标路径是否已存在
+func NewPlanner(rules *Rules, exists ExistsFunc) *Planner {
+	return &Planner{
+		rules:   rules,
+		exists:  exists,
+		targets: map[string]bool{},
+		sources: map[string]bool{},
+		plan:    &Plan{},
+	}
+}
+
+// Plan 返回整理计划, 目标路径为其他文件的源路径时, 先移走该文件
+func (pl *Planner) Plan() *Plan {
+	pl.order()
+	return pl.plan
+}
+
+// taken 目标路径是否已被占用, 计划中将被移走的路径不占用, 由 order 保证先移走
+func (pl *Planner) taken(target string) bool {
+	if pl.targets[target] {
+		return true
+	}
+	return !pl.sources[target] && pl.exists != nil && pl.exists(target)
+}
+
+// order 将移走目标路径的移动排在前面, 循环依赖的移动无法执行, 加入跳过的列表
+func (pl *Planner) order() {
+	const (
+		visiting = iota + 1
+		done
+		failed
+	)
+	var (
+		byFrom  = make(map[string]*Move, len(pl.plan.Moves))
+		state   = make(map[*Move]int, len(pl.plan.Moves))
+		ordered = make([]*Move, 0, len(pl.plan.Moves))
+		visit   func(move *Move) bool
+	)
+	for _, move := range pl.plan.Moves {
+		byFrom[move.From] = move
+	}
+	visit = func(move *Move) bool {
+		switch state[move] {
+		case done:
+			return true
+		case visiting, failed:
+			return false
+		}
+		state[move] = visiting
+		if next := byFrom[move.To]; next != nil && !visit(next) {
+			state[move] = failed
+			return false
+		}
+		state[move] = done
+		ordered = append(ordered, move)
+		return true
+	}
+	for _, move := range pl.plan.Moves {
+		if !visit(move) {
+			state[move] = failed
+			pl.plan.Skipped = append(pl.plan.Skipped, &Skip{
+				Path:   move.From,
+				Rule:   move.Rule,
+				Reason: "目标路径的文件无法先移走",
+			})
+		}
+	}
+	pl.plan.Moves = ordered
+}
+
+// Add 按第一个匹配的规则, 将文件加入整理计划, 没有匹配的规则时忽略
+func (pl *Planner) Add(f *File) {
+	for _, rule := range pl.rules.Rules {
+		matched, captures := rule.Match(f)
+		if !matched {
+			continue
+		}
+
+		target, err := rule.TargetPath(f, captures)
+		if err != nil {
+			pl.skip(f, rule, err.Error())
+			return
+		}
+		if target == f.Path {
+			// 已整理
+			return
+		}
+
+		move := &Move{
+			From: f.Path,
+			To:   target,
+			Rule: rule.Name,
+		}
+		if pl.taken(target) {
+			switch rule.OnConflict {
+			case ConflictRename:
+				move.To = pl.rename(target)
+				if move.To == "" {
+					pl.skip(f, rule, "目标已存在, 重命名失败")
+					return
+				}
+			case ConflictOverwrite:
+				if pl.targets[target] {
+					pl.skip(f, rule, "与计划中的其他文件冲突")
+					return
+				}
+				move.Overwrite = true
+			default:
+				pl.skip(f, rule, "目标已存在")
+				return
+			}
+		}
+
+		pl.targets[move.To] = true
+		pl.sources[move.From] = true
+		pl.plan.Moves = append(pl.plan.Moves, move)
+		return
+	}
+}
+
+func (pl *Planner) skip(f *File, rule *Rule, reason string) {
+	pl.plan.Skipped = append(pl.plan.Skipped, &Skip{
+		Path:   f.Path,
+		Rule:   rule.Name,
+		Reason: reason,
+	})
+}
+
+// rename 生成未被占用的目标路径, 如 name (1).ext
+func (pl *Planner) rename(target string) string {
+	var (
+		dir, name = path.Split(target)
+		ext       = path.Ext(name)
+		base      = strings.TrimSuffix(name, ext)
+	)
+	for i := 1; i <= MaxRenameAttempts; i++ {
+		p := dir + base + " (" + strconv.Itoa(i) + ")" + ext
+		if !pl.taken(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// Batches 按顺序将移动分批, 每批最多 size 个, 目标路径为同一批中其他移动的源路径时, 放到下一批
+func Batches(moves []*Move, size int) (batches [][]*Move) {
+	var (
+		batch   []*Move
+		sources = map[string]bool{}
+	)
+	for _, move := range moves {
+		if len(batch) >= size || sources[move.To] {
+			batches = append(batches, batch)
+			batch, sources = nil, map[string]bool{}
+		}
+		batch = append(batch, move)
+		sources[move.From] = true
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package pcsorganize
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRules = `{
+	"rules": [
+		{
+			"name": "photo",
+			"glob": "*.jpg",
+			"target": "/Photos/{mtime:2006}/{mtime:01}/{name}",
+			"on_conflict": "rename"
+		},
+		{
+			"name": "episode",
+			"regex": "/(\\w+)\\.S(\\d+)E\\d+\\.mkv$",
+			"target": "/TV/{1}/S{2}/{name}"
+		}
+	]
+}`
+
+func TestPlanner(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2019, 3, 5, 12, 0, 0, 0, time.UTC).Unix()
+	existing := map[string]bool{
+		"/Photos/2019/03/a.jpg":        true,
+		"/TV/show/S01/show.S01E02.mkv": true,
+	}
+	pl := NewPlanner(rules, func(p string) bool {
+		return existing[p]
+	})
+	for _, f := range []*File{
+		{Path: "/camera/a.jpg", Mtime: mtime},
+		{Path: "/other/a.jpg", Mtime: mtime},
+		{Path: "/dl/show.S01E02.mkv"},
+		{Path: "/dl/show.S02E01.mkv"},
+		{Path: "/dl/readme.txt"},
+	} {
+		pl.Add(f)
+	}
+
+	plan := pl.Plan()
+	expected := []string{
+		"/camera/a.jpg -> /Photos/2019/03/a (1).jpg",
+		"/other/a.jpg -> /Photos/2019/03/a (2).jpg",
+		"/dl/show.S02E01.mkv -> /TV/show/S02/show.S02E01.mkv",
+	}
+	if len(plan.Moves) != len(expected) {
+		t.Fatalf("moves: %d, expected: %d", len(plan.Moves), len(expected))
+	}
+	for k, move := range plan.Moves {
+		if s := move.From + " -> " + move.To; s != expected[k] {
+			t.Errorf("move %d: %s, expected: %s", k, s, expected[k])
+		}
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0].Path != "/dl/show.S01E02.mkv" {
+		t.Errorf("skipped: %v", plan.Skipped)
+	}
+}
+
+func TestParseRulesError(t *testing.T) {
+	for _, s := range []string{
+		`{"rules": [{"glob": "*"}]}`,
+		`{"rules": [{"target": "/{unknown}"}]}`,
+		`{"rules": [{"target": "/{name}", "on_conflict": "merge"}]}`,
+		`{"rules": [{"target": "/{name}", "min_size": "abc"}]}`,
+	} {
+		_, err := ParseRules(strings.NewReader(s))
+		if err == nil {
+			t.Errorf("expected error: %s", s)
+		}
+	}
+}
+
+const testChainRules = `{
+	"rules": [
+		{"name": "a", "regex": "^/a/", "target": "/b/{name}"},
+		{"name": "b", "regex": "^/b/", "target": "/c/{name}"},
+		{"name": "x", "regex": "^/s/x\\.txt$", "target": "/s/y.txt"},
+		{"name": "y", "regex": "^/s/y\\.txt$", "target": "/s/x.txt"}
+	]
+}`
+
+func TestPlannerOrder(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(testChainRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pl := NewPlanner(rules, nil)
+	for _, p := range []string{"/a/1.txt", "/b/1.txt", "/a/2.txt", "/s/x.txt", "/s/y.txt"} {
+		pl.Add(&File{Path: p})
+	}
+
+	// 先移走目标路径的文件, 循环依赖的跳过
+	plan := pl.Plan()
+	expected := []string{
+		"/b/1.txt -> /c/1.txt",
+		"/a/1.txt -> /b/1.txt",
+		"/a/2.txt -> /b/2.txt",
+	}
+	if len(plan.Moves) != len(expected) {
+		t.Fatalf("moves: %d, expected: %d", len(plan.Moves), len(expected))
+	}
+	for k, move := range plan.Moves {
+		if s := move.From + " -> " + move.To; s != expected[k] {
+			t.Errorf("move %d: %s, expected: %s", k, s, expected[k])
+		}
+	}
+	if len(plan.Skipped) != 2 {
+		t.Errorf("skipped: %d", len(plan.Skipped))
+	}
+
+	// 依赖的移动不在同一批
+	batches := Batches(plan.Moves, 100)
+	if len(batches) != 2 || len(batches[0]) != 1 || len(batches[1]) != 2 {
+		t.Fatalf("batches: %v", batches)
+	}
+	batches = Batches(plan.Moves[1:], 1)
+	if len(batches) != 2 {
+		t.Fatalf("batches: %d", len(batches))
+	}
+}
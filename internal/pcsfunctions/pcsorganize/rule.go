@@ -0,0 +1,273 @@
+// Package pcsorganize 网盘文件整理规则, 根据规则生成移动计划
+package pcsorganize
+
+import (
+	"errors"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/pcstime"
+	"github.com/json-iterator/go"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ConflictSkip 目标已存在时, 跳过
+	ConflictSkip = "skip"
+	// ConflictRename 目标已存在时, 重命名为 name (1).ext
+	ConflictRename = "rename"
+	// ConflictOverwrite 目标已存在时, 将已存在的文件移到回收站, 再移动
+	ConflictOverwrite = "overwrite"
+)
+
+var (
+	// ErrRuleNoTarget 规则没有目标路径模板
+	ErrRuleNoTarget = errors.New("rule has no target")
+	// ErrUnknownConflict 未知的冲突处理方式
+	ErrUnknownConflict = errors.New("unknown on_conflict, supported: skip, rename, overwrite")
+
+	templateVarRE = regexp.MustCompile(`\{([^{}]+)\}`)
+
+	timeLayouts = []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339}
+)
+
+type (
+	// Rules 整理规则文件
+	Rules struct {
+		OnConflict string  `json:"on_conflict"` // 默认的冲突处理方式
+		Rules      []*Rule `json:"rules"`
+	}
+
+	// Rule 整理规则, 所有设置的条件都满足时匹配
+	Rule struct {
+		Name        string `json:"name"`
+		Glob        string `json:"glob"`         // 匹配文件名的通配符
+		Regex       string `json:"regex"`        // 匹配路径的正则表达式, 捕获组可在目标路径中使用 {1}, {2} ...
+		MinSize     string `json:"min_size"`     // 最小文件大小, 如 1MB
+		MaxSize     string `json:"max_size"`     // 最大文件大小
+		MtimeAfter  string `json:"mtime_after"`  // 修改时间不早于, 如 2019-01-01
+		MtimeBefore string `json:"mtime_before"` // 修改时间早于
+		Target      string `json:"target"`       // 目标路径模板, 如 /Photos/{mtime:2006}/{mtime:01}/{name}
+		OnConflict  string `json:"on_conflict"`  // 冲突处理方式, 为空则使用默认的
+
+		regex         *regexp.Regexp
+		minSize       int64
+		maxSize       int64
+		after, before time.Time
+		hasMinSize    bool
+		hasMaxSize    bool
+	}
+
+	// File 要整理的网盘文件
+	File struct {
+		Path  string
+		Size  int64
+		Mtime int64
+		Ctime int64
+		MD5   string
+	}
+)
+
+// LoadRules 从文件读取整理规则
+func LoadRules(filename string) (*Rules, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRules(f)
+}
+
+// ParseRules 解析 json 格式的整理规则
+func ParseRules(r io.Reader) (*Rules, error) {
+	rules := &Rules{}
+	err := jsoniter.NewDecoder(r).Decode(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if rules.OnConflict == "" {
+		rules.OnConflict = ConflictSkip
+	}
+	err = checkConflict(rules.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, rule := range rules.Rules {
+		if rule.Name == "" {
+			rule.Name = "#" + strconv.Itoa(k)
+		}
+		err = rule.compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %s", rule.Name, err)
+		}
+		if rule.OnConflict == "" {
+			rule.OnConflict = rules.OnConflict
+		}
+	}
+	return rules, nil
+}
+
+func checkConflict(onConflict string) error {
+	switch onConflict {
+	case ConflictSkip, ConflictRename, ConflictOverwrite:
+		return nil
+	}
+	return ErrUnknownConflict
+}
+
+func parseTime(s string) (t time.Time, err error) {
+	for _, layout := range timeLayouts {
+		t, err = time.ParseInLocation(layout, s, pcstime.CSTLocation)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+func (rule *Rule) compile() (err error) {
+	if rule.Target == "" {
+		return ErrRuleNoTarget
+	}
+	if rule.OnConflict != "" {
+		err = checkConflict(rule.OnConflict)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rule.Glob != "" {
+		_, err = path.Match(rule.Glob, "")
+		if err != nil {
+			return err
+		}
+	}
+	if rule.Regex != "" {
+		rule.regex, err = regexp.Compile(rule.Regex)
+		if err != nil {
+			return err
+		}
+	}
+	if rule.MinSize != "" {
+		rule.minSize, err = converter.ParseFileSizeStr(rule.MinSize)
+		if err != nil {
+			return err
+		}
+		rule.hasMinSize = true
+	}
+	if rule.MaxSize != "" {
+		rule.maxSize, err = converter.ParseFileSizeStr(rule.MaxSize)
+		if err != nil {
+			return err
+		}
+		rule.hasMaxSize = true
+	}
+	if rule.MtimeAfter != "" {
+		rule.after, err = parseTime(rule.MtimeAfter)
+		if err != nil {
+			return err
+		}
+	}
+	if rule.MtimeBefore != "" {
+		rule.before, err = parseTime(rule.MtimeBefore)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 检查模板变量
+	_, err = rule.render(&File{Path: "/a.b"}, make([]string, 10))
+	return err
+}
+
+// Match 文件是否匹配规则, 返回正则表达式的捕获组
+func (rule *Rule) Match(f *File) (matched bool, captures []string) {
+	if rule.Glob != "" {
+		if ok, _ := path.Match(rule.Glob, path.Base(f.Path)); !ok {
+			return false, nil
+		}
+	}
+	if rule.hasMinSize && f.Size < rule.minSize {
+		return false, nil
+	}
+	if rule.hasMaxSize && f.Size > rule.maxSize {
+		return false, nil
+	}
+	if !rule.after.IsZero() && f.Mtime < rule.after.Unix() {
+		return false, nil
+	}
+	if !rule.before.IsZero() && f.Mtime >= rule.before.Unix() {
+		return false, nil
+	}
+	if rule.regex != nil {
+		captures = rule.regex.FindStringSubmatch(f.Path)
+		if captures == nil {
+			return false, nil
+		}
+	}
+	return true, captures
+}
+
+// TargetPath 生成文件的目标路径
+func (rule *Rule) TargetPath(f *File, captures []string) (string, error) {
+	return rule.render(f, captures)
+}
+
+// render 渲染目标路径模板, 支持的变量:
+// {name} 文件名, {base} 不含扩展名的文件名, {ext} 不含 . 的扩展名, {dir} 所在目录, {path} 路径,
+// {size} 文件大小, {md5} 文件的 md5, {mtime:2006} {ctime:01} 按 go 的时间格式格式化的修改/创建时间,
+// {1} {2} ... 正则表达式的捕获组
+func (rule *Rule) render(f *File, captures []string) (target string, err error) {
+	var (
+		name = path.Base(f.Path)
+		ext  = path.Ext(name)
+	)
+	target = templateVarRE.ReplaceAllStringFunc(rule.Target, func(s string) string {
+		v := s[1 : len(s)-1]
+		switch v {
+		case "name":
+			return name
+		case "base":
+			return strings.TrimSuffix(name, ext)
+		case "ext":
+			return strings.TrimPrefix(ext, ".")
+		case "dir":
+			return path.Dir(f.Path)
+		case "path":
+			return f.Path
+		case "size":
+			return strconv.FormatInt(f.Size, 10)
+		case "md5":
+			return f.MD5
+		}
+
+		switch {
+		case strings.HasPrefix(v, "mtime:"):
+			return time.Unix(f.Mtime, 0).In(pcstime.CSTLocation).Format(v[len("mtime:"):])
+		case strings.HasPrefix(v, "ctime:"):
+			return time.Unix(f.Ctime, 0).In(pcstime.CSTLocation).Format(v[len("ctime:"):])
+		}
+
+		i, convErr := strconv.Atoi(v)
+		if convErr == nil && i >= 0 && i < len(captures) {
+			return captures[i]
+		}
+		if err == nil {
+			err = fmt.Errorf("unknown template variable: %s", s)
+		}
+		return s
+	})
+	if err != nil {
+		return "", err
+	}
+
+	target = path.Clean("/" + target)
+	return target, nil
+}
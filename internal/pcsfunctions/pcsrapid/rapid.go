@@ -0,0 +1,436 @@
+// Package pcsrapid 秒传信息的导入导出格式
+package pcsrapid
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/pcsliner/args"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"github.com/json-iterator/go"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FormatCommand BaiduPCS-Go 命令格式, 即 rapidupload 和 mkdir 命令
+	FormatCommand = "command"
+	// FormatBdpan PanDownload 的 bdpan:// 链接, 为 base64 编码的 文件名|文件大小|md5|前256KB切片的md5
+	FormatBdpan = "bdpan"
+	// FormatHash 以 # 分隔的秒传链接, md5#前256KB切片的md5#文件大小#文件名
+	FormatHash = "hash"
+	// FormatPanDL PanDL 等工具使用的 json 格式
+	FormatPanDL = "pandl"
+
+	// BdpanPrefix bdpan 链接的前缀
+	BdpanPrefix = "bdpan://"
+)
+
+var (
+	// FormatList 支持的格式
+	FormatList = []string{FormatCommand, FormatBdpan, FormatHash, FormatPanDL}
+
+	// ErrUnknownFormat 未知的格式
+	ErrUnknownFormat = errors.New("unknown format, supported: command, bdpan, hash, pandl")
+	// ErrInvalidLine 无法识别的行
+	ErrInvalidLine = errors.New("unrecognized rapid upload link")
+	// ErrInvalidMD5 md5 格式错误
+	ErrInvalidMD5 = errors.New("invalid md5")
+
+	// commandPathReplacer 转义 command 格式中双引号内的路径
+	commandPathReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+type (
+	// Entry 一条秒传信息, 或一个空目录
+	Entry struct {
+		Path     string // 路径, 可以是相对路径
+		Length   int64
+		MD5      string
+		SliceMD5 string
+		CRC32    string // 可以为空, 秒传不需要 crc32
+		Dir      bool   // 空目录, 只创建目录
+	}
+
+	// pandlFile PanDL json 格式的一个文件
+	pandlFile struct {
+		Path     string       `json:"path,omitempty"`
+		Name     string       `json:"name,omitempty"`
+		Filename string       `json:"filename,omitempty"`
+		Size     int64        `json:"size"`
+		Length   int64        `json:"length,omitempty"`
+		MD5      string       `json:"md5"`
+		SliceMD5 string       `json:"md5s"`
+		SliceAlt string       `json:"slice_md5,omitempty"`
+		CRC32    numberString `json:"crc32,omitempty"`
+	}
+
+	// numberString json 中的数字或字符串
+	numberString string
+
+	// pandlList PanDL json 格式
+	pandlList struct {
+		Files []*pandlFile `json:"files"`
+	}
+
+	// Writer 按格式写入秒传信息, pandl 格式在 Close 时写入
+	Writer struct {
+		w      io.Writer
+		format string
+		pandl  pandlList
+	}
+)
+
+// CheckFormat 检查格式是否支持
+func CheckFormat(format string) error {
+	for _, f := range FormatList {
+		if f == format {
+			return nil
+		}
+	}
+	return ErrUnknownFormat
+}
+
+// UnmarshalJSON 同时支持数字和字符串
+func (ns *numberString) UnmarshalJSON(data []byte) error {
+	var n jsoniter.Number
+	err := jsoniter.Unmarshal(data, &n)
+	if err == nil {
+		*ns = numberString(n)
+		return nil
+	}
+	var s string
+	err = jsoniter.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	*ns = numberString(s)
+	return nil
+}
+
+func isMD5(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// fix 检查并规范化秒传信息
+func (e *Entry) fix() error {
+	e.Path = strings.TrimSpace(e.Path)
+	if e.Path == "" {
+		return ErrInvalidLine
+	}
+	if e.Dir {
+		return nil
+	}
+	if !isMD5(e.MD5) || !isMD5(e.SliceMD5) {
+		return ErrInvalidMD5
+	}
+	if e.Length < 0 {
+		return ErrInvalidLine
+	}
+	e.MD5, e.SliceMD5 = strings.ToLower(e.MD5), strings.ToLower(e.SliceMD5)
+	return nil
+}
+
+// ParseLine 解析一行秒传信息, 自动识别格式, 支持 command, bdpan, hash,
+// 以及 sumfile 生成的 rapid 格式的清单
+func ParseLine(line string) (*Entry, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, BdpanPrefix) {
+		return parseBdpan(line)
+	}
+
+	hashLine := strings.TrimPrefix(line, "\\")
+	if len(hashLine) <= 32 || hashLine[32] != '#' || !isMD5(hashLine[:32]) {
+		return parseCommand(line)
+	}
+
+	if me, err := checksum.ParseManifestLine(line); err == nil && me.Format == checksum.ManifestRapid {
+		e := &Entry{
+			Path:     me.Path,
+			Length:   me.Length,
+			MD5:      hex.EncodeToString(me.MD5),
+			SliceMD5: hex.EncodeToString(me.SliceMD5),
+			CRC32:    strconv.FormatUint(uint64(me.CRC32), 10),
+		}
+		return e, e.fix()
+	}
+	return parseHash(line)
+}
+
+// parseCommand 解析 BaiduPCS-Go rapidupload -length=1 -md5=... -slicemd5=... -crc32=... "/path",
+// 或 BaiduPCS-Go mkdir "/path"
+func parseCommand(line string) (*Entry, error) {
+	lineArgs := args.Parse(line)
+	for k, arg := range lineArgs {
+		switch arg {
+		case "mkdir":
+			if k+1 >= len(lineArgs) {
+				return nil, ErrInvalidLine
+			}
+			e := &Entry{
+				Path: lineArgs[len(lineArgs)-1],
+				Dir:  true,
+			}
+			return e, e.fix()
+		case "rapidupload":
+			e := &Entry{}
+			for _, flagArg := range lineArgs[k+1:] {
+				if !strings.HasPrefix(flagArg, "-") {
+					e.Path = flagArg
+					continue
+				}
+				kv := strings.SplitN(strings.TrimLeft(flagArg, "-"), "=", 2)
+				if len(kv) != 2 {
+					return nil, ErrInvalidLine
+				}
+				switch kv[0] {
+				case "length":
+					length, err := strconv.ParseInt(kv[1], 10, 64)
+					if err != nil {
+						return nil, ErrInvalidLine
+					}
+					e.Length = length
+				case "md5":
+					e.MD5 = kv[1]
+				case "slicemd5":
+					e.SliceMD5 = kv[1]
+				case "crc32":
+					e.CRC32 = kv[1]
+				}
+			}
+			return e, e.fix()
+		}
+	}
+	return nil, ErrInvalidLine
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		var data []byte
+		data, err = enc.DecodeString(s)
+		if err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+// parseBdpan 解析 bdpan:// 链接
+func parseBdpan(line string) (*Entry, error) {
+	data, err := decodeBase64(strings.TrimPrefix(line, BdpanPrefix))
+	if err != nil {
+		return nil, ErrInvalidLine
+	}
+	fields := strings.Split(strings.TrimSpace(string(data)), "|")
+	if len(fields) < 4 {
+		return nil, ErrInvalidLine
+	}
+	length, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidLine
+	}
+	e := &Entry{
+		Path:     fields[0],
+		Length:   length,
+		MD5:      fields[2],
+		SliceMD5: fields[3],
+	}
+	return e, e.fix()
+}
+
+// parseHash 解析 md5#前256KB切片的md5#文件大小#文件名,
+// 或 md5#前256KB切片的md5#crc32#文件大小#文件名
+func parseHash(line string) (*Entry, error) {
+	fields := strings.SplitN(line, "#", 5)
+	if len(fields) == 5 && isDigits(fields[2]) && isDigits(fields[3]) {
+		length, _ := strconv.ParseInt(fields[3], 10, 64)
+		e := &Entry{
+			Path:     fields[4],
+			Length:   length,
+			MD5:      fields[0],
+			SliceMD5: fields[1],
+			CRC32:    fields[2],
+		}
+		return e, e.fix()
+	}
+
+	fields = strings.SplitN(line, "#", 4)
+	if len(fields) != 4 || !isDigits(fields[2]) {
+		return nil, ErrInvalidLine
+	}
+	length, _ := strconv.ParseInt(fields[2], 10, 64)
+	e := &Entry{
+		Path:     fields[3],
+		Length:   length,
+		MD5:      fields[0],
+		SliceMD5: fields[1],
+	}
+	return e, e.fix()
+}
+
+// parsePanDL 解析 PanDL json 格式, 支持 {"files": [...]} 或直接为数组
+func parsePanDL(data []byte) (entries []*Entry, improper int, err error) {
+	var files []*pandlFile
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		err = jsoniter.Unmarshal(data, &files)
+	} else {
+		list := pandlList{}
+		err = jsoniter.Unmarshal(data, &list)
+		files = list.Files
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, f := range files {
+		e := &Entry{
+			Path:     f.Path,
+			Length:   f.Size,
+			MD5:      f.MD5,
+			SliceMD5: f.SliceMD5,
+			CRC32:    string(f.CRC32),
+		}
+		if e.Path == "" {
+			e.Path = f.Name
+		}
+		if e.Path == "" {
+			e.Path = f.Filename
+		}
+		if e.Length == 0 {
+			e.Length = f.Length
+		}
+		if e.SliceMD5 == "" {
+			e.SliceMD5 = f.SliceAlt
+		}
+		if e.fix() != nil {
+			improper++
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, improper, nil
+}
+
+// Parse 解析秒传信息, 自动识别格式, 每行一条, 或为 PanDL json 格式.
+// 忽略空行和 # 开头的注释, 返回无法识别的行
+func Parse(r io.Reader) (entries []*Entry, improper []string, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		var n int
+		entries, n, err = parsePanDL(trimmed)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := 0; i < n; i++ {
+			improper = append(improper, "(json)")
+		}
+		return entries, improper, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		e, err := ParseLine(line)
+		if err != nil {
+			improper = append(improper, line)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, improper, scanner.Err()
+}
+
+// NewWriter 初始化 Writer
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	err := CheckFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:      w,
+		format: format,
+	}, nil
+}
+
+// Write 写入一条秒传信息, bdpan 和 hash 格式不支持空目录, 忽略
+func (ew *Writer) Write(e *Entry) (err error) {
+	switch ew.format {
+	case FormatCommand:
+		if e.Dir {
+			_, err = fmt.Fprintf(ew.w, "BaiduPCS-Go mkdir \"%s\"\n", commandPathReplacer.Replace(e.Path))
+			return
+		}
+		_, err = fmt.Fprintf(ew.w, "BaiduPCS-Go rapidupload -length=%d -md5=%s -slicemd5=%s -crc32=%s \"%s\"\n", e.Length, e.MD5, e.SliceMD5, e.CRC32, commandPathReplacer.Replace(e.Path))
+	case FormatBdpan:
+		if e.Dir {
+			return nil
+		}
+		_, err = fmt.Fprintf(ew.w, "%s%s\n", BdpanPrefix, base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s|%d|%s|%s", e.Path, e.Length, e.MD5, e.SliceMD5))))
+	case FormatHash:
+		if e.Dir {
+			return nil
+		}
+		_, err = fmt.Fprintf(ew.w, "%s#%s#%d#%s\n", e.MD5, e.SliceMD5, e.Length, e.Path)
+	case FormatPanDL:
+		if e.Dir {
+			return nil
+		}
+		ew.pandl.Files = append(ew.pandl.Files, &pandlFile{
+			Path:     e.Path,
+			Size:     e.Length,
+			MD5:      e.MD5,
+			SliceMD5: e.SliceMD5,
+			CRC32:    numberString(e.CRC32),
+		})
+	}
+	return
+}
+
+// Close 写入剩余的数据
+func (ew *Writer) Close() error {
+	if ew.format != FormatPanDL {
+		return nil
+	}
+	if ew.pandl.Files == nil {
+		ew.pandl.Files = []*pandlFile{}
+	}
+	enc := jsoniter.NewEncoder(ew.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&ew.pandl)
+}
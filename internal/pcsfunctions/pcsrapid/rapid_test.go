@@ -0,0 +1,116 @@
+package pcsrapid_test
+
+import (
+	"bytes"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsrapid"
+	"github.com/felixonmars/BaiduPCS-Go/pcsliner/args"
+	"strings"
+	"testing"
+)
+
+const (
+	testMD5      = "764efa883dda1e11db47671c4a3bbd9e"
+	testSliceMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+)
+
+func TestParse(t *testing.T) {
+	input := strings.Join([]string{
+		`BaiduPCS-Go rapidupload -length=3 -md5=` + testMD5 + ` -slicemd5=` + testSliceMD5 + ` -crc32=123 "/我的资源/a b.txt"`,
+		`BaiduPCS-Go mkdir "/我的资源/空目录"`,
+		`bdpan://YS50eHR8M3w3NjRlZmE4ODNkZGExZTExZGI0NzY3MWM0YTNiYmQ5ZXxkNDFkOGNkOThmMDBiMjA0ZTk4MDA5OThlY2Y4NDI3ZQ==`,
+		strings.ToUpper(testMD5) + `#` + testSliceMD5 + `#3#dir/c#1.txt`,
+		testMD5 + `#` + testSliceMD5 + `#123#3#d.txt`,
+		`# comment`,
+		``,
+		`not a link`,
+	}, "\n")
+
+	entries, improper, err := pcsrapid.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(improper) != 1 || improper[0] != "not a link" {
+		t.Fatalf("improper: %v", improper)
+	}
+
+	want := []pcsrapid.Entry{
+		{Path: "/我的资源/a b.txt", Length: 3, MD5: testMD5, SliceMD5: testSliceMD5, CRC32: "123"},
+		{Path: "/我的资源/空目录", Dir: true},
+		{Path: "a.txt", Length: 3, MD5: testMD5, SliceMD5: testSliceMD5},
+		{Path: "dir/c#1.txt", Length: 3, MD5: testMD5, SliceMD5: testSliceMD5},
+		{Path: "d.txt", Length: 3, MD5: testMD5, SliceMD5: testSliceMD5, CRC32: "123"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries: %d, want %d", len(entries), len(want))
+	}
+	for k := range want {
+		if *entries[k] != want[k] {
+			t.Errorf("entry %d: %+v, want %+v", k, *entries[k], want[k])
+		}
+	}
+}
+
+func TestWriteParse(t *testing.T) {
+	e := &pcsrapid.Entry{Path: "/a/b.txt", Length: 3, MD5: testMD5, SliceMD5: testSliceMD5, CRC32: "123"}
+	for _, format := range pcsrapid.FormatList {
+		buf := &bytes.Buffer{}
+		w, err := pcsrapid.NewWriter(buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(e)
+		w.Write(&pcsrapid.Entry{Path: "/empty", Dir: true})
+		w.Close()
+
+		entries, improper, err := pcsrapid.Parse(buf)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if len(improper) != 0 || len(entries) == 0 {
+			t.Fatalf("%s: entries %d, improper %v", format, len(entries), improper)
+		}
+		got := entries[0]
+		if got.Path != e.Path || got.Length != e.Length || got.MD5 != e.MD5 || got.SliceMD5 != e.SliceMD5 {
+			t.Errorf("%s: %+v, want %+v", format, *got, *e)
+		}
+	}
+}
+
+func TestWriteParseCommandPath(t *testing.T) {
+	for _, p := range []string{
+		`/a b/c.txt`,
+		`/a"b/c".txt`,
+		`/a\b/c\"d.txt`,
+		`/a\`,
+		"/a'b`c.txt",
+	} {
+		buf := &bytes.Buffer{}
+		w, err := pcsrapid.NewWriter(buf, pcsrapid.FormatCommand)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(&pcsrapid.Entry{Path: p, Length: 3, MD5: testMD5, SliceMD5: testSliceMD5, CRC32: "123"})
+		w.Write(&pcsrapid.Entry{Path: p, Dir: true})
+		w.Close()
+
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			lineArgs := args.Parse(line)
+			if got := lineArgs[len(lineArgs)-1]; got != p {
+				t.Errorf("%s: args: %q", p, lineArgs)
+			}
+		}
+
+		entries, improper, err := pcsrapid.Parse(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(improper) != 0 || len(entries) != 2 {
+			t.Fatalf("%s: entries %d, improper %v", p, len(entries), improper)
+		}
+		for _, e := range entries {
+			if e.Path != p {
+				t.Errorf("path: %s, want %s", e.Path, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Package pcssplit 超过单文件大小限制的文件, 分割为多个部分上传, 并用清单记录各部分
+package pcssplit
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/json-iterator/go"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ManifestSuffix 清单文件名的后缀, 清单与各部分保存在同一目录, 文件名为 <原文件名>.pcssplit.json
+	ManifestSuffix = ".pcssplit.json"
+	// ManifestVersion 清单格式的版本
+	ManifestVersion = 1
+
+	partInfix = ".part"
+)
+
+var (
+	// ErrInvalidManifest 清单的内容无效
+	ErrInvalidManifest = errors.New("invalid split manifest")
+	// ErrInvalidPartSize 分割的大小无效
+	ErrInvalidPartSize = errors.New("invalid split part size")
+)
+
+type (
+	// Manifest 分割上传的清单
+	Manifest struct {
+		Version  int     `json:"version"`
+		Name     string  `json:"name"`      // 原文件名
+		Size     int64   `json:"size"`      // 原文件大小
+		MD5      string  `json:"md5"`       // 原文件的 md5
+		PartSize int64   `json:"part_size"` // 分割的大小
+		Parts    []*Part `json:"parts"`
+	}
+
+	// Part 分割后的一部分
+	Part struct {
+		Name     string `json:"name"`      // 文件名, 与清单在同一目录
+		Offset   int64  `json:"offset"`    // 在原文件中的位置
+		Size     int64  `json:"size"`      // 大小
+		MD5      string `json:"md5"`       // md5
+		SliceMD5 string `json:"slice_md5"` // 前256KB切片的 md5, 用于秒传
+		CRC32    uint32 `json:"crc32"`     // crc32, 用于秒传
+	}
+
+	// sliceHash 只计算前 n 字节的摘要值
+	sliceHash struct {
+		hash.Hash
+		n int64
+	}
+)
+
+func (sh *sliceHash) Write(p []byte) (int, error) {
+	if sh.n <= 0 {
+		return len(p), nil
+	}
+	b := p
+	if int64(len(b)) > sh.n {
+		b = b[:sh.n]
+	}
+	sh.n -= int64(len(b))
+	sh.Hash.Write(b)
+	return len(p), nil
+}
+
+// ManifestName 原文件名对应的清单文件名
+func ManifestName(name string) string {
+	return name + ManifestSuffix
+}
+
+// TrimManifestSuffix 清单文件名对应的原文件名, 不是清单文件名时, ok 为 false
+func TrimManifestSuffix(filename string) (name string, ok bool) {
+	if len(filename) <= len(ManifestSuffix) || !strings.HasSuffix(filename, ManifestSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(filename, ManifestSuffix), true
+}
+
+// PartName 第 seq 个部分的文件名, seq 从 0 开始, 文件名为 <原文件名>.part001
+func PartName(name string, seq int) string {
+	return fmt.Sprintf("%s%s%03d", name, partInfix, seq+1)
+}
+
+// ParsePartName 部分的文件名对应的原文件名, 不是部分的文件名时, ok 为 false
+func ParsePartName(filename string) (name string, ok bool) {
+	i := strings.LastIndex(filename, partInfix)
+	if i <= 0 {
+		return "", false
+	}
+	digits := filename[i+len(partInfix):]
+	if len(digits) < 3 {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(digits, 10, 32); err != nil {
+		return "", false
+	}
+	return filename[:i], true
+}
+
+// Sum 从 r 读取大小为 size 的文件, 按 partSize 分割, 一次读取计算原文件和各部分的摘要值
+func Sum(r io.Reader, name string, size, partSize int64) (*Manifest, error) {
+	if partSize <= 0 {
+		return nil, ErrInvalidPartSize
+	}
+
+	var (
+		m = &Manifest{
+			Version:  ManifestVersion,
+			Name:     name,
+			Size:     size,
+			PartSize: partSize,
+		}
+		whole = md5.New()
+	)
+	for offset := int64(0); offset < size; offset += partSize {
+		n := partSize
+		if offset+n > size {
+			n = size - offset
+		}
+
+		var (
+			partMD5   = md5.New()
+			partSlice = &sliceHash{Hash: md5.New(), n: baidupcs.SliceMD5Size}
+			partCRC32 = crc32.NewIEEE()
+		)
+		_, err := io.CopyN(io.MultiWriter(whole, partMD5, partSlice, partCRC32), r, n)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		m.Parts = append(m.Parts, &Part{
+			Name:     PartName(name, len(m.Parts)),
+			Offset:   offset,
+			Size:     n,
+			MD5:      hex.EncodeToString(partMD5.Sum(nil)),
+			SliceMD5: hex.EncodeToString(partSlice.Sum(nil)),
+			CRC32:    partCRC32.Sum32(),
+		})
+	}
+	m.MD5 = hex.EncodeToString(whole.Sum(nil))
+	return m, nil
+}
+
+// Validate 检查清单, 各部分须连续, 文件名不能含有路径
+func (m *Manifest) Validate() error {
+	if m.Version != ManifestVersion || m.Name == "" || m.Size < 0 || len(m.MD5) != 32 {
+		return ErrInvalidManifest
+	}
+	var offset int64
+	for _, part := range m.Parts {
+		if part == nil || part.Offset != offset || part.Size <= 0 || len(part.MD5) != 32 {
+			return ErrInvalidManifest
+		}
+		if part.Name == "" || part.Name == "." || part.Name == ".." || strings.ContainsAny(part.Name, "/\\") {
+			return ErrInvalidManifest
+		}
+		offset += part.Size
+	}
+	if offset != m.Size {
+		return ErrInvalidManifest
+	}
+	return nil
+}
+
+// Marshal 编码清单
+func (m *Manifest) Marshal() ([]byte, error) {
+	return jsoniter.MarshalIndent(m, "", "  ")
+}
+
+// ParseManifest 解析并检查清单, 内容无效时返回 ErrInvalidManifest
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	err := jsoniter.Unmarshal(data, m)
+	if err != nil {
+		return nil, ErrInvalidManifest
+	}
+	err = m.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
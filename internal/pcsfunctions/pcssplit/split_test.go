@@ -0,0 +1,106 @@
+package pcssplit_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcssplit"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	data := make([]byte, 700*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	m, err := pcssplit.Sum(bytes.NewReader(data), "disk.img", int64(len(data)), 300*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	wholeMD5 := md5.Sum(data)
+	if m.MD5 != hex.EncodeToString(wholeMD5[:]) || m.Size != int64(len(data)) {
+		t.Fatalf("manifest: %+v", m)
+	}
+	if len(m.Parts) != 3 {
+		t.Fatalf("parts: %d", len(m.Parts))
+	}
+	for k, part := range m.Parts {
+		b := data[part.Offset : part.Offset+part.Size]
+		slice := b
+		if len(slice) > 256*1024 {
+			slice = slice[:256*1024]
+		}
+		partMD5, sliceMD5 := md5.Sum(b), md5.Sum(slice)
+		if part.MD5 != hex.EncodeToString(partMD5[:]) || part.SliceMD5 != hex.EncodeToString(sliceMD5[:]) || part.CRC32 != crc32.ChecksumIEEE(b) {
+			t.Fatalf("part %d: %+v", k, part)
+		}
+		if part.Name != pcssplit.PartName("disk.img", k) {
+			t.Fatalf("part %d name: %s", k, part.Name)
+		}
+	}
+	if m.Parts[2].Size != 100*1024 {
+		t.Fatalf("last part size: %d", m.Parts[2].Size)
+	}
+
+	// 数据不足
+	_, err = pcssplit.Sum(bytes.NewReader(data[:1000]), "disk.img", int64(len(data)), 300*1024)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	m, err := pcssplit.Sum(bytes.NewReader([]byte("hello world")), "a.txt", 11, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := pcssplit.ParseManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.MD5 != m.MD5 || len(parsed.Parts) != 3 || parsed.Parts[2].Name != "a.txt.part003" {
+		t.Fatalf("parsed: %+v", parsed)
+	}
+
+	// 部分不连续
+	parsed.Parts[1].Offset++
+	if parsed.Validate() == nil {
+		t.Fatal("expected invalid manifest")
+	}
+	parsed.Parts[1].Offset--
+
+	// 部分的文件名含有路径
+	parsed.Parts[0].Name = "../a.txt.part001"
+	if parsed.Validate() == nil {
+		t.Fatal("expected invalid manifest")
+	}
+}
+
+func TestNames(t *testing.T) {
+	name, ok := pcssplit.ParsePartName("a.part.img.part012")
+	if !ok || name != "a.part.img" {
+		t.Fatalf("ParsePartName: %s, %v", name, ok)
+	}
+	for _, filename := range []string{"a.part1", "a.partabc", ".part001", "a.img"} {
+		if _, ok = pcssplit.ParsePartName(filename); ok {
+			t.Fatalf("ParsePartName(%s): expected not ok", filename)
+		}
+	}
+
+	name, ok = pcssplit.TrimManifestSuffix(pcssplit.ManifestName("a.img"))
+	if !ok || name != "a.img" {
+		t.Fatalf("TrimManifestSuffix: %s, %v", name, ok)
+	}
+	if _, ok = pcssplit.TrimManifestSuffix(pcssplit.ManifestSuffix); ok {
+		t.Fatal("TrimManifestSuffix: expected not ok")
+	}
+}
@@ -0,0 +1,195 @@
+package pcsupload
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// OnConflictOverwrite 网盘文件已存在时, 覆盖
+	OnConflictOverwrite = "overwrite"
+	// OnConflictNewCopy 网盘文件已存在时, 由服务器生成文件副本并重命名
+	OnConflictNewCopy = "newcopy"
+	// OnConflictSkip 网盘文件已存在时, 跳过
+	OnConflictSkip = "skip"
+	// OnConflictSkipIdentical 网盘文件已存在且内容相同时, 跳过, 否则覆盖
+	OnConflictSkipIdentical = "skip-identical"
+	// OnConflictFail 网盘文件已存在时, 上传失败
+	OnConflictFail = "fail"
+)
+
+const (
+	// ConflictNone 网盘文件不存在
+	ConflictNone Conflict = iota
+	// ConflictIdentical 网盘文件已存在, 内容相同
+	ConflictIdentical
+	// ConflictDifferent 网盘文件已存在, 内容不同
+	ConflictDifferent
+	// ConflictExists 网盘文件已存在, 未比较内容
+	ConflictExists
+	// ConflictDir 网盘路径为目录
+	ConflictDir
+	// ConflictUnknown 检测失败
+	ConflictUnknown
+)
+
+var (
+	// ErrTargetExists 网盘文件已存在
+	ErrTargetExists = errors.New("target file exists")
+	// ErrUnknownOnConflict 未知的处理方式
+	ErrUnknownOnConflict = errors.New("unknown on-conflict, supported: " + strings.Join(OnConflictList, ", "))
+
+	// OnConflictList 支持的网盘文件已存在时的处理方式
+	OnConflictList = []string{OnConflictOverwrite, OnConflictNewCopy, OnConflictSkip, OnConflictSkipIdentical, OnConflictFail}
+)
+
+type (
+	// Conflict 上传的网盘文件的状态
+	Conflict int
+
+	// ConflictChecker 上传前检测网盘文件是否已存在, 缓存目录的列表
+	ConflictChecker struct {
+		pcs     *baidupcs.BaiduPCS
+		compare bool                                          // 大小相同时, 比较 md5
+		dirs    map[string]map[string]*baidupcs.FileDirectory // 目录 => 文件名 => 文件, 目录不存在则为 nil
+	}
+)
+
+func (c Conflict) String() string {
+	switch c {
+	case ConflictNone:
+		return "新文件"
+	case ConflictIdentical:
+		return "已存在, 内容相同"
+	case ConflictDifferent:
+		return "已存在, 内容不同"
+	case ConflictExists:
+		return "已存在"
+	case ConflictDir:
+		return "网盘路径为目录"
+	}
+	return "检测失败"
+}
+
+// CheckOnConflict 检查处理方式是否支持, 空为 skip-identical
+func CheckOnConflict(onConflict string) error {
+	if onConflict == "" {
+		return nil
+	}
+	for _, s := range OnConflictList {
+		if s == onConflict {
+			return nil
+		}
+	}
+	return ErrUnknownOnConflict
+}
+
+// Ondup 处理方式对应的服务器 ondup 参数.
+// skip 和 fail 由客户端在上传前检测, 服务器参数仍为覆盖
+func Ondup(onConflict string) string {
+	if onConflict == OnConflictNewCopy {
+		return baidupcs.OndupNewCopy
+	}
+	return baidupcs.OndupOverwrite
+}
+
+// ShouldUpload 网盘文件的状态为 c 时, 按处理方式是否上传
+func ShouldUpload(onConflict string, c Conflict) bool {
+	switch c {
+	case ConflictNone, ConflictUnknown:
+		return true
+	case ConflictDir:
+		return false
+	}
+
+	switch onConflict {
+	case OnConflictSkip, OnConflictFail:
+		return false
+	case OnConflictOverwrite, OnConflictNewCopy:
+		return true
+	}
+	// skip-identical
+	return c != ConflictIdentical
+}
+
+// NewConflictChecker 初始化 ConflictChecker, compare 为 true 时, 比较大小相同的文件的 md5
+func NewConflictChecker(pcs *baidupcs.BaiduPCS, compare bool) *ConflictChecker {
+	return &ConflictChecker{
+		pcs:     pcs,
+		compare: compare,
+		dirs:    map[string]map[string]*baidupcs.FileDirectory{},
+	}
+}
+
+func (cc *ConflictChecker) list(dir string) (map[string]*baidupcs.FileDirectory, pcserror.Error) {
+	files, ok := cc.dirs[dir]
+	if ok {
+		return files, nil
+	}
+
+	fdl, pcsError := cc.pcs.FilesDirectoriesList(dir, baidupcs.DefaultOrderOptions)
+	if pcsError != nil {
+		if pcsError.GetErrType() != pcserror.ErrTypeRemoteError || pcsError.GetRemoteErrCode() != 31066 {
+			return nil, pcsError
+		}
+		// file does not exist
+	} else {
+		files = make(map[string]*baidupcs.FileDirectory, len(fdl))
+		for _, fd := range fdl {
+			files[fd.Filename] = fd
+		}
+	}
+	cc.dirs[dir] = files
+	return files, nil
+}
+
+// Check 检测本地文件 localPath 上传到 savePath 时, 网盘文件的状态
+func (cc *ConflictChecker) Check(localPath, savePath string) (Conflict, error) {
+	dir, name := path.Split(savePath)
+	files, pcsError := cc.list(path.Clean(dir))
+	if pcsError != nil {
+		return ConflictUnknown, pcsError
+	}
+	fd := files[name]
+	if fd == nil {
+		return ConflictNone, nil
+	}
+	if fd.Isdir {
+		return ConflictDir, nil
+	}
+	if !cc.compare {
+		return ConflictExists, nil
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return ConflictUnknown, err
+	}
+	if info.Size() != fd.Size {
+		return ConflictDifferent, nil
+	}
+
+	lfc := checksum.NewLocalFileChecksum(localPath, int(baidupcs.SliceMD5Size))
+	err = lfc.OpenPath()
+	if err != nil {
+		return ConflictUnknown, err
+	}
+	defer lfc.Close()
+	err = lfc.SumWithCache(pcsfunctions.ChecksumCache(), checksum.CHECKSUM_MD5)
+	if err != nil {
+		return ConflictUnknown, err
+	}
+
+	// 网盘记录的 md5 可能是错误的, 此时按内容不同处理
+	if hex.EncodeToString(lfc.MD5) == fd.MD5 {
+		return ConflictIdentical, nil
+	}
+	return ConflictDifferent, nil
+}
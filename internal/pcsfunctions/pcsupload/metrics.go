@@ -0,0 +1,17 @@
+package pcsupload
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/metrics"
+)
+
+var (
+	rapidUploadAttemptsTotal = metrics.NewCounter("baidupcs_rapidupload_attempts_total", "秒传的尝试次数")
+	rapidUploadHitsTotal     = metrics.NewCounter("baidupcs_rapidupload_hits_total", "秒传的成功次数")
+	_                        = metrics.NewGaugeFunc("baidupcs_rapidupload_hit_ratio", "秒传的命中率", func() float64 {
+		attempts := rapidUploadAttemptsTotal.Value()
+		if attempts == 0 {
+			return 0
+		}
+		return rapidUploadHitsTotal.Value() / attempts
+	})
+)
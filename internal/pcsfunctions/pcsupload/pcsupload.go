@@ -2,7 +2,7 @@
 package pcsupload
 
 import (
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 )
 
 const (
@@ -10,5 +10,5 @@ const (
 )
 
 var (
-	pcsUploadVerbose = pcsverbose.New("PCSUPLOAD")
+	pcsUploadVerbose = pcslog.New("PCSUPLOAD")
 )
@@ -0,0 +1,159 @@
+package pcsupload
+
+import (
+	"bytes"
+	"context"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcssplit"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"github.com/felixonmars/BaiduPCS-Go/requester/uploader"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+)
+
+type (
+	// SplitUploadOptions 分割上传可选参数
+	SplitUploadOptions struct {
+		Parallel      int  // 每个部分同时上传的分片数量
+		MaxRetry      int  // 每个部分上传失败最大重试次数
+		NoRapidUpload bool // 禁用秒传
+
+		// PartUploaded 一个部分上传完成时调用, rapid 为是否秒传成功
+		PartUploaded func(seq int, part *pcssplit.Part, rapid bool)
+		// BlockUploaded 分片上传完成时调用, 可能被并发调用
+		BlockUploaded func(size int64)
+	}
+)
+
+// SplitUpload 按清单 m 将本地文件 f 的各部分上传到网盘目录 dir, 最后上传清单.
+// 各部分先尝试秒传, 失败重试时, 已上传的部分可以秒传, 不需要重新上传
+func SplitUpload(pcs *baidupcs.BaiduPCS, f *os.File, m *pcssplit.Manifest, dir string, opt *SplitUploadOptions) error {
+	if opt == nil {
+		opt = &SplitUploadOptions{}
+	}
+	if opt.Parallel <= 0 {
+		opt.Parallel = 1
+	}
+
+	for seq, part := range m.Parts {
+		partPath := path.Join(dir, part.Name)
+		for times := 0; ; times++ {
+			rapid, err := uploadSplitPart(pcs, f, part, partPath, opt)
+			if err == nil {
+				if opt.PartUploaded != nil {
+					opt.PartUploaded(seq, part, rapid)
+				}
+				break
+			}
+			if times >= opt.MaxRetry || pcs.Context().Err() != nil || !pcsfunctions.IsRetryable(err) {
+				return err
+			}
+
+			pcsUploadVerbose.Warn("split part upload failed, retrying", "part", partPath, "retry", times+1, "err", err)
+			if retry.Sleep(pcs.Context(), pcsfunctions.RetryWaitErr(times+1, err)) != nil {
+				return pcs.Context().Err()
+			}
+		}
+	}
+
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = StreamUpload(pcs, bytes.NewReader(data), path.Join(dir, pcssplit.ManifestName(m.Name)), &StreamUploadOptions{
+		MaxRetry: opt.MaxRetry,
+	})
+	return err
+}
+
+// uploadSplitPart 上传一个部分, 先尝试秒传
+func uploadSplitPart(pcs *baidupcs.BaiduPCS, f *os.File, part *pcssplit.Part, partPath string, opt *SplitUploadOptions) (rapid bool, err error) {
+	if !opt.NoRapidUpload && part.Size <= baidupcs.MaxRapidUploadSize {
+		rapidUploadAttemptsTotal.Inc()
+		pcsError := pcs.RapidUpload(partPath, baidupcs.OndupOverwrite, part.MD5, part.SliceMD5, strconv.FormatUint(uint64(part.CRC32), 10), part.Size)
+		if pcsError == nil {
+			rapidUploadHitsTotal.Inc()
+			return true, nil
+		}
+		pcsUploadVerbose.Debug("split part rapid upload failed", "part", partPath, "err", pcsError)
+	}
+	return false, uploadFileSection(pcs, f, part.Offset, part.Size, partPath, opt)
+}
+
+// uploadFileSection 上传本地文件 f 从 offset 开始, 大小为 size 的部分到网盘的 targetPath
+func uploadFileSection(pcs *baidupcs.BaiduPCS, f *os.File, offset, size int64, targetPath string, opt *SplitUploadOptions) error {
+	return uploadSection(pcs.Context(), &PCSUpload{pcs: pcs, targetPath: targetPath}, f, offset, size, opt)
+}
+
+// uploadSection 将本地文件 f 从 offset 开始, 大小为 size 的部分分片并发上传到 mu, 最后合并分片.
+// ctx 取消时, 返回 ctx 的错误, 不合并分片
+func uploadSection(parent context.Context, mu uploader.MultiUpload, f *os.File, offset, size int64, opt *SplitUploadOptions) error {
+	var (
+		blockSize   = getBlockSize(size)
+		blocks      = int((size + blockSize - 1) / blockSize)
+		checksums   = make([]string, blocks)
+		ctx, cancel = context.WithCancel(parent)
+		sem         = make(chan struct{}, opt.Parallel)
+		wg          sync.WaitGroup
+		errMu       sync.Mutex
+		uploadErr   error
+	)
+	defer cancel()
+
+	for seq := 0; seq < blocks; seq++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		blk := &streamBlock{
+			seq:        seq,
+			offset:     int64(seq) * blockSize,
+			size:       blockSize,
+			file:       f,
+			fileOffset: offset + int64(seq)*blockSize,
+		}
+		if blk.offset+blk.size > size {
+			blk.size = size - blk.offset
+		}
+
+		wg.Add(1)
+		go func(blk *streamBlock) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			checksum, err := uploadStreamBlock(ctx, mu, blk, opt.MaxRetry)
+			if err != nil {
+				errMu.Lock()
+				if uploadErr == nil {
+					uploadErr = err
+				}
+				errMu.Unlock()
+				cancel()
+				return
+			}
+			checksums[blk.seq] = checksum
+			if opt.BlockUploaded != nil {
+				opt.BlockUploaded(blk.size)
+			}
+		}(blk)
+	}
+	wg.Wait()
+
+	if uploadErr != nil {
+		return uploadErr
+	}
+	if err := ctx.Err(); err != nil {
+		// 等待上传时被取消, 部分分片未上传
+		return err
+	}
+	return mu.CreateSuperFile(checksums...)
+}
@@ -0,0 +1,120 @@
+package pcsupload
+
+import (
+	"context"
+	"errors"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/requester/rio"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeMultiUpload 记录上传的分片, block 不为空时, 上传分片前等待 block 关闭或 ctx 取消
+type fakeMultiUpload struct {
+	block  chan struct{}
+	failed map[int]int // 每个分片前几次上传失败
+
+	mu       sync.Mutex
+	uploaded map[int]int // 每个分片上传的次数
+	running  int         // 正在上传的分片数量
+	peak     int         // 同时上传的最大分片数量
+	merged   []string
+	started  chan int
+	onUpload func() // 开始上传分片时调用
+}
+
+func newFakeMultiUpload() *fakeMultiUpload {
+	return &fakeMultiUpload{
+		failed:   map[int]int{},
+		uploaded: map[int]int{},
+		started:  make(chan int, 1000),
+	}
+}
+
+func (fm *fakeMultiUpload) Precreate() error {
+	return nil
+}
+
+func (fm *fakeMultiUpload) TmpFile(ctx context.Context, partseq int, partOffset int64, r rio.ReaderLen64) (string, error) {
+	fm.mu.Lock()
+	fm.uploaded[partseq]++
+	times := fm.uploaded[partseq]
+	fm.running++
+	if fm.running > fm.peak {
+		fm.peak = fm.running
+	}
+	if fm.onUpload != nil {
+		fm.onUpload()
+	}
+	fm.mu.Unlock()
+	defer func() {
+		fm.mu.Lock()
+		fm.running--
+		fm.mu.Unlock()
+	}()
+	fm.started <- partseq
+
+	if fm.block != nil {
+		select {
+		case <-fm.block:
+		case <-ctx.Done():
+		}
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if times <= fm.failed[partseq] {
+		return "", errors.New("connection reset by peer")
+	}
+	return strconv.Itoa(partseq) + ":" + strconv.Itoa(len(data)), nil
+}
+
+func (fm *fakeMultiUpload) CreateSuperFile(checksumList ...string) error {
+	fm.mu.Lock()
+	fm.merged = checksumList
+	fm.mu.Unlock()
+	return nil
+}
+
+func TestUploadSectionCanceled(t *testing.T) {
+	f, err := ioutil.TempFile("", "split_upload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// 2 个分片
+	err = f.Truncate(baidupcs.MinUploadBlockSize + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		fm          = newFakeMultiUpload()
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	fm.block = make(chan struct{}) // 取消时, 第一个分片仍上传成功
+	go func() {
+		<-fm.started
+		cancel()
+	}()
+
+	err = uploadSection(ctx, fm, f, 0, baidupcs.MinUploadBlockSize+1, &SplitUploadOptions{
+		Parallel: 1,
+	})
+	if err != context.Canceled {
+		t.Fatalf("err: %v, want: %v", err, context.Canceled)
+	}
+	if fm.merged != nil {
+		t.Fatalf("merged after cancel: %v", fm.merged)
+	}
+	if fm.uploaded[1] != 0 {
+		t.Fatalf("block 1 uploaded after cancel")
+	}
+}
@@ -0,0 +1,243 @@
+package pcsupload
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"github.com/felixonmars/BaiduPCS-Go/requester/uploader"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+const (
+	// MaxStreamUploadBlocks 流式上传最大的分片数量
+	MaxStreamUploadBlocks = 999
+	// StreamBlockTier 流式上传时, 每上传多少个分片, 分片大小增大为4倍
+	StreamBlockTier = 250
+)
+
+var (
+	// ErrStreamTooLarge 数据流过大, 分片数量超出限制
+	ErrStreamTooLarge = errors.New("数据流过大, 分片数量超出限制")
+)
+
+type (
+	// StreamUploadOptions 流式上传可选参数
+	StreamUploadOptions struct {
+		SpillDir string // 暂存分片的目录, 为空则使用系统临时目录
+		Parallel int    // 同时上传的分片数量, 也是最多暂存的分片数量
+		MaxRetry int    // 单个分片上传失败最大重试次数
+
+		// BlockUploaded 分片上传完成时调用, 可能被并发调用
+		BlockUploaded func(seq int, size int64)
+	}
+
+	// StreamUploadResult 流式上传的结果
+	StreamUploadResult struct {
+		Size   int64  // 数据总量
+		MD5    []byte // 整个数据流的 md5
+		Blocks int    // 分片数量
+	}
+
+	streamBlock struct {
+		seq    int
+		offset int64
+		size   int64
+		file   *os.File
+
+		fileOffset int64 // 分片在 file 中的位置
+	}
+
+	// sectionReaderLen64 为 io.SectionReader 实现 rio.ReaderLen64 接口
+	sectionReaderLen64 struct {
+		*io.SectionReader
+	}
+)
+
+func (sr sectionReaderLen64) Len() int64 {
+	return sr.Size()
+}
+
+// StreamBlockSize 流式上传第 seq 个分片的大小.
+// 数据流的长度未知, 分片逐渐增大: 4MB, 16MB, 64MB, 256MB 各 StreamBlockTier 个,
+// 使分片数量不超过 MaxStreamUploadBlocks, 最多可上传约 80GB
+func StreamBlockSize(seq int) int64 {
+	tier := seq / StreamBlockTier
+	if tier > 3 {
+		tier = 3
+	}
+	return baidupcs.MinUploadBlockSize << uint(2*tier)
+}
+
+// StreamUpload 上传数据流到网盘的 targetPath, 不需要知道数据流的长度.
+// 数据流按分片暂存到 SpillDir, 边读取边上传, 分片上传完成后删除暂存文件,
+// 最后合并分片, 同时只暂存 Parallel 个分片, 不会将整个数据流写入磁盘
+func StreamUpload(pcs *baidupcs.BaiduPCS, r io.Reader, targetPath string, opt *StreamUploadOptions) (result *StreamUploadResult, err error) {
+	pu := &PCSUpload{pcs: pcs, targetPath: targetPath}
+	return streamUpload(pcs.Context(), pu, r, opt, func() error {
+		pcsError := pu.uploadEmptyFile(pu.ondup)
+		if pcsError != nil {
+			return pcsError
+		}
+		return nil
+	})
+}
+
+// streamUpload 将数据流分片上传到 mu, 数据流为空时调用 uploadEmpty 上传空文件
+func streamUpload(parent context.Context, mu uploader.MultiUpload, r io.Reader, opt *StreamUploadOptions, uploadEmpty func() error) (result *StreamUploadResult, err error) {
+	if opt == nil {
+		opt = &StreamUploadOptions{}
+	}
+	if opt.Parallel <= 0 {
+		opt.Parallel = 1
+	}
+
+	var (
+		md5w        = md5.New()
+		tee         = io.TeeReader(r, md5w)
+		ctx, cancel = context.WithCancel(parent)
+		sem         = make(chan struct{}, opt.Parallel)
+		checksums   = make([]string, MaxStreamUploadBlocks)
+		wg          sync.WaitGroup
+		errMu       sync.Mutex
+		uploadErr   error
+	)
+	defer cancel()
+
+	result = &StreamUploadResult{}
+	setErr := func(e error) {
+		errMu.Lock()
+		if uploadErr == nil {
+			uploadErr = e
+		}
+		errMu.Unlock()
+		cancel()
+	}
+
+	for seq := 0; ; seq++ {
+		if seq >= MaxStreamUploadBlocks {
+			// 检测是否还有数据
+			n, _ := io.ReadFull(tee, make([]byte, 1))
+			if n > 0 {
+				setErr(ErrStreamTooLarge)
+			}
+			break
+		}
+
+		// 等待暂存的分片上传完成
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		blk, readErr := spillStreamBlock(tee, opt.SpillDir, seq, result.Size)
+		if blk == nil {
+			<-sem
+			if readErr != nil {
+				setErr(readErr)
+			}
+			break
+		}
+
+		result.Size += blk.size
+		result.Blocks++
+		wg.Add(1)
+		go func(blk *streamBlock) {
+			defer func() {
+				blk.file.Close()
+				os.Remove(blk.file.Name())
+				<-sem
+				wg.Done()
+			}()
+
+			checksum, err := uploadStreamBlock(ctx, mu, blk, opt.MaxRetry)
+			if err != nil {
+				setErr(err)
+				return
+			}
+			checksums[blk.seq] = checksum
+			if opt.BlockUploaded != nil {
+				opt.BlockUploaded(blk.seq, blk.size)
+			}
+		}(blk)
+
+		if readErr != nil {
+			if readErr != io.EOF {
+				setErr(readErr)
+			}
+			break
+		}
+	}
+	wg.Wait()
+
+	if uploadErr != nil {
+		return result, uploadErr
+	}
+	if err = ctx.Err(); err != nil {
+		// 等待上传时被取消, 数据流未读完
+		return result, err
+	}
+	result.MD5 = md5w.Sum(nil)
+
+	if result.Blocks == 0 {
+		return result, uploadEmpty()
+	}
+	return result, mu.CreateSuperFile(checksums[:result.Blocks]...)
+}
+
+// spillStreamBlock 从数据流读取一个分片, 写入暂存文件,
+// 数据流已读完时, 返回的分片为 nil, err 为 nil; 读取到最后一个分片时, err 为 io.EOF
+func spillStreamBlock(r io.Reader, spillDir string, seq int, offset int64) (blk *streamBlock, err error) {
+	file, err := ioutil.TempFile(spillDir, "BaiduPCS-Go-stream-")
+	if err != nil {
+		return nil, err
+	}
+
+	size := StreamBlockSize(seq)
+	n, err := io.CopyN(file, r, size)
+	if n == 0 || (err != nil && err != io.EOF) {
+		file.Close()
+		os.Remove(file.Name())
+		if err == io.EOF {
+			err = nil
+		}
+		return nil, err
+	}
+
+	pcsUploadVerbose.Debug("stream block spilled", "seq", seq, "offset", offset, "size", n, "file", file.Name())
+	return &streamBlock{
+		seq:    seq,
+		offset: offset,
+		size:   n,
+		file:   file,
+	}, err
+}
+
+// uploadStreamBlock 上传暂存的分片, 失败时重试
+func uploadStreamBlock(ctx context.Context, mu uploader.MultiUpload, blk *streamBlock, maxRetry int) (checksum string, err error) {
+	for times := 0; ; times++ {
+		checksum, err = mu.TmpFile(ctx, blk.seq, blk.offset, sectionReaderLen64{io.NewSectionReader(blk.file, blk.fileOffset, blk.size)})
+		if err == nil {
+			return checksum, nil
+		}
+		if me, ok := err.(*uploader.MultiError); ok && me.Terminated {
+			return "", me
+		}
+		if times >= maxRetry || ctx.Err() != nil || !pcsfunctions.IsRetryable(err) {
+			return "", err
+		}
+
+		pcsUploadVerbose.Warn("stream block upload failed, retrying", "seq", blk.seq, "retry", times+1, "err", err)
+		if retry.Sleep(ctx, pcsfunctions.RetryWaitErr(times+1, err)) != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package pcsupload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStreamBlockSize(t *testing.T) {
+	for _, c := range []struct {
+		seq  int
+		size int64
+	}{
+		{0, baidupcs.MinUploadBlockSize},
+		{StreamBlockTier - 1, baidupcs.MinUploadBlockSize},
+		{StreamBlockTier, 4 * baidupcs.MinUploadBlockSize},
+		{2*StreamBlockTier - 1, 4 * baidupcs.MinUploadBlockSize},
+		{2 * StreamBlockTier, 16 * baidupcs.MinUploadBlockSize},
+		{3 * StreamBlockTier, 64 * baidupcs.MinUploadBlockSize},
+		{MaxStreamUploadBlocks - 1, 64 * baidupcs.MinUploadBlockSize},
+		{10 * StreamBlockTier, 64 * baidupcs.MinUploadBlockSize},
+	} {
+		if size := StreamBlockSize(c.seq); size != c.size {
+			t.Errorf("seq %d: size %d, want %d", c.seq, size, c.size)
+		}
+	}
+
+	// 分片数量不超过限制时, 最多可上传约 80GB
+	var total int64
+	for seq := 0; seq < MaxStreamUploadBlocks; seq++ {
+		total += StreamBlockSize(seq)
+	}
+	if total < 80<<30 {
+		t.Errorf("max stream size: %d", total)
+	}
+}
+
+// newStreamTestData 返回 blocks 个完整分片, 再加上 tail 字节的数据
+func newStreamTestData(blocks int, tail int64) []byte {
+	data := make([]byte, int64(blocks)*baidupcs.MinUploadBlockSize+tail)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+// countSpillFiles 返回暂存目录中的文件数量
+func countSpillFiles(t *testing.T, dir string) int {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(fis)
+}
+
+func TestStreamUpload(t *testing.T) {
+	defer func(d time.Duration) {
+		pcsfunctions.RetryPolicy.BaseDelay = d
+	}(pcsfunctions.RetryPolicy.BaseDelay)
+	pcsfunctions.RetryPolicy.BaseDelay = time.Millisecond
+
+	spillDir, err := ioutil.TempDir("", "stream_upload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spillDir)
+
+	var (
+		data      = newStreamTestData(4, 100)
+		fm        = newFakeMultiUpload()
+		maxSpills int
+		uploaded  int64
+	)
+	fm.failed[1] = 1 // 第2个分片失败一次后重试
+	fm.onUpload = func() {
+		if n := countSpillFiles(t, spillDir); n > maxSpills {
+			maxSpills = n
+		}
+	}
+
+	result, err := streamUpload(context.Background(), fm, bytes.NewReader(data), &StreamUploadOptions{
+		SpillDir: spillDir,
+		Parallel: 2,
+		MaxRetry: 1,
+		BlockUploaded: func(seq int, size int64) {
+			fm.mu.Lock()
+			uploaded += size
+			fm.mu.Unlock()
+		},
+	}, func() error {
+		t.Fatal("upload empty file")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := md5.Sum(data)
+	if result.Size != int64(len(data)) || result.Blocks != 5 || !bytes.Equal(result.MD5, sum[:]) {
+		t.Fatalf("result: size %d, blocks %d, md5 %x", result.Size, result.Blocks, result.MD5)
+	}
+	if uploaded != int64(len(data)) {
+		t.Errorf("uploaded: %d", uploaded)
+	}
+
+	want := make([]string, 5)
+	for i := range want {
+		want[i] = strconv.Itoa(i) + ":" + strconv.FormatInt(baidupcs.MinUploadBlockSize, 10)
+	}
+	want[4] = "4:100"
+	if !reflect.DeepEqual(fm.merged, want) {
+		t.Errorf("merged: %v, want: %v", fm.merged, want)
+	}
+	if fm.uploaded[1] != 2 {
+		t.Errorf("block 1 uploaded %d times, want 2", fm.uploaded[1])
+	}
+
+	// 同时上传和暂存的分片不超过 Parallel, 上传完成后删除暂存文件
+	if fm.peak > 2 || maxSpills > 2 {
+		t.Errorf("peak uploads: %d, max spills: %d", fm.peak, maxSpills)
+	}
+	if n := countSpillFiles(t, spillDir); n != 0 {
+		t.Errorf("%d spill files left", n)
+	}
+}
+
+func TestStreamUploadRetryExceeded(t *testing.T) {
+	defer func(d time.Duration) {
+		pcsfunctions.RetryPolicy.BaseDelay = d
+	}(pcsfunctions.RetryPolicy.BaseDelay)
+	pcsfunctions.RetryPolicy.BaseDelay = time.Millisecond
+
+	spillDir, err := ioutil.TempDir("", "stream_upload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spillDir)
+
+	fm := newFakeMultiUpload()
+	fm.failed[0] = 3
+	_, err = streamUpload(context.Background(), fm, bytes.NewReader(newStreamTestData(0, 100)), &StreamUploadOptions{
+		SpillDir: spillDir,
+		MaxRetry: 2,
+	}, nil)
+	if err == nil {
+		t.Fatal("upload should fail")
+	}
+	if fm.uploaded[0] != 3 || fm.merged != nil {
+		t.Fatalf("uploaded %d times, merged: %v", fm.uploaded[0], fm.merged)
+	}
+	if n := countSpillFiles(t, spillDir); n != 0 {
+		t.Errorf("%d spill files left", n)
+	}
+}
+
+func TestStreamUploadEmpty(t *testing.T) {
+	var (
+		fm    = newFakeMultiUpload()
+		empty bool
+	)
+	result, err := streamUpload(context.Background(), fm, bytes.NewReader(nil), nil, func() error {
+		empty = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !empty || result.Blocks != 0 || len(fm.uploaded) != 0 || fm.merged != nil {
+		t.Fatalf("empty: %t, blocks: %d, uploaded: %v, merged: %v", empty, result.Blocks, fm.uploaded, fm.merged)
+	}
+}
+
+func TestStreamUploadCanceled(t *testing.T) {
+	spillDir, err := ioutil.TempDir("", "stream_upload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spillDir)
+
+	var (
+		fm          = newFakeMultiUpload()
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	fm.block = make(chan struct{}) // 取消时, 正在上传的分片仍上传成功
+	go func() {
+		<-fm.started
+		cancel()
+	}()
+
+	_, err = streamUpload(ctx, fm, bytes.NewReader(newStreamTestData(2, 0)), &StreamUploadOptions{
+		SpillDir: spillDir,
+		Parallel: 1,
+	}, nil)
+	if err != context.Canceled {
+		t.Fatalf("err: %v, want: %v", err, context.Canceled)
+	}
+	if fm.merged != nil || fm.uploaded[1] != 0 {
+		t.Fatalf("uploaded: %v, merged: %v", fm.uploaded, fm.merged)
+	}
+	if n := countSpillFiles(t, spillDir); n != 0 {
+		t.Errorf("%d spill files left", n)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
 	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"github.com/felixonmars/BaiduPCS-Go/requester/multipartreader"
 	"github.com/felixonmars/BaiduPCS-Go/requester/rio"
@@ -17,6 +18,7 @@ type (
 	PCSUpload struct {
 		pcs        *baidupcs.BaiduPCS
 		targetPath string
+		ondup      string // 同名文件已存在时的处理方式, 为空则覆盖
 	}
 
 	EmptyReaderLen64 struct {
@@ -54,7 +56,7 @@ func (pu *PCSUpload) TmpFile(ctx context.Context, partseq int, partOffset int64,
 
 	var respErr *uploader.MultiError
 	checksum, pcsError := pu.pcs.UploadTmpFile(func(uploadURL string, jar http.CookieJar) (resp *http.Response, err error) {
-		client := pcsconfig.Config.PCSHTTPClient()
+		client := pcsconfig.Config.UploadHTTPClient(pcsconfig.Config.PCSUA)
 		client.SetCookiejar(jar)
 		client.SetTimeout(0)
 
@@ -87,6 +89,12 @@ func (pu *PCSUpload) TmpFile(ctx context.Context, partseq int, partOffset int64,
 		return
 	})
 
+	if respErr == nil && pcsError != nil && ctx.Err() == nil && !pcsfunctions.IsRetryable(pcsError) {
+		// 按错误的类别, 不可恢复的错误, 如登录状态失效, 网盘容量已满
+		respErr = &uploader.MultiError{
+			Terminated: true,
+		}
+	}
 	if respErr != nil {
 		respErr.Err = pcsError
 		return checksum, respErr
@@ -95,25 +103,45 @@ func (pu *PCSUpload) TmpFile(ctx context.Context, partseq int, partOffset int64,
 	return checksum, pcsError
 }
 
-func (pu *PCSUpload) CreateSuperFile(checksumList ...string) (err error) {
+// uploadEmptyFile 在网盘目标位置, 上传一个空文件
+func (pu *PCSUpload) uploadEmptyFile(ondup string) pcserror.Error {
 	pu.lazyInit()
-
-	// 先在网盘目标位置, 上传一个空文件
-	// 防止出现file does not exist
-	pcsError := pu.pcs.Upload(pu.targetPath, func(uploadURL string, jar http.CookieJar) (resp *http.Response, err error) {
+	return pu.pcs.Upload(pu.targetPath, ondup, func(uploadURL string, jar http.CookieJar) (resp *http.Response, err error) {
 		mr := multipartreader.NewMultipartReader()
 		mr.AddFormFile("file", "file", &EmptyReaderLen64{})
 		mr.CloseMultipart()
 
-		c := requester.NewHTTPClient()
+		c := pcsconfig.Config.UploadHTTPClient(requester.UserAgent)
 		c.SetCookiejar(jar)
 		return c.Req(http.MethodPost, uploadURL, mr, nil)
 	})
+}
+
+func (pu *PCSUpload) CreateSuperFile(checksumList ...string) (err error) {
+	pu.lazyInit()
+
+	ondup := pu.ondup
+	if ondup == baidupcs.OndupNewCopy {
+		// 网盘文件已存在时, 目录也存在, 不需要预先上传空文件, 由服务器生成副本
+		_, pcsError := pu.pcs.FilesDirectoriesMeta(pu.targetPath)
+		if pcsError == nil {
+			return pu.pcs.UploadCreateSuperFile(false, pu.targetPath, ondup, checksumList...)
+		}
+		if pcsError.GetErrType() != pcserror.ErrTypeRemoteError || pcsError.GetRemoteErrCode() != 31066 {
+			return pcsError
+		}
+		// 网盘文件不存在, 空文件和合并的文件都覆盖, 避免合并时生成空文件的副本
+		ondup = baidupcs.OndupOverwrite
+	}
+
+	// 先在网盘目标位置, 上传一个空文件
+	// 防止出现file does not exist
+	pcsError := pu.uploadEmptyFile(ondup)
 	if pcsError != nil {
 		// 修改操作
 		pcsError.(*pcserror.PCSErrInfo).Operation = baidupcs.OperationUploadCreateSuperFile
 		return pcsError
 	}
 
-	return pu.pcs.UploadCreateSuperFile(false, pu.targetPath, checksumList...)
+	return pu.pcs.UploadCreateSuperFile(false, pu.targetPath, ondup, checksumList...)
 }
@@ -36,7 +36,7 @@ func (pu2 *PCSUpload2) Precreate() (err error) {
 func (pu2 *PCSUpload2) TmpFile(ctx context.Context, partseq int, partOffset int64, r rio.ReaderLen64) (checksum string, uperr error) {
 	pu2.lazyInit()
 	return pu.pcs.UploadTmpFile(func(uploadURL string, jar http.CookieJar) (resp *http.Response, err error) {
-		client := pcsconfig.Config.HTTPClient()
+		client := pcsconfig.Config.UploadHTTPClient(pcsconfig.Config.UserAgent)
 		client.SetCookiejar(jar)
 		client.SetTimeout(0)
 
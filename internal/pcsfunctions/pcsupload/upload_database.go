@@ -1,6 +1,7 @@
 package pcsupload
 
 import (
+	"bytes"
 	"errors"
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsconfig"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,15 +19,18 @@ type (
 	// Uploading 未完成上传的信息
 	Uploading struct {
 		*checksum.LocalFileMeta
-		State *uploader.InstanceState `json:"state"`
+		SavePath  string                  `json:"save_path,omitempty"` // 网盘的保存路径
+		State     *uploader.InstanceState `json:"state"`
+		Timestamp int64                   `json:"timestamp,omitempty"` // 最后更新的时间
 	}
 
-	// UploadingDatabase 未完成上传的数据库
+	// UploadingDatabase 未完成上传的数据库, 可被多个上传任务同时使用
 	UploadingDatabase struct {
 		UploadingList []*Uploading `json:"upload_state"`
 		Timestamp     int64        `json:"timestamp"`
 
 		dataFile *os.File
+		mu       sync.Mutex
 	}
 )
 
@@ -58,6 +63,9 @@ func NewUploadingDatabase() (ud *UploadingDatabase, err error) {
 
 // Save 保存内容
 func (ud *UploadingDatabase) Save() error {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+
 	if ud.dataFile == nil {
 		return errors.New("dataFile is nil")
 	}
@@ -86,26 +94,64 @@ func (ud *UploadingDatabase) Save() error {
 	return nil
 }
 
+// sameContent 是否为内容相同的文件, 比较内容指纹或 md5
+func (u *Uploading) sameContent(meta *checksum.LocalFileMeta) bool {
+	if u.LocalFileMeta == nil || u.Length != meta.Length {
+		return false
+	}
+	if u.Fingerprint != "" && u.Fingerprint == meta.Fingerprint {
+		return true
+	}
+	return len(meta.MD5) > 0 && bytes.Equal(u.MD5, meta.MD5)
+}
+
+// match 是否为同一个文件的上传, 内容相同或路径相同
+func (u *Uploading) match(meta *checksum.LocalFileMeta) bool {
+	if u.LocalFileMeta == nil {
+		return false
+	}
+	return u.sameContent(meta) || u.Path == meta.Path
+}
+
+// Uploaded 已上传的数据量
+func (u *Uploading) Uploaded() (uploaded int64) {
+	if u.State == nil {
+		return 0
+	}
+	for _, block := range u.State.BlockList {
+		if block.CheckSum != "" {
+			uploaded += block.Range.End - block.Range.Begin
+		}
+	}
+	return
+}
+
 // UpdateUploading 更新正在上传
-func (ud *UploadingDatabase) UpdateUploading(meta *checksum.LocalFileMeta, state *uploader.InstanceState) {
+func (ud *UploadingDatabase) UpdateUploading(meta *checksum.LocalFileMeta, savePath string, state *uploader.InstanceState) {
 	if meta == nil {
 		return
 	}
 
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+
 	meta.CompleteAbsPath()
-	for k, uploading := range ud.UploadingList {
-		if uploading.LocalFileMeta == nil {
-			continue
-		}
-		if uploading.LocalFileMeta.EqualLengthMD5(meta) || uploading.LocalFileMeta.Path == meta.Path {
-			ud.UploadingList[k].State = state
+	metaCopy := *meta
+	for _, uploading := range ud.UploadingList {
+		if uploading.match(meta) {
+			uploading.LocalFileMeta = &metaCopy
+			uploading.SavePath = savePath
+			uploading.State = state
+			uploading.Timestamp = time.Now().Unix()
 			return
 		}
 	}
 
 	ud.UploadingList = append(ud.UploadingList, &Uploading{
-		LocalFileMeta: meta,
+		LocalFileMeta: &metaCopy,
+		SavePath:      savePath,
 		State:         state,
+		Timestamp:     time.Now().Unix(),
 	})
 }
 
@@ -113,18 +159,33 @@ func (ud *UploadingDatabase) deleteIndex(k int) {
 	ud.UploadingList = append(ud.UploadingList[:k], ud.UploadingList[k+1:]...)
 }
 
+// DeleteIndex 按序号删除
+func (ud *UploadingDatabase) DeleteIndex(k int) bool {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+
+	if k < 0 || k >= len(ud.UploadingList) {
+		return false
+	}
+	ud.deleteIndex(k)
+	return true
+}
+
 // Delete 删除
 func (ud *UploadingDatabase) Delete(meta *checksum.LocalFileMeta) bool {
 	if meta == nil {
 		return false
 	}
 
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+	return ud.delete(meta)
+}
+
+func (ud *UploadingDatabase) delete(meta *checksum.LocalFileMeta) bool {
 	meta.CompleteAbsPath()
 	for k, uploading := range ud.UploadingList {
-		if uploading.LocalFileMeta == nil {
-			continue
-		}
-		if uploading.LocalFileMeta.EqualLengthMD5(meta) || uploading.LocalFileMeta.Path == meta.Path {
+		if uploading.match(meta) {
 			ud.deleteIndex(k)
 			return true
 		}
@@ -132,38 +193,63 @@ func (ud *UploadingDatabase) Delete(meta *checksum.LocalFileMeta) bool {
 	return false
 }
 
-// Search 搜索
-func (ud *UploadingDatabase) Search(meta *checksum.LocalFileMeta) *uploader.InstanceState {
+// Search 搜索, 优先按内容指纹和 md5 查找, 文件重命名或移动后仍可继续上传;
+// 其次按路径查找, 文件已被修改时, 由上传器校验已上传的分片
+func (ud *UploadingDatabase) Search(meta *checksum.LocalFileMeta) *Uploading {
 	if meta == nil {
 		return nil
 	}
 
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+
 	meta.CompleteAbsPath()
 	ud.clearModTimeChange()
 	for _, uploading := range ud.UploadingList {
-		if uploading.LocalFileMeta == nil {
+		if !uploading.sameContent(meta) {
 			continue
 		}
-		if uploading.LocalFileMeta.EqualLengthMD5(meta) {
-			return uploading.State
+		if uploading.Path != meta.Path {
+			pcsUploadVerbose.Info("resume moved file", "from", uploading.Path, "to", meta.Path)
 		}
-		if uploading.LocalFileMeta.Path == meta.Path {
-			// 移除旧的信息
-			// 目前只是比较了文件大小
-			if meta.Length != uploading.LocalFileMeta.Length {
-				ud.Delete(meta)
-				return nil
-			}
-
-			// 覆盖数据
-			meta.MD5 = uploading.LocalFileMeta.MD5
-			meta.SliceMD5 = uploading.LocalFileMeta.SliceMD5
-			return uploading.State
+		if meta.MD5 == nil {
+			meta.MD5 = uploading.MD5
+			meta.SliceMD5 = uploading.SliceMD5
 		}
+		return uploading
+	}
+
+	for _, uploading := range ud.UploadingList {
+		if uploading.LocalFileMeta == nil || uploading.Path != meta.Path {
+			continue
+		}
+
+		// 移除旧的信息, 文件大小改变, 分片已失效
+		if meta.Length != uploading.Length {
+			ud.delete(meta)
+			return nil
+		}
+
+		// 没有内容指纹的旧数据, 修改时间未改变, 覆盖数据;
+		// 内容指纹不同, 文件已被修改, md5 已失效
+		if uploading.Fingerprint == "" {
+			meta.MD5 = uploading.MD5
+			meta.SliceMD5 = uploading.SliceMD5
+		}
+		return uploading
 	}
 	return nil
 }
 
+// List 列出未完成的上传
+func (ud *UploadingDatabase) List() []*Uploading {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+	return append([]*Uploading{}, ud.UploadingList...)
+}
+
+// clearModTimeChange 清除文件已不存在或已被修改的旧数据,
+// 有内容指纹的数据保留, 文件可能被移动, 或由上传器校验已上传的分片
 func (ud *UploadingDatabase) clearModTimeChange() {
 	for i := 0; i < len(ud.UploadingList); i++ {
 		uploading := ud.UploadingList[i]
@@ -171,7 +257,7 @@ func (ud *UploadingDatabase) clearModTimeChange() {
 			continue
 		}
 
-		if uploading.ModTime == -1 { // 忽略
+		if uploading.ModTime == -1 || uploading.Fingerprint != "" { // 忽略
 			continue
 		}
 
@@ -194,5 +280,7 @@ func (ud *UploadingDatabase) clearModTimeChange() {
 
 // Close 关闭数据库
 func (ud *UploadingDatabase) Close() error {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
 	return ud.dataFile.Close()
 }
@@ -11,11 +11,11 @@ import (
 	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/taskframework"
 	"github.com/felixonmars/BaiduPCS-Go/requester/rio"
 	"github.com/felixonmars/BaiduPCS-Go/requester/uploader"
 	"path"
-	"strings"
 	"time"
 )
 
@@ -32,15 +32,18 @@ type (
 		PCS               *baidupcs.BaiduPCS
 		UploadingDatabase *UploadingDatabase // 数据库
 		Parallel          int
-		NoRapidUpload     bool // 禁用秒传
-		NoSplitFile       bool // 禁用分片上传
+		NoRapidUpload     bool   // 禁用秒传
+		NoSplitFile       bool   // 禁用分片上传
+		OnConflict        string // 网盘文件已存在时的处理方式, 为空则为 skip-identical
+		PrintFormat       string
 
 		UploadStatistic *UploadStatistic
 
-		taskInfo *taskframework.TaskInfo
-		panDir   string
-		panFile  string
-		state    *uploader.InstanceState
+		taskInfo      *taskframework.TaskInfo
+		panDir        string
+		panFile       string
+		state         *uploader.InstanceState
+		resumeChanged bool // 断点续传的文件已被修改
 	}
 )
 
@@ -55,6 +58,11 @@ const (
 
 const (
 	StrUploadFailed = "上传文件失败"
+	// StrUploadCanceled 上传已取消
+	StrUploadCanceled = "上传已取消"
+
+	// DefaultPrintFormat 默认的上传进度输出格式
+	DefaultPrintFormat = "\r[%s] ↑ %s/%s %s/s in %s ............"
 )
 
 func (utu *UploadTaskUnit) SetTaskInfo(taskInfo *taskframework.TaskInfo) {
@@ -70,8 +78,19 @@ func (utu *UploadTaskUnit) prepareFile() {
 	utu.panDir = path.Clean(panDir)
 	utu.panFile = panFile
 
+	// 计算内容指纹, 用于识别重命名或移动后的文件
+	err := utu.LocalFileChecksum.SumFingerprint()
+	if err != nil {
+		pcsUploadVerbose.Warn("sum fingerprint failed", "path", utu.LocalFileChecksum.Path, "err", err)
+	}
+
 	// 检测断点续传
-	utu.state = utu.UploadingDatabase.Search(&utu.LocalFileChecksum.LocalFileMeta)
+	utu.state, utu.resumeChanged = nil, false
+	uploading := utu.UploadingDatabase.Search(&utu.LocalFileChecksum.LocalFileMeta)
+	if uploading != nil {
+		utu.state = uploading.State
+		utu.resumeChanged = uploading.ModTime != utu.LocalFileChecksum.ModTime
+	}
 	if utu.state != nil || utu.LocalFileChecksum.LocalFileMeta.MD5 != nil { // 读取到了md5
 		utu.Step = StepUploadUpload
 		return
@@ -91,34 +110,57 @@ func (utu *UploadTaskUnit) prepareFile() {
 	utu.Step = StepUploadRapidUpload
 }
 
+// checkExists 处理方式为 skip 或 fail 时, 检测网盘文件是否已存在, 已存在则返回跳过或失败的结果
+func (utu *UploadTaskUnit) checkExists() (result *taskframework.TaskUnitRunResult) {
+	if utu.OnConflict != OnConflictSkip && utu.OnConflict != OnConflictFail {
+		return nil
+	}
+
+	// 不使用缓存, 上传前的检测之后, 网盘文件可能已出现
+	fdl, pcsError := utu.PCS.FilesDirectoriesList(utu.panDir, baidupcs.DefaultOrderOptions)
+	if pcsError != nil {
+		if pcsError.GetErrType() == pcserror.ErrTypeRemoteError && pcsError.GetRemoteErrCode() == 31066 {
+			// 目录不存在
+			return nil
+		}
+		return &taskframework.TaskUnitRunResult{
+			ResultMessage: "获取文件列表错误",
+			Err:           pcsError,
+			NeedRetry:     pcsfunctions.IsRetryable(pcsError),
+		}
+	}
+
+	for _, fd := range fdl {
+		if fd.Filename != utu.panFile {
+			continue
+		}
+		if utu.OnConflict == OnConflictSkip {
+			fmt.Printf("[%s] 目标文件, %s, 已存在, 跳过...\n", utu.taskInfo.Id(), utu.SavePath)
+			return &taskframework.TaskUnitRunResult{
+				Succeed: true,
+			}
+		}
+		return &taskframework.TaskUnitRunResult{
+			ResultMessage: "目标文件已存在",
+			Err:           ErrTargetExists,
+		}
+	}
+	return nil
+}
+
 // rapidUpload 执行秒传
 func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework.TaskUnitRunResult) {
 	utu.Step = StepUploadRapidUpload
 
-	// TODO: 建立一个通过百度错误码判断重试的函数
 	result = &taskframework.TaskUnitRunResult{}
 
 	fdl, pcsError := utu.PCS.CacheFilesDirectoriesList(utu.panDir, baidupcs.DefaultOrderOptions)
-	if pcsError != nil {
-		switch pcsError.GetErrType() {
-		case pcserror.ErrTypeRemoteError:
-			switch pcsError.GetRemoteErrCode() {
-			case 31066:
-			// file does not exist
-			// 不缓存文件夹
-			default:
-				// 其他百度服务器错误, 不重试
-				result.ResultMessage = "获取文件列表错误"
-				result.Err = pcsError
-				return
-			}
-		default:
-			// 未知错误, 重试
-			result.ResultMessage = "获取文件列表错误"
-			result.NeedRetry = true
-			result.Err = pcsError
-			return
-		}
+	if pcsError != nil && !(pcsError.GetErrType() == pcserror.ErrTypeRemoteError && pcsError.GetRemoteErrCode() == 31066) {
+		// 目录不存在时继续, 其他错误按错误的类别判断是否重试
+		result.ResultMessage = "获取文件列表错误"
+		result.Err = pcsError
+		result.NeedRetry = pcsfunctions.IsRetryable(pcsError)
+		return
 	}
 
 	// 文件大于128MB, 输出提示信息
@@ -127,7 +169,7 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 	}
 
 	// 经测试, 文件的 crc32 值并非秒传文件所必需
-	err := utu.LocalFileChecksum.Sum(checksum.CHECKSUM_MD5 | checksum.CHECKSUM_SLICE_MD5)
+	err := utu.LocalFileChecksum.SumWithCache(pcsfunctions.ChecksumCache(), checksum.CHECKSUM_MD5|checksum.CHECKSUM_SLICE_MD5)
 	if err != nil {
 		// 不重试
 		result.ResultMessage = "计算文件秒传信息错误"
@@ -136,7 +178,7 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 	}
 
 	// 检测缓存, 通过文件的md5值判断本地文件和网盘文件是否一样
-	if fdl != nil {
+	if fdl != nil && (utu.OnConflict == "" || utu.OnConflict == OnConflictSkipIdentical) {
 		for _, fd := range fdl {
 			if fd.Filename == utu.panFile {
 				// TODO: fd.MD5 有可能是错误的
@@ -150,8 +192,10 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 		}
 	}
 
-	pcsError = utu.PCS.RapidUpload(utu.SavePath, hex.EncodeToString(utu.LocalFileChecksum.MD5), hex.EncodeToString(utu.LocalFileChecksum.SliceMD5), fmt.Sprint(utu.LocalFileChecksum.CRC32), utu.LocalFileChecksum.Length)
+	rapidUploadAttemptsTotal.Inc()
+	pcsError = utu.PCS.RapidUpload(utu.SavePath, Ondup(utu.OnConflict), hex.EncodeToString(utu.LocalFileChecksum.MD5), hex.EncodeToString(utu.LocalFileChecksum.SliceMD5), fmt.Sprint(utu.LocalFileChecksum.CRC32), utu.LocalFileChecksum.Length)
 	if pcsError == nil {
+		rapidUploadHitsTotal.Inc()
 		fmt.Printf("[%s] 秒传成功, 保存到网盘路径: %s\n\n", utu.taskInfo.Id(), utu.SavePath)
 		// 统计
 		utu.UploadStatistic.AddTotalSize(utu.LocalFileChecksum.Length)
@@ -159,21 +203,21 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 		return
 	}
 
-	// 判断配额是否已满
-	switch pcsError.GetErrType() {
-	// 远程服务器错误
-	case pcserror.ErrTypeRemoteError:
-		switch pcsError.GetRemoteErrCode() {
-		case 31112: //exceed quota
-			result.ResultMessage = "秒传失败, 超出配额, 网盘容量已满"
-			return
-		}
+	// 判断配额是否已满, 登录状态是否有效
+	switch pcserror.Classify(pcsError) {
+	case retry.ClassQuota:
+		result.ResultMessage = "秒传失败, 超出配额, 网盘容量已满"
+		return
+	case retry.ClassAuth:
+		result.ResultMessage = "秒传失败, 可能百度帐号登录状态过期, 请尝试重新登录"
+		result.Err = pcsError
+		return
 	}
 
 	fmt.Printf("[%s] 秒传失败, 开始上传文件...\n\n", utu.taskInfo.Id())
 
 	// 保存秒传信息
-	utu.UploadingDatabase.UpdateUploading(&utu.LocalFileChecksum.LocalFileMeta, nil)
+	utu.UploadingDatabase.UpdateUploading(&utu.LocalFileChecksum.LocalFileMeta, utu.SavePath, nil)
 	utu.UploadingDatabase.Save()
 	isContinue = true
 	return
@@ -182,6 +226,9 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 // upload 上传文件
 func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 	utu.Step = StepUploadUpload
+	if utu.PrintFormat == "" {
+		utu.PrintFormat = DefaultPrintFormat
+	}
 
 	var blockSize int64
 	if utu.NoSplitFile {
@@ -191,10 +238,15 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 		blockSize = getBlockSize(utu.LocalFileChecksum.Length)
 	}
 
-	muer := uploader.NewMultiUploader(NewPCSUpload(utu.PCS, utu.SavePath), rio.NewFileReaderAtLen64(utu.LocalFileChecksum.GetFile()), &uploader.MultiUploaderConfig{
-		Parallel:  utu.Parallel,
-		BlockSize: blockSize,
-		MaxRate:   pcsconfig.Config.MaxUploadRate,
+	muer := uploader.NewMultiUploader(&PCSUpload{
+		pcs:        utu.PCS,
+		targetPath: utu.SavePath,
+		ondup:      Ondup(utu.OnConflict),
+	}, rio.NewFileReaderAtLen64(utu.LocalFileChecksum.GetFile()), &uploader.MultiUploaderConfig{
+		Parallel:    utu.Parallel,
+		BlockSize:   blockSize,
+		MaxRate:     pcsconfig.Config.MaxUploadRate,
+		FileChanged: utu.fileChangedFunc(),
 	})
 
 	// 设置断点续传
@@ -205,12 +257,12 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 	muer.OnUploadStatusEvent(func(status uploader.Status, updateChan <-chan struct{}) {
 		select {
 		case <-updateChan:
-			utu.UploadingDatabase.UpdateUploading(&utu.LocalFileChecksum.LocalFileMeta, muer.InstanceState())
+			utu.UploadingDatabase.UpdateUploading(&utu.LocalFileChecksum.LocalFileMeta, utu.SavePath, muer.InstanceState())
 			utu.UploadingDatabase.Save()
 		default:
 		}
 
-		fmt.Printf("\r[%s] ↑ %s/%s %s/s in %s ............", utu.taskInfo.Id(),
+		fmt.Printf(utu.PrintFormat, utu.taskInfo.Id(),
 			converter.ConvertFileSize(status.Uploaded(), 2),
 			converter.ConvertFileSize(status.TotalSize(), 2),
 			converter.ConvertFileSize(status.SpeedsPerSecond(), 2),
@@ -230,6 +282,17 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 		result.Succeed = true
 	})
 	muer.OnError(func(err error) {
+		if err == uploader.ErrFileSizeChanged {
+			// 文件大小改变, 已上传的分片已失效, 重新上传
+			utu.UploadingDatabase.Delete(&utu.LocalFileChecksum.LocalFileMeta)
+			utu.UploadingDatabase.Save()
+
+			result.ResultMessage = StrUploadFailed
+			result.Err = err
+			result.NeedRetry = true
+			return
+		}
+
 		pcsError, ok := err.(pcserror.Error)
 		if !ok {
 			// 未知错误类型 (非预期的)
@@ -239,51 +302,66 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 			return
 		}
 
-		// 默认需要重试
-		result.NeedRetry = true
-
-		switch pcsError.GetErrType() {
-		case pcserror.ErrTypeRemoteError:
-			// 远程百度服务器的错误
-			switch pcsError.GetRemoteErrCode() {
-			case 31363:
-				// block miss in superfile2, 上传状态过期
-				// 需要重试的
-				utu.UploadingDatabase.Delete(&utu.LocalFileChecksum.LocalFileMeta)
-				utu.UploadingDatabase.Save()
-
-				result.ResultMessage = StrUploadFailed
-				result.Err = errors.New("上传状态过期, 重新上传")
-			case 31200:
-				//服务器错误
-				//[Method:Insert][Error:Insert Request Forbid]
-				// do nothing
-			default:
-				result.ResultMessage = StrUploadFailed
-				result.Err = pcsError
-			}
-		case pcserror.ErrTypeNetError:
-			// 网络错误
-			result.ResultMessage = StrUploadFailed
-			result.Err = pcsError
-			if strings.Contains(pcsError.GetError().Error(), "413 Request Entity Too Large") {
-				// 请求实体过大
-				// 不重试
-				result.NeedRetry = false
-				return
-			}
-		default:
+		if pcsError.GetErrType() == pcserror.ErrTypeRemoteError && pcsError.GetRemoteErrCode() == 31363 {
+			// block miss in superfile2, 上传状态过期
+			// 需要重试的
+			utu.UploadingDatabase.Delete(&utu.LocalFileChecksum.LocalFileMeta)
+			utu.UploadingDatabase.Save()
+
 			result.ResultMessage = StrUploadFailed
-			result.NeedRetry = false
-			result.Err = pcsError
+			result.Err = errors.New("上传状态过期, 重新上传")
+			result.NeedRetry = true
+			return
 		}
-		return
+
+		// 按错误的类别判断是否重试, 如请求实体过大, 网盘容量已满时不重试
+		result.ResultMessage = StrUploadFailed
+		result.Err = pcsError
+		result.NeedRetry = pcsfunctions.IsRetryable(pcsError)
 	})
-	muer.Execute()
+	muer.OnCancel(func() {
+		// 保存断点信息, 下次从已上传的分片继续
+		utu.UploadingDatabase.UpdateUploading(&utu.LocalFileChecksum.LocalFileMeta, utu.SavePath, muer.InstanceState())
+		utu.UploadingDatabase.Save()
+
+		fmt.Printf("\n")
+		result.ResultMessage = StrUploadCanceled
+		result.Err = utu.taskInfo.Context().Err()
+	})
+	muer.ExecuteContext(utu.taskInfo.Context())
 
 	return
 }
 
+// fileChangedFunc 检测文件是否被修改, 断点续传的文件已被修改时, 第一次检测返回 true
+func (utu *UploadTaskUnit) fileChangedFunc() func() bool {
+	var (
+		resumeChanged = utu.state != nil && utu.resumeChanged
+		lastModTime   int64
+	)
+	if info, err := utu.LocalFileChecksum.GetFile().Stat(); err == nil {
+		lastModTime = info.ModTime().UnixNano()
+	}
+	return func() bool {
+		if resumeChanged {
+			resumeChanged = false
+			fmt.Printf("[%s] 文件已被修改, 校验已上传的分片...\n", utu.taskInfo.Id())
+			return true
+		}
+
+		info, err := utu.LocalFileChecksum.GetFile().Stat()
+		if err != nil {
+			return true
+		}
+		changed := info.ModTime().UnixNano() != lastModTime || info.Size() != utu.LocalFileChecksum.Length
+		lastModTime = info.ModTime().UnixNano()
+		if changed {
+			fmt.Printf("\n[%s] 文件在上传过程中被修改, 校验已上传的分片...\n", utu.taskInfo.Id())
+		}
+		return changed
+	}
+}
+
 func (utu *UploadTaskUnit) OnRetry(lastRunResult *taskframework.TaskUnitRunResult) {
 	// 输出错误信息
 	if lastRunResult.Err == nil {
@@ -315,6 +393,9 @@ func (utu *UploadTaskUnit) RetryWait() time.Duration {
 }
 
 func (utu *UploadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
+	// 取消任务时, 中断进行中的请求
+	utu.PCS = utu.PCS.WithContext(utu.taskInfo.Context())
+
 	fmt.Printf("[%s] 准备上传: %s\n", utu.taskInfo.Id(), utu.LocalFileChecksum.Path)
 
 	err := utu.LocalFileChecksum.OpenPath()
@@ -327,6 +408,11 @@ func (utu *UploadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 	// 准备文件
 	utu.prepareFile()
 
+	// 服务器始终覆盖, 由客户端跳过或失败
+	if existsResult := utu.checkExists(); existsResult != nil {
+		return existsResult
+	}
+
 	switch utu.Step {
 	case StepUploadRapidUpload:
 		goto stepUploadRapidUpload
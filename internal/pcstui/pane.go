@@ -0,0 +1,267 @@
+package pcstui
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	parentDirName = ".."
+)
+
+type (
+	// entry 文件或目录
+	entry struct {
+		name  string
+		path  string
+		isDir bool
+		size  int64
+	}
+
+	// pane 文件列表窗格
+	pane struct {
+		title    string
+		dir      string
+		entries  []*entry
+		cursor   int
+		offset   int
+		selected map[string]bool // 已选中的路径
+		err      error
+
+		list   func(dir string, refresh bool) ([]*entry, error) // 列出目录
+		parent func(dir string) string
+	}
+)
+
+func newLocalPane(dir string) *pane {
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	return &pane{
+		title:    "本地",
+		dir:      dir,
+		selected: map[string]bool{},
+		list: func(dir string, refresh bool) ([]*entry, error) {
+			infos, err := ioutil.ReadDir(dir)
+			if err != nil {
+				return nil, err
+			}
+			entries := make([]*entry, 0, len(infos))
+			for _, info := range infos {
+				entries = append(entries, &entry{
+					name:  info.Name(),
+					path:  filepath.Join(dir, info.Name()),
+					isDir: info.IsDir(),
+					size:  info.Size(),
+				})
+			}
+			return entries, nil
+		},
+		parent: filepath.Dir,
+	}
+}
+
+func newRemotePane(pcs *baidupcs.BaiduPCS, dir string) *pane {
+	return &pane{
+		title:    "网盘",
+		dir:      dir,
+		selected: map[string]bool{},
+		list: func(dir string, refresh bool) ([]*entry, error) {
+			var (
+				fdl      baidupcs.FileDirectoryList
+				pcsError error
+			)
+			if refresh {
+				fdl, pcsError = pcs.FilesDirectoriesList(dir, baidupcs.DefaultOrderOptions)
+			} else {
+				fdl, pcsError = pcs.CacheFilesDirectoriesList(dir, baidupcs.DefaultOrderOptions)
+			}
+			if pcsError != nil {
+				return nil, pcsError
+			}
+			entries := make([]*entry, 0, len(fdl))
+			for _, fd := range fdl {
+				entries = append(entries, &entry{
+					name:  fd.Filename,
+					path:  fd.Path,
+					isDir: fd.Isdir,
+					size:  fd.Size,
+				})
+			}
+			return entries, nil
+		},
+		parent: path.Dir,
+	}
+}
+
+// load 加载目录, 尽量保持光标所在的文件
+func (p *pane) load(refresh bool) {
+	var current string
+	if e := p.current(); e != nil {
+		current = e.name
+	}
+
+	entries, err := p.list(p.dir, refresh)
+	p.err = err
+	if err != nil {
+		entries = nil
+	}
+
+	// 目录排在前面
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].isDir && !entries[j].isDir
+	})
+
+	if parentDir := p.parent(p.dir); parentDir != p.dir {
+		entries = append([]*entry{{name: parentDirName, path: parentDir, isDir: true}}, entries...)
+	}
+	p.entries = entries
+
+	// 清除已不存在的选中项
+	exists := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		exists[e.path] = true
+	}
+	for selectedPath := range p.selected {
+		if !exists[selectedPath] {
+			delete(p.selected, selectedPath)
+		}
+	}
+
+	p.cursor = 0
+	for k, e := range entries {
+		if e.name == current {
+			p.cursor = k
+			break
+		}
+	}
+}
+
+// chdir 切换目录
+func (p *pane) chdir(dir string) {
+	p.dir = dir
+	p.selected = map[string]bool{}
+	p.offset = 0
+	p.entries = nil
+	p.load(false)
+}
+
+// enter 进入光标所在的目录
+func (p *pane) enter() {
+	e := p.current()
+	if e == nil || !e.isDir {
+		return
+	}
+	if e.name == parentDirName {
+		p.up()
+		return
+	}
+	p.chdir(e.path)
+}
+
+// up 返回上级目录, 光标定位到原目录
+func (p *pane) up() {
+	parentDir := p.parent(p.dir)
+	if parentDir == p.dir {
+		return
+	}
+
+	from := p.dir
+	p.chdir(parentDir)
+	for k, e := range p.entries {
+		if e.path == from {
+			p.cursor = k
+			break
+		}
+	}
+}
+
+func (p *pane) current() *entry {
+	if p.cursor < 0 || p.cursor >= len(p.entries) {
+		return nil
+	}
+	return p.entries[p.cursor]
+}
+
+func (p *pane) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.entries) {
+		p.cursor = len(p.entries) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+// toggle 选中或取消选中光标所在的项, 并下移光标
+func (p *pane) toggle() {
+	e := p.current()
+	if e != nil && e.name != parentDirName {
+		if p.selected[e.path] {
+			delete(p.selected, e.path)
+		} else {
+			p.selected[e.path] = true
+		}
+	}
+	p.move(1)
+}
+
+// toggleAll 全选, 已全选则取消全选
+func (p *pane) toggleAll() {
+	var all = true
+	for _, e := range p.entries {
+		if e.name != parentDirName && !p.selected[e.path] {
+			all = false
+			break
+		}
+	}
+
+	p.selected = map[string]bool{}
+	if all {
+		return
+	}
+	for _, e := range p.entries {
+		if e.name != parentDirName {
+			p.selected[e.path] = true
+		}
+	}
+}
+
+// targets 返回要操作的路径, 没有选中项时返回光标所在的项
+func (p *pane) targets() (paths []string) {
+	for _, e := range p.entries {
+		if p.selected[e.path] {
+			paths = append(paths, e.path)
+		}
+	}
+	if len(paths) > 0 {
+		return
+	}
+
+	if e := p.current(); e != nil && e.name != parentDirName {
+		paths = append(paths, e.path)
+	}
+	return
+}
+
+// scroll 调整偏移量, 使光标可见
+func (p *pane) scroll(height int) {
+	if height <= 0 {
+		return
+	}
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+height {
+		p.offset = p.cursor - height + 1
+	}
+	if p.offset > len(p.entries)-height {
+		p.offset = len(p.entries) - height
+	}
+	if p.offset < 0 {
+		p.offset = 0
+	}
+}
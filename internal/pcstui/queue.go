@@ -0,0 +1,271 @@
+package pcstui
+
+import (
+	"bufio"
+	"context"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcscommand"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jobDownload jobKind = iota
+	jobUpload
+)
+
+const (
+	jobWaiting jobState = iota
+	jobRunning
+	jobDone
+)
+
+const (
+	maxQueueLogs = 50
+)
+
+type (
+	jobKind  int
+	jobState int
+
+	// transferJob 传输任务, 一次下载或上传操作
+	transferJob struct {
+		id        int
+		kind      jobKind
+		paths     []string
+		target    string // 下载保存的本地目录, 或上传保存的网盘目录
+		state     jobState
+		startTime time.Time
+		endTime   time.Time
+
+		downloadStatistic *pcsdownload.DownloadStatistic
+		uploadStatistic   *pcsupload.UploadStatistic
+	}
+
+	// transferQueue 传输队列, 依次执行任务, 并收集任务的输出
+	transferQueue struct {
+		jobs    []*transferJob
+		tasks   map[string]string // 当前任务各个文件的最新输出, key 为任务id
+		taskIDs []string
+		logs    []string
+		lastID  int
+		notify  chan struct{} // 有新的任务
+		done    chan *transferJob
+		stopped chan struct{} // 队列已停止
+		ctx     context.Context
+		cancel  context.CancelFunc
+		mu      sync.Mutex
+	}
+)
+
+func (k jobKind) String() string {
+	if k == jobUpload {
+		return "上传"
+	}
+	return "下载"
+}
+
+func (s jobState) String() string {
+	switch s {
+	case jobRunning:
+		return "进行中"
+	case jobDone:
+		return "已完成"
+	default:
+		return "等待中"
+	}
+}
+
+// newTransferQueue 初始化传输队列, 任务在 ctx 下执行, 调用 cancel 后停止
+func newTransferQueue(ctx context.Context, cancel context.CancelFunc) *transferQueue {
+	tq := &transferQueue{
+		tasks:   map[string]string{},
+		notify:  make(chan struct{}, 1),
+		done:    make(chan *transferJob, 64),
+		stopped: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go tq.run()
+	return tq
+}
+
+// add 加入传输队列
+func (tq *transferQueue) add(kind jobKind, paths []string, target string) *transferJob {
+	tq.mu.Lock()
+	tq.lastID++
+	job := &transferJob{
+		id:     tq.lastID,
+		kind:   kind,
+		paths:  paths,
+		target: target,
+	}
+	switch kind {
+	case jobDownload:
+		job.downloadStatistic = &pcsdownload.DownloadStatistic{}
+	case jobUpload:
+		job.uploadStatistic = &pcsupload.UploadStatistic{}
+	}
+	tq.jobs = append(tq.jobs, job)
+	tq.mu.Unlock()
+
+	select {
+	case tq.notify <- struct{}{}:
+	default:
+	}
+	return job
+}
+
+// stop 取消进行中的任务, 不再执行等待中的任务, 等待队列停止
+func (tq *transferQueue) stop() {
+	tq.cancel()
+	<-tq.stopped
+}
+
+// next 返回第一个等待中的任务
+func (tq *transferQueue) next() *transferJob {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	for _, job := range tq.jobs {
+		if job.state == jobWaiting {
+			return job
+		}
+	}
+	return nil
+}
+
+func (tq *transferQueue) run() {
+	defer close(tq.stopped)
+	for {
+		job := tq.next()
+		if job == nil {
+			select {
+			case <-tq.notify:
+				continue
+			case <-tq.ctx.Done():
+				return
+			}
+		}
+		if tq.ctx.Err() != nil { // 已取消
+			return
+		}
+
+		tq.mu.Lock()
+		job.state = jobRunning
+		job.startTime = time.Now()
+		tq.tasks = map[string]string{}
+		tq.taskIDs = nil
+		tq.mu.Unlock()
+
+		switch job.kind {
+		case jobDownload:
+			pcscommand.RunDownload(job.paths, &pcscommand.DownloadOptions{
+				SaveTo:    job.target,
+				MaxRetry:  pcsdownload.DefaultDownloadMaxRetry,
+				Statistic: job.downloadStatistic,
+			})
+		case jobUpload:
+			pcscommand.RunUpload(job.paths, job.target, &pcscommand.UploadOptions{
+				MaxRetry:  pcscommand.DefaultUploadMaxRetry,
+				Statistic: job.uploadStatistic,
+			})
+		}
+
+		tq.mu.Lock()
+		job.state = jobDone
+		job.endTime = time.Now()
+		tq.mu.Unlock()
+
+		select {
+		case tq.done <- job:
+		default:
+		}
+	}
+}
+
+// totalSize 已完成传输的数据量
+func (job *transferJob) totalSize() int64 {
+	switch job.kind {
+	case jobDownload:
+		return job.downloadStatistic.TotalSize()
+	case jobUpload:
+		return job.uploadStatistic.TotalSize()
+	}
+	return 0
+}
+
+// elapsed 任务耗时
+func (job *transferJob) elapsed() time.Duration {
+	switch job.state {
+	case jobRunning:
+		return time.Since(job.startTime)
+	case jobDone:
+		return job.endTime.Sub(job.startTime)
+	}
+	return 0
+}
+
+// running 是否有未完成的任务
+func (tq *transferQueue) running() bool {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	for _, job := range tq.jobs {
+		if job.state != jobDone {
+			return true
+		}
+	}
+	return false
+}
+
+// capture 读取任务的输出, 按行记录
+func (tq *transferQueue) capture(r io.Reader) {
+	var (
+		br   = bufio.NewReader(r)
+		line []byte
+	)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			tq.addLine(string(line))
+			return
+		}
+
+		switch b {
+		case '\r', '\n':
+			tq.addLine(string(line))
+			line = line[:0]
+		default:
+			line = append(line, b)
+		}
+	}
+}
+
+func (tq *transferQueue) addLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	// 以 [任务id] 开头的为文件的输出, [0] 为提示信息
+	if strings.HasPrefix(line, "[") {
+		if i := strings.Index(line, "]"); i > 1 && line[1:i] != "0" {
+			id := line[1:i]
+			if _, ok := tq.tasks[id]; !ok {
+				tq.taskIDs = append(tq.taskIDs, id)
+			}
+			tq.tasks[id] = line
+			return
+		}
+	}
+
+	tq.logs = append(tq.logs, line)
+	if len(tq.logs) > maxQueueLogs {
+		tq.logs = tq.logs[len(tq.logs)-maxQueueLogs:]
+	}
+}
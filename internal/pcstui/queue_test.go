@@ -0,0 +1,34 @@
+package pcstui
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTransferQueueStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tq := newTransferQueue(ctx, cancel)
+
+	// 加入任务不阻塞, 已取消的队列不执行任务
+	added := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			tq.add(jobDownload, []string{"/a"}, "/tmp")
+		}
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("add blocked")
+	}
+
+	tq.stop()
+	for _, job := range tq.jobs {
+		if job.state != jobWaiting {
+			t.Fatalf("job #%d: %s", job.id, job.state)
+		}
+	}
+}
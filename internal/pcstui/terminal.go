@@ -0,0 +1,142 @@
+package pcstui
+
+import (
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	keyRune key = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPgUp
+	keyPgDn
+	keyHome
+	keyEnd
+	keyEnter
+	keyTab
+	keyBackspace
+	keyDelete
+	keyEsc
+	keyCtrlC
+)
+
+const (
+	escAltScreenOn  = "\x1b[?1049h"
+	escAltScreenOff = "\x1b[?1049l"
+	escHideCursor   = "\x1b[?25l"
+	escShowCursor   = "\x1b[?25h"
+	escHome         = "\x1b[H"
+	escClearLine    = "\x1b[K"
+	escReset        = "\x1b[0m"
+	escBold         = "\x1b[1m"
+	escReverse      = "\x1b[7m"
+	escYellow       = "\x1b[33m"
+	escBlue         = "\x1b[34m"
+	escRed          = "\x1b[31m"
+)
+
+type (
+	key int
+
+	// keyEvent 按键事件
+	keyEvent struct {
+		key key
+		ch  rune
+	}
+)
+
+// csiKeys 以 ESC [ 或 ESC O 开头的控制序列
+var csiKeys = map[string]key{
+	"A":  keyUp,
+	"B":  keyDown,
+	"C":  keyRight,
+	"D":  keyLeft,
+	"H":  keyHome,
+	"F":  keyEnd,
+	"1~": keyHome,
+	"7~": keyHome,
+	"4~": keyEnd,
+	"8~": keyEnd,
+	"3~": keyDelete,
+	"5~": keyPgUp,
+	"6~": keyPgDn,
+}
+
+// decodeKeys 解析终端输入的按键
+func decodeKeys(b []byte) (events []keyEvent) {
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			if len(b) == 1 || (b[1] != '[' && b[1] != 'O') {
+				events = append(events, keyEvent{key: keyEsc})
+				b = b[1:]
+				continue
+			}
+
+			// 查找控制序列的结束字节
+			i := 2
+			for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+				i++
+			}
+			if i >= len(b) {
+				return
+			}
+			if k, ok := csiKeys[string(b[2:i+1])]; ok {
+				events = append(events, keyEvent{key: k})
+			}
+			b = b[i+1:]
+		case c == '\r' || c == '\n':
+			events = append(events, keyEvent{key: keyEnter})
+			b = b[1:]
+		case c == '\t':
+			events = append(events, keyEvent{key: keyTab})
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			events = append(events, keyEvent{key: keyBackspace})
+			b = b[1:]
+		case c == 0x03:
+			events = append(events, keyEvent{key: keyCtrlC})
+			b = b[1:]
+		case c < 0x20:
+			// 忽略其他控制字符
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			events = append(events, keyEvent{key: keyRune, ch: r})
+			b = b[size:]
+		}
+	}
+	return
+}
+
+// readKeys 读取终端输入, 发送按键事件, stop 关闭后退出, 避免退出后继续占用终端输入
+func readKeys(in *os.File, keyCh chan<- keyEvent, stop <-chan struct{}) {
+	buf := make([]byte, 256)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if !waitInput(in, 100*time.Millisecond) {
+			continue
+		}
+
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, ev := range decodeKeys(buf[:n]) {
+			select {
+			case keyCh <- ev:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
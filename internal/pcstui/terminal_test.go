@@ -0,0 +1,26 @@
+package pcstui
+
+import (
+	"testing"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	events := decodeKeys([]byte("\x1b[A\x1b[6~j中\r\x7f\x1b"))
+	want := []keyEvent{
+		{key: keyUp},
+		{key: keyPgDn},
+		{key: keyRune, ch: 'j'},
+		{key: keyRune, ch: '中'},
+		{key: keyEnter},
+		{key: keyBackspace},
+		{key: keyEsc},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %v, want %v", events, want)
+	}
+	for k := range want {
+		if events[k] != want[k] {
+			t.Errorf("event %d: got %v, want %v", k, events[k], want[k])
+		}
+	}
+}
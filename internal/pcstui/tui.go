@@ -0,0 +1,566 @@
+// Package pcstui 双窗格的终端文件管理界面, 左侧为本地文件, 右侧为网盘文件
+package pcstui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs"
+	"github.com/felixonmars/BaiduPCS-Go/internal/pcscommand"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/crypto/ssh/terminal"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	helpText = "Tab 切换  ↑↓/jk 移动  Enter/l 打开  Backspace/h 上级  Space 选择  a 全选  g 下载  u 上传  c 复制  m 移动  d 删除  r 刷新  q 退出"
+)
+
+type (
+	// Options TUI 可选项
+	Options struct {
+		LocalDir  string // 本地初始目录, 为空则使用当前目录
+		RemoteDir string // 网盘初始目录, 为空则使用当前工作目录
+	}
+
+	// prompt 底部的输入框
+	prompt struct {
+		label  string
+		input  []rune
+		onDone func(value string)
+	}
+
+	app struct {
+		pcs    *baidupcs.BaiduPCS
+		out    *os.File
+		panes  [2]*pane // 0 为本地, 1 为网盘
+		active int
+		queue  *transferQueue
+		prompt *prompt
+		msg    string
+		quit   bool
+	}
+)
+
+// Run 运行 TUI
+func Run(opt *Options) {
+	if opt == nil {
+		opt = &Options{}
+	}
+
+	var (
+		in  = os.Stdin
+		out = os.Stdout
+	)
+	if !terminal.IsTerminal(int(in.Fd())) || !terminal.IsTerminal(int(out.Fd())) {
+		fmt.Printf("TUI 需要在终端中运行\n")
+		return
+	}
+
+	if opt.LocalDir == "" {
+		opt.LocalDir, _ = os.Getwd()
+	}
+	if opt.RemoteDir == "" {
+		opt.RemoteDir = pcscommand.GetActiveUser().Workdir
+	} else {
+		opt.RemoteDir = pcscommand.GetActiveUser().PathJoin(opt.RemoteDir)
+	}
+
+	oldState, err := terminal.MakeRaw(int(in.Fd()))
+	if err != nil {
+		fmt.Printf("设置终端错误: %s\n", err)
+		return
+	}
+
+	// 任务的输出写入管道, 由传输队列收集, 避免破坏界面
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		terminal.Restore(int(in.Fd()), oldState)
+		fmt.Printf("创建管道错误: %s\n", err)
+		return
+	}
+
+	// 传输任务在可取消的 context 下执行, 退出时取消, 保存断点信息
+	ctx, cancel := context.WithCancel(pcscommand.Context())
+	prevCtx := pcscommand.Context()
+	pcscommand.SetContext(ctx)
+
+	a := &app{
+		pcs:   pcscommand.GetBaiduPCS(),
+		out:   out,
+		queue: newTransferQueue(ctx, cancel),
+	}
+	a.panes[0] = newLocalPane(opt.LocalDir)
+	a.panes[1] = newRemotePane(a.pcs, opt.RemoteDir)
+	a.active = 1
+
+	os.Stdout = pw
+	go a.queue.capture(pr)
+
+	out.WriteString(escAltScreenOn + escHideCursor)
+	defer func() {
+		// 等待传输任务停止后再恢复输出, 避免任务的输出破坏终端
+		if a.queue.running() {
+			a.msg = "正在取消传输任务, 保存断点信息..."
+			a.render()
+		}
+		a.queue.stop()
+
+		out.WriteString(escReset + escShowCursor + escAltScreenOff)
+		terminal.Restore(int(in.Fd()), oldState)
+		os.Stdout = out
+		pw.Close()
+		pcscommand.SetContext(prevCtx)
+	}()
+
+	a.msg = "正在加载..."
+	a.render()
+	for _, p := range a.panes {
+		p.load(false)
+	}
+	a.msg = ""
+
+	var (
+		keyCh  = make(chan keyEvent, 16)
+		stop   = make(chan struct{})
+		ticker = time.NewTicker(500 * time.Millisecond)
+	)
+	defer ticker.Stop()
+	defer close(stop)
+	go readKeys(in, keyCh, stop)
+
+	for !a.quit {
+		a.render()
+		select {
+		case ev := <-keyCh:
+			a.handleKey(ev)
+		case job := <-a.queue.done:
+			// 传输完成, 刷新目标窗格
+			if job.kind == jobDownload {
+				a.panes[0].load(false)
+			} else {
+				a.panes[1].load(false)
+			}
+			a.msg = fmt.Sprintf("#%d %s结束", job.id, job.kind)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *app) activePane() *pane {
+	return a.panes[a.active]
+}
+
+// ask 显示输入框
+func (a *app) ask(label, value string, onDone func(value string)) {
+	a.prompt = &prompt{
+		label:  label,
+		input:  []rune(value),
+		onDone: onDone,
+	}
+}
+
+// confirm 显示确认框, 输入 y 确认
+func (a *app) confirm(label string, onYes func()) {
+	a.ask(label+" (y/N): ", "", func(value string) {
+		if strings.EqualFold(strings.TrimSpace(value), "y") {
+			onYes()
+		}
+	})
+}
+
+func (a *app) handlePromptKey(ev keyEvent) {
+	p := a.prompt
+	switch ev.key {
+	case keyEnter:
+		a.prompt = nil
+		p.onDone(string(p.input))
+	case keyEsc, keyCtrlC:
+		a.prompt = nil
+		a.msg = "已取消"
+	case keyBackspace:
+		if len(p.input) > 0 {
+			p.input = p.input[:len(p.input)-1]
+		}
+	case keyRune:
+		p.input = append(p.input, ev.ch)
+	}
+}
+
+func (a *app) handleKey(ev keyEvent) {
+	if a.prompt != nil {
+		a.handlePromptKey(ev)
+		return
+	}
+
+	p := a.activePane()
+	a.msg = ""
+	switch ev.key {
+	case keyUp:
+		p.move(-1)
+	case keyDown:
+		p.move(1)
+	case keyPgUp:
+		p.move(-a.paneHeight())
+	case keyPgDn:
+		p.move(a.paneHeight())
+	case keyHome:
+		p.move(-len(p.entries))
+	case keyEnd:
+		p.move(len(p.entries))
+	case keyEnter, keyRight:
+		p.enter()
+	case keyBackspace, keyLeft:
+		p.up()
+	case keyTab:
+		a.active = 1 - a.active
+	case keyCtrlC:
+		a.quitConfirm()
+	case keyRune:
+		a.handleRune(ev.ch)
+	}
+}
+
+func (a *app) handleRune(ch rune) {
+	p := a.activePane()
+	switch ch {
+	case 'k':
+		p.move(-1)
+	case 'j':
+		p.move(1)
+	case 'l':
+		p.enter()
+	case 'h':
+		p.up()
+	case ' ':
+		p.toggle()
+	case 'a':
+		p.toggleAll()
+	case 'r':
+		p.load(true)
+	case 'g':
+		a.download()
+	case 'u':
+		a.upload()
+	case 'c':
+		a.copyOrMove(false)
+	case 'm':
+		a.copyOrMove(true)
+	case 'd':
+		a.remove()
+	case 'q':
+		a.quitConfirm()
+	}
+}
+
+func (a *app) quitConfirm() {
+	if !a.queue.running() {
+		a.quit = true
+		return
+	}
+	a.confirm("有未完成的传输任务, 确认退出?", func() {
+		a.quit = true
+	})
+}
+
+// remoteTargets 返回网盘窗格中要操作的路径
+func (a *app) remoteTargets() []string {
+	if a.active != 1 {
+		a.msg = "请在网盘窗格中选择文件"
+		return nil
+	}
+	paths := a.panes[1].targets()
+	if len(paths) == 0 {
+		a.msg = "未选择文件"
+	}
+	return paths
+}
+
+func (a *app) download() {
+	paths := a.remoteTargets()
+	if len(paths) == 0 {
+		return
+	}
+	job := a.queue.add(jobDownload, paths, a.panes[0].dir)
+	a.panes[1].selected = map[string]bool{}
+	a.msg = fmt.Sprintf("#%d 加入下载队列: %d 项 -> %s", job.id, len(paths), job.target)
+}
+
+func (a *app) upload() {
+	if a.active != 0 {
+		a.msg = "请在本地窗格中选择文件"
+		return
+	}
+	paths := a.panes[0].targets()
+	if len(paths) == 0 {
+		a.msg = "未选择文件"
+		return
+	}
+	job := a.queue.add(jobUpload, paths, a.panes[1].dir)
+	a.panes[0].selected = map[string]bool{}
+	a.msg = fmt.Sprintf("#%d 加入上传队列: %d 项 -> %s", job.id, len(paths), job.target)
+}
+
+// copyOrMove 复制或移动网盘文件到输入的目录
+func (a *app) copyOrMove(isMove bool) {
+	paths := a.remoteTargets()
+	if len(paths) == 0 {
+		return
+	}
+
+	op := "复制"
+	if isMove {
+		op = "移动"
+	}
+	a.ask(fmt.Sprintf("%s %d 项到网盘目录: ", op, len(paths)), a.panes[1].dir, func(value string) {
+		to := pcscommand.GetActiveUser().PathJoin(strings.TrimSpace(value))
+		cpmvJSONs := make([]*baidupcs.CpMvJSON, 0, len(paths))
+		for _, from := range paths {
+			cpmvJSONs = append(cpmvJSONs, &baidupcs.CpMvJSON{
+				From: from,
+				To:   path.Join(to, path.Base(from)),
+			})
+		}
+
+		a.msg = fmt.Sprintf("正在%s...", op)
+		a.render()
+
+		var pcsError error
+		if isMove {
+			pcsError = a.pcs.Move(cpmvJSONs...)
+		} else {
+			pcsError = a.pcs.Copy(cpmvJSONs...)
+		}
+		if pcsError != nil {
+			a.msg = fmt.Sprintf("%s失败: %s", op, pcsError)
+			return
+		}
+
+		a.panes[1].selected = map[string]bool{}
+		a.panes[1].load(false)
+		a.msg = fmt.Sprintf("%s成功: %d 项 -> %s", op, len(paths), to)
+	})
+}
+
+func (a *app) remove() {
+	paths := a.remoteTargets()
+	if len(paths) == 0 {
+		return
+	}
+
+	a.confirm(fmt.Sprintf("删除网盘中的 %d 项, 可在回收站找回, 确认?", len(paths)), func() {
+		a.msg = "正在删除..."
+		a.render()
+
+		pcsError := a.pcs.Remove(paths...)
+		if pcsError != nil {
+			a.msg = fmt.Sprintf("删除失败: %s", pcsError)
+			return
+		}
+
+		a.panes[1].selected = map[string]bool{}
+		a.panes[1].load(false)
+		a.msg = fmt.Sprintf("删除成功: %d 项", len(paths))
+	})
+}
+
+func (a *app) size() (width, height int) {
+	width, height, err := terminal.GetSize(int(a.out.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return
+}
+
+func (a *app) queueHeight() int {
+	_, height := a.size()
+	h := height / 3
+	if h > 10 {
+		h = 10
+	}
+	if h < 3 {
+		h = 3
+	}
+	return h
+}
+
+// paneHeight 窗格中文件列表的行数
+func (a *app) paneHeight() int {
+	_, height := a.size()
+	// 标题, 窗格目录, 传输队列, 状态栏, 帮助
+	h := height - 2 - a.queueHeight() - 2
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+// fit 截断或填充字符串到指定的显示宽度
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if runewidth.StringWidth(s) > width {
+		s = runewidth.Truncate(s, width, "~")
+	}
+	return runewidth.FillRight(s, width)
+}
+
+// fitLeft 截断或左侧填充字符串到指定的显示宽度
+func fitLeft(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if runewidth.StringWidth(s) > width {
+		s = runewidth.Truncate(s, width, "~")
+	}
+	return runewidth.FillLeft(s, width)
+}
+
+func (a *app) render() {
+	var (
+		width, height = a.size()
+		leftWidth     = (width - 1) / 2
+		rightWidth    = width - 1 - leftWidth
+		paneHeight    = a.paneHeight()
+		lines         = make([]string, 0, height)
+	)
+
+	user := pcscommand.GetActiveUser()
+	lines = append(lines, escReverse+fit(fmt.Sprintf(" BaiduPCS-Go  帐号: %s", user.Name), width)+escReset)
+
+	// 窗格
+	var cols [2][]string
+	for k, p := range a.panes {
+		w := leftWidth
+		if k == 1 {
+			w = rightWidth
+		}
+		p.scroll(paneHeight)
+		cols[k] = a.renderPane(p, k == a.active, w, paneHeight)
+	}
+	for i := range cols[0] {
+		lines = append(lines, cols[0][i]+"│"+cols[1][i])
+	}
+
+	lines = append(lines, a.renderQueue(width, a.queueHeight())...)
+
+	// 状态栏
+	switch {
+	case a.prompt != nil:
+		lines = append(lines, fit(a.prompt.label+string(a.prompt.input)+"_", width))
+	case a.msg != "":
+		lines = append(lines, escBold+fit(a.msg, width)+escReset)
+	default:
+		lines = append(lines, fit(fmt.Sprintf("%s: 已选择 %d 项", a.activePane().title, len(a.activePane().selected)), width))
+	}
+	lines = append(lines, escReverse+fit(helpText, width)+escReset)
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(escHome)
+	for k, line := range lines {
+		if k > 0 {
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString(line)
+		buf.WriteString(escClearLine)
+	}
+	a.out.Write(buf.Bytes())
+}
+
+func (a *app) renderPane(p *pane, active bool, width, height int) []string {
+	lines := make([]string, 0, height+1)
+
+	title := fmt.Sprintf(" %s: %s", p.title, p.dir)
+	if active {
+		lines = append(lines, escReverse+escBold+fit(title, width)+escReset)
+	} else {
+		lines = append(lines, escBold+fit(title, width)+escReset)
+	}
+
+	if p.err != nil {
+		lines = append(lines, escRed+fit(" "+p.err.Error(), width)+escReset)
+	}
+
+	const sizeWidth = 10
+	for i := p.offset; i < len(p.entries) && len(lines) < height+1; i++ {
+		e := p.entries[i]
+
+		mark := " "
+		if p.selected[e.path] {
+			mark = "*"
+		}
+
+		var name, size string
+		if e.isDir {
+			name = e.name + "/"
+		} else {
+			name = e.name
+			size = converter.ConvertFileSize(e.size, 2)
+		}
+		line := mark + fit(name, width-1-sizeWidth) + fitLeft(size, sizeWidth)
+
+		switch {
+		case active && i == p.cursor:
+			line = escReverse + line + escReset
+		case p.selected[e.path]:
+			line = escYellow + line + escReset
+		case e.isDir:
+			line = escBlue + line + escReset
+		}
+		lines = append(lines, line)
+	}
+
+	for len(lines) < height+1 {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return lines
+}
+
+func (a *app) renderQueue(width, height int) []string {
+	tq := a.queue
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	lines := make([]string, 0, height)
+	lines = append(lines, escReverse+fit(fmt.Sprintf(" 传输队列 (%d)", len(tq.jobs)), width)+escReset)
+
+	var body []string
+	for _, job := range tq.jobs {
+		if job.state == jobDone && len(tq.jobs)-job.id >= height {
+			// 只显示最近的已完成任务
+			continue
+		}
+		body = append(body, fmt.Sprintf(" #%d %s %d 项 -> %s [%s] %s, %s", job.id, job.kind, len(job.paths), job.target, job.state,
+			converter.ConvertFileSize(job.totalSize(), 2), job.elapsed()/time.Second*time.Second))
+		if job.state != jobRunning {
+			continue
+		}
+		for _, id := range tq.taskIDs {
+			body = append(body, "   "+tq.tasks[id])
+		}
+	}
+	if len(tq.logs) > 0 {
+		body = append(body, " "+tq.logs[len(tq.logs)-1])
+	}
+
+	// 内容过多时, 显示最后的部分
+	if len(body) > height-1 {
+		body = body[len(body)-(height-1):]
+	}
+	for _, line := range body {
+		lines = append(lines, fit(line, width))
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines
+}
@@ -0,0 +1,13 @@
+// +build plan9
+
+package pcstui
+
+import (
+	"os"
+	"time"
+)
+
+// waitInput 不支持等待, 直接读取
+func waitInput(in *os.File, timeout time.Duration) bool {
+	return true
+}
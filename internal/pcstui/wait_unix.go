@@ -0,0 +1,25 @@
+// +build !windows,!plan9
+
+package pcstui
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+	"time"
+)
+
+// waitInput 等待终端有可读取的输入, 超时返回 false
+func waitInput(in *os.File, timeout time.Duration) bool {
+	fds := []unix.PollFd{
+		{
+			Fd:     int32(in.Fd()),
+			Events: unix.POLLIN,
+		},
+	}
+	n, err := unix.Poll(fds, int(timeout/time.Millisecond))
+	if err != nil {
+		// 被信号中断等情况, 交由 Read 处理
+		return err != unix.EINTR
+	}
+	return n > 0
+}
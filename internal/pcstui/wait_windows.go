@@ -0,0 +1,16 @@
+package pcstui
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+	"time"
+)
+
+// waitInput 等待终端有可读取的输入, 超时返回 false
+func waitInput(in *os.File, timeout time.Duration) bool {
+	event, err := windows.WaitForSingleObject(windows.Handle(in.Fd()), uint32(timeout/time.Millisecond))
+	if err != nil {
+		return true
+	}
+	return event == windows.WAIT_OBJECT_0
+}
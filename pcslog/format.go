@@ -0,0 +1,305 @@
+package pcslog
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/pcstime"
+	"github.com/json-iterator/go"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// TextTimeLayout text 格式的时间
+	TextTimeLayout = "2006-01-02 15:04:05.000"
+	// RFC3339Milli json, logfmt 格式的时间
+	RFC3339Milli = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// Encode 按格式编码日志, 返回一行数据 (以换行符结尾)
+func (e *Entry) Encode(f Format) []byte {
+	buf := &bytes.Buffer{}
+	switch f {
+	case FormatJSON:
+		e.encodeJSON(buf)
+	case FormatLogfmt:
+		e.encodeLogfmt(buf)
+	default:
+		e.encodeText(buf)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func (e *Entry) moduleName() string {
+	if e.Module == "" {
+		return "-"
+	}
+	return e.Module
+}
+
+func (e *Entry) encodeText(buf *bytes.Buffer) {
+	buf.WriteByte('[')
+	buf.WriteString(e.Time.In(pcstime.CSTLocation).Format(TextTimeLayout))
+	buf.WriteString("] ")
+	buf.WriteString(strings.ToUpper(e.Level.String()))
+	buf.WriteByte(' ')
+	buf.WriteString(e.moduleName())
+	buf.WriteString(": ")
+	buf.WriteString(escapeNewline(e.Msg))
+	for _, field := range e.Fields {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, field.Key, formatValue(field.Value))
+	}
+}
+
+func (e *Entry) encodeLogfmt(buf *bytes.Buffer) {
+	writeLogfmtPair(buf, "time", e.Time.In(pcstime.CSTLocation).Format(RFC3339Milli))
+	buf.WriteByte(' ')
+	writeLogfmtPair(buf, "level", e.Level.String())
+	buf.WriteByte(' ')
+	writeLogfmtPair(buf, "module", e.moduleName())
+	buf.WriteByte(' ')
+	writeLogfmtPair(buf, "msg", e.Msg)
+	for _, field := range e.Fields {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, field.Key, formatValue(field.Value))
+	}
+}
+
+func (e *Entry) encodeJSON(buf *bytes.Buffer) {
+	stream := jsoniter.ConfigCompatibleWithStandardLibrary.BorrowStream(buf)
+	defer jsoniter.ConfigCompatibleWithStandardLibrary.ReturnStream(stream)
+
+	stream.WriteObjectStart()
+	stream.WriteObjectField("time")
+	stream.WriteString(e.Time.In(pcstime.CSTLocation).Format(RFC3339Milli))
+	stream.WriteMore()
+	stream.WriteObjectField("level")
+	stream.WriteString(e.Level.String())
+	stream.WriteMore()
+	stream.WriteObjectField("module")
+	stream.WriteString(e.moduleName())
+	stream.WriteMore()
+	stream.WriteObjectField("msg")
+	stream.WriteString(e.Msg)
+	for _, field := range e.Fields {
+		stream.WriteMore()
+		stream.WriteObjectField(field.Key)
+		switch value := field.Value.(type) {
+		case nil:
+			stream.WriteNil()
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			stream.WriteVal(value)
+		default:
+			stream.WriteString(formatValue(value))
+		}
+	}
+	stream.WriteObjectEnd()
+	stream.Flush()
+}
+
+func (e *Entry) redact() {
+	e.Msg = RedactString(e.Msg)
+	for k := range e.Fields {
+		if IsSensitiveKey(e.Fields[k].Key) {
+			e.Fields[k].Value = RedactedMark
+			continue
+		}
+		switch value := e.Fields[k].Value.(type) {
+		case string:
+			e.Fields[k].Value = RedactString(value)
+		case error:
+			e.Fields[k].Value = RedactString(value.Error())
+		case map[string]string:
+			e.Fields[k].Value = RedactHeader(value)
+		}
+	}
+}
+
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case error:
+		return value.Error()
+	case time.Duration:
+		return value.String()
+	case fmt.Stringer:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func escapeNewline(s string) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(s)
+}
+
+func needQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeLogfmtPair(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if needQuote(value) {
+		buf.WriteString(strconv.Quote(value))
+		return
+	}
+	buf.WriteString(value)
+}
+
+// ParseLine 解析一行日志, 自动识别 text, json, logfmt 格式
+func ParseLine(line string) (e *Entry, ok bool) {
+	line = strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(line, "{"):
+		return parseJSONLine(line)
+	case strings.HasPrefix(line, "["):
+		return parseTextLine(line)
+	case strings.HasPrefix(line, "time="):
+		return parseLogfmtLine(line)
+	}
+	return nil, false
+}
+
+func parseTextLine(line string) (e *Entry, ok bool) {
+	i := strings.Index(line, "] ")
+	if i < 0 {
+		return nil, false
+	}
+
+	t, err := time.ParseInLocation(TextTimeLayout, line[1:i], pcstime.CSTLocation)
+	if err != nil {
+		return nil, false
+	}
+
+	parts := strings.SplitN(line[i+2:], " ", 3)
+	if len(parts) < 2 || !strings.HasSuffix(parts[1], ":") {
+		return nil, false
+	}
+
+	level, err := ParseLevel(parts[0])
+	if err != nil {
+		return nil, false
+	}
+
+	e = &Entry{
+		Time:   t,
+		Level:  level,
+		Module: strings.TrimSuffix(parts[1], ":"),
+	}
+	if len(parts) == 3 {
+		e.Msg = parts[2]
+	}
+	return e, true
+}
+
+func parseLogfmtLine(line string) (e *Entry, ok bool) {
+	e = &Entry{}
+	for line != "" {
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, false
+		}
+		key := line[:i]
+		line = line[i+1:]
+
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			quoted := quotedPrefix(line)
+			if quoted == "" {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(quoted)
+			line = line[len(quoted):]
+		} else {
+			j := strings.IndexByte(line, ' ')
+			if j < 0 {
+				j = len(line)
+			}
+			value = line[:j]
+			line = line[j:]
+		}
+		line = strings.TrimLeft(line, " ")
+
+		if !e.setField(key, value) {
+			return nil, false
+		}
+	}
+	return e, !e.Time.IsZero()
+}
+
+func parseJSONLine(line string) (e *Entry, ok bool) {
+	m := map[string]interface{}{}
+	err := jsoniter.UnmarshalFromString(line, &m)
+	if err != nil {
+		return nil, false
+	}
+
+	e = &Entry{}
+	for _, key := range []string{"time", "level", "module", "msg"} {
+		value, _ := m[key].(string)
+		if !e.setField(key, value) {
+			return nil, false
+		}
+		delete(m, key)
+	}
+	for key, value := range m {
+		e.Fields = append(e.Fields, Field{Key: key, Value: value})
+	}
+	return e, true
+}
+
+// quotedPrefix 返回 s 开头被双引号包围的部分
+func quotedPrefix(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return s[:i+1]
+		}
+	}
+	return ""
+}
+
+func (e *Entry) setField(key, value string) bool {
+	switch key {
+	case "time":
+		t, err := time.Parse(RFC3339Milli, value)
+		if err != nil {
+			return false
+		}
+		e.Time = t
+	case "level":
+		level, err := ParseLevel(value)
+		if err != nil {
+			return false
+		}
+		e.Level = level
+	case "module":
+		e.Module = value
+	case "msg":
+		e.Msg = value
+	default:
+		e.Fields = append(e.Fields, Field{Key: key, Value: value})
+	}
+	return true
+}
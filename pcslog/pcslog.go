@@ -0,0 +1,309 @@
+// Package pcslog 结构化日志包, 支持日志等级, 模块名称, 以及 text/json/logfmt 输出格式
+package pcslog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LevelDebug 调试
+	LevelDebug Level = iota
+	// LevelInfo 提示
+	LevelInfo
+	// LevelWarn 警告
+	LevelWarn
+	// LevelError 错误
+	LevelError
+	// LevelOff 关闭日志
+	LevelOff
+)
+
+const (
+	// FormatText 文本格式
+	FormatText Format = "text"
+	// FormatJSON json 格式
+	FormatJSON Format = "json"
+	// FormatLogfmt logfmt 格式
+	FormatLogfmt Format = "logfmt"
+)
+
+type (
+	// Level 日志等级
+	Level int
+
+	// Format 日志输出格式
+	Format string
+
+	// Field 日志附加字段
+	Field struct {
+		Key   string
+		Value interface{}
+	}
+
+	// Entry 一条日志
+	Entry struct {
+		Time   time.Time
+		Level  Level
+		Module string
+		Msg    string
+		Fields []Field
+	}
+
+	// Logger 按模块区分的日志记录器
+	Logger struct {
+		module string
+		fields []Field
+	}
+)
+
+var (
+	// ErrUnknownLevel 未知的日志等级
+	ErrUnknownLevel = errors.New("unknown log level")
+	// ErrUnknownFormat 未知的日志格式
+	ErrUnknownFormat = errors.New("unknown log format")
+
+	// ConsoleOutputs 终端输出
+	ConsoleOutputs = []io.Writer{os.Stderr}
+
+	levelNames = []string{"debug", "info", "warn", "error", "off"}
+
+	mu             sync.RWMutex
+	defaultLevel   = LevelInfo
+	moduleLevels   = map[string]Level{}
+	fileFormat     = FormatText
+	fileOutput     io.Writer
+	consoleEnabled func() bool
+)
+
+// ParseLevel 解析日志等级, 不区分大小写
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "warning":
+		return LevelWarn, nil
+	case "none", "disable":
+		return LevelOff, nil
+	}
+	for k, name := range levelNames {
+		if name == s {
+			return Level(k), nil
+		}
+	}
+	return LevelOff, fmt.Errorf("%s: %s", ErrUnknownLevel, s)
+}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelOff {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseFormat 解析日志格式
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case FormatText, FormatJSON, FormatLogfmt:
+		return f, nil
+	case "":
+		return FormatText, nil
+	default:
+		return FormatText, fmt.Errorf("%s: %s", ErrUnknownFormat, s)
+	}
+}
+
+// ParseLevelSpec 解析日志等级配置, 例如: info,BAIDUPCS=debug,DOWNLOADER=warn,
+// 不带模块名称的为默认等级
+func ParseLevelSpec(spec string) (def Level, modules map[string]Level, err error) {
+	def = LevelInfo
+	modules = map[string]Level{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		i := strings.Index(item, "=")
+		if i < 0 {
+			def, err = ParseLevel(item)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		var l Level
+		l, err = ParseLevel(item[i+1:])
+		if err != nil {
+			return
+		}
+		modules[strings.ToUpper(strings.TrimSpace(item[:i]))] = l
+	}
+	return
+}
+
+// SetLevelSpec 设置写入日志文件的等级, 参见 ParseLevelSpec
+func SetLevelSpec(spec string) error {
+	def, modules, err := ParseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defaultLevel, moduleLevels = def, modules
+	mu.Unlock()
+	return nil
+}
+
+// SetFormat 设置日志文件的输出格式
+func SetFormat(f Format) {
+	mu.Lock()
+	fileFormat = f
+	mu.Unlock()
+}
+
+// SetFileOutput 设置日志文件输出, nil 则不输出到文件
+func SetFileOutput(w io.Writer) {
+	mu.Lock()
+	fileOutput = w
+	mu.Unlock()
+}
+
+// SetConsoleEnabledFunc 设置是否输出到终端的判断函数, 启用时输出所有等级的日志
+func SetConsoleEnabledFunc(fn func() bool) {
+	mu.Lock()
+	consoleEnabled = fn
+	mu.Unlock()
+}
+
+// New 根据模块名称, 初始化Logger
+func New(module string) *Logger {
+	return &Logger{
+		module: module,
+	}
+}
+
+// Module 返回模块名称
+func (l *Logger) Module() string {
+	return l.module
+}
+
+// With 返回附加了字段的Logger, kv 为键值对
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]Field, 0, len(l.fields)+len(kv)/2)
+	fields = append(fields, l.fields...)
+	return &Logger{
+		module: l.module,
+		fields: append(fields, kvToFields(kv)...),
+	}
+}
+
+// Enabled 该等级的日志是否会被记录
+func (l *Logger) Enabled(level Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return l.enabledLocked(level)
+}
+
+func (l *Logger) enabledLocked(level Level) bool {
+	if consoleEnabled != nil && consoleEnabled() {
+		return true
+	}
+	if fileOutput == nil {
+		return false
+	}
+	return level >= l.fileLevelLocked()
+}
+
+func (l *Logger) fileLevelLocked() Level {
+	if ml, ok := moduleLevels[strings.ToUpper(l.module)]; ok {
+		return ml
+	}
+	return defaultLevel
+}
+
+// Log 记录日志, kv 为附加字段的键值对
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !l.enabledLocked(level) {
+		return
+	}
+
+	entry := &Entry{
+		Time:   time.Now(),
+		Level:  level,
+		Module: l.module,
+		Msg:    strings.TrimRight(msg, "\n"),
+		Fields: append(append(make([]Field, 0, len(l.fields)+len(kv)/2), l.fields...), kvToFields(kv)...),
+	}
+	entry.redact()
+
+	if consoleEnabled != nil && consoleEnabled() {
+		line := entry.Encode(FormatText)
+		for _, output := range ConsoleOutputs {
+			output.Write(line)
+		}
+	}
+	if fileOutput != nil && level >= l.fileLevelLocked() {
+		fileOutput.Write(entry.Encode(fileFormat))
+	}
+}
+
+// Debug 调试
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.Log(LevelDebug, msg, kv...)
+}
+
+// Debugf 调试, 格式输出
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	l.Log(LevelDebug, fmt.Sprintf(format, a...))
+}
+
+// Info 提示
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.Log(LevelInfo, msg, kv...)
+}
+
+// Infof 提示, 格式输出
+func (l *Logger) Infof(format string, a ...interface{}) {
+	l.Log(LevelInfo, fmt.Sprintf(format, a...))
+}
+
+// Warn 警告
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.Log(LevelWarn, msg, kv...)
+}
+
+// Warnf 警告, 格式输出
+func (l *Logger) Warnf(format string, a ...interface{}) {
+	l.Log(LevelWarn, fmt.Sprintf(format, a...))
+}
+
+// Error 错误
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.Log(LevelError, msg, kv...)
+}
+
+// Errorf 错误, 格式输出
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.Log(LevelError, fmt.Sprintf(format, a...))
+}
+
+func kvToFields(kv []interface{}) []Field {
+	fields := make([]Field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		if i+1 >= len(kv) {
+			fields = append(fields, Field{Key: key, Value: "(MISSING)"})
+			break
+		}
+		fields = append(fields, Field{Key: key, Value: kv[i+1]})
+	}
+	return fields
+}
@@ -0,0 +1,59 @@
+package pcslog
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// RedactedMark 敏感信息替换后的内容
+	RedactedMark = "***"
+)
+
+var (
+	// SensitiveKeys 需要隐藏的敏感字段, 不区分大小写
+	SensitiveKeys = []string{
+		"bduss", "stoken", "ptoken", "bdstoken", "access_token", "token", "sign", "devuid",
+		"cookie", "set-cookie", "authorization", "proxy-authorization", "password", "passwd",
+	}
+
+	sensitiveQueryPattern = regexp.MustCompile(`(?i)\b(` + strings.Join(quoteMetaList(SensitiveKeys), "|") + `)=([^&\s";]+)`)
+	proxyUserinfoPattern  = regexp.MustCompile(`(?i)\b((?:https?|socks5h?)://)[^/\s:@]+:[^/\s@]+@`)
+)
+
+func quoteMetaList(list []string) []string {
+	quoted := make([]string, 0, len(list))
+	for _, s := range list {
+		quoted = append(quoted, regexp.QuoteMeta(s))
+	}
+	return quoted
+}
+
+// IsSensitiveKey 是否为敏感字段
+func IsSensitiveKey(key string) bool {
+	for _, k := range SensitiveKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString 隐藏字符串中 key=value 形式的敏感信息, 以及代理地址中的认证信息
+func RedactString(s string) string {
+	s = sensitiveQueryPattern.ReplaceAllString(s, "${1}="+RedactedMark)
+	return proxyUserinfoPattern.ReplaceAllString(s, "${1}"+RedactedMark+"@")
+}
+
+// RedactHeader 返回隐藏了敏感信息的请求头副本
+func RedactHeader(header map[string]string) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for key, value := range header {
+		if IsSensitiveKey(key) {
+			redacted[key] = RedactedMark
+			continue
+		}
+		redacted[key] = RedactString(value)
+	}
+	return redacted
+}
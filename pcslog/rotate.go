@@ -0,0 +1,142 @@
+package pcslog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const (
+	// DefaultMaxSize 默认单个日志文件的最大大小
+	DefaultMaxSize = 10 << 20
+	// DefaultMaxBackups 默认保留的旧日志文件数量
+	DefaultMaxBackups = 3
+)
+
+type (
+	// RotateWriter 按大小轮转的日志文件,
+	// 超过 MaxSize 时, 将 path 重命名为 path.1, path.1 重命名为 path.2, 以此类推
+	RotateWriter struct {
+		path       string
+		maxSize    int64
+		maxBackups int
+
+		file *os.File
+		size int64
+		mu   sync.Mutex
+	}
+)
+
+// NewRotateWriter 初始化RotateWriter
+func NewRotateWriter(path string, maxSize int64, maxBackups int) *RotateWriter {
+	rw := &RotateWriter{
+		path: path,
+	}
+	rw.SetLimit(maxSize, maxBackups)
+	return rw
+}
+
+// SetLimit 设置单个日志文件的最大大小, 以及保留的旧日志文件数量
+func (rw *RotateWriter) SetLimit(maxSize int64, maxBackups int) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxBackups < 0 {
+		maxBackups = 0
+	}
+
+	rw.mu.Lock()
+	rw.maxSize, rw.maxBackups = maxSize, maxBackups
+	rw.mu.Unlock()
+}
+
+// Path 返回日志文件路径
+func (rw *RotateWriter) Path() string {
+	return rw.path
+}
+
+// BackupPath 返回第 n 个旧日志文件的路径
+func (rw *RotateWriter) BackupPath(n int) string {
+	return BackupPath(rw.path, n)
+}
+
+// BackupPath 返回第 n 个旧日志文件的路径
+func BackupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+func (rw *RotateWriter) openFile() (err error) {
+	err = os.MkdirAll(filepath.Dir(rw.path), 0700)
+	if err != nil {
+		return
+	}
+
+	rw.file, err = os.OpenFile(rw.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+
+	info, err := rw.file.Stat()
+	if err != nil {
+		rw.file.Close()
+		rw.file = nil
+		return
+	}
+	rw.size = info.Size()
+	return nil
+}
+
+func (rw *RotateWriter) rotate() (err error) {
+	if rw.file != nil {
+		rw.file.Close()
+		rw.file = nil
+	}
+
+	if rw.maxBackups == 0 {
+		os.Remove(rw.path)
+	} else {
+		os.Remove(rw.BackupPath(rw.maxBackups))
+		for n := rw.maxBackups - 1; n >= 1; n-- {
+			os.Rename(rw.BackupPath(n), rw.BackupPath(n+1))
+		}
+		os.Rename(rw.path, rw.BackupPath(1))
+	}
+	return rw.openFile()
+}
+
+// Write 写入日志, 超过大小限制时自动轮转
+func (rw *RotateWriter) Write(p []byte) (n int, err error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.file == nil {
+		err = rw.openFile()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if rw.size > 0 && rw.size+int64(len(p)) > rw.maxSize {
+		err = rw.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = rw.file.Write(p)
+	rw.size += int64(n)
+	return
+}
+
+// Close 关闭日志文件
+func (rw *RotateWriter) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.file == nil {
+		return nil
+	}
+	err := rw.file.Close()
+	rw.file = nil
+	return err
+}
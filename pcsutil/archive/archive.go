@@ -0,0 +1,123 @@
+// Package archive 将本地目录打包为 tar, zip, tar.zst 数据流, 同时生成成员的索引,
+// 根据索引, 只需要读取压缩包中成员所在的范围, 即可解压单个成员
+package archive
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// FormatTar tar 格式, 不压缩
+	FormatTar Format = "tar"
+	// FormatZip zip 格式, 每个成员单独压缩
+	FormatZip Format = "zip"
+	// FormatTarZst tar 格式, 使用 zstd 分帧压缩
+	FormatTarZst Format = "tar.zst"
+
+	// IndexSuffix 索引文件的后缀
+	IndexSuffix = ".index.json"
+)
+
+var (
+	// ErrUnknownFormat 未知的压缩包格式
+	ErrUnknownFormat = errors.New("unknown archive format, supported: tar, zip, tar.zst")
+	// ErrCorruptIndex 索引与压缩包不匹配
+	ErrCorruptIndex = errors.New("archive index is corrupt")
+	// ErrIllegalName 成员名称非法, 可能导致写入目标目录之外
+	ErrIllegalName = errors.New("illegal member name")
+	// ErrFileChanged 打包时文件被修改
+	ErrFileChanged = errors.New("file changed while archiving")
+)
+
+type (
+	// Format 压缩包格式
+	Format string
+
+	// Index 压缩包索引, 记录每个成员在压缩包中的位置
+	Index struct {
+		Format  Format    `json:"format"`
+		Size    int64     `json:"size"` // 压缩包大小
+		Members []*Member `json:"members"`
+		Frames  []*Frame  `json:"frames,omitempty"` // tar.zst 的压缩帧
+	}
+
+	// Member 压缩包成员
+	Member struct {
+		Name    string `json:"name"` // 使用 / 分隔的相对路径
+		Mode    uint32 `json:"mode"` // os.FileMode
+		ModTime int64  `json:"mtime"`
+		Size    int64  `json:"size"`
+		// Offset 成员数据在 tar/zip 数据流中的偏移量, tar.zst 为解压后的偏移量
+		Offset int64 `json:"offset"`
+		// StoredSize 成员数据在数据流中的长度, zip 压缩的成员为长度的上限
+		StoredSize int64  `json:"stored_size"`
+		Method     uint16 `json:"method,omitempty"` // zip 压缩方法
+	}
+
+	// Frame tar.zst 的一个独立的 zstd 压缩帧
+	Frame struct {
+		Offset           int64 `json:"offset"` // 解压后的偏移量
+		Size             int64 `json:"size"`   // 解压后的大小
+		CompressedOffset int64 `json:"compressed_offset"`
+		CompressedSize   int64 `json:"compressed_size"`
+	}
+)
+
+// ParseFormat 解析压缩包格式
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatTar, FormatZip, FormatTarZst:
+		return f, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Ext 压缩包的扩展名
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+// Find 按名称查找成员
+func (idx *Index) Find(name string) *Member {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	for _, m := range idx.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Select 按名称选择成员, 名称为目录时, 包含目录下的所有成员,
+// 返回的成员按偏移量排序
+func (idx *Index) Select(names ...string) (members []*Member, notFound []string) {
+	selected := map[*Member]bool{}
+	for _, name := range names {
+		name = strings.Trim(path.Clean("/"+name), "/")
+		found := false
+		for _, m := range idx.Members {
+			if m.Name == name || strings.HasPrefix(m.Name, name+"/") {
+				selected[m] = true
+				found = true
+			}
+		}
+		if !found {
+			notFound = append(notFound, name)
+		}
+	}
+
+	for m := range selected {
+		members = append(members, m)
+	}
+	sortMembers(members)
+	return
+}
+
+func sortMembers(members []*Member) {
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Offset < members[j].Offset
+	})
+}
@@ -0,0 +1,101 @@
+package archive_test
+
+import (
+	"bytes"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/archive"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestDir(t *testing.T, root string) map[string][]byte {
+	files := map[string][]byte{
+		"a.txt":          []byte("hello"),
+		"empty":          {},
+		"sub/b.bin":      make([]byte, 300*1024),
+		"sub/deep/c.bin": make([]byte, 5*1024*1024),
+	}
+	rnd := rand.New(rand.NewSource(1))
+	rnd.Read(files["sub/b.bin"])
+	rnd.Read(files["sub/deep/c.bin"])
+
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestWriteDirExtract(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "archive_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	root := filepath.Join(tmp, "src")
+	files := writeTestDir(t, root)
+
+	for _, format := range []archive.Format{archive.FormatTar, archive.FormatZip, archive.FormatTarZst} {
+		buf := &bytes.Buffer{}
+		idx, err := archive.WriteDir(buf, root, format)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if idx.Size != int64(buf.Len()) {
+			t.Fatalf("%s: index size %d, archive size %d", format, idx.Size, buf.Len())
+		}
+
+		var (
+			data     = buf.Bytes()
+			requests int
+			open     = func(begin, end int64) (io.ReadCloser, error) {
+				requests++
+				return ioutil.NopCloser(bytes.NewReader(data[begin:end])), nil
+			}
+		)
+
+		// 单个成员
+		members, notFound := idx.Select("src/sub/b.bin")
+		if len(members) != 1 || len(notFound) != 0 {
+			t.Fatalf("%s: select: %v, not found: %v", format, members, notFound)
+		}
+		out := filepath.Join(tmp, string(format)+"-one")
+		err = idx.Extract(open, out, members)
+		if err != nil {
+			t.Fatalf("%s: extract one: %s", format, err)
+		}
+		got, err := ioutil.ReadFile(filepath.Join(out, "src", "sub", "b.bin"))
+		if err != nil || !bytes.Equal(got, files["sub/b.bin"]) {
+			t.Fatalf("%s: extract one: content mismatch, err: %v", format, err)
+		}
+		if _, err = os.Stat(filepath.Join(out, "src", "a.txt")); !os.IsNotExist(err) {
+			t.Fatalf("%s: extract one: unexpected member extracted", format)
+		}
+
+		// 全部成员, 合并为一次读取
+		requests = 0
+		out = filepath.Join(tmp, string(format)+"-all")
+		err = idx.Extract(open, out, nil)
+		if err != nil {
+			t.Fatalf("%s: extract all: %s", format, err)
+		}
+		if requests != 1 {
+			t.Errorf("%s: extract all: %d requests, want 1", format, requests)
+		}
+		for name, want := range files {
+			got, err := ioutil.ReadFile(filepath.Join(out, "src", filepath.FromSlash(name)))
+			if err != nil || !bytes.Equal(got, want) {
+				t.Fatalf("%s: extract all: %s mismatch, err: %v", format, name, err)
+			}
+		}
+	}
+}
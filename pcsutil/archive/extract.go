@@ -0,0 +1,182 @@
+package archive
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MergeGap 解压多个成员时, 间隔不超过 MergeGap 的成员合并为一次范围读取
+	MergeGap = 256 * converter.KB
+)
+
+type (
+	// RangeOpenFunc 打开压缩包 [begin, end) 范围的数据
+	RangeOpenFunc func(begin, end int64) (io.ReadCloser, error)
+
+	emptyReadCloser struct{}
+
+	limitReadCloser struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+func (emptyReadCloser) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (emptyReadCloser) Close() error {
+	return nil
+}
+
+// openStream 打开 tar/zip 数据流 [begin, end) 范围的数据, tar.zst 只读取并解压所在的帧
+func (idx *Index) openStream(open RangeOpenFunc, begin, end int64) (io.ReadCloser, error) {
+	if begin >= end {
+		return emptyReadCloser{}, nil
+	}
+	if idx.Format != FormatTarZst {
+		return open(begin, end)
+	}
+
+	i := sort.Search(len(idx.Frames), func(i int) bool {
+		return idx.Frames[i].Offset+idx.Frames[i].Size > begin
+	})
+	j := sort.Search(len(idx.Frames), func(i int) bool {
+		return idx.Frames[i].Offset >= end
+	})
+	if i >= j {
+		return nil, ErrCorruptIndex
+	}
+
+	first, last := idx.Frames[i], idx.Frames[j-1]
+	rc, err := open(first.CompressedOffset, last.CompressedOffset+last.CompressedSize)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := newZstdReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+
+	_, err = io.CopyN(ioutil.Discard, zr, begin-first.Offset)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return limitReadCloser{io.LimitReader(zr, end-begin), zr}, nil
+}
+
+// Extract 解压成员到本地目录 dir, members 为空则解压全部成员.
+// 偏移量相近的成员合并为一次范围读取
+func (idx *Index) Extract(open RangeOpenFunc, dir string, members []*Member) error {
+	if len(members) == 0 {
+		members = idx.Members
+	}
+	members = append([]*Member{}, members...)
+	sortMembers(members)
+
+	for i := 0; i < len(members); {
+		var (
+			j   = i + 1
+			end = members[i].Offset + members[i].StoredSize
+		)
+		for ; j < len(members) && members[j].Offset <= end+MergeGap; j++ {
+			if e := members[j].Offset + members[j].StoredSize; e > end {
+				end = e
+			}
+		}
+
+		err := idx.extractSpan(open, dir, members[i:j], end)
+		if err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+// extractSpan 读取一次 [members[0].Offset, end) 范围的数据, 依次解压其中的成员
+func (idx *Index) extractSpan(open RangeOpenFunc, dir string, members []*Member, end int64) error {
+	pos := members[0].Offset
+	rc, err := idx.openStream(open, pos, end)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	for _, m := range members {
+		if m.Offset < pos {
+			return ErrCorruptIndex
+		}
+		_, err = io.CopyN(ioutil.Discard, rc, m.Offset-pos)
+		if err != nil {
+			return err
+		}
+
+		lr := io.LimitReader(rc, m.StoredSize)
+		err = extractMember(dir, m, lr)
+		if err != nil {
+			return err
+		}
+		// zip 的 StoredSize 为上限, 跳过剩余的部分
+		_, err = io.Copy(ioutil.Discard, lr)
+		if err != nil {
+			return err
+		}
+		pos = m.Offset + m.StoredSize
+	}
+	return nil
+}
+
+// extractMember 从 r 读取成员的数据, 写入本地目录 dir
+func extractMember(dir string, m *Member, r io.Reader) error {
+	dir = filepath.Clean(dir)
+	target := filepath.Join(dir, filepath.FromSlash(m.Name))
+	if !strings.HasPrefix(target, dir+string(os.PathSeparator)) {
+		return ErrIllegalName
+	}
+
+	mode := os.FileMode(m.Mode)
+	if mode.IsDir() {
+		return os.MkdirAll(target, mode.Perm()|0700)
+	}
+
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	if m.Method == zip.Deflate {
+		fr := flate.NewReader(r)
+		defer fr.Close()
+		r = fr
+	}
+	_, err = io.CopyN(f, r, m.Size)
+	if err == io.EOF {
+		err = ErrCorruptIndex
+	}
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	mtime := time.Unix(m.ModTime, 0)
+	return os.Chtimes(target, mtime, mtime)
+}
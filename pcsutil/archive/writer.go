@@ -0,0 +1,199 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+type (
+	// countWriter 统计写入的数据量
+	countWriter struct {
+		w io.Writer
+		n int64
+	}
+
+	walkFunc func(name, localPath string, info os.FileInfo) error
+)
+
+func (cw *countWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	return
+}
+
+// WriteDir 将本地目录 root 打包为 format 格式, 写入 w, 返回索引.
+// 成员名称以 root 的目录名开头, 只打包目录和普通文件
+func WriteDir(w io.Writer, root string, format Format) (idx *Index, err error) {
+	var (
+		cw    = &countWriter{w: w}
+		inner = cw
+		fw    *frameWriter
+	)
+	idx = &Index{
+		Format: format,
+	}
+
+	switch format {
+	case FormatTar, FormatZip:
+	case FormatTarZst:
+		fw, err = newFrameWriter(cw, idx)
+		if err != nil {
+			return nil, err
+		}
+		inner = &countWriter{w: fw}
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	walk := func(fn walkFunc) error {
+		base := filepath.Base(root)
+		return filepath.Walk(root, func(localPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, localPath)
+			if err != nil {
+				return err
+			}
+			return fn(path.Join(base, filepath.ToSlash(rel)), localPath, info)
+		})
+	}
+
+	if format == FormatZip {
+		err = writeZip(inner, idx, walk)
+	} else {
+		err = writeTar(inner, idx, walk)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if fw != nil {
+		err = fw.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	idx.Size = cw.n
+	return idx, nil
+}
+
+func writeTar(cw *countWriter, idx *Index, walk func(walkFunc) error) error {
+	tw := tar.NewWriter(cw)
+	err := walk(func(name, localPath string, info os.FileInfo) error {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		// tar 的文件头直接写入, 写入后即为数据的偏移量
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		m := &Member{
+			Name:    name,
+			Mode:    uint32(info.Mode()),
+			ModTime: info.ModTime().Unix(),
+			Offset:  cw.n,
+		}
+		idx.Members = append(idx.Members, m)
+		if info.IsDir() {
+			return nil
+		}
+
+		m.Size, m.StoredSize = info.Size(), info.Size()
+		return copyFile(tw, localPath, m.Size)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeZip(cw *countWriter, idx *Index, walk func(walkFunc) error) error {
+	var (
+		zw   = zip.NewWriter(cw)
+		prev *Member // 上一个有数据的成员, 压缩后的长度在下一个成员开始时才能确定
+	)
+	err := walk(func(name, localPath string, info os.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+			hdr.Method = zip.Store
+		} else {
+			hdr.Method = zip.Deflate
+		}
+
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		err = zw.Flush()
+		if err != nil {
+			return err
+		}
+
+		if prev != nil {
+			prev.StoredSize = cw.n - prev.Offset
+			prev = nil
+		}
+		m := &Member{
+			Name:    name,
+			Mode:    uint32(info.Mode()),
+			ModTime: info.ModTime().Unix(),
+			Offset:  cw.n,
+		}
+		idx.Members = append(idx.Members, m)
+		if info.IsDir() {
+			return nil
+		}
+
+		m.Size, m.Method = info.Size(), hdr.Method
+		prev = m
+		return copyFile(w, localPath, m.Size)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	if prev != nil {
+		prev.StoredSize = cw.n - prev.Offset
+	}
+	return nil
+}
+
+// copyFile 复制文件的 size 字节, 文件大小不一致时返回 ErrFileChanged
+func copyFile(w io.Writer, localPath string, size int64) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.CopyN(w, f, size)
+	if err == io.EOF || n != size {
+		return ErrFileChanged
+	}
+	return err
+}
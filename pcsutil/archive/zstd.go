@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/klauspost/compress/zstd"
+	"io"
+)
+
+const (
+	// ZstdFrameSize tar.zst 每个压缩帧解压后的大小, 解压单个成员时, 只需要读取成员所在的帧
+	ZstdFrameSize = 4 * converter.MB
+)
+
+type (
+	// frameWriter 将数据按 ZstdFrameSize 分为独立的 zstd 帧写入, 并记录到索引
+	frameWriter struct {
+		w      *countWriter
+		idx    *Index
+		enc    *zstd.Encoder
+		buf    []byte // 未压缩的数据
+		frame  []byte // 压缩后的帧, 重复使用
+		offset int64  // buf 开头的解压后的偏移量
+	}
+
+	// zstdReader 解压连续的 zstd 帧, 关闭时关闭数据源
+	zstdReader struct {
+		rc  io.ReadCloser
+		dec *zstd.Decoder
+	}
+)
+
+func newFrameWriter(w *countWriter, idx *Index) (*frameWriter, error) {
+	// 只使用 EncodeAll, 每次调用生成一个独立的帧
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &frameWriter{
+		w:   w,
+		idx: idx,
+		enc: enc,
+		buf: make([]byte, 0, ZstdFrameSize),
+	}, nil
+}
+
+func (fw *frameWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		k := int(ZstdFrameSize) - len(fw.buf)
+		if k > len(p) {
+			k = len(p)
+		}
+		fw.buf = append(fw.buf, p[:k]...)
+		p = p[k:]
+		n += k
+
+		if len(fw.buf) >= int(ZstdFrameSize) {
+			err = fw.flushFrame()
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// flushFrame 压缩缓冲区的数据, 作为一个独立的帧写入
+func (fw *frameWriter) flushFrame() error {
+	if len(fw.buf) == 0 {
+		return nil
+	}
+
+	frame := &Frame{
+		Offset:           fw.offset,
+		Size:             int64(len(fw.buf)),
+		CompressedOffset: fw.w.n,
+	}
+	fw.frame = fw.enc.EncodeAll(fw.buf, fw.frame[:0])
+	_, err := fw.w.Write(fw.frame)
+	if err != nil {
+		return err
+	}
+
+	frame.CompressedSize = fw.w.n - frame.CompressedOffset
+	fw.idx.Frames = append(fw.idx.Frames, frame)
+	fw.offset += frame.Size
+	fw.buf = fw.buf[:0]
+	return nil
+}
+
+// Close 写入剩余的数据
+func (fw *frameWriter) Close() error {
+	err := fw.flushFrame()
+	fw.enc.Close()
+	return err
+}
+
+func newZstdReader(rc io.ReadCloser) (*zstdReader, error) {
+	dec, err := zstd.NewReader(rc, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdReader{
+		rc:  rc,
+		dec: dec,
+	}, nil
+}
+
+func (zr *zstdReader) Read(p []byte) (n int, err error) {
+	return zr.dec.Read(p)
+}
+
+// Close 释放解压器, 关闭数据源
+func (zr *zstdReader) Close() error {
+	zr.dec.Close()
+	return zr.rc.Close()
+}
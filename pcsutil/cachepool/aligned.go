@@ -0,0 +1,26 @@
+package cachepool
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// AlignedByteSlice 申请首地址按 align 对齐的 []byte, align 须为2的幂, 用于 O_DIRECT 等要求内存对齐的场景.
+// The slice is not zeroed.
+func AlignedByteSlice(size, align int) []byte {
+	b := RawMallocByteSlice(size + align)
+	offset := int(uintptr(unsafe.Pointer(&b[0])) & uintptr(align-1))
+	if offset != 0 {
+		offset = align - offset
+	}
+	return b[offset : offset+size : offset+size]
+}
+
+// NewAlignedPool 初始化首地址按 align 对齐的 []byte 缓存池, 每个 []byte 的大小为 size
+func NewAlignedPool(size, align int) *sync.Pool {
+	return &sync.Pool{
+		New: func() interface{} {
+			return AlignedByteSlice(size, align)
+		},
+	}
+}
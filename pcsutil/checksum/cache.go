@@ -0,0 +1,246 @@
+package checksum
+
+import (
+	"bufio"
+	"github.com/json-iterator/go"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// CacheEntry 缓存的本地文件摘要值
+	CacheEntry struct {
+		Path      string `json:"path"`
+		Dev       uint64 `json:"dev,omitempty"`
+		Ino       uint64 `json:"ino,omitempty"`
+		Size      int64  `json:"size"`
+		ModTime   int64  `json:"mtime"` // 修改时间, 纳秒
+		SliceSize int    `json:"slice_size,omitempty"`
+		MD5       []byte `json:"md5,omitempty"`
+		SliceMD5  []byte `json:"slicemd5,omitempty"`
+		CRC32     uint32 `json:"crc32,omitempty"`
+		SHA256    []byte `json:"sha256,omitempty"`
+		Flag      int    `json:"flag"` // 已缓存的摘要值
+		Time      int64  `json:"time"` // 计算的时间
+	}
+
+	// Cache 本地文件摘要值的持久化缓存, 按设备号, inode, 大小和修改时间识别未改变的文件.
+	// 缓存文件只追加写入, 同一个键以最后一条为准, 可使用 Prune 压缩
+	Cache struct {
+		filename string
+		entries  map[string]*CacheEntry
+		file     *os.File
+		mu       sync.Mutex
+	}
+)
+
+// key 缓存的键, 不支持 inode 时使用路径
+func (ce *CacheEntry) key() string {
+	if ce.Ino != 0 {
+		return strconv.FormatUint(ce.Dev, 10) + ":" + strconv.FormatUint(ce.Ino, 10) + ":" + strconv.FormatInt(ce.Size, 10) + ":" + strconv.FormatInt(ce.ModTime, 10)
+	}
+	return ce.Path + ":" + strconv.FormatInt(ce.Size, 10) + ":" + strconv.FormatInt(ce.ModTime, 10)
+}
+
+// newCacheEntry 根据文件信息生成缓存项, 不包含摘要值
+func newCacheEntry(localPath string, info os.FileInfo) *CacheEntry {
+	absPath, err := filepath.Abs(localPath)
+	if err == nil {
+		localPath = absPath
+	}
+	ce := &CacheEntry{
+		Path:    localPath,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+	}
+	ce.Dev, ce.Ino, _ = fileID(info)
+	return ce
+}
+
+// OpenCache 打开缓存文件, 不存在则创建
+func OpenCache(filename string) (*Cache, error) {
+	c := &Cache{
+		filename: filename,
+		entries:  map[string]*CacheEntry{},
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	c.file = f
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		ce := &CacheEntry{}
+		if jsoniter.Unmarshal(scanner.Bytes(), ce) != nil {
+			// 忽略损坏的行, 如写入时中断
+			continue
+		}
+		c.entries[ce.key()] = ce
+	}
+	return c, nil
+}
+
+// Len 缓存项的数量
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Get 查找未改变的文件的摘要值, 需包含 flag 指定的全部摘要值
+func (c *Cache) Get(localPath string, info os.FileInfo, sliceSize, flag int) *CacheEntry {
+	if c == nil {
+		return nil
+	}
+	key := newCacheEntry(localPath, info).key()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ce := c.entries[key]
+	if ce == nil || ce.Flag&flag != flag {
+		return nil
+	}
+	if flag&CHECKSUM_SLICE_MD5 != 0 && ce.SliceSize != sliceSize {
+		return nil
+	}
+	return ce
+}
+
+// Put 缓存文件的摘要值, 与已缓存的摘要值合并
+func (c *Cache) Put(localPath string, info os.FileInfo, lfm *LocalFileMeta, sliceSize, flag int) error {
+	if c == nil || flag == 0 {
+		return nil
+	}
+	ce := newCacheEntry(localPath, info)
+	key := ce.key()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old := c.entries[key]; old != nil {
+		*ce = *old
+	}
+	if flag&CHECKSUM_MD5 != 0 {
+		ce.MD5 = lfm.MD5
+	}
+	if flag&CHECKSUM_SLICE_MD5 != 0 {
+		ce.SliceMD5 = lfm.SliceMD5
+		ce.SliceSize = sliceSize
+	}
+	if flag&CHECKSUM_CRC32 != 0 {
+		ce.CRC32 = lfm.CRC32
+	}
+	if flag&CHECKSUM_SHA256 != 0 {
+		ce.SHA256 = lfm.SHA256
+	}
+	ce.Flag |= flag
+	ce.Time = time.Now().Unix()
+	c.entries[key] = ce
+
+	data, err := jsoniter.Marshal(ce)
+	if err != nil {
+		return err
+	}
+	_, err = c.file.Write(append(data, '\n'))
+	return err
+}
+
+// Prune 清除文件已不存在或已改变的缓存项, 以及 before 之前计算的缓存项 (before 为零值则不限),
+// 并重写缓存文件, 返回清除的数量
+func (c *Cache) Prune(before time.Time) (pruned int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, ce := range c.entries {
+		if !before.IsZero() && ce.Time < before.Unix() {
+			delete(c.entries, key)
+			pruned++
+			continue
+		}
+		info, err := os.Stat(ce.Path)
+		if err != nil || newCacheEntry(ce.Path, info).key() != key {
+			delete(c.entries, key)
+			pruned++
+		}
+	}
+
+	// 写入临时文件, 再替换
+	tmpName := c.filename + ".tmp"
+	tmp, err := os.OpenFile(tmpName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return pruned, err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := jsoniter.NewEncoder(w)
+	for _, ce := range c.entries {
+		err = enc.Encode(ce)
+		if err != nil {
+			tmp.Close()
+			return pruned, err
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return pruned, err
+	}
+
+	c.file.Close()
+	err = os.Rename(tmpName, c.filename)
+	if err != nil {
+		return pruned, err
+	}
+	c.file, err = os.OpenFile(c.filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	return pruned, err
+}
+
+// Close 关闭缓存文件
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// SumWithCache 计算文件摘要值, 文件未改变时使用缓存, 计算后写入缓存, cache 为 nil 时不使用缓存.
+// 需先调用 OpenPath
+func (lfc *LocalFileChecksum) SumWithCache(cache *Cache, checkSumFlag int) (err error) {
+	if cache == nil || lfc.file == nil {
+		return lfc.Sum(checkSumFlag)
+	}
+	lfc.fix()
+
+	info, err := lfc.file.Stat()
+	if err != nil {
+		return err
+	}
+	if ce := cache.Get(lfc.Path, info, lfc.sliceSize, checkSumFlag); ce != nil {
+		if checkSumFlag&CHECKSUM_MD5 != 0 {
+			lfc.MD5 = ce.MD5
+		}
+		if checkSumFlag&CHECKSUM_SLICE_MD5 != 0 {
+			lfc.SliceMD5 = ce.SliceMD5
+		}
+		if checkSumFlag&CHECKSUM_CRC32 != 0 {
+			lfc.CRC32 = ce.CRC32
+		}
+		if checkSumFlag&CHECKSUM_SHA256 != 0 {
+			lfc.SHA256 = ce.SHA256
+		}
+		return nil
+	}
+
+	err = lfc.Sum(checkSumFlag)
+	if err != nil {
+		return err
+	}
+	return cache.Put(lfc.Path, info, &lfc.LocalFileMeta, lfc.sliceSize, checkSumFlag)
+}
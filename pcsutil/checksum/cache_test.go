@@ -0,0 +1,73 @@
+package checksum_test
+
+import (
+	"bytes"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checksum_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		filename  = filepath.Join(dir, "a.txt")
+		cacheName = filepath.Join(dir, "cache.jsonl")
+		flag      = checksum.CHECKSUM_MD5 | checksum.CHECKSUM_CRC32
+	)
+	err = ioutil.WriteFile(filename, []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := checksum.OpenCache(cacheName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := checksum.GetFileSumWithCache(cache, filename, flag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Close()
+
+	// 重新打开, 从缓存文件读取
+	cache, err = checksum.OpenCache(cacheName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if cache.Len() != 1 {
+		t.Fatalf("cache len %d, want 1", cache.Len())
+	}
+	info, _ := os.Stat(filename)
+	ce := cache.Get(filename, info, checksum.DefaultBufSize, flag)
+	if ce == nil || !bytes.Equal(ce.MD5, want.MD5) || ce.CRC32 != want.CRC32 {
+		t.Fatalf("cache entry %+v, want md5 %x crc32 %d", ce, want.MD5, want.CRC32)
+	}
+	if cache.Get(filename, info, checksum.DefaultBufSize, checksum.CHECKSUM_SLICE_MD5) != nil {
+		t.Fatal("slice md5 should not be cached")
+	}
+
+	// 文件改变后, 缓存失效
+	mtime := info.ModTime().Add(time.Second)
+	os.Chtimes(filename, mtime, mtime)
+	info, _ = os.Stat(filename)
+	if cache.Get(filename, info, checksum.DefaultBufSize, flag) != nil {
+		t.Fatal("changed file should not hit the cache")
+	}
+
+	pruned, err := cache.Prune(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 || cache.Len() != 0 {
+		t.Fatalf("pruned %d, len %d, want 1, 0", pruned, cache.Len())
+	}
+}
@@ -3,6 +3,7 @@ package checksum
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/cachepool"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
 	"hash/crc32"
@@ -22,6 +23,8 @@ const (
 	CHECKSUM_SLICE_MD5
 	// CHECKSUM_CRC32 获取文件的 crc32 值
 	CHECKSUM_CRC32
+	// CHECKSUM_SHA256 获取文件的 sha256 值
+	CHECKSUM_SHA256
 )
 
 type (
@@ -33,6 +36,11 @@ type (
 		MD5      []byte `json:"md5"`      // 文件的 md5
 		CRC32    uint32 `json:"crc32"`    // 文件的 crc32
 		ModTime  int64  `json:"modtime"`  // 修改日期
+
+		// SHA256 文件的 sha256, 不用于秒传
+		SHA256 []byte `json:"sha256,omitempty"`
+		// Fingerprint 文件的内容指纹, 文件重命名或移动后不变
+		Fingerprint string `json:"fingerprint,omitempty"`
 	}
 
 	// LocalFileChecksum 校验本地文件
@@ -180,7 +188,7 @@ func (lfc *LocalFileChecksum) createChecksumWriteUnit(cw ChecksumWriter, isAll,
 // Sum 计算文件摘要值
 func (lfc *LocalFileChecksum) Sum(checkSumFlag int) (err error) {
 	lfc.fix()
-	wus := make([]*ChecksumWriteUnit, 0, 2)
+	wus := make([]*ChecksumWriteUnit, 0, 3)
 	if (checkSumFlag & (CHECKSUM_MD5 | CHECKSUM_SLICE_MD5)) != 0 {
 		md5w := md5.New()
 		wu, d := lfc.createChecksumWriteUnit(
@@ -217,6 +225,23 @@ func (lfc *LocalFileChecksum) Sum(checkSumFlag int) (err error) {
 		defer d(err)
 	}
 
+	if (checkSumFlag & CHECKSUM_SHA256) != 0 {
+		sha256w := sha256.New()
+		wu, d := lfc.createChecksumWriteUnit(
+			NewHashChecksumWriter(sha256w),
+			true,
+			false,
+			func(sliceSum interface{}, sum interface{}) {
+				if sum != nil {
+					lfc.SHA256 = sum.([]byte)
+				}
+			},
+		)
+
+		wus = append(wus, wu)
+		defer d(err)
+	}
+
 	err = lfc.repeatRead(wus...)
 	return
 }
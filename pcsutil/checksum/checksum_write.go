@@ -44,7 +44,10 @@ func (wi *ChecksumWriteUnit) handleEnd() error {
 
 func (wi *ChecksumWriteUnit) write(p []byte) (n int, err error) {
 	if wi.End <= 0 {
-		// do nothing
+		// 空文件, 也有摘要值
+		if !wi.OnlySliceSum && wi.Sum == nil {
+			wi.Sum = wi.ChecksumWriter.Sum()
+		}
 		err = ErrChecksumWriteStop
 		return
 	}
@@ -47,3 +47,20 @@ func GetFileSum(localPath string, flag int) (lfc *LocalFileChecksum, err error)
 	}
 	return lfc, nil
 }
+
+// GetFileSumWithCache 获取文件的摘要值, 文件未改变时使用缓存 cache
+func GetFileSumWithCache(cache *Cache, localPath string, flag int) (lfc *LocalFileChecksum, err error) {
+	lfc = NewLocalFileChecksum(localPath, int(baidupcs.SliceMD5Size))
+	defer lfc.Close()
+
+	err = lfc.OpenPath()
+	if err != nil {
+		return nil, err
+	}
+
+	err = lfc.SumWithCache(cache, flag)
+	if err != nil {
+		return nil, err
+	}
+	return lfc, nil
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package checksum
+
+import (
+	"os"
+)
+
+// fileID 不支持获取 inode, 使用文件路径作为缓存的键
+func fileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,63 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"io"
+)
+
+const (
+	// FingerprintSampleSize 计算内容指纹时, 每个采样块的大小
+	FingerprintSampleSize = 64 * converter.KB
+	// FingerprintSamples 计算内容指纹时, 采样块的数量
+	FingerprintSamples = 16
+)
+
+// Fingerprint 计算内容指纹, 由数据大小和均匀分布的采样块的 md5 组成,
+// 只读取少量的数据, 用于断点续传时识别重命名或移动后的同一个文件
+func Fingerprint(r io.ReaderAt, size int64) (string, error) {
+	var (
+		h       = md5.New()
+		sizeBuf [8]byte
+	)
+	binary.BigEndian.PutUint64(sizeBuf[:], uint64(size))
+	h.Write(sizeBuf[:])
+
+	if size <= FingerprintSampleSize*FingerprintSamples {
+		// 数据较小, 读取全部
+		_, err := io.Copy(h, io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	var (
+		buf  = make([]byte, FingerprintSampleSize)
+		step = (size - FingerprintSampleSize) / (FingerprintSamples - 1)
+	)
+	for i := int64(0); i < FingerprintSamples; i++ {
+		n, err := r.ReadAt(buf, i*step)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		h.Write(buf[:n])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SumFingerprint 计算文件的内容指纹, 须先调用 OpenPath
+func (lfc *LocalFileChecksum) SumFingerprint() error {
+	if lfc.file == nil {
+		return ErrFileIsNil
+	}
+
+	fingerprint, err := Fingerprint(lfc.file, lfc.Length)
+	if err != nil {
+		return err
+	}
+	lfc.Fingerprint = fingerprint
+	return nil
+}
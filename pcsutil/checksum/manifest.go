@@ -0,0 +1,193 @@
+package checksum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ManifestMD5 md5sum 兼容的格式: <md5>  <路径>
+	ManifestMD5 = "md5"
+	// ManifestSHA256 sha256sum 兼容的格式: <sha256>  <路径>
+	ManifestSHA256 = "sha256"
+	// ManifestRapid 秒传信息格式: <md5>#<前256KB切片的md5>#<crc32>#<文件大小>  <路径>
+	ManifestRapid = "rapid"
+)
+
+var (
+	// ErrManifestFormat 未知的清单格式
+	ErrManifestFormat = errors.New("unknown manifest format, supported: md5, sha256, rapid")
+	// ErrManifestLine 清单的行格式错误
+	ErrManifestLine = errors.New("improperly formatted manifest line")
+
+	manifestPathEscaper   = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+	manifestPathUnescaper = strings.NewReplacer("\\\\", "\\", "\\n", "\n")
+)
+
+type (
+	// ManifestEntry 清单的一行
+	ManifestEntry struct {
+		Path     string
+		Format   string
+		Length   int64
+		MD5      []byte
+		SliceMD5 []byte
+		CRC32    uint32
+		SHA256   []byte
+	}
+)
+
+// ManifestFlag 清单格式需要计算的摘要值
+func ManifestFlag(format string) (int, error) {
+	switch format {
+	case ManifestMD5:
+		return CHECKSUM_MD5, nil
+	case ManifestSHA256:
+		return CHECKSUM_SHA256, nil
+	case ManifestRapid:
+		return CHECKSUM_MD5 | CHECKSUM_SLICE_MD5 | CHECKSUM_CRC32, nil
+	}
+	return 0, ErrManifestFormat
+}
+
+// ManifestLine 生成清单的一行, 不含换行符.
+// 路径含有 \ 或换行符时, 与 md5sum 相同, 转义路径并在行首加上 \
+func ManifestLine(format string, lfm *LocalFileMeta, localPath string) (string, error) {
+	var digest string
+	switch format {
+	case ManifestMD5:
+		digest = hex.EncodeToString(lfm.MD5)
+	case ManifestSHA256:
+		digest = hex.EncodeToString(lfm.SHA256)
+	case ManifestRapid:
+		digest = hex.EncodeToString(lfm.MD5) + "#" + hex.EncodeToString(lfm.SliceMD5) + "#" + strconv.FormatUint(uint64(lfm.CRC32), 10) + "#" + strconv.FormatInt(lfm.Length, 10)
+	default:
+		return "", ErrManifestFormat
+	}
+
+	if strings.ContainsAny(localPath, "\\\n") {
+		return "\\" + digest + "  " + manifestPathEscaper.Replace(localPath), nil
+	}
+	return digest + "  " + localPath, nil
+}
+
+// ParseManifestLine 解析清单的一行, 自动识别格式
+func ParseManifestLine(line string) (*ManifestEntry, error) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	i := strings.IndexByte(line, ' ')
+	if i <= 0 || i+2 >= len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+		return nil, ErrManifestLine
+	}
+	var (
+		digest = line[:i]
+		me     = &ManifestEntry{
+			Path:   line[i+2:],
+			Length: -1,
+		}
+		err error
+	)
+	if escaped {
+		me.Path = manifestPathUnescaper.Replace(me.Path)
+	}
+
+	if strings.Contains(digest, "#") {
+		fields := strings.Split(digest, "#")
+		if len(fields) != 4 {
+			return nil, ErrManifestLine
+		}
+		me.Format = ManifestRapid
+		me.MD5, err = decodeDigest(fields[0], 32)
+		if err != nil {
+			return nil, err
+		}
+		if fields[1] != "" { // 空文件没有切片的 md5
+			me.SliceMD5, err = decodeDigest(fields[1], 32)
+			if err != nil {
+				return nil, err
+			}
+		}
+		crc32, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, ErrManifestLine
+		}
+		me.CRC32 = uint32(crc32)
+		me.Length, err = strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, ErrManifestLine
+		}
+		return me, nil
+	}
+
+	switch len(digest) {
+	case 32:
+		me.Format = ManifestMD5
+		me.MD5, err = decodeDigest(digest, 32)
+	case 64:
+		me.Format = ManifestSHA256
+		me.SHA256, err = decodeDigest(digest, 64)
+	default:
+		return nil, ErrManifestLine
+	}
+	if err != nil {
+		return nil, err
+	}
+	return me, nil
+}
+
+func decodeDigest(s string, n int) ([]byte, error) {
+	if len(s) != n {
+		return nil, ErrManifestLine
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, ErrManifestLine
+	}
+	return b, nil
+}
+
+// ParseManifest 解析清单, 忽略空行, 返回格式错误的行数
+func ParseManifest(r io.Reader) (entries []*ManifestEntry, improper int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		me, err := ParseManifestLine(line)
+		if err != nil {
+			improper++
+			continue
+		}
+		entries = append(entries, me)
+	}
+	return entries, improper, scanner.Err()
+}
+
+// Flag 校验该行需要计算的摘要值
+func (me *ManifestEntry) Flag() int {
+	flag, _ := ManifestFlag(me.Format)
+	return flag
+}
+
+// Match 本地文件的摘要值是否与清单一致
+func (me *ManifestEntry) Match(lfm *LocalFileMeta) bool {
+	switch me.Format {
+	case ManifestMD5:
+		return bytes.Equal(me.MD5, lfm.MD5)
+	case ManifestSHA256:
+		return bytes.Equal(me.SHA256, lfm.SHA256)
+	case ManifestRapid:
+		return me.Length == lfm.Length && bytes.Equal(me.MD5, lfm.MD5) && bytes.Equal(me.SliceMD5, lfm.SliceMD5) && me.CRC32 == lfm.CRC32
+	}
+	return false
+}
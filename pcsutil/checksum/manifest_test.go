@@ -0,0 +1,46 @@
+package checksum_test
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/checksum"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	lfm := &checksum.LocalFileMeta{
+		Length:   3,
+		MD5:      []byte{0x76, 0x4e, 0xfa, 0x88, 0x3d, 0xda, 0x1e, 0x11, 0xdb, 0x47, 0x67, 0x1c, 0x4a, 0x3b, 0xbd, 0x9e},
+		SliceMD5: []byte{0x76, 0x4e, 0xfa, 0x88, 0x3d, 0xda, 0x1e, 0x11, 0xdb, 0x47, 0x67, 0x1c, 0x4a, 0x3b, 0xbd, 0x9e},
+		CRC32:    3983506042,
+	}
+	for _, format := range []string{checksum.ManifestMD5, checksum.ManifestRapid} {
+		for _, name := range []string{"a b/c.txt", "a\\b\nc"} {
+			line, err := checksum.ManifestLine(format, lfm, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(line, "\n") {
+				t.Fatalf("line contains newline: %q", line)
+			}
+			me, err := checksum.ParseManifestLine(line)
+			if err != nil {
+				t.Fatalf("%q: %s", line, err)
+			}
+			if me.Format != format || me.Path != name || !me.Match(lfm) {
+				t.Fatalf("%q: parsed %+v", line, me)
+			}
+		}
+	}
+
+	entries, improper, err := checksum.ParseManifest(strings.NewReader("764efa883dda1e11db47671c4a3bbd9e *bin\n\nbad line\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  empty\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || improper != 1 {
+		t.Fatalf("entries %d, improper %d, want 2, 1", len(entries), improper)
+	}
+	if entries[0].Path != "bin" || entries[1].Format != checksum.ManifestSHA256 || entries[1].Path != "empty" {
+		t.Fatalf("parsed %+v %+v", entries[0], entries[1])
+	}
+}
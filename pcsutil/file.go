@@ -1,7 +1,7 @@
 package pcsutil
 
 import (
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 	"github.com/kardianos/osext"
 	"os"
 	"path"
@@ -10,6 +10,10 @@ import (
 	"strings"
 )
 
+var (
+	pcsutilVerbose = pcslog.New("PCSUTIL")
+)
+
 func IsPipeInput() bool {
 	fileInfo, err := os.Stdin.Stat()
 	if err != nil {
@@ -46,10 +50,10 @@ func ChWorkDir() {
 func Executable() string {
 	executablePath, err := osext.Executable()
 	if err != nil {
-		pcsverbose.Verbosef("DEBUG: osext.Executable: %s\n", err)
+		pcsutilVerbose.Debug("osext.Executable failed", "err", err)
 		executablePath, err = filepath.Abs(filepath.Dir(os.Args[0]))
 		if err != nil {
-			pcsverbose.Verbosef("DEBUG: filepath.Abs: %s\n", err)
+			pcsutilVerbose.Debug("filepath.Abs failed", "err", err)
 			executablePath = filepath.Dir(os.Args[0])
 		}
 	}
@@ -61,7 +65,7 @@ func Executable() string {
 	// 读取链接
 	linkedExecutablePath, err := filepath.EvalSymlinks(executablePath)
 	if err != nil {
-		pcsverbose.Verbosef("DEBUG: filepath.EvalSymlinks: %s\n", err)
+		pcsutilVerbose.Debug("filepath.EvalSymlinks failed", "err", err)
 		return executablePath
 	}
 	return linkedExecutablePath
@@ -0,0 +1,352 @@
+// Package metrics 监控指标包, 以 Prometheus 文本格式输出
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+type (
+	// Collector 指标收集接口
+	Collector interface {
+		describe() (name, help, typ string)
+		collect(fn func(suffix string, labels []labelPair, value float64))
+	}
+
+	labelPair struct {
+		name  string
+		value string
+	}
+
+	// value 支持原子操作的 float64
+	value struct {
+		bits uint64
+	}
+
+	// Counter 计数器, 只增不减
+	Counter struct {
+		v value
+	}
+
+	// Gauge 可增可减的数值
+	Gauge struct {
+		v value
+	}
+
+	// GaugeFunc 在输出时调用函数获取数值
+	GaugeFunc struct {
+		name, help string
+		fn         func() float64
+	}
+
+	// Histogram 直方图, 统计分布
+	Histogram struct {
+		buckets []float64
+		counts  []uint64
+		sum     value
+		count   uint64
+	}
+
+	vec struct {
+		name, help, typ string
+		labelNames      []string
+		newMetric       func() interface{}
+		metrics         map[string]interface{}
+		labelValues     map[string][]string
+		mu              sync.RWMutex
+	}
+
+	// CounterVec 带标签的计数器
+	CounterVec struct {
+		*vec
+	}
+
+	// GaugeVec 带标签的数值
+	GaugeVec struct {
+		*vec
+	}
+
+	// HistogramVec 带标签的直方图
+	HistogramVec struct {
+		*vec
+	}
+)
+
+var (
+	// DefBuckets 默认的直方图分段, 单位秒
+	DefBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+)
+
+func (v *value) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		if atomic.CompareAndSwapUint64(&v.bits, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) Set(f float64) {
+	atomic.StoreUint64(&v.bits, math.Float64bits(f))
+}
+
+func (v *value) Get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+// Inc 加1
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add 增加, delta 小于0时忽略
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.v.Add(delta)
+}
+
+// Value 返回当前值
+func (c *Counter) Value() float64 {
+	return c.v.Get()
+}
+
+// Set 设置数值
+func (g *Gauge) Set(f float64) {
+	g.v.Set(f)
+}
+
+// Add 增加数值
+func (g *Gauge) Add(delta float64) {
+	g.v.Add(delta)
+}
+
+// Value 返回当前值
+func (g *Gauge) Value() float64 {
+	return g.v.Get()
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe 记录一个值
+func (h *Histogram) Observe(f float64) {
+	i := sort.SearchFloat64s(h.buckets, f)
+	if i < len(h.counts) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	h.sum.Add(f)
+	atomic.AddUint64(&h.count, 1)
+}
+
+func newVec(name, help, typ string, labelNames []string, newMetric func() interface{}) *vec {
+	return &vec{
+		name:        name,
+		help:        help,
+		typ:         typ,
+		labelNames:  labelNames,
+		newMetric:   newMetric,
+		metrics:     map[string]interface{}{},
+		labelValues: map[string][]string{},
+	}
+}
+
+func (v *vec) with(labelValues ...string) interface{} {
+	if len(labelValues) != len(v.labelNames) {
+		panic("metrics: " + v.name + ": inconsistent label cardinality")
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	v.mu.RLock()
+	m, ok := v.metrics[key]
+	v.mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if m, ok = v.metrics[key]; ok {
+		return m
+	}
+	m = v.newMetric()
+	v.metrics[key] = m
+	v.labelValues[key] = append([]string(nil), labelValues...)
+	return m
+}
+
+// Delete 删除指定标签的指标
+func (v *vec) Delete(labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	delete(v.metrics, key)
+	delete(v.labelValues, key)
+	v.mu.Unlock()
+}
+
+// DeletePartialMatch 删除标签 labelName 的值为 labelValue 的所有指标
+func (v *vec) DeletePartialMatch(labelName, labelValue string) {
+	index := -1
+	for k, name := range v.labelNames {
+		if name == labelName {
+			index = k
+		}
+	}
+	if index < 0 {
+		return
+	}
+
+	v.mu.Lock()
+	for key, values := range v.labelValues {
+		if values[index] == labelValue {
+			delete(v.metrics, key)
+			delete(v.labelValues, key)
+		}
+	}
+	v.mu.Unlock()
+}
+
+func (v *vec) describe() (name, help, typ string) {
+	return v.name, v.help, v.typ
+}
+
+func (v *vec) collect(fn func(suffix string, labels []labelPair, value float64)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.metrics))
+	for key := range v.metrics {
+		keys = append(keys, key)
+	}
+	v.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.mu.RLock()
+		m, ok := v.metrics[key]
+		values := v.labelValues[key]
+		v.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		labels := make([]labelPair, 0, len(values)+1)
+		for k, name := range v.labelNames {
+			labels = append(labels, labelPair{name: name, value: values[k]})
+		}
+		collectMetric(m, labels, fn)
+	}
+}
+
+func collectMetric(m interface{}, labels []labelPair, fn func(suffix string, labels []labelPair, value float64)) {
+	switch metric := m.(type) {
+	case *Counter:
+		fn("", labels, metric.Value())
+	case *Gauge:
+		fn("", labels, metric.Value())
+	case *Histogram:
+		var cumulative uint64
+		for k, upper := range metric.buckets {
+			cumulative += atomic.LoadUint64(&metric.counts[k])
+			fn("_bucket", append(labels, labelPair{name: "le", value: formatFloat(upper)}), float64(cumulative))
+		}
+		count := atomic.LoadUint64(&metric.count)
+		fn("_bucket", append(labels, labelPair{name: "le", value: "+Inf"}), float64(count))
+		fn("_sum", labels, metric.sum.Get())
+		fn("_count", labels, float64(count))
+	}
+}
+
+// WithLabelValues 返回指定标签的计数器
+func (cv *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return cv.with(labelValues...).(*Counter)
+}
+
+// WithLabelValues 返回指定标签的数值
+func (gv *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return gv.with(labelValues...).(*Gauge)
+}
+
+// WithLabelValues 返回指定标签的直方图
+func (hv *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	return hv.with(labelValues...).(*Histogram)
+}
+
+func (gf *GaugeFunc) describe() (name, help, typ string) {
+	return gf.name, gf.help, typeGauge
+}
+
+func (gf *GaugeFunc) collect(fn func(suffix string, labels []labelPair, value float64)) {
+	fn("", nil, gf.fn())
+}
+
+// single 不带标签的指标
+type single struct {
+	name, help, typ string
+	metric          interface{}
+}
+
+func (s *single) describe() (name, help, typ string) {
+	return s.name, s.help, s.typ
+}
+
+func (s *single) collect(fn func(suffix string, labels []labelPair, value float64)) {
+	collectMetric(s.metric, nil, fn)
+}
+
+// NewCounter 初始化计数器, 并注册到 DefaultRegistry
+func NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	DefaultRegistry.MustRegister(&single{name: name, help: help, typ: typeCounter, metric: c})
+	return c
+}
+
+// NewGauge 初始化数值, 并注册到 DefaultRegistry
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	DefaultRegistry.MustRegister(&single{name: name, help: help, typ: typeGauge, metric: g})
+	return g
+}
+
+// NewGaugeFunc 初始化GaugeFunc, 并注册到 DefaultRegistry
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	gf := &GaugeFunc{name: name, help: help, fn: fn}
+	DefaultRegistry.MustRegister(gf)
+	return gf
+}
+
+// NewCounterVec 初始化带标签的计数器, 并注册到 DefaultRegistry
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	cv := &CounterVec{newVec(name, help, typeCounter, labelNames, func() interface{} { return &Counter{} })}
+	DefaultRegistry.MustRegister(cv)
+	return cv
+}
+
+// NewGaugeVec 初始化带标签的数值, 并注册到 DefaultRegistry
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	gv := &GaugeVec{newVec(name, help, typeGauge, labelNames, func() interface{} { return &Gauge{} })}
+	DefaultRegistry.MustRegister(gv)
+	return gv
+}
+
+// NewHistogramVec 初始化带标签的直方图, 并注册到 DefaultRegistry, buckets 为空则使用 DefBuckets
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	hv := &HistogramVec{newVec(name, help, typeHistogram, labelNames, func() interface{} { return newHistogram(buckets) })}
+	DefaultRegistry.MustRegister(hv)
+	return hv
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := &Counter{}
+	r.MustRegister(&single{name: "test_bytes_total", help: "test bytes", typ: typeCounter, metric: c})
+	c.Add(1024)
+	c.Inc()
+
+	cv := &CounterVec{newVec("test_errors_total", "test errors", typeCounter, []string{"op", "code"}, func() interface{} { return &Counter{} })}
+	r.MustRegister(cv)
+	cv.WithLabelValues("获取文件列表", "31066").Inc()
+
+	hv := &HistogramVec{newVec("test_duration_seconds", "test duration", typeHistogram, []string{"op"}, func() interface{} { return newHistogram([]float64{0.1, 1}) })}
+	r.MustRegister(hv)
+	hv.WithLabelValues("a").Observe(0.05)
+	hv.WithLabelValues("a").Observe(0.5)
+	hv.WithLabelValues("a").Observe(5)
+
+	if err := r.Register(cv); err == nil {
+		t.Fatalf("duplicate register should fail")
+	}
+
+	buf := &bytes.Buffer{}
+	r.WriteText(buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE test_bytes_total counter\ntest_bytes_total 1025\n",
+		`test_errors_total{op="获取文件列表",code="31066"} 1`,
+		`test_duration_seconds_bucket{op="a",le="0.1"} 1`,
+		`test_duration_seconds_bucket{op="a",le="1"} 2`,
+		`test_duration_seconds_bucket{op="a",le="+Inf"} 3`,
+		`test_duration_seconds_count{op="a"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	cv.Delete("获取文件列表", "31066")
+	buf.Reset()
+	r.WriteText(buf)
+	if strings.Contains(buf.String(), "31066") {
+		t.Errorf("deleted metric still present")
+	}
+}
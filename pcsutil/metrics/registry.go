@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// ContentType Prometheus 文本格式的 Content-Type
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type (
+	// Registry 指标注册表
+	Registry struct {
+		collectors map[string]Collector
+		mu         sync.RWMutex
+	}
+)
+
+var (
+	// ErrAlreadyRegistered 指标名称已注册
+	ErrAlreadyRegistered = errors.New("metric already registered")
+
+	// DefaultRegistry 默认的注册表
+	DefaultRegistry = NewRegistry()
+)
+
+// NewRegistry 初始化注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: map[string]Collector{},
+	}
+}
+
+// Register 注册指标
+func (r *Registry) Register(c Collector) error {
+	name, _, _ := c.describe()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[name]; ok {
+		return fmt.Errorf("%s: %s", ErrAlreadyRegistered, name)
+	}
+	r.collectors[name] = c
+	return nil
+}
+
+// MustRegister 注册指标, 出错则 panic
+func (r *Registry) MustRegister(c Collector) {
+	err := r.Register(c)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// WriteText 以 Prometheus 文本格式输出所有指标
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		r.mu.RLock()
+		c := r.collectors[name]
+		r.mu.RUnlock()
+
+		_, help, typ := c.describe()
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+		c.collect(func(suffix string, labels []labelPair, value float64) {
+			bw.WriteString(name)
+			bw.WriteString(suffix)
+			if len(labels) > 0 {
+				bw.WriteByte('{')
+				for k, label := range labels {
+					if k > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(label.name)
+					bw.WriteString(`="`)
+					bw.WriteString(escapeLabelValue(label.value))
+					bw.WriteByte('"')
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatFloat(value))
+			bw.WriteByte('\n')
+		})
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP 实现 http.Handler
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteText(w)
+}
+
+// Handler 返回输出 DefaultRegistry 的 http.Handler
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -1,4 +1,4 @@
-//+build !windows,!plan9
+//+build !windows,!plan9,!linux
 
 // Package prealloc 初始化分配文件包
 package prealloc
@@ -0,0 +1,31 @@
+// Package prealloc 初始化分配文件包
+package prealloc
+
+import (
+	"syscall"
+)
+
+// PreAlloc 预分配文件空间, 使用 fallocate 分配磁盘空间, 已写入的数据不受影响,
+// 文件系统不支持 fallocate 时, 只设置文件大小 (稀疏文件)
+func PreAlloc(fd uintptr, length int64) error {
+	err := syscall.Fallocate(int(fd), 0, 0, length)
+	switch err {
+	case nil, syscall.EOPNOTSUPP, syscall.ENOSYS:
+		// 不支持 fallocate 时忽略
+	default:
+		return &PreAllocError{
+			ProcName: "Fallocate",
+			Err:      err,
+		}
+	}
+
+	// fallocate 不会缩小文件, 原有的文件更大时需要截断
+	err = syscall.Ftruncate(int(fd), length)
+	if err != nil {
+		return &PreAllocError{
+			ProcName: "Ftruncate",
+			Err:      err,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Package retry 失败重试策略, 按错误的类别判断是否重试, 重试的等待时间按指数增长, 并加入随机抖动
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+type (
+	// Class 错误的类别
+	Class int
+
+	// ClassifyFunc 判断错误的类别
+	ClassifyFunc func(err error) Class
+
+	// Policy 重试策略
+	Policy struct {
+		MaxRetry  int           // 最大重试次数
+		BaseDelay time.Duration // 第一次重试的等待时间, 之后每次翻倍
+		MaxDelay  time.Duration // 最长的等待时间, 为 0 则不限制
+		Jitter    float64       // 随机抖动的比例, 0~1, 等待时间在 [(1-Jitter)*d, d] 之间随机
+		Classify  ClassifyFunc  // 判断错误的类别, 为空则所有错误都可以重试
+
+		// ThrottledFactor 被限流时, 等待时间的倍数, 不大于 1 时为 4
+		ThrottledFactor int
+	}
+)
+
+const (
+	// ClassRetryable 临时错误, 可以重试, 如网络错误, 服务器内部错误
+	ClassRetryable Class = iota
+	// ClassPermanent 永久错误, 重试也不会成功, 如文件不存在, 参数错误, 已取消
+	ClassPermanent
+	// ClassAuth 登录状态错误, 需要重新登录, 不重试
+	ClassAuth
+	// ClassQuota 网盘容量或次数超出限制, 不重试
+	ClassQuota
+	// ClassThrottled 请求过于频繁被限流, 等待更长的时间后重试
+	ClassThrottled
+)
+
+var (
+	// DefaultPolicy 默认的重试策略
+	DefaultPolicy = &Policy{
+		MaxRetry:  3,
+		BaseDelay: 1 * time.Second,
+		MaxDelay:  30 * time.Second,
+		Jitter:    0.2,
+	}
+
+	classNames = map[Class]string{
+		ClassRetryable: "retryable",
+		ClassPermanent: "permanent",
+		ClassAuth:      "auth",
+		ClassQuota:     "quota",
+		ClassThrottled: "throttled",
+	}
+)
+
+func (c Class) String() string {
+	name, ok := classNames[c]
+	if !ok {
+		return "unknown"
+	}
+	return name
+}
+
+// Retryable 该类别的错误是否可以重试
+func (c Class) Retryable() bool {
+	return c == ClassRetryable || c == ClassThrottled
+}
+
+// ClassOf 按策略判断错误的类别
+func (p *Policy) ClassOf(err error) Class {
+	if p.Classify == nil {
+		return ClassRetryable
+	}
+	return p.Classify(err)
+}
+
+// Backoff 第 attempt 次重试的等待时间, attempt 从 1 开始
+func (p *Policy) Backoff(attempt int, class Class) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		if p.MaxDelay > 0 && d >= p.MaxDelay || d*2 <= d { // 达到最长的等待时间, 或溢出
+			break
+		}
+		d *= 2
+	}
+	if class == ClassThrottled {
+		factor := p.ThrottledFactor
+		if factor <= 1 {
+			factor = 4
+		}
+		if throttled := d * time.Duration(factor); throttled > d { // 未溢出
+			d = throttled
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 && d > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		n := int64(float64(d) * jitter)
+		if n > 0 {
+			d -= time.Duration(rand.Int63n(n + 1))
+		}
+	}
+	return d
+}
+
+// Next 第 attempt 次失败之后, 是否重试, 以及重试前的等待时间, attempt 从 1 开始
+func (p *Policy) Next(err error, attempt int) (wait time.Duration, ok bool) {
+	if attempt > p.MaxRetry {
+		return 0, false
+	}
+	class := p.ClassOf(err)
+	if !class.Retryable() {
+		return 0, false
+	}
+	return p.Backoff(attempt, class), true
+}
+
+// Do 执行 fn, 失败时按策略重试, 返回最后一次的错误, ctx 取消时停止重试
+func (p *Policy) Do(ctx context.Context, fn func() error) (err error) {
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		wait, ok := p.Next(err, attempt)
+		if !ok || Sleep(ctx, wait) != nil {
+			return err
+		}
+	}
+}
+
+// Sleep 等待 d, ctx 取消时提前返回 ctx.Err()
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
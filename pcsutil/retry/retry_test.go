@@ -0,0 +1,101 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
+	"testing"
+	"time"
+)
+
+var (
+	errTemp = errors.New("temporary")
+	errPerm = errors.New("permanent")
+)
+
+func classify(err error) retry.Class {
+	if err == errPerm {
+		return retry.ClassPermanent
+	}
+	return retry.ClassRetryable
+}
+
+func TestBackoff(t *testing.T) {
+	p := &retry.Policy{
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  time.Second,
+	}
+	for attempt, want := range []time.Duration{100, 100, 200, 400, 800, 1000, 1000} {
+		if d := p.Backoff(attempt, retry.ClassRetryable); d != want*time.Millisecond {
+			t.Fatalf("attempt %d: %s", attempt, d)
+		}
+	}
+	if d := p.Backoff(1, retry.ClassThrottled); d != 400*time.Millisecond {
+		t.Fatalf("throttled: %s", d)
+	}
+	if d := p.Backoff(1000, retry.ClassRetryable); d != time.Second {
+		t.Fatalf("overflow: %s", d)
+	}
+
+	// 随机抖动
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(3, retry.ClassRetryable)
+		if d < 200*time.Millisecond || d > 400*time.Millisecond {
+			t.Fatalf("jitter: %s", d)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := &retry.Policy{
+		MaxRetry:  3,
+		BaseDelay: time.Millisecond,
+		Classify:  classify,
+	}
+
+	var calls int
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return errTemp
+	})
+	if err != errTemp || calls != 4 {
+		t.Fatalf("err: %v, calls: %d", err, calls)
+	}
+
+	// 永久错误不重试
+	calls = 0
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return errPerm
+	})
+	if err != errPerm || calls != 1 {
+		t.Fatalf("err: %v, calls: %d", err, calls)
+	}
+
+	// 成功
+	calls = 0
+	err = p.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errTemp
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("err: %v, calls: %d", err, calls)
+	}
+
+	// 已取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	p.BaseDelay = time.Hour
+	err = p.Do(ctx, func() error {
+		calls++
+		return errTemp
+	})
+	if err != errTemp || calls != 1 {
+		t.Fatalf("err: %v, calls: %d", err, calls)
+	}
+}
@@ -1,7 +1,9 @@
 package taskframework
 
 import (
+	"context"
 	"github.com/GeertJohan/go.incremental"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/waitgroup"
 	"github.com/oleiade/lane"
 	"strconv"
@@ -13,10 +15,13 @@ type (
 		incr     *incremental.Int // 任务id生成
 		deque    *lane.Deque      // 队列
 		parallel int              // 任务的最大并发量
+		slots    chan struct{}    // 与其他执行器共用的并发量, 为空则不共用
 
 		// 是否统计失败队列
 		IsFailedDeque bool
 		failedDeque   *lane.Deque
+
+		retryPolicy *retry.Policy // 重试策略, 为空则只由任务单元判断是否重试
 	}
 )
 
@@ -44,6 +49,28 @@ func (te *TaskExecutor) SetParallel(parallel int) {
 	te.parallel = parallel
 }
 
+// SetRetryPolicy 设置重试策略, 任务需要重试时, 按策略判断错误的类别,
+// 不可重试的错误直接失败, 被限流时按策略延长等待时间
+func (te *TaskExecutor) SetRetryPolicy(p *retry.Policy) {
+	te.retryPolicy = p
+}
+
+// ShareIdWith 与 other 共用任务id的生成, 多个执行器的任务id不重复
+func (te *TaskExecutor) ShareIdWith(other *TaskExecutor) {
+	other.lazyInit()
+	te.incr = other.incr
+}
+
+// ShareParallelWith 与 other 共用 other 的最大并发量, 多个执行器同时执行的任务数之和不超过该值,
+// 需在 other 设置最大并发量之后调用
+func (te *TaskExecutor) ShareParallelWith(other *TaskExecutor) {
+	other.lazyInit()
+	if other.slots == nil {
+		other.slots = make(chan struct{}, other.parallel)
+	}
+	te.slots = other.slots
+}
+
 //Append 将任务加到任务队列末尾
 func (te *TaskExecutor) Append(unit TaskUnit, maxRetry int) *TaskInfo {
 	te.lazyInit()
@@ -74,6 +101,11 @@ func (te *TaskExecutor) Count() int {
 
 //Execute 执行任务
 func (te *TaskExecutor) Execute() {
+	te.ExecuteContext(context.Background())
+}
+
+// ExecuteContext 执行任务, ctx 取消后, 不再执行新的任务和重试, 未执行的任务留在队列中
+func (te *TaskExecutor) ExecuteContext(ctx context.Context) {
 	te.lazyInit()
 
 	for {
@@ -87,9 +119,16 @@ func (te *TaskExecutor) Execute() {
 			// 获取任务
 			task := e.(*TaskInfoItem)
 			wg.AddDelta()
+			if !te.acquire(ctx) { // 已取消, 放回队列
+				wg.Done()
+				te.deque.Prepend(task)
+				break
+			}
+			task.Info.ctx = ctx
 
 			go func(task *TaskInfoItem) {
 				defer wg.Done()
+				defer te.release()
 
 				result := task.Unit.Run()
 
@@ -100,16 +139,18 @@ func (te *TaskExecutor) Execute() {
 				}
 
 				if result.Succeed {
+					tasksTotal.WithLabelValues("success").Inc()
 					task.Unit.OnSuccess(result)
 					task.Unit.OnComplete(result)
 					return
 				}
 
-				// 需要进行重试
-				if result.NeedRetry {
+				// 需要进行重试, 已取消或错误不可重试时不再重试
+				if result.NeedRetry && ctx.Err() == nil && te.retryable(result) {
 					// 重试次数超出限制
 					// 执行失败
 					if task.Info.IsExceedRetry() {
+						tasksTotal.WithLabelValues("failed").Inc()
 						task.Unit.OnFailed(result)
 						if te.IsFailedDeque {
 							// 加入失败队列
@@ -120,15 +161,18 @@ func (te *TaskExecutor) Execute() {
 					}
 
 					task.Info.retry++         // 增加重试次数
+					taskRetriesTotal.Inc()
 					task.Unit.OnRetry(result) // 调用重试
 					task.Unit.OnComplete(result)
 
-					time.Sleep(task.Unit.RetryWait()) // 等待
-					te.deque.Append(task)             // 重新加入队列末尾
+					// 等待
+					retry.Sleep(ctx, te.retryWait(task, result))
+					te.deque.Append(task) // 重新加入队列末尾
 					return
 				}
 
 				// 执行失败
+				tasksTotal.WithLabelValues("failed").Inc()
 				task.Unit.OnFailed(result)
 				if te.IsFailedDeque {
 					// 加入失败队列
@@ -140,13 +184,57 @@ func (te *TaskExecutor) Execute() {
 
 		wg.Wait()
 
-		// 没有任务了
-		if te.deque.Size() == 0 {
+		// 没有任务了, 或已取消
+		if te.deque.Size() == 0 || ctx.Err() != nil {
 			break
 		}
 	}
 }
 
+// acquire 获取共用的并发量, ctx 取消后返回 false
+func (te *TaskExecutor) acquire(ctx context.Context) bool {
+	if te.slots == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case te.slots <- struct{}{}:
+		if ctx.Err() != nil {
+			<-te.slots
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release 释放共用的并发量
+func (te *TaskExecutor) release() {
+	if te.slots != nil {
+		<-te.slots
+	}
+}
+
+// retryable 按重试策略判断执行结果的错误是否可以重试
+func (te *TaskExecutor) retryable(result *TaskUnitRunResult) bool {
+	if te.retryPolicy == nil || result.Err == nil {
+		return true
+	}
+	return te.retryPolicy.ClassOf(result.Err).Retryable()
+}
+
+// retryWait 重试的等待时间, 取任务单元和重试策略中较长的
+func (te *TaskExecutor) retryWait(task *TaskInfoItem, result *TaskUnitRunResult) time.Duration {
+	wait := task.Unit.RetryWait()
+	if te.retryPolicy == nil || result.Err == nil {
+		return wait
+	}
+	if d := te.retryPolicy.Backoff(task.Info.retry, te.retryPolicy.ClassOf(result.Err)); d > wait {
+		return d
+	}
+	return wait
+}
+
 //FailedDeque 获取失败队列
 func (te *TaskExecutor) FailedDeque() *lane.Deque {
 	return te.failedDeque
@@ -0,0 +1,10 @@
+package taskframework
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/metrics"
+)
+
+var (
+	taskRetriesTotal = metrics.NewCounter("baidupcs_task_retries_total", "任务的重试次数")
+	tasksTotal       = metrics.NewCounterVec("baidupcs_tasks_total", "执行结束的任务数", "result")
+)
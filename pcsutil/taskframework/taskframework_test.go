@@ -1,8 +1,13 @@
 package taskframework_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/taskframework"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -57,3 +62,157 @@ func TestTaskExecutor(t *testing.T) {
 	}
 	te.Execute()
 }
+
+func TestTaskExecutorShareId(t *testing.T) {
+	te1, te2 := taskframework.NewTaskExecutor(), taskframework.NewTaskExecutor()
+	te2.ShareIdWith(te1)
+	ids := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		for _, te := range []*taskframework.TaskExecutor{te1, te2} {
+			id := te.Append(&TestUnit{}, 0).Id()
+			if ids[id] {
+				t.Fatalf("duplicate task id: %s", id)
+			}
+			ids[id] = true
+		}
+	}
+}
+
+type (
+	ConcurrentUnit struct {
+		TestUnit
+		running, max *int32
+	}
+)
+
+func (cu *ConcurrentUnit) Run() (result *taskframework.TaskUnitRunResult) {
+	n := atomic.AddInt32(cu.running, 1)
+	for {
+		m := atomic.LoadInt32(cu.max)
+		if n <= m || atomic.CompareAndSwapInt32(cu.max, m, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	atomic.AddInt32(cu.running, -1)
+	return &taskframework.TaskUnitRunResult{
+		Succeed: true,
+	}
+}
+
+func TestTaskExecutorShareParallel(t *testing.T) {
+	var (
+		running, max int32
+		te1, te2     = taskframework.NewTaskExecutor(), taskframework.NewTaskExecutor()
+		wg           sync.WaitGroup
+	)
+	te1.SetParallel(3)
+	te2.SetParallel(3)
+	te2.ShareParallelWith(te1)
+	for i := 0; i < 10; i++ {
+		te1.Append(&ConcurrentUnit{running: &running, max: &max}, 0)
+		te2.Append(&ConcurrentUnit{running: &running, max: &max}, 0)
+	}
+	for _, te := range []*taskframework.TaskExecutor{te1, te2} {
+		wg.Add(1)
+		go func(te *taskframework.TaskExecutor) {
+			defer wg.Done()
+			te.Execute()
+		}(te)
+	}
+	wg.Wait()
+
+	// 两个执行器同时执行的任务数之和不超过共用的并发量
+	if max > 3 {
+		t.Fatalf("max running: %d", max)
+	}
+	if te1.Count() != 0 || te2.Count() != 0 {
+		t.Fatalf("left: %d, %d", te1.Count(), te2.Count())
+	}
+}
+
+type (
+	CancelUnit struct {
+		TestUnit
+		cancel context.CancelFunc
+		runs   *int32
+	}
+)
+
+func (cu *CancelUnit) Run() (result *taskframework.TaskUnitRunResult) {
+	atomic.AddInt32(cu.runs, 1)
+	cu.cancel()
+	return &taskframework.TaskUnitRunResult{
+		NeedRetry: true,
+		Err:       cu.taskInfo.Context().Err(),
+	}
+}
+
+func TestTaskExecutorContext(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		runs        int32
+		te          = taskframework.NewTaskExecutor()
+	)
+	defer cancel()
+	te.IsFailedDeque = true
+	for i := 0; i < 3; i++ {
+		te.Append(&CancelUnit{cancel: cancel, runs: &runs}, 5)
+	}
+	te.ExecuteContext(ctx)
+
+	// 取消后不重试, 也不执行新的任务
+	if runs != 1 {
+		t.Fatalf("runs: %d", runs)
+	}
+	if te.Count() != 2 || te.FailedDeque().Size() != 1 {
+		t.Fatalf("left: %d, failed: %d", te.Count(), te.FailedDeque().Size())
+	}
+}
+
+type (
+	ErrUnit struct {
+		TestUnit
+		err  error
+		runs int
+	}
+)
+
+func (eu *ErrUnit) Run() (result *taskframework.TaskUnitRunResult) {
+	eu.runs++
+	return &taskframework.TaskUnitRunResult{
+		NeedRetry: true,
+		Err:       eu.err,
+	}
+}
+
+func (eu *ErrUnit) RetryWait() time.Duration {
+	return 0
+}
+
+func TestTaskExecutorRetryPolicy(t *testing.T) {
+	var (
+		errPerm = errors.New("permanent")
+		errTemp = errors.New("temporary")
+		te      = taskframework.NewTaskExecutor()
+		perm    = &ErrUnit{err: errPerm}
+		temp    = &ErrUnit{err: errTemp}
+	)
+	te.SetRetryPolicy(&retry.Policy{
+		BaseDelay: time.Millisecond,
+		Classify: func(err error) retry.Class {
+			if err == errPerm {
+				return retry.ClassPermanent
+			}
+			return retry.ClassRetryable
+		},
+	})
+	te.Append(perm, 2)
+	te.Append(temp, 2)
+	te.Execute()
+
+	// 不可重试的错误直接失败
+	if perm.runs != 1 || temp.runs != 3 {
+		t.Fatalf("perm runs: %d, temp runs: %d", perm.runs, temp.runs)
+	}
+}
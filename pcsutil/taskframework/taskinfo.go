@@ -1,10 +1,15 @@
 package taskframework
 
+import (
+	"context"
+)
+
 type (
 	TaskInfo struct {
 		id       string
 		maxRetry int
 		retry    int
+		ctx      context.Context
 	}
 
 	TaskInfoItem struct {
@@ -33,3 +38,11 @@ func (t *TaskInfo) SetMaxRetry(maxRetry int) {
 func (t *TaskInfo) Retry() int {
 	return t.retry
 }
+
+// Context 返回执行任务的 context, 任务单元可以据此停止执行
+func (t *TaskInfo) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
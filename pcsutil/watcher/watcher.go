@@ -0,0 +1,118 @@
+// Package watcher 监听本地目录中文件的变化,
+// linux 使用 inotify, 其他系统定时通知重新扫描目录
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPollInterval 不支持 inotify 时, 默认的重新扫描目录的间隔
+	DefaultPollInterval = 10 * time.Second
+)
+
+var (
+	// ErrNotDir 监听的路径不是目录
+	ErrNotDir = errors.New("watch path is not a directory")
+)
+
+type (
+	// Event 文件变化事件
+	Event struct {
+		Path   string // 可能发生变化的文件
+		Rescan bool   // 可能遗漏了事件, 需要重新扫描整个目录
+	}
+
+	// Watcher 递归监听目录
+	Watcher struct {
+		Events chan Event
+		Errors chan error
+
+		root         string
+		pollInterval time.Duration
+		done         chan struct{}
+		closeOnce    sync.Once
+		closeFunc    func() error
+	}
+)
+
+// New 递归监听目录 root, pollInterval 为不支持 inotify 时, 重新扫描目录的间隔
+func New(root string, pollInterval time.Duration) (*Watcher, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, ErrNotDir
+	}
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	w := &Watcher{
+		Events:       make(chan Event, 1024),
+		Errors:       make(chan error, 16),
+		root:         filepath.Clean(root),
+		pollInterval: pollInterval,
+		done:         make(chan struct{}),
+	}
+	err = w.start()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Root 监听的目录
+func (w *Watcher) Root() string {
+	return w.root
+}
+
+func (w *Watcher) sendEvent(ev Event) bool {
+	select {
+	case w.Events <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *Watcher) sendError(err error) {
+	select {
+	case w.Errors <- err:
+	case <-w.done:
+	default:
+		// 错误未被处理, 丢弃
+	}
+}
+
+// poll 定时通知重新扫描目录
+func (w *Watcher) poll() {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !w.sendEvent(Event{Rescan: true}) {
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Close 停止监听
+func (w *Watcher) Close() (err error) {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		if w.closeFunc != nil {
+			err = w.closeFunc()
+		}
+	})
+	return
+}
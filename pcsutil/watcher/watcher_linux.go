@@ -0,0 +1,135 @@
+package watcher
+
+import (
+	"bytes"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+)
+
+const (
+	watchMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_ATTRIB
+)
+
+type (
+	inotify struct {
+		w    *Watcher
+		file *os.File
+		fd   int
+		dirs map[int]string // watch descriptor => 目录
+		mu   sync.Mutex
+	}
+)
+
+func (w *Watcher) start() error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		// 不支持 inotify, 定时重新扫描
+		go w.poll()
+		return nil
+	}
+
+	in := &inotify{
+		w:    w,
+		file: os.NewFile(uintptr(fd), "inotify"),
+		fd:   fd,
+		dirs: map[int]string{},
+	}
+	err = in.addRecursive(w.root, false)
+	if err != nil {
+		in.file.Close()
+		return err
+	}
+
+	w.closeFunc = in.file.Close
+	go in.readEvents()
+	return nil
+}
+
+// addRecursive 监听目录及其子目录, notify 为 true 时, 通知目录中已存在的文件,
+// 用于新建的目录, 添加监听之前写入的文件
+func (in *inotify) addRecursive(dir string, notify bool) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			in.w.sendError(err)
+			return nil
+		}
+		if !info.IsDir() {
+			if notify {
+				in.w.sendEvent(Event{Path: p})
+			}
+			return nil
+		}
+
+		wd, err := unix.InotifyAddWatch(in.fd, p, watchMask)
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			in.w.sendError(err)
+			return filepath.SkipDir
+		}
+		in.mu.Lock()
+		in.dirs[wd] = p
+		in.mu.Unlock()
+		return nil
+	})
+}
+
+func (in *inotify) readEvents() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			select {
+			case <-in.w.done:
+			default:
+				in.w.sendError(err)
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(raw.Len)]
+			offset += unix.SizeofInotifyEvent + int(raw.Len)
+
+			if !in.handleEvent(int(raw.Wd), raw.Mask, string(bytes.TrimRight(nameBytes, "\x00"))) {
+				return
+			}
+		}
+	}
+}
+
+func (in *inotify) handleEvent(wd int, mask uint32, name string) bool {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		return in.w.sendEvent(Event{Rescan: true})
+	}
+
+	in.mu.Lock()
+	dir, ok := in.dirs[wd]
+	if mask&unix.IN_IGNORED != 0 {
+		delete(in.dirs, wd)
+	}
+	in.mu.Unlock()
+	if !ok || name == "" {
+		return true
+	}
+
+	p := filepath.Join(dir, name)
+	if mask&unix.IN_ISDIR != 0 {
+		if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			err := in.addRecursive(p, true)
+			if err != nil {
+				in.w.sendError(err)
+			}
+		}
+		return true
+	}
+	return in.w.sendEvent(Event{Path: p})
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package watcher
+
+func (w *Watcher) start() error {
+	go w.poll()
+	return nil
+}
@@ -0,0 +1,51 @@
+package watcher_test
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/watcher"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	root, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	w, err := watcher.New(root, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 新建的子目录中的文件
+	sub := filepath.Join(root, "a", "b")
+	err = os.MkdirAll(sub, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(sub, "1.txt")
+	err = ioutil.WriteFile(target, []byte("123"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-w.Events:
+			// 不支持 inotify 时, 只通知重新扫描
+			if ev.Path == target || ev.Rescan {
+				return
+			}
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatal("timeout")
+		}
+	}
+}
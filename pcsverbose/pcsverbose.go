@@ -1,9 +1,10 @@
-// Package pcsverbose 调试包
+// Package pcsverbose 调试包, 日志记录已由 pcslog 代替, 此包保留调试开关及兼容接口.
+// 新的代码请使用 pcslog.New 创建模块日志, 不要再调用 Verbosef, Verboseln
 package pcsverbose
 
 import (
 	"fmt"
-	"io"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 	"os"
 )
 
@@ -13,74 +14,40 @@ const (
 )
 
 var (
-	// IsVerbose 是否调试
+	// IsVerbose 是否调试, 启用后所有日志输出到终端
 	IsVerbose = os.Getenv(EnvVerbose) == "1"
 
-	// Outputs 输出
-	Outputs = []io.Writer{os.Stderr}
+	verboseLogger = pcslog.New("")
 )
 
-// PCSVerbose 调试
-type PCSVerbose struct {
-	Module string
-}
+// PCSVerbose 调试, 等同于 pcslog.Logger
+type PCSVerbose = pcslog.Logger
 
-// New 根据module, 初始化PCSVerbose
-func New(module string) *PCSVerbose {
-	return &PCSVerbose{
-		Module: module,
-	}
+func init() {
+	pcslog.SetConsoleEnabledFunc(func() bool {
+		return IsVerbose
+	})
 }
 
-// Info 提示
-func (pv *PCSVerbose) Info(l string) {
-	Verbosef("DEBUG: %s INFO: %s\n", pv.Module, l)
-}
-
-// Infof 提示, 格式输出
-func (pv *PCSVerbose) Infof(format string, a ...interface{}) {
-	Verbosef("DEBUG: %s INFO: %s", pv.Module, fmt.Sprintf(format, a...))
-}
-
-// Warn 警告
-func (pv *PCSVerbose) Warn(l string) {
-	Verbosef("DEBUG: %s WARN: %s\n", pv.Module, l)
-}
-
-// Warnf 警告, 格式输出
-func (pv *PCSVerbose) Warnf(format string, a ...interface{}) {
-	Verbosef("DEBUG: %s WARN: %s", pv.Module, fmt.Sprintf(format, a...))
+// New 根据module, 初始化PCSVerbose, 参见 pcslog.New
+func New(module string) *PCSVerbose {
+	return pcslog.New(module)
 }
 
 // Verbosef 调试格式输出
+//
+// Deprecated: 使用 pcslog.New 创建的模块日志
 func Verbosef(format string, a ...interface{}) (n int, err error) {
-	if IsVerbose {
-		for _, Output := range Outputs {
-			n1, err := fmt.Fprintf(Output, TimePrefix()+" "+format, a...)
-			n += n1
-			if err != nil {
-				return n, err
-			}
-		}
-	}
-	return
+	msg := fmt.Sprintf(format, a...)
+	verboseLogger.Debug(msg)
+	return len(msg), nil
 }
 
 // Verboseln 调试输出一行
+//
+// Deprecated: 使用 pcslog.New 创建的模块日志
 func Verboseln(a ...interface{}) (n int, err error) {
-	if IsVerbose {
-		for _, Output := range Outputs {
-			n1, err := fmt.Fprint(Output, TimePrefix()+" ")
-			n += n1
-			if err != nil {
-				return n, err
-			}
-			n2, err := fmt.Fprintln(Output, a...)
-			n += n2
-			if err != nil {
-				return n, err
-			}
-		}
-	}
-	return
+	msg := fmt.Sprintln(a...)
+	verboseLogger.Debug(msg)
+	return len(msg), nil
 }
@@ -0,0 +1,221 @@
+// Package aria2 aria2 输入文件和 JSON-RPC 客户端
+package aria2
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/felixonmars/BaiduPCS-Go/requester"
+	"github.com/json-iterator/go"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// StatusActive 正在下载
+	StatusActive = "active"
+	// StatusWaiting 等待中
+	StatusWaiting = "waiting"
+	// StatusPaused 已暂停
+	StatusPaused = "paused"
+	// StatusError 下载出错
+	StatusError = "error"
+	// StatusComplete 下载完成
+	StatusComplete = "complete"
+	// StatusRemoved 已移除
+	StatusRemoved = "removed"
+)
+
+var (
+	// ErrEmptyURIs 没有下载链接
+	ErrEmptyURIs = errors.New("aria2: empty uris")
+)
+
+type (
+	// Task aria2 下载任务
+	Task struct {
+		URIs    []string
+		Out     string   // 保存的文件名
+		Dir     string   // 保存的目录
+		Headers []string // 请求头, 如 "Cookie: BDUSS=xxx"
+		MD5     string   // 文件的 md5, 用于校验
+		Options map[string]string
+	}
+
+	// Client aria2 JSON-RPC 客户端
+	Client struct {
+		URL    string // JSON-RPC 地址, 如 http://localhost:6800/jsonrpc
+		Secret string // rpc-secret
+		client *requester.HTTPClient
+		lastID int64
+	}
+
+	// Status 任务状态, 只包含部分字段
+	Status struct {
+		GID             string `json:"gid"`
+		Status          string `json:"status"`
+		TotalLength     string `json:"totalLength"`
+		CompletedLength string `json:"completedLength"`
+		DownloadSpeed   string `json:"downloadSpeed"`
+		ErrorCode       string `json:"errorCode"`
+		ErrorMessage    string `json:"errorMessage"`
+	}
+
+	// RPCError aria2 返回的错误
+	RPCError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	rpcRequest struct {
+		JSONRPC string        `json:"jsonrpc"`
+		ID      string        `json:"id"`
+		Method  string        `json:"method"`
+		Params  []interface{} `json:"params"`
+	}
+
+	rpcResponse struct {
+		ID     string              `json:"id"`
+		Result jsoniter.RawMessage `json:"result"`
+		Error  *RPCError           `json:"error"`
+	}
+)
+
+// options 返回 aria2 的选项, header 可以有多个
+func (t *Task) options() map[string]interface{} {
+	opts := make(map[string]interface{}, len(t.Options)+4)
+	for k, v := range t.Options {
+		opts[k] = v
+	}
+	if t.Out != "" {
+		opts["out"] = t.Out
+	}
+	if t.Dir != "" {
+		opts["dir"] = t.Dir
+	}
+	if t.MD5 != "" {
+		opts["checksum"] = "md5=" + t.MD5
+	}
+	if len(t.Headers) > 0 {
+		opts["header"] = t.Headers
+	}
+	return opts
+}
+
+// WriteInputFile 输出 aria2 的输入文件, 用于 aria2c -i
+func WriteInputFile(w io.Writer, tasks []*Task) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range tasks {
+		if len(t.URIs) == 0 {
+			return ErrEmptyURIs
+		}
+		bw.WriteString(strings.Join(t.URIs, "\t"))
+		bw.WriteByte('\n')
+
+		opts := t.options()
+		keys := make([]string, 0, len(opts))
+		for k := range opts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			switch v := opts[k].(type) {
+			case []string:
+				for _, s := range v {
+					fmt.Fprintf(bw, " %s=%s\n", k, s)
+				}
+			default:
+				fmt.Fprintf(bw, " %s=%s\n", k, v)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func (e *RPCError) Error() string {
+	return "aria2: " + strconv.Itoa(e.Code) + ", " + e.Message
+}
+
+// Int64 转换长度或速度
+func (s *Status) Int64(value string) int64 {
+	i, _ := strconv.ParseInt(value, 10, 64)
+	return i
+}
+
+// NewClient 初始化 aria2 JSON-RPC 客户端
+func NewClient(rpcURL, secret string) *Client {
+	return &Client{
+		URL:    rpcURL,
+		Secret: secret,
+		client: requester.NewHTTPClient(),
+	}
+}
+
+// Call 调用 aria2 的方法, result 为结果的指针
+func (c *Client) Call(method string, result interface{}, params ...interface{}) error {
+	if c.Secret != "" {
+		params = append([]interface{}{"token:" + c.Secret}, params...)
+	}
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := jsoniter.Marshal(&rpcRequest{
+		JSONRPC: "2.0",
+		ID:      strconv.FormatInt(atomic.AddInt64(&c.lastID, 1), 10),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Req(http.MethodPost, c.URL, body, map[string]string{
+		"Content-Type": "application/json",
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	res := rpcResponse{}
+	err = jsoniter.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return fmt.Errorf("aria2: %s, %s", resp.Status, err)
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return jsoniter.Unmarshal(res.Result, result)
+}
+
+// AddTask 添加下载任务, 返回 gid
+func (c *Client) AddTask(t *Task) (gid string, err error) {
+	if len(t.URIs) == 0 {
+		return "", ErrEmptyURIs
+	}
+	err = c.Call("aria2.addUri", &gid, t.URIs, t.options())
+	return
+}
+
+// TellStatus 获取任务状态
+func (c *Client) TellStatus(gid string) (status *Status, err error) {
+	status = &Status{}
+	err = c.Call("aria2.tellStatus", status, gid)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// RemoveDownloadResult 移除已完成, 出错或已移除的任务的记录
+func (c *Client) RemoveDownloadResult(gid string) error {
+	return c.Call("aria2.removeDownloadResult", nil, gid)
+}
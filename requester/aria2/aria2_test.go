@@ -0,0 +1,69 @@
+package aria2_test
+
+import (
+	"bytes"
+	"github.com/felixonmars/BaiduPCS-Go/requester/aria2"
+	"github.com/json-iterator/go"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteInputFile(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := aria2.WriteInputFile(buf, []*aria2.Task{
+		{
+			URIs:    []string{"http://a/1", "http://b/1"},
+			Out:     "1.mp4",
+			Dir:     "/tmp/d",
+			Headers: []string{"User-Agent: ua", "Cookie: BDUSS=x"},
+			MD5:     "abc",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "http://a/1\thttp://b/1\n checksum=md5=abc\n dir=/tmp/d\n header=User-Agent: ua\n header=Cookie: BDUSS=x\n out=1.mp4\n"
+	if buf.String() != expected {
+		t.Fatalf("got:\n%s", buf.String())
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     string        `json:"id"`
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		jsoniter.NewDecoder(r.Body).Decode(&req)
+		if len(req.Params) == 0 || req.Params[0] != "token:secret" {
+			w.Write([]byte(`{"id":"` + req.ID + `","error":{"code":1,"message":"Unauthorized"}}`))
+			return
+		}
+		switch req.Method {
+		case "aria2.addUri":
+			w.Write([]byte(`{"id":"` + req.ID + `","result":"2089b05ecca3d829"}`))
+		case "aria2.tellStatus":
+			w.Write([]byte(`{"id":"` + req.ID + `","result":{"gid":"2089b05ecca3d829","status":"error","errorCode":"22"}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := aria2.NewClient(server.URL, "secret")
+	gid, err := client.AddTask(&aria2.Task{URIs: []string{"http://a/1"}})
+	if err != nil || gid != "2089b05ecca3d829" {
+		t.Fatalf("gid: %s, err: %v", gid, err)
+	}
+
+	status, err := client.TellStatus(gid)
+	if err != nil || status.Status != aria2.StatusError || status.ErrorCode != "22" {
+		t.Fatalf("status: %+v, err: %v", status, err)
+	}
+
+	_, err = aria2.NewClient(server.URL, "").AddTask(&aria2.Task{URIs: []string{"http://a/1"}})
+	if e, ok := err.(*aria2.RPCError); !ok || e.Code != 1 {
+		t.Fatalf("err: %v", err)
+	}
+}
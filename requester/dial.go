@@ -73,27 +73,6 @@ func getDialer() *net.Dialer {
 	}
 }
 
-func checkProxyAddr(proxyAddr string) (u *url.URL, err error) {
-	if proxyAddr == "" {
-		return nil, ErrProxyAddrEmpty
-	}
-
-	host, port, err := net.SplitHostPort(proxyAddr)
-	if err == nil {
-		u = &url.URL{
-			Host: net.JoinHostPort(host, port),
-		}
-		return
-	}
-
-	u, err = url.Parse(proxyAddr)
-	if err == nil {
-		return
-	}
-
-	return
-}
-
 // SetGlobalProxy 设置代理
 func SetGlobalProxy(proxyAddr string) {
 	ProxyAddr = proxyAddr
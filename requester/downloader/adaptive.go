@@ -0,0 +1,263 @@
+package downloader
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/requester/rio/speeds"
+	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
+)
+
+const (
+	// AdaptiveEvaluateTicks 自适应并发量的调整周期, 单位为监控周期 (约1秒)
+	AdaptiveEvaluateTicks = 3
+	// AdaptiveProbeRounds 速度没有提升时, 每隔多少个调整周期尝试增加一次并发量
+	AdaptiveProbeRounds = 10
+)
+
+type (
+	// adaptiveController AIMD 方式调整并发量:
+	// 速度随并发量提升时, 加性增加; 出现限流或大量错误时, 乘性减少
+	adaptiveController struct {
+		min, max  int
+		target    float64
+		lastSpeed int64
+		ticks     int
+		idleRound int // 速度没有提升的调整周期数
+	}
+)
+
+func newAdaptiveController(min, max, initial int) *adaptiveController {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	ac := &adaptiveController{
+		min:    min,
+		max:    max,
+		target: float64(initial),
+	}
+	ac.clamp()
+	return ac
+}
+
+func (ac *adaptiveController) clamp() {
+	if ac.target < float64(ac.min) {
+		ac.target = float64(ac.min)
+	}
+	if ac.target > float64(ac.max) {
+		ac.target = float64(ac.max)
+	}
+}
+
+// Target 目标并发量
+func (ac *adaptiveController) Target() int {
+	return int(ac.target)
+}
+
+// update 根据总速度, 正在工作的线程数, 出错和被限流的线程数, 调整目标并发量,
+// 返回是否到达调整周期
+func (ac *adaptiveController) update(speed int64, active, failed, throttled int) bool {
+	ac.ticks++
+	if ac.ticks < AdaptiveEvaluateTicks {
+		return false
+	}
+	ac.ticks = 0
+
+	switch {
+	case throttled > 0:
+		// 服务器限流, 减半
+		ac.target /= 2
+		ac.idleRound = 0
+	case active > 0 && failed*4 > active:
+		// 错误率过高
+		ac.target *= 0.75
+		ac.idleRound = 0
+	case active >= ac.Target() && speed > ac.lastSpeed+ac.lastSpeed/20:
+		// 已用满目标并发量, 且速度有提升, 继续增加
+		ac.target++
+		ac.idleRound = 0
+	default:
+		// 速度没有提升, 保持, 定期试探
+		ac.idleRound++
+		if ac.idleRound >= AdaptiveProbeRounds {
+			ac.target++
+			ac.idleRound = 0
+		}
+	}
+	ac.clamp()
+	ac.lastSpeed = speed
+	return true
+}
+
+// SetAdaptiveParallel 启用自适应并发量, 并发量在 [min, max] 之间调整, initial 为初始并发量
+func (mt *Monitor) SetAdaptiveParallel(min, max, initial int) {
+	mt.adaptive = newAdaptiveController(min, max, initial)
+	mt.parked = map[*Worker]bool{}
+}
+
+// isParked worker 是否被自适应并发量暂时停止
+func (mt *Monitor) isParked(worker *Worker) bool {
+	return mt.parked != nil && mt.parked[worker]
+}
+
+// numActiveWorkers 正在工作的线程数, 不包括已完成和被暂时停止的线程
+func (mt *Monitor) numActiveWorkers() (num int) {
+	for _, worker := range mt.workers {
+		if !worker.Completed() && !mt.isParked(worker) {
+			num++
+		}
+	}
+	return
+}
+
+// canStartWorker 是否可以启动新的线程
+func (mt *Monitor) canStartWorker() bool {
+	return mt.adaptive == nil || mt.numActiveWorkers() < mt.adaptive.Target()
+}
+
+// adjustParallel 根据自适应并发量, 增加或减少正在工作的线程
+func (mt *Monitor) adjustParallel() {
+	if mt.adaptive == nil {
+		return
+	}
+
+	var active, failed, throttled int
+	for _, worker := range mt.workers {
+		if worker.Completed() || mt.isParked(worker) {
+			continue
+		}
+		active++
+		switch worker.GetStatus().StatusCode() {
+		case StatusCodeNetError, StatusCodeFailed:
+			failed++
+		case StatusCodeTooManyConnections:
+			throttled++
+		}
+	}
+
+	if !mt.adaptive.update(mt.status.SpeedsPerSecond(), active, failed, throttled) {
+		return
+	}
+
+	target := mt.adaptive.Target()
+	downloaderVerbose.Debug("adaptive parallel", "active", active, "target", target, "failed", failed, "throttled", throttled)
+	for ; active > target; active-- {
+		if !mt.parkSlowestWorker() {
+			break
+		}
+	}
+	for ; active < target; active++ {
+		if !mt.startMoreWorker() {
+			break
+		}
+	}
+}
+
+// parkSlowestWorker 暂时停止最慢的线程, 保留剩余的range, 优先停止被限流和出错的线程
+func (mt *Monitor) parkSlowestWorker() bool {
+	var (
+		slowest      *Worker
+		slowestSpeed int64
+	)
+	for _, worker := range mt.workers {
+		if worker.Completed() || mt.isParked(worker) {
+			continue
+		}
+		if worker.GetStatus().StatusCode() == StatusCodeWaitToWrite {
+			continue
+		}
+
+		speed := worker.GetSpeedsPerSecond()
+		if worker.Failed() {
+			speed = -1
+		}
+		if slowest == nil || speed < slowestSpeed {
+			slowest, slowestSpeed = worker, speed
+		}
+	}
+	if slowest == nil {
+		return false
+	}
+
+	mt.parked[slowest] = true
+	slowest.Park()
+	downloaderVerbose.Debug("worker parked", "worker", slowest.ID(), "range", slowest.GetRange().ShowDetails())
+	return true
+}
+
+// startMoreWorker 启动一个线程, 优先恢复被暂时停止的线程, 其次分配新的range,
+// 最后拆分剩余最多的线程, 没有空闲的线程时, 新建线程
+func (mt *Monitor) startMoreWorker() bool {
+	for worker := range mt.parked {
+		delete(mt.parked, worker)
+		worker.ClearStatus()
+		mt.assignMirror(worker)
+		downloaderVerbose.Debug("worker resumed", "worker", worker.ID(), "range", worker.GetRange().ShowDetails())
+		go worker.Execute()
+		return true
+	}
+
+	if mt.GetAvailableWorker() == nil {
+		if len(mt.workers) >= mt.adaptive.max || !mt.spawnWorker() {
+			return false
+		}
+	}
+
+	gen := mt.status.RangeListGen()
+	if gen != nil && !gen.IsDone() {
+		before := mt.numActiveWorkers()
+		mt.TryAddNewWork()
+		return mt.numActiveWorkers() > before
+	}
+
+	// 拆分剩余最多的线程
+	var largest *Worker
+	for _, worker := range mt.workers {
+		if worker.Completed() || mt.isParked(worker) {
+			continue
+		}
+		if largest == nil || worker.GetRange().Len() > largest.GetRange().Len() {
+			largest = worker
+		}
+	}
+	if largest == nil {
+		return false
+	}
+	before := mt.numActiveWorkers()
+	mt.DynamicSplitWorker(largest)
+	return mt.numActiveWorkers() > before
+}
+
+// spawnWorker 以第一个线程为模板, 新建一个空闲的线程
+func (mt *Monitor) spawnWorker() bool {
+	if len(mt.workers) == 0 {
+		return false
+	}
+
+	tpl := mt.workers[0]
+	if tpl.acceptRanges == "" {
+		// 单线程模式
+		return false
+	}
+
+	durl, referer, mirror := tpl.source()
+	worker := NewWorker(len(mt.workers), durl, tpl.writerAt)
+	worker.SetClient(tpl.client)
+	worker.SetWriteMutex(tpl.writeMu)
+	worker.SetReferer(referer)
+	worker.SetTotalSize(tpl.totalSize)
+	worker.SetAcceptRange(tpl.acceptRanges)
+	worker.SetMirror(mirror)
+	worker.SetRangeChecksumList(tpl.checksums)
+	worker.SetDownloadStatus(mt.status)
+	worker.SetRange(&transfer.Range{})
+	worker.speedsStat = &speeds.Speeds{}
+	worker.pauseChan = make(chan struct{})
+	worker.status.statusCode = StatusCodeSuccessed // 空闲
+
+	mt.workersMu.Lock()
+	mt.workers = append(mt.workers, worker)
+	mt.workersMu.Unlock()
+	downloaderVerbose.Debug("worker spawned", "worker", worker.ID())
+	return true
+}
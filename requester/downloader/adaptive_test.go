@@ -0,0 +1,146 @@
+package downloader
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/requester/rio/speeds"
+	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
+	"testing"
+)
+
+func TestNewAdaptiveController(t *testing.T) {
+	for _, c := range []struct {
+		min, max, initial int
+		wantMin, wantMax  int
+		wantTarget        int
+	}{
+		{1, 16, 4, 1, 16, 4},
+		{0, 0, 5, 1, 1, 1},
+		{2, 8, 20, 2, 8, 8},
+		{4, 8, 1, 4, 8, 4},
+	} {
+		ac := newAdaptiveController(c.min, c.max, c.initial)
+		if ac.min != c.wantMin || ac.max != c.wantMax || ac.Target() != c.wantTarget {
+			t.Fatalf("newAdaptiveController(%d, %d, %d): min: %d, max: %d, target: %d", c.min, c.max, c.initial, ac.min, ac.max, ac.Target())
+		}
+	}
+}
+
+func TestAdaptiveControllerUpdate(t *testing.T) {
+	for _, c := range []struct {
+		name                      string
+		target                    int
+		lastSpeed                 int64
+		idleRound                 int
+		speed                     int64
+		active, failed, throttled int
+		want                      int
+	}{
+		{"increase", 4, 1000, 0, 1100, 4, 0, 0, 5},
+		{"increase at max", 16, 1000, 0, 1100, 16, 0, 0, 16},
+		{"below target", 4, 1000, 0, 1100, 3, 0, 0, 4},
+		{"no speed up", 4, 1000, 0, 1040, 4, 0, 0, 4},
+		{"probe", 4, 1000, AdaptiveProbeRounds - 1, 1000, 4, 0, 0, 5},
+		{"errors", 4, 1000, 0, 1100, 4, 2, 0, 3},
+		{"few errors", 8, 1000, 0, 1000, 8, 1, 0, 8},
+		{"throttled", 8, 1000, 0, 1100, 8, 0, 1, 4},
+		{"throttled at min", 1, 1000, 0, 1100, 1, 0, 1, 1},
+		{"throttled over errors", 8, 1000, 0, 1100, 8, 8, 1, 4},
+	} {
+		ac := newAdaptiveController(1, 16, c.target)
+		ac.lastSpeed = c.lastSpeed
+		ac.idleRound = c.idleRound
+		ac.ticks = AdaptiveEvaluateTicks - 1
+		if !ac.update(c.speed, c.active, c.failed, c.throttled) {
+			t.Fatalf("%s: not evaluated", c.name)
+		}
+		if ac.Target() != c.want {
+			t.Fatalf("%s: target: %d, want: %d", c.name, ac.Target(), c.want)
+		}
+		if ac.lastSpeed != c.speed {
+			t.Fatalf("%s: lastSpeed: %d", c.name, ac.lastSpeed)
+		}
+	}
+}
+
+func TestAdaptiveControllerTicks(t *testing.T) {
+	ac := newAdaptiveController(1, 16, 4)
+	for i := 1; i < AdaptiveEvaluateTicks; i++ {
+		if ac.update(0, 4, 0, 1) {
+			t.Fatalf("tick %d: evaluated before %d ticks", i, AdaptiveEvaluateTicks)
+		}
+	}
+	if ac.Target() != 4 {
+		t.Fatalf("target changed between ticks: %d", ac.Target())
+	}
+	if !ac.update(0, 4, 0, 1) || ac.Target() != 2 {
+		t.Fatalf("target: %d", ac.Target())
+	}
+}
+
+func newTestWorker(id int, statusCode StatusCode) *Worker {
+	worker := NewWorker(id, "http://example.com", nil)
+	worker.SetRange(&transfer.Range{Begin: 0, End: 100})
+	worker.speedsStat = &speeds.Speeds{}
+	worker.status.statusCode = statusCode
+	return worker
+}
+
+func TestMonitorParkSlowestWorker(t *testing.T) {
+	var (
+		downloading = newTestWorker(0, StatusCodeDownloading)
+		failed      = newTestWorker(1, StatusCodeNetError)
+		waitToWrite = newTestWorker(2, StatusCodeWaitToWrite)
+		completed   = newTestWorker(3, StatusCodeSuccessed)
+		mt          = &Monitor{
+			workers: WorkerList{downloading, failed, waitToWrite, completed},
+		}
+	)
+	mt.SetAdaptiveParallel(1, 4, 2)
+	if n := mt.numActiveWorkers(); n != 3 {
+		t.Fatalf("active: %d", n)
+	}
+	if mt.canStartWorker() {
+		t.Fatalf("can start worker with %d active, target %d", mt.numActiveWorkers(), mt.adaptive.Target())
+	}
+
+	// 优先停止出错的线程, 不停止等待写入的线程
+	for _, want := range []*Worker{failed, downloading} {
+		if !mt.parkSlowestWorker() {
+			t.Fatalf("park failed, want worker %d", want.ID())
+		}
+		if !mt.isParked(want) {
+			t.Fatalf("worker %d not parked", want.ID())
+		}
+	}
+	if mt.parkSlowestWorker() {
+		t.Fatalf("parked waiting to write worker")
+	}
+	if n := mt.numActiveWorkers(); n != 1 {
+		t.Fatalf("active: %d", n)
+	}
+	if !mt.canStartWorker() {
+		t.Fatalf("can not start worker with %d active, target %d", mt.numActiveWorkers(), mt.adaptive.Target())
+	}
+}
+
+func TestMonitorSpawnWorker(t *testing.T) {
+	mt := &Monitor{}
+	if mt.spawnWorker() {
+		t.Fatalf("spawned without template")
+	}
+
+	// 单线程模式不新建线程
+	tpl := newTestWorker(0, StatusCodeDownloading)
+	mt.workers = WorkerList{tpl}
+	if mt.spawnWorker() {
+		t.Fatalf("spawned in single mode")
+	}
+
+	tpl.SetAcceptRange(DefaultAcceptRanges)
+	if !mt.spawnWorker() || len(mt.workers) != 2 {
+		t.Fatalf("spawn failed, workers: %d", len(mt.workers))
+	}
+	spawned := mt.workers[1]
+	if spawned.ID() != 1 || !spawned.Completed() || spawned.url != tpl.url {
+		t.Fatalf("spawned worker: id: %d, completed: %t, url: %s", spawned.ID(), spawned.Completed(), spawned.url)
+	}
+}
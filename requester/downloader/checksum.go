@@ -0,0 +1,136 @@
+package downloader
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// RangeChecksumSize 每个校验值对应的最大数据量
+	RangeChecksumSize = 4 * converter.MB
+	// ZeroHoleCheckSize 检测零填充空洞的区块大小
+	ZeroHoleCheckSize = 64 * converter.KB
+)
+
+type (
+	// rangeChecksummer 计算线程连续写入的数据的校验值,
+	// 每 RangeChecksumSize 提交一次, 写入不连续时也提交
+	rangeChecksummer struct {
+		list       *transfer.RangeChecksumList
+		begin, end int64
+		crc        uint32
+	}
+)
+
+func (rc *rangeChecksummer) write(off int64, p []byte) {
+	if off != rc.end || rc.end-rc.begin >= RangeChecksumSize {
+		rc.commit()
+		rc.begin, rc.end = off, off
+	}
+	rc.crc = crc32.Update(rc.crc, crc32.IEEETable, p)
+	rc.end += int64(len(p))
+}
+
+func (rc *rangeChecksummer) commit() {
+	rc.list.Add(rc.begin, rc.end, rc.crc)
+	rc.begin, rc.crc = rc.end, 0
+}
+
+// FindCorruptRanges 检测已下载的数据中可能损坏的范围, 包括校验值不匹配的范围,
+// 以及没有校验值的范围中全部为0的区块 (写入中断留下的空洞).
+// checksums 须按 Begin 排序, 返回的范围已合并相邻的部分.
+func FindCorruptRanges(r io.ReaderAt, totalSize int64, checksums []*transfer.RangeChecksum) (corrupt transfer.RangeList, err error) {
+	var (
+		buf    = make([]byte, ZeroHoleCheckSize)
+		offset int64 // 已检测的位置
+	)
+	add := func(begin, end int64) {
+		if end <= begin {
+			return
+		}
+		if n := len(corrupt); n > 0 && corrupt[n-1].End >= begin {
+			if end > corrupt[n-1].End {
+				corrupt[n-1].End = end
+			}
+			return
+		}
+		corrupt = append(corrupt, &transfer.Range{Begin: begin, End: end})
+	}
+
+	// checkZero 检测 [begin, end) 中的零填充空洞
+	checkZero := func(begin, end int64) error {
+		for pos := begin; pos < end; pos += ZeroHoleCheckSize {
+			chunkEnd := pos + ZeroHoleCheckSize
+			if chunkEnd > end {
+				chunkEnd = end
+			}
+			n, err := r.ReadAt(buf[:chunkEnd-pos], pos)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if isZero(buf[:n]) {
+				add(pos, chunkEnd)
+			}
+			if int64(n) < chunkEnd-pos {
+				// 文件不完整
+				add(pos+int64(n), end)
+				return nil
+			}
+		}
+		return nil
+	}
+
+	for _, cs := range checksums {
+		if cs.End <= offset {
+			continue
+		}
+		if cs.End > totalSize {
+			// 超出文件大小, 校验值无效
+			continue
+		}
+		if cs.Begin < offset {
+			// 与已检测的部分重叠, 无法使用该校验值
+			err = checkZero(offset, cs.End)
+			if err != nil {
+				return nil, err
+			}
+			offset = cs.End
+			continue
+		}
+		if cs.Begin > offset {
+			err = checkZero(offset, cs.Begin)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		h := crc32.NewIEEE()
+		_, err = io.CopyBuffer(h, io.NewSectionReader(r, cs.Begin, cs.End-cs.Begin), buf)
+		if err != nil {
+			return nil, err
+		}
+		if h.Sum32() != cs.Crc32 {
+			add(cs.Begin, cs.End)
+		}
+		offset = cs.End
+	}
+
+	if offset < totalSize {
+		err = checkZero(offset, totalSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return corrupt, nil
+}
+
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
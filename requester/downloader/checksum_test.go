@@ -0,0 +1,60 @@
+package downloader_test
+
+import (
+	"bytes"
+	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
+	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+func TestFindCorruptRanges(t *testing.T) {
+	const size = 1024 * 1024
+	data := make([]byte, size)
+	rand.Read(data)
+
+	checksums := transfer.NewRangeChecksumList(nil)
+	for _, r := range [][2]int64{{0, 300000}, {300000, 600000}} {
+		checksums.Add(r[0], r[1], crc32.ChecksumIEEE(data[r[0]:r[1]]))
+	}
+
+	// 校验值不匹配
+	corrupted := append([]byte(nil), data...)
+	corrupted[400000] ^= 0xff
+	// 没有校验值的范围中, 写入中断留下的空洞
+	for i := int64(700000); i < 700000+2*downloader.ZeroHoleCheckSize; i++ {
+		corrupted[i] = 0
+	}
+
+	corrupt, err := downloader.FindCorruptRanges(bytes.NewReader(corrupted), size, checksums.List())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupt) != 2 {
+		t.Fatalf("corrupt ranges: %v", corrupt)
+	}
+	if corrupt[0].Begin != 300000 || corrupt[0].End != 600000 {
+		t.Errorf("checksum mismatch range: %s", corrupt[0].ShowDetails())
+	}
+	if corrupt[1].Begin < 700000 || corrupt[1].End > 700000+2*downloader.ZeroHoleCheckSize || corrupt[1].Len() < downloader.ZeroHoleCheckSize {
+		t.Errorf("zero hole range: %s", corrupt[1].ShowDetails())
+	}
+
+	// 文件不完整
+	corrupt, err = downloader.FindCorruptRanges(bytes.NewReader(data[:size-1000]), size, checksums.List())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupt) != 1 || corrupt[0].End != size {
+		t.Fatalf("truncated file: %v", corrupt)
+	}
+
+	corrupt, err = downloader.FindCorruptRanges(bytes.NewReader(data), size, checksums.List())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupt) != 0 {
+		t.Fatalf("unexpected corrupt ranges: %v", corrupt)
+	}
+}
@@ -18,6 +18,8 @@ var (
 type Config struct {
 	Mode                       transfer.RangeGenMode      // 下载Range分配模式
 	MaxParallel                int                        // 最大下载并发量
+	MinParallel                int                        // 最小下载并发量, 启用自适应并发量时才有效
+	AdaptiveParallel           bool                       // 是否根据下载速度, 错误率和限流, 自动调整并发量
 	CacheSize                  int                        // 下载缓冲
 	BlockSize                  int64                      // 每个Range区块的大小, RangeGenMode 为 RangeGenMode2 时才有效
 	MaxRate                    int64                      // 限制最大下载速度
@@ -25,6 +27,9 @@ type Config struct {
 	InstanceStatePath          string                     // 断点续传信息路径
 	IsTest                     bool                       // 是否测试下载
 	TryHTTP                    bool                       // 是否尝试使用 http 连接
+	RangeChecksum              bool                       // 是否记录已写入数据的校验值, 用于修复损坏的数据
+	DirectIO                   bool                       // 写入文件时是否使用 O_DIRECT, 只支持 linux
+	WriteBlockSize             int                        // 写入文件时合并写入的区块大小, 0 为不合并
 }
 
 //NewConfig 返回默认配置
@@ -42,6 +47,12 @@ func (cfg *Config) Fix() {
 	if cfg.MaxParallel < 1 {
 		cfg.MaxParallel = 1
 	}
+	if cfg.MinParallel < 1 {
+		cfg.MinParallel = 1
+	}
+	if cfg.MinParallel > cfg.MaxParallel {
+		cfg.MinParallel = cfg.MaxParallel
+	}
 }
 
 //Copy 拷贝新的配置
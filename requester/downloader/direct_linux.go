@@ -0,0 +1,11 @@
+package downloader
+
+import (
+	"os"
+	"syscall"
+)
+
+// openDirect 以 O_DIRECT 方式打开文件, 用于写入
+func openDirect(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_WRONLY|syscall.O_DIRECT, 0)
+}
@@ -0,0 +1,18 @@
+//+build !linux
+
+package downloader
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	// ErrDirectIONotSupported 当前系统不支持 O_DIRECT
+	ErrDirectIONotSupported = errors.New("direct I/O is not supported on this platform")
+)
+
+// openDirect 以 O_DIRECT 方式打开文件, 用于写入
+func openDirect(name string) (*os.File, error) {
+	return nil, ErrDirectIONotSupported
+}
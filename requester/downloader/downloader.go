@@ -4,16 +4,17 @@ package downloader
 import (
 	"context"
 	"errors"
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/cachepool"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/prealloc"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/waitgroup"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"github.com/felixonmars/BaiduPCS-Go/requester/rio/speeds"
 	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
 	"io"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 )
@@ -23,6 +24,15 @@ const (
 	DefaultAcceptRanges = "bytes"
 )
 
+var (
+	// ErrDownloadRangeNotSupported 服务器不支持 Range, 无法只下载文件的一部分
+	ErrDownloadRangeNotSupported = errors.New("server does not support range requests")
+	// ErrDownloadRangeInvalid 下载范围不合法
+	ErrDownloadRangeInvalid = errors.New("invalid download range")
+
+	downloaderVerbose = pcslog.New("DOWNLOADER")
+)
+
 type (
 	// Downloader 下载
 	Downloader struct {
@@ -48,6 +58,9 @@ type (
 		config                  *Config
 		monitor                 *Monitor
 		instanceState           *InstanceState
+		downloadRange           *transfer.Range // 只下载文件的一部分
+		mirrorRefreshFunc       MirrorRefreshFunc
+		checksums               *transfer.RangeChecksumList // 已写入数据的校验值
 	}
 
 	// DURLCheckFunc 下载URL检测函数
@@ -73,6 +86,26 @@ func (der *Downloader) SetFirstInfo(i *DownloadFirstInfo) {
 	der.firstInfo = i
 }
 
+// SetDownloadRange 设置只下载文件的 [begin, end) 部分, end 小于 0 时下载到文件末尾.
+// 只下载部分文件时, 不支持断点续传
+func (der *Downloader) SetDownloadRange(begin, end int64) {
+	der.downloadRange = &transfer.Range{
+		Begin: begin,
+		End:   end,
+	}
+}
+
+// SetMirrorRefreshFunc 设置刷新镜像的函数, 所有镜像都不可用时 (如链接过期), 用于重新获取下载链接
+func (der *Downloader) SetMirrorRefreshFunc(f MirrorRefreshFunc) {
+	der.mirrorRefreshFunc = f
+}
+
+// RangeChecksums 返回已写入数据的校验值, 包括断点续传之前写入的数据,
+// 未启用 Config.RangeChecksum 时返回 nil
+func (der *Downloader) RangeChecksums() []*transfer.RangeChecksum {
+	return der.checksums.List()
+}
+
 //SetClient 设置http客户端
 func (der *Downloader) SetClient(client *requester.HTTPClient) {
 	der.client = client
@@ -120,9 +153,13 @@ func (der *Downloader) SelectParallel(single bool, maxParallel int, totalSize in
 	} else if isRange {
 		parallel = len(instanceRangeList)
 	} else {
-		parallel = der.config.MaxParallel
-		if int64(parallel) > totalSize/int64(MinParallelSize) {
-			parallel = int(totalSize/int64(MinParallelSize)) + 1
+		parallel = der.maxParallel(totalSize)
+		if der.config.AdaptiveParallel {
+			// 自适应并发量, 从较低的并发量开始增加
+			parallel /= 4
+			if parallel < der.config.MinParallel {
+				parallel = der.config.MinParallel
+			}
 		}
 	}
 
@@ -132,6 +169,15 @@ func (der *Downloader) SelectParallel(single bool, maxParallel int, totalSize in
 	return
 }
 
+// maxParallel 最大并发量, 文件较小时减少并发量
+func (der *Downloader) maxParallel(totalSize int64) (parallel int) {
+	parallel = der.config.MaxParallel
+	if int64(parallel) > totalSize/int64(MinParallelSize) {
+		parallel = int(totalSize/int64(MinParallelSize)) + 1
+	}
+	return
+}
+
 // SelectBlockSizeAndInitRangeGen 获取合适的 BlockSize, 和初始化 RangeGen
 func (der *Downloader) SelectBlockSizeAndInitRangeGen(single bool, status *transfer.DownloadStatus, parallel int) (blockSize int64, initErr error) {
 	// Range 生成器
@@ -139,21 +185,27 @@ func (der *Downloader) SelectBlockSizeAndInitRangeGen(single bool, status *trans
 		blockSize = -1
 		return
 	}
-	gen := status.RangeListGen()
+	var (
+		gen        = status.RangeListGen()
+		begin, end = int64(0), status.TotalSize()
+	)
+	if der.downloadRange != nil {
+		begin, end = der.downloadRange.Begin, der.downloadRange.End
+	}
 	if gen == nil {
 		switch der.config.Mode {
 		case transfer.RangeGenMode_Default:
-			gen = transfer.NewRangeListGenDefault(status.TotalSize(), 0, 0, parallel)
+			gen = transfer.NewRangeListGenDefault(end, begin, 0, parallel)
 			blockSize = gen.LoadBlockSize()
 		case transfer.RangeGenMode_BlockSize:
-			b2 := status.TotalSize()/int64(parallel) + 1
+			b2 := (end-begin)/int64(parallel) + 1
 			if b2 > der.config.BlockSize { // 选小的BlockSize, 以更高并发
 				blockSize = der.config.BlockSize
 			} else {
 				blockSize = b2
 			}
 
-			gen = transfer.NewRangeListGenBlockSize(status.TotalSize(), 0, blockSize)
+			gen = transfer.NewRangeListGenBlockSize(end, begin, blockSize)
 		default:
 			initErr = transfer.ErrUnknownRangeGenMode
 			return
@@ -206,7 +258,7 @@ func (der *Downloader) checkLoadBalancers() *LoadBalancerResponseList {
 			}
 
 			loadBalancerResponses = append(loadBalancerResponses, loadBalancer)
-			pcsverbose.Verbosef("DEBUG: load balance task: URL: %s, Referer: %s\n", loadBalancer.URL, loadBalancer.Referer)
+			downloaderVerbose.Debug("load balance task", "url", loadBalancer.URL, "referer", loadBalancer.Referer)
 		}
 	)
 
@@ -229,7 +281,7 @@ func (der *Downloader) checkLoadBalancers() *LoadBalancerResponseList {
 				subResp.Body.Close() // 不读Body, 马上关闭连接
 			}
 			if subErr != nil {
-				pcsverbose.Verbosef("DEBUG: loadBalanser Error: %s\n", subErr)
+				downloaderVerbose.Debug("load balancer request failed", "err", subErr)
 				return
 			}
 
@@ -243,18 +295,18 @@ func (der *Downloader) checkLoadBalancers() *LoadBalancerResponseList {
 				} else {
 					err = errors.New(subResp.Status)
 				}
-				pcsverbose.Verbosef("DEBUG: loadBalanser Status Error: %s\n", err)
+				downloaderVerbose.Debug("load balancer status error", "err", err)
 				return
 			}
 
 			// 检测长度
 			if der.firstInfo.ContentLength != subContentLength {
-				pcsverbose.Verbosef("DEBUG: loadBalanser Content-Length not equal to main server\n")
+				downloaderVerbose.Debug("load balancer content length not equal to main server")
 				return
 			}
 
 			if !der.loadBalancerCompareFunc(der.firstInfo.ToMap(), subResp) {
-				pcsverbose.Verbosef("DEBUG: loadBalanser not equal to main server\n")
+				downloaderVerbose.Debug("load balancer not equal to main server")
 				return
 			}
 
@@ -270,7 +322,15 @@ func (der *Downloader) checkLoadBalancers() *LoadBalancerResponseList {
 
 //Execute 开始任务
 func (der *Downloader) Execute() error {
+	return der.ExecuteContext(context.Background())
+}
+
+// ExecuteContext 开始任务, ctx 取消时停止下载并保存断点信息, 返回 ctx.Err()
+func (der *Downloader) ExecuteContext(ctx context.Context) error {
 	der.lazyInit()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	var (
 		resp *http.Response
@@ -311,7 +371,7 @@ func (der *Downloader) Execute() error {
 			AcceptRanges:  acceptRanges,
 			Referer:       resp.Header.Get("Referer"),
 		}
-		pcsverbose.Verbosef("DEBUG: download task: URL: %s, Referer: %s\n", resp.Request.URL, resp.Request.Referer())
+		downloaderVerbose.Debug("download task", "url", resp.Request.URL, "referer", resp.Request.Referer())
 	} else {
 		if der.firstInfo.AcceptRanges == "" {
 			der.firstInfo.AcceptRanges = DefaultAcceptRanges
@@ -324,9 +384,22 @@ func (der *Downloader) Execute() error {
 		bii                      *transfer.DownloadInstanceInfo
 	)
 
-	if !single {
+	if der.downloadRange != nil {
+		if single {
+			return ErrDownloadRangeNotSupported
+		}
+		if der.downloadRange.End < 0 || der.downloadRange.End > der.firstInfo.ContentLength {
+			der.downloadRange.End = der.firstInfo.ContentLength
+		}
+		if der.downloadRange.Begin < 0 || der.downloadRange.Begin > der.downloadRange.End {
+			return ErrDownloadRangeInvalid
+		}
+	}
+
+	if !single && der.downloadRange == nil {
 		//load breakpoint
 		//服务端不支持多线程时, 不记录断点
+		//只下载部分文件时, 也不记录断点
 		err := der.initInstanceState(der.config.InstanceStateStorageFormat)
 		if err != nil {
 			return err
@@ -348,7 +421,11 @@ func (der *Downloader) Execute() error {
 	} else {
 		// 新建状态
 		status = transfer.NewDownloadStatus()
-		status.SetTotalSize(der.firstInfo.ContentLength)
+		if der.downloadRange != nil {
+			status.SetTotalSize(der.downloadRange.Len())
+		} else {
+			status.SetTotalSize(der.firstInfo.ContentLength)
+		}
 	}
 
 	// 设置限速
@@ -368,20 +445,30 @@ func (der *Downloader) Execute() error {
 	cacheSize := der.SelectCacheSize(der.config.CacheSize, blockSize) // 实际下载缓存
 	cachepool.SetSyncPoolSize(cacheSize)                              // 调整pool大小
 
-	pcsverbose.Verbosef("DEBUG: download task CREATED: parallel: %d, cache size: %d\n", parallel, cacheSize)
+	downloaderVerbose.Debug("download task created", "parallel", parallel, "cache_size", cacheSize)
 
 	der.monitor.InitMonitorCapacity(parallel)
 
 	var writer Writer
 	if !der.config.IsTest {
 		// 尝试修剪文件
-		if fder, ok := der.writer.(Fder); ok {
+		if fder, ok := der.writer.(Fder); ok && der.downloadRange == nil {
 			err = prealloc.PreAlloc(fder.Fd(), status.TotalSize())
 			if err != nil {
-				pcsverbose.Verbosef("DEBUG: truncate file error: %s\n", err)
+				downloaderVerbose.Warn("truncate file failed", "err", err)
 			}
 		}
 		writer = der.writer // 非测试模式, 赋值writer
+
+		// 文件支持多个线程并发写入, 按配置合并写入或使用 O_DIRECT
+		if file, ok := writer.(*os.File); ok {
+			fw := NewFileWriter(file, &FileWriterOptions{
+				DirectIO:  der.config.DirectIO,
+				BlockSize: der.config.WriteBlockSize,
+			})
+			defer fw.Close()
+			writer = fw
+		}
 	}
 
 	// 数据平均分配给各个线程
@@ -405,18 +492,39 @@ func (der *Downloader) Execute() error {
 	}
 
 	var (
-		writeMu = &sync.Mutex{}
+		writeMu *sync.Mutex
 	)
+	switch writer.(type) {
+	case *SequentialWriter:
+		// SequentialWriter 会阻塞乱序的写入, 不能加锁
+	case *FileWriter:
+		// 不重叠的范围可以并发写入, 不需要加锁
+	default:
+		writeMu = &sync.Mutex{}
+	}
+	// 记录已写入数据的校验值
+	if der.config.RangeChecksum && writer != nil {
+		der.checksums = bii.Checksums
+		if der.checksums == nil {
+			der.checksums = transfer.NewRangeChecksumList(nil)
+		}
+		der.monitor.SetRangeChecksumList(der.checksums)
+	}
+
+	// 所有的镜像
+	mirrorPool := NewMirrorPool(loadBalancerResponseList)
+	mirrorPool.SetRefreshFunc(der.mirrorRefreshFunc)
 	for k, r := range bii.Ranges {
-		loadBalancer := loadBalancerResponseList.SequentialGet()
-		if loadBalancer == nil {
+		mirror := mirrorPool.SequentialGet()
+		if mirror == nil {
 			continue
 		}
 
-		worker := NewWorker(k, loadBalancer.URL, writer)
+		worker := NewWorker(k, mirror.URL, writer)
 		worker.SetClient(der.client)
 		worker.SetWriteMutex(writeMu)
-		worker.SetReferer(loadBalancer.Referer)
+		worker.SetMirror(mirror)
+		worker.SetRangeChecksumList(der.checksums)
 		worker.SetTotalSize(der.firstInfo.ContentLength)
 
 		// 使用第一个连接
@@ -431,14 +539,26 @@ func (der *Downloader) Execute() error {
 	}
 
 	der.monitor.SetStatus(status)
+	der.monitor.SetMirrorPool(mirrorPool)
 
 	// 服务器不支持断点续传, 或者单线程下载, 都不重载worker
 	der.monitor.SetReloadWorker(parallel > 1)
 
-	moniterCtx, moniterCancelFunc := context.WithCancel(context.Background())
+	if der.config.AdaptiveParallel && !single {
+		maxParallel := der.maxParallel(status.TotalSize())
+		if maxParallel < parallel {
+			maxParallel = parallel // 断点续传时, 已有的线程数可能更多
+		}
+		der.monitor.SetAdaptiveParallel(der.config.MinParallel, maxParallel, parallel)
+	}
+
+	moniterCtx, moniterCancelFunc := context.WithCancel(ctx)
 	der.monitorCancelFunc = moniterCancelFunc
 
 	der.monitor.SetInstanceState(der.instanceState)
+	if pw, ok := writer.(PendingWriter); ok {
+		der.monitor.SetPendingWriter(pw)
+	}
 
 	// 开始执行
 	der.executeTime = time.Now()
@@ -448,9 +568,16 @@ func (der *Downloader) Execute() error {
 
 	// 检查错误
 	err = der.monitor.Err()
+	if pw, ok := writer.(PendingWriter); ok {
+		// 写入缓存的数据
+		flushErr := pw.Flush()
+		if err == nil {
+			err = flushErr
+		}
+	}
 	if err == nil { // 成功
 		pcsutil.Trigger(der.onSuccessEvent)
-		if !single {
+		if !single && der.instanceState != nil {
 			der.removeInstanceState() // 移除断点续传文件
 		}
 	}
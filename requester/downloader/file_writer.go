@@ -0,0 +1,295 @@
+package downloader
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/cachepool"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
+	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	// DefaultWriteBlockSize 启用 O_DIRECT 但未设置区块大小时, 默认的写入合并区块大小
+	DefaultWriteBlockSize = 1 * converter.MB
+	// DirectIOAlignment O_DIRECT 要求的对齐大小
+	DirectIOAlignment = 4096
+	// MaxPendingWriteBlocks 最多缓存的未写满的区块数量, 超过后写入最久未更新的区块
+	MaxPendingWriteBlocks = 64
+)
+
+type (
+	// FileWriterOptions 文件写入的可选参数
+	FileWriterOptions struct {
+		DirectIO  bool // 使用 O_DIRECT 写入完整的区块, 绕过系统缓存, 只支持 linux
+		BlockSize int  // 写入合并的区块大小, 0 为不合并
+	}
+
+	// FileWriter 文件数据输出, 多个线程可以并发写入不重叠的范围, 不需要加锁.
+	// 启用写入合并时, 零散的写入先缓存在按区块对齐的缓冲区中, 区块写满后一次写入文件,
+	// 未写满的区块在 Flush 时写入.
+	FileWriter struct {
+		file      *os.File
+		direct    *os.File // O_DIRECT 打开的文件, 只用于写入完整的区块
+		blockSize int64
+		pool      *sync.Pool
+		blocks    map[int64]*writeBlock // 正在缓存的区块, key 为区块序号
+		flushing  map[*writeBlock]bool  // 正在写入文件的区块
+		seq       int64
+		err       error
+		mu        sync.Mutex
+	}
+
+	writeBlock struct {
+		index   int64
+		buf     []byte
+		written []writeInterval // 已写入的范围, 相对于区块开头, 按顺序排列且已合并
+		seq     int64           // 最后写入的序号, 用于选出最久未更新的区块
+	}
+
+	writeInterval struct {
+		begin, end int64
+	}
+)
+
+// NewFileWriter 初始化 FileWriter, 不会关闭 file
+func NewFileWriter(file *os.File, opt *FileWriterOptions) *FileWriter {
+	if opt == nil {
+		opt = &FileWriterOptions{}
+	}
+
+	fw := &FileWriter{
+		file:      file,
+		blockSize: int64(opt.BlockSize),
+	}
+
+	if opt.DirectIO {
+		if fw.blockSize <= 0 {
+			fw.blockSize = DefaultWriteBlockSize
+		}
+		// 区块须按 DirectIOAlignment 对齐
+		fw.blockSize = (fw.blockSize + DirectIOAlignment - 1) / DirectIOAlignment * DirectIOAlignment
+
+		direct, err := openDirect(file.Name())
+		if err != nil {
+			downloaderVerbose.Info("open file with O_DIRECT failed, fallback to buffered I/O", "path", file.Name(), "err", err)
+		} else {
+			fw.direct = direct
+		}
+	}
+
+	if fw.blockSize > 0 {
+		fw.pool = cachepool.NewAlignedPool(int(fw.blockSize), DirectIOAlignment)
+		fw.blocks = map[int64]*writeBlock{}
+		fw.flushing = map[*writeBlock]bool{}
+	}
+	return fw
+}
+
+// Fd 实现 Fder 接口, 用于预分配文件空间
+func (fw *FileWriter) Fd() uintptr {
+	return fw.file.Fd()
+}
+
+// File 返回文件
+func (fw *FileWriter) File() *os.File {
+	return fw.file
+}
+
+// WriteAt 实现 io.WriterAt 接口, 可以并发调用
+func (fw *FileWriter) WriteAt(p []byte, off int64) (n int, err error) {
+	if fw.blockSize <= 0 {
+		// 不合并, pwrite 可以并发调用
+		return fw.file.WriteAt(p, off)
+	}
+
+	var toFlush []*writeBlock
+	fw.mu.Lock()
+	if fw.err != nil {
+		fw.mu.Unlock()
+		return 0, fw.err
+	}
+	n = len(p)
+	for len(p) > 0 {
+		var (
+			index  = off / fw.blockSize
+			bbegin = off - index*fw.blockSize
+			l      = int64(len(p))
+		)
+		if l > fw.blockSize-bbegin {
+			l = fw.blockSize - bbegin
+		}
+
+		blk := fw.blocks[index]
+		if blk == nil {
+			blk = &writeBlock{
+				index: index,
+				buf:   fw.pool.Get().([]byte),
+			}
+			fw.blocks[index] = blk
+		}
+		copy(blk.buf[bbegin:], p[:l])
+		blk.add(bbegin, bbegin+l)
+		fw.seq++
+		blk.seq = fw.seq
+
+		if blk.full(fw.blockSize) {
+			toFlush = append(toFlush, fw.takeBlock(blk))
+		}
+		p, off = p[l:], off+l
+	}
+
+	// 缓存的区块过多, 写入最久未更新的区块
+	if len(fw.blocks) > MaxPendingWriteBlocks {
+		var oldest *writeBlock
+		for _, blk := range fw.blocks {
+			if oldest == nil || blk.seq < oldest.seq {
+				oldest = blk
+			}
+		}
+		toFlush = append(toFlush, fw.takeBlock(oldest))
+	}
+	fw.mu.Unlock()
+
+	for _, blk := range toFlush {
+		err = fw.writeBlock(blk)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// takeBlock 从缓存中取出区块, 准备写入文件, 调用者须加锁
+func (fw *FileWriter) takeBlock(blk *writeBlock) *writeBlock {
+	delete(fw.blocks, blk.index)
+	fw.flushing[blk] = true
+	return blk
+}
+
+// writeBlock 将区块写入文件, 完整的区块使用 O_DIRECT 一次写入, 其余的部分按已写入的范围写入
+func (fw *FileWriter) writeBlock(blk *writeBlock) (err error) {
+	base := blk.index * fw.blockSize
+	if blk.full(fw.blockSize) {
+		w := fw.file
+		if fw.direct != nil {
+			w = fw.direct
+		}
+		_, err = w.WriteAt(blk.buf, base)
+	} else {
+		for _, wi := range blk.written {
+			_, err = fw.file.WriteAt(blk.buf[wi.begin:wi.end], base+wi.begin)
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	fw.mu.Lock()
+	delete(fw.flushing, blk)
+	if err != nil && fw.err == nil {
+		fw.err = err
+	}
+	fw.mu.Unlock()
+
+	fw.pool.Put(blk.buf)
+	return err
+}
+
+// Flush 将缓存的区块全部写入文件
+func (fw *FileWriter) Flush() error {
+	if fw.blockSize <= 0 {
+		return nil
+	}
+
+	fw.mu.Lock()
+	toFlush := make([]*writeBlock, 0, len(fw.blocks))
+	for _, blk := range fw.blocks {
+		toFlush = append(toFlush, fw.takeBlock(blk))
+	}
+	fw.mu.Unlock()
+
+	for _, blk := range toFlush {
+		err := fw.writeBlock(blk)
+		if err != nil {
+			return err
+		}
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.err
+}
+
+// PendingRanges 已写入但还未写入文件的范围, 按顺序排列,
+// 保存断点信息时, 这些范围须视为未下载
+func (fw *FileWriter) PendingRanges() (ranges transfer.RangeList) {
+	if fw.blockSize <= 0 {
+		return nil
+	}
+
+	fw.mu.Lock()
+	add := func(blk *writeBlock) {
+		base := blk.index * fw.blockSize
+		for _, wi := range blk.written {
+			ranges = append(ranges, &transfer.Range{Begin: base + wi.begin, End: base + wi.end})
+		}
+	}
+	for _, blk := range fw.blocks {
+		add(blk)
+	}
+	for blk := range fw.flushing {
+		add(blk)
+	}
+	fw.mu.Unlock()
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Begin < ranges[j].Begin
+	})
+	return ranges
+}
+
+// Close 写入缓存的区块, 关闭 O_DIRECT 打开的文件, 不会关闭 file
+func (fw *FileWriter) Close() error {
+	err := fw.Flush()
+	if fw.direct != nil {
+		closeErr := fw.direct.Close()
+		if err == nil {
+			err = closeErr
+		}
+		fw.direct = nil
+	}
+	return err
+}
+
+// add 增加已写入的范围, 与相邻或重叠的范围合并
+func (blk *writeBlock) add(begin, end int64) {
+	i := sort.Search(len(blk.written), func(i int) bool {
+		return blk.written[i].end >= begin
+	})
+	j := i
+	for j < len(blk.written) && blk.written[j].begin <= end {
+		if blk.written[j].begin < begin {
+			begin = blk.written[j].begin
+		}
+		if blk.written[j].end > end {
+			end = blk.written[j].end
+		}
+		j++
+	}
+
+	merged := writeInterval{begin: begin, end: end}
+	if i == j {
+		blk.written = append(blk.written, writeInterval{})
+		copy(blk.written[i+1:], blk.written[i:])
+		blk.written[i] = merged
+		return
+	}
+	blk.written[i] = merged
+	blk.written = append(blk.written[:i+1], blk.written[j:]...)
+}
+
+// full 区块是否已写满
+func (blk *writeBlock) full(blockSize int64) bool {
+	return len(blk.written) == 1 && blk.written[0].begin == 0 && blk.written[0].end == blockSize
+}
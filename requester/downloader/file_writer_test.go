@@ -0,0 +1,78 @@
+package downloader_test
+
+import (
+	"bytes"
+	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestFileWriter(t *testing.T) {
+	data := make([]byte, 3*1024*1024+1234)
+	rand.Read(data)
+
+	for _, opt := range []*downloader.FileWriterOptions{
+		{},
+		{BlockSize: 64 * 1024},
+		{DirectIO: true},
+	} {
+		f, err := ioutil.TempFile("", "filewriter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		var (
+			fw    = downloader.NewFileWriter(f, opt)
+			wg    sync.WaitGroup
+			parts = 7
+			size  = len(data) / parts
+		)
+		for i := 0; i < parts; i++ {
+			begin, end := i*size, (i+1)*size
+			if i == parts-1 {
+				end = len(data)
+			}
+			wg.Add(1)
+			go func(begin, end int) {
+				defer wg.Done()
+				for begin < end {
+					n := 1000 + rand.Intn(8000)
+					if begin+n > end {
+						n = end - begin
+					}
+					_, err := fw.WriteAt(data[begin:begin+n], int64(begin))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					begin += n
+				}
+			}(begin, end)
+		}
+		wg.Wait()
+
+		if opt.BlockSize > 0 && len(fw.PendingRanges()) == 0 {
+			t.Errorf("%+v: expected pending ranges before flush", opt)
+		}
+		err = fw.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fw.PendingRanges()) != 0 {
+			t.Errorf("%+v: pending ranges after flush: %v", opt, fw.PendingRanges())
+		}
+		f.Close()
+
+		written, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, data) {
+			t.Errorf("%+v: data mismatch", opt)
+		}
+	}
+}
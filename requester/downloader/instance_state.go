@@ -4,7 +4,6 @@ import (
 	"errors"
 	"github.com/golang/protobuf/proto"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/cachepool"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
 	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
 	"github.com/json-iterator/go"
 	"os"
@@ -89,7 +88,7 @@ func (is *InstanceState) Get() (eii *transfer.DownloadInstanceInfo) {
 	}
 
 	if err != nil {
-		pcsverbose.Verbosef("DEBUG: InstanceInfo unmarshal error: %s\n", err)
+		downloaderVerbose.Warn("instance state unmarshal failed", "err", err)
 		return
 	}
 
@@ -126,12 +125,12 @@ func (is *InstanceState) Put(eii *transfer.DownloadInstanceInfo) {
 
 	err = is.saveFile.Truncate(int64(len(data)))
 	if err != nil {
-		pcsverbose.Verbosef("DEBUG: truncate file error: %s\n", err)
+		downloaderVerbose.Warn("truncate instance state file failed", "err", err)
 	}
 
 	_, err = is.saveFile.WriteAt(data, 0)
 	if err != nil {
-		pcsverbose.Verbosef("DEBUG: write instance state error: %s\n", err)
+		downloaderVerbose.Warn("write instance state failed", "err", err)
 	}
 }
 
@@ -0,0 +1,31 @@
+package downloader
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/metrics"
+	"strconv"
+	"sync/atomic"
+)
+
+var (
+	downloadBytesTotal = metrics.NewCounter("baidupcs_download_bytes_total", "下载的总字节数")
+	workerSpeedBytes   = metrics.NewGaugeVec("baidupcs_download_worker_speed_bytes", "下载线程每秒的速度", "download", "worker")
+
+	downloadSeq int64
+)
+
+// nextDownloadID 生成下载任务的标识, 用于区分监控指标
+func nextDownloadID() string {
+	return strconv.FormatInt(atomic.AddInt64(&downloadSeq, 1), 10)
+}
+
+// updateWorkerSpeedsMetrics 更新下载线程速度的监控指标
+func (mt *Monitor) updateWorkerSpeedsMetrics() {
+	for _, worker := range mt.workers {
+		workerSpeedBytes.WithLabelValues(mt.metricsID, strconv.Itoa(worker.ID())).Set(float64(worker.GetSpeedsPerSecond()))
+	}
+}
+
+// clearWorkerSpeedsMetrics 下载结束, 清除下载线程速度的监控指标
+func (mt *Monitor) clearWorkerSpeedsMetrics() {
+	workerSpeedBytes.DeletePartialMatch("download", mt.metricsID)
+}
@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MirrorMaxFailures 镜像连续出错的最大次数, 超过后不再使用该镜像
+	MirrorMaxFailures = 3
+	// MirrorRefreshInterval 刷新镜像的最小时间间隔
+	MirrorRefreshInterval = 30 * time.Second
+)
+
+var (
+	// ErrMirrorRefreshTooFrequent 刷新镜像过于频繁
+	ErrMirrorRefreshTooFrequent = errors.New("mirror refresh too frequent")
+	// ErrMirrorRefreshEmpty 刷新镜像没有获取到下载链接
+	ErrMirrorRefreshEmpty = errors.New("mirror refresh returned no urls")
+)
+
+type (
+	// Mirror 下载镜像, 统计每个镜像的吞吐量和错误
+	Mirror struct {
+		URL     string
+		Referer string
+
+		downloaded     int64 // 已下载的数据量, 原子操作
+		workers        int32 // 正在使用该镜像的线程数, 原子操作
+		failures       int32 // 连续出错的次数, 原子操作
+		disabled       int32 // 是否已停用, 原子操作
+		lastDownloaded int64
+		speeds         float64 // 每个线程的平均速度, 指数加权
+		measured       bool    // 是否已统计过速度
+	}
+
+	// MirrorRefreshFunc 刷新镜像的函数, 用于重新获取过期的下载链接
+	MirrorRefreshFunc func() (urls []string, err error)
+
+	// MirrorPool 镜像池, 为线程分配吞吐量最高的镜像, 停用出错的镜像,
+	// 所有镜像都停用时, 重新获取下载链接
+	MirrorPool struct {
+		mirrors     []*Mirror
+		cursor      int
+		refreshFunc MirrorRefreshFunc
+		lastUpdate  time.Time
+		lastRefresh time.Time
+		mu          sync.Mutex
+	}
+)
+
+// NewMirrorPool 初始化镜像池
+func NewMirrorPool(lbrl *LoadBalancerResponseList) *MirrorPool {
+	mp := &MirrorPool{
+		mirrors:    make([]*Mirror, 0, len(lbrl.lbr)),
+		lastUpdate: time.Now(),
+	}
+	for _, lbr := range lbrl.lbr {
+		mp.mirrors = append(mp.mirrors, &Mirror{
+			URL:     lbr.URL,
+			Referer: lbr.Referer,
+		})
+	}
+	return mp
+}
+
+// addDownloaded 增加已下载的数据量
+func (m *Mirror) addDownloaded(n int64) {
+	atomic.AddInt64(&m.downloaded, n)
+}
+
+// succeed 请求成功, 清空连续出错的次数
+func (m *Mirror) succeed() {
+	atomic.StoreInt32(&m.failures, 0)
+}
+
+// fail 请求出错, forbidden 为 true 时 (如403, 链接可能已过期), 马上停用
+func (m *Mirror) fail(forbidden bool) {
+	if forbidden || atomic.AddInt32(&m.failures, 1) >= MirrorMaxFailures {
+		if atomic.CompareAndSwapInt32(&m.disabled, 0, 1) {
+			downloaderVerbose.Debug("mirror disabled", "mirror", m.URL, "forbidden", forbidden)
+		}
+	}
+}
+
+// Disabled 是否已停用
+func (m *Mirror) Disabled() bool {
+	return atomic.LoadInt32(&m.disabled) == 1
+}
+
+// SetRefreshFunc 设置刷新镜像的函数
+func (mp *MirrorPool) SetRefreshFunc(f MirrorRefreshFunc) {
+	mp.refreshFunc = f
+}
+
+// SequentialGet 顺序获取未停用的镜像, 用于初始分配
+func (mp *MirrorPool) SequentialGet() *Mirror {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	for i := 0; i < len(mp.mirrors); i++ {
+		m := mp.mirrors[mp.cursor%len(mp.mirrors)]
+		mp.cursor++
+		if !m.Disabled() {
+			return m
+		}
+	}
+	return nil
+}
+
+// Select 选择预计速度最快的镜像, 优先选择未统计过速度的镜像,
+// 所有镜像都已停用时, 尝试刷新镜像, 刷新失败或过于频繁时返回 nil
+func (mp *MirrorPool) Select() *Mirror {
+	mp.mu.Lock()
+	best := mp.selectLocked()
+	mp.mu.Unlock()
+	if best != nil {
+		return best
+	}
+
+	err := mp.Refresh()
+	if err != nil {
+		downloaderVerbose.Debug("mirror refresh failed", "err", err)
+		return nil
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.selectLocked()
+}
+
+func (mp *MirrorPool) selectLocked() (best *Mirror) {
+	var bestScore float64
+	for _, m := range mp.mirrors {
+		if m.Disabled() {
+			continue
+		}
+
+		// 按每个线程的平均速度评分, 线程多的镜像略微降低优先级, 使线程分散到速度相近的镜像
+		workers := float64(atomic.LoadInt32(&m.workers))
+		score := m.speeds / (1 + 0.1*workers)
+		if !m.measured {
+			// 未统计过速度, 线程越少越优先
+			score = 1e18 / (workers + 1)
+		}
+		if best == nil || score > bestScore {
+			best, bestScore = m, score
+		}
+	}
+	return
+}
+
+// UpdateSpeeds 更新各个镜像的速度, 由监控器定时调用
+func (mp *MirrorPool) UpdateSpeeds() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	elapsed := time.Since(mp.lastUpdate).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	mp.lastUpdate = time.Now()
+
+	for _, m := range mp.mirrors {
+		var (
+			downloaded = atomic.LoadInt64(&m.downloaded)
+			workers    = atomic.LoadInt32(&m.workers)
+			delta      = downloaded - m.lastDownloaded
+		)
+		m.lastDownloaded = downloaded
+		if workers <= 0 {
+			continue
+		}
+
+		speeds := float64(delta) / elapsed / float64(workers)
+		if m.measured {
+			m.speeds = m.speeds*0.7 + speeds*0.3
+		} else {
+			m.speeds = speeds
+			m.measured = true
+		}
+	}
+}
+
+// Refresh 重新获取下载链接, 替换所有的镜像, 已下载的进度不受影响
+func (mp *MirrorPool) Refresh() error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if mp.refreshFunc == nil {
+		return ErrMirrorRefreshEmpty
+	}
+	if time.Since(mp.lastRefresh) < MirrorRefreshInterval {
+		return ErrMirrorRefreshTooFrequent
+	}
+	mp.lastRefresh = time.Now()
+
+	urls, err := mp.refreshFunc()
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return ErrMirrorRefreshEmpty
+	}
+
+	mirrors := make([]*Mirror, 0, len(urls))
+	for _, u := range urls {
+		mirrors = append(mirrors, &Mirror{
+			URL: u,
+		})
+	}
+	mp.mirrors = mirrors
+	downloaderVerbose.Debug("mirrors refreshed", "num", len(mirrors))
+	return nil
+}
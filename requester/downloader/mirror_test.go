@@ -0,0 +1,187 @@
+package downloader
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestMirrorPool(urls ...string) *MirrorPool {
+	mp := &MirrorPool{
+		lastUpdate: time.Now(),
+	}
+	for _, u := range urls {
+		mp.mirrors = append(mp.mirrors, &Mirror{
+			URL: u,
+		})
+	}
+	return mp
+}
+
+func TestMirrorFail(t *testing.T) {
+	m := &Mirror{URL: "a"}
+	for i := 1; i < MirrorMaxFailures; i++ {
+		m.fail(false)
+	}
+	if m.Disabled() {
+		t.Fatalf("disabled before %d failures", MirrorMaxFailures)
+	}
+
+	// 成功后清空连续出错的次数
+	m.succeed()
+	for i := 1; i < MirrorMaxFailures; i++ {
+		m.fail(false)
+	}
+	if m.Disabled() {
+		t.Fatalf("failures not cleared after succeed")
+	}
+	m.fail(false)
+	if !m.Disabled() {
+		t.Fatalf("not disabled after %d failures", MirrorMaxFailures)
+	}
+
+	// 403 等马上停用
+	m = &Mirror{URL: "b"}
+	m.fail(true)
+	if !m.Disabled() {
+		t.Fatalf("not disabled when forbidden")
+	}
+}
+
+func TestMirrorPoolSelect(t *testing.T) {
+	mp := newTestMirrorPool("a", "b", "c")
+	a, b, c := mp.mirrors[0], mp.mirrors[1], mp.mirrors[2]
+
+	// 未统计过速度的镜像优先, 线程越少越优先
+	a.measured, a.speeds = true, 1000
+	b.workers = 2
+	if m := mp.Select(); m != c {
+		t.Fatalf("select %s, want c", m.URL)
+	}
+
+	// 按每个线程的平均速度评分
+	b.measured, b.speeds = true, 2000
+	c.measured, c.speeds = true, 500
+	if m := mp.Select(); m != b {
+		t.Fatalf("select %s, want b", m.URL)
+	}
+
+	// 线程多的镜像降低优先级
+	b.workers = 20
+	if m := mp.Select(); m != a {
+		t.Fatalf("select %s, want a", m.URL)
+	}
+
+	// 跳过已停用的镜像
+	a.fail(true)
+	if m := mp.Select(); m != b {
+		t.Fatalf("select %s, want b", m.URL)
+	}
+}
+
+func TestMirrorPoolUpdateSpeeds(t *testing.T) {
+	mp := newTestMirrorPool("a", "b")
+	a, b := mp.mirrors[0], mp.mirrors[1]
+	a.workers = 2
+	a.addDownloaded(2000)
+	b.addDownloaded(1000) // 没有线程使用, 不统计
+
+	mp.lastUpdate = time.Now().Add(-time.Second)
+	mp.UpdateSpeeds()
+	if !a.measured || a.speeds < 900 || a.speeds > 1000 {
+		t.Fatalf("a: measured: %t, speeds: %f", a.measured, a.speeds)
+	}
+	if b.measured {
+		t.Fatalf("b measured without workers")
+	}
+}
+
+func TestMirrorPoolRefresh(t *testing.T) {
+	var (
+		calls      int
+		refreshErr error
+		urls       = []string{"x", "y"}
+	)
+	mp := newTestMirrorPool("a")
+	if err := mp.Refresh(); err != ErrMirrorRefreshEmpty {
+		t.Fatalf("refresh without func: %v", err)
+	}
+	mp.SetRefreshFunc(func() ([]string, error) {
+		calls++
+		return urls, refreshErr
+	})
+
+	// 所有镜像都停用时, 刷新镜像
+	mp.mirrors[0].fail(true)
+	m := mp.Select()
+	if m == nil || m.URL != "x" || calls != 1 {
+		t.Fatalf("select after refresh: %v, calls: %d", m, calls)
+	}
+
+	// 刷新过于频繁时, 没有可用的镜像
+	for _, m := range mp.mirrors {
+		m.fail(true)
+	}
+	if m := mp.Select(); m != nil || calls != 1 {
+		t.Fatalf("select when refresh too frequent: %v, calls: %d", m, calls)
+	}
+	if err := mp.Refresh(); err != ErrMirrorRefreshTooFrequent {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	// 超过刷新间隔后, 可以再次刷新
+	mp.lastRefresh = time.Now().Add(-MirrorRefreshInterval)
+	if m := mp.Select(); m == nil || calls != 2 {
+		t.Fatalf("select after interval: %v, calls: %d", m, calls)
+	}
+
+	// 刷新出错, 或没有获取到下载链接
+	refreshErr = errors.New("refresh error")
+	mp.lastRefresh = time.Time{}
+	if err := mp.Refresh(); err != refreshErr {
+		t.Fatalf("refresh: %v", err)
+	}
+	refreshErr, urls = nil, nil
+	mp.lastRefresh = time.Time{}
+	if err := mp.Refresh(); err != ErrMirrorRefreshEmpty {
+		t.Fatalf("refresh: %v", err)
+	}
+}
+
+func TestMonitorAssignMirror(t *testing.T) {
+	mp := newTestMirrorPool("a")
+	mt := &Monitor{
+		mirrorPool: mp,
+	}
+	worker := NewWorker(0, "a", nil)
+	if !mt.assignMirror(worker) || worker.Mirror() != mp.mirrors[0] {
+		t.Fatalf("assign mirror failed")
+	}
+
+	// 当前镜像已停用, 且无法刷新时, 不使用已失效的链接
+	mp.mirrors[0].fail(true)
+	if mt.assignMirror(worker) {
+		t.Fatalf("assigned a disabled mirror")
+	}
+}
+
+func TestWorkerSetMirrorConcurrent(t *testing.T) {
+	mp := newTestMirrorPool("a", "b")
+	worker := NewWorker(0, "a", nil)
+
+	// 监控切换镜像时, Execute 可能正在读取下载地址
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			worker.SetMirror(mp.mirrors[i%2])
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		durl, _, mirror := worker.source()
+		if mirror != nil && durl != mirror.URL {
+			t.Fatalf("url: %s, mirror: %s", durl, mirror.URL)
+		}
+	}
+	<-done
+}
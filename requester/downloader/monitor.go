@@ -3,15 +3,26 @@ package downloader
 import (
 	"context"
 	"errors"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
 	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
 	"sort"
+	"sync"
 	"time"
 )
 
 var (
 	//ErrNoWokers no workers
 	ErrNoWokers = errors.New("no workers")
+
+	// WorkerRetryPolicy 重设出错的worker前等待的策略, 连续出错时等待时间按指数增长
+	// 按 pcserror 的错误类别判断, 不可重试的错误停止下载
+	WorkerRetryPolicy = &retry.Policy{
+		BaseDelay: 1 * time.Second,
+		MaxDelay:  30 * time.Second,
+		Jitter:    0.5,
+		Classify:  pcserror.Classify,
+	}
 )
 
 type (
@@ -23,10 +34,25 @@ type (
 		completed       chan struct{}
 		err             error
 		resetController *ResetController
-		isReloadWorker  bool //是否重载worker, 单线程模式不重载
+		isReloadWorker  bool   //是否重载worker, 单线程模式不重载
+		metricsID       string // 监控指标中的下载标识
+		mirrorPool      *MirrorPool
+		checksums       *transfer.RangeChecksumList // 已写入数据的校验值, 保存到断点信息
+		pendingWriter   PendingWriter               // 缓存写入数据的输出, 保存断点信息时, 缓存的数据视为未下载
+		adaptive        *adaptiveController // 自适应并发量, 为空则不启用
+		parked          map[*Worker]bool    // 被自适应并发量暂时停止的worker
+		workersMu       sync.RWMutex        // 监控以外的协程读取workers时加锁
 
 		// 临时变量
 		lastAvaliableIndex int
+
+		backoffs map[*Worker]*workerBackoff // 出错的worker的重设等待状态
+	}
+
+	// workerBackoff worker连续出错的次数, 以及下次重设的时间
+	workerBackoff struct {
+		failures int
+		resetAt  time.Time
 	}
 
 	// RangeWorkerFunc 遍历workers的函数
@@ -61,12 +87,23 @@ func (mt *Monitor) Append(worker *Worker) {
 	if worker == nil {
 		return
 	}
+	mt.workersMu.Lock()
 	mt.workers = append(mt.workers, worker)
+	mt.workersMu.Unlock()
 }
 
 //SetWorkers 设置workers, 此操作会覆盖原有的workers
 func (mt *Monitor) SetWorkers(workers WorkerList) {
+	mt.workersMu.Lock()
 	mt.workers = workers
+	mt.workersMu.Unlock()
+}
+
+// workersSnapshot 返回workers的副本, 用于监控以外的协程
+func (mt *Monitor) workersSnapshot() WorkerList {
+	mt.workersMu.RLock()
+	defer mt.workersMu.RUnlock()
+	return mt.workers.Duplicate()
 }
 
 //SetStatus 设置DownloadStatus
@@ -79,6 +116,39 @@ func (mt *Monitor) SetInstanceState(instanceState *InstanceState) {
 	mt.instanceState = instanceState
 }
 
+//SetRangeChecksumList 设置校验值列表
+func (mt *Monitor) SetRangeChecksumList(checksums *transfer.RangeChecksumList) {
+	mt.checksums = checksums
+}
+
+//SetPendingWriter 设置缓存写入数据的输出
+func (mt *Monitor) SetPendingWriter(pw PendingWriter) {
+	mt.pendingWriter = pw
+}
+
+//SetMirrorPool 设置镜像池, 重设或分配新range时, 为worker选择速度最快的镜像
+func (mt *Monitor) SetMirrorPool(mirrorPool *MirrorPool) {
+	mt.mirrorPool = mirrorPool
+}
+
+// assignMirror 为worker分配镜像, 没有可用的镜像时保持不变,
+// 返回 false 表示worker当前的镜像已停用, 且没有可用的镜像
+func (mt *Monitor) assignMirror(worker *Worker) bool {
+	if mt.mirrorPool == nil {
+		return true
+	}
+	m := mt.mirrorPool.Select()
+	if m == nil {
+		current := worker.Mirror()
+		return current == nil || !current.Disabled()
+	}
+	if m != worker.Mirror() {
+		downloaderVerbose.Debug("worker use mirror", "worker", worker.ID(), "mirror", m.URL)
+	}
+	worker.SetMirror(m)
+	return true
+}
+
 //Status 返回DownloadStatus
 func (mt *Monitor) Status() *transfer.DownloadStatus {
 	return mt.status
@@ -117,6 +187,43 @@ func (mt *Monitor) GetAllWorkersRange() transfer.RangeList {
 	return allWorkerRanges
 }
 
+// instanceRanges 保存到断点信息的剩余范围, 包括已写入但还未写入文件的数据
+func (mt *Monitor) instanceRanges() transfer.RangeList {
+	ranges := mt.GetAllWorkersRange()
+	if mt.pendingWriter == nil {
+		return ranges
+	}
+	pending := mt.pendingWriter.PendingRanges()
+	if len(pending) == 0 {
+		return ranges
+	}
+
+	// 复制, 合并相邻或重叠的范围
+	all := make(transfer.RangeList, 0, len(ranges)+len(pending))
+	for _, r := range ranges {
+		if r.Len() > 0 {
+			all = append(all, &transfer.Range{Begin: r.LoadBegin(), End: r.LoadEnd()})
+		}
+	}
+	all = append(all, pending...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Begin < all[j].Begin
+	})
+
+	merged := all[:1]
+	for _, r := range all[1:] {
+		last := merged[len(merged)-1]
+		if r.Begin <= last.End {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
 //NumLeftWorkers 剩余的worker数量
 func (mt *Monitor) NumLeftWorkers() (num int) {
 	for _, worker := range mt.workers {
@@ -152,7 +259,7 @@ func (mt *Monitor) IsLeftWorkersAllFailed() bool {
 func (mt *Monitor) registerAllCompleted() {
 	mt.completed = make(chan struct{}, 0)
 	var (
-		workerNum   = len(mt.workers)
+		workerNum   int
 		completeNum = 0
 	)
 
@@ -160,8 +267,11 @@ func (mt *Monitor) registerAllCompleted() {
 		for {
 			time.Sleep(1 * time.Second)
 
+			// 自适应并发量会增加worker
+			workers := mt.workersSnapshot()
+			workerNum = len(workers)
 			completeNum = 0
-			for _, worker := range mt.workers {
+			for _, worker := range workers {
 				switch worker.GetStatus().StatusCode() {
 				case StatusCodeInternalError:
 					// 检测到内部错误
@@ -186,32 +296,80 @@ func (mt *Monitor) registerAllCompleted() {
 
 //ResetFailedAndNetErrorWorkers 重设部分网络错误的worker
 func (mt *Monitor) ResetFailedAndNetErrorWorkers() {
+	if mt.backoffs == nil {
+		mt.backoffs = map[*Worker]*workerBackoff{}
+	}
+
+	now := time.Now()
 	for k := range mt.workers {
-		if !mt.resetController.CanReset() {
+		if !mt.resetController.CanReset() || mt.isParked(mt.workers[k]) {
 			continue
 		}
 
 		switch mt.workers[k].GetStatus().StatusCode() {
 		case StatusCodeNetError:
-			pcsverbose.Verbosef("DEBUG: monitor: ResetFailedAndNetErrorWorkers: reset StatusCodeNetError worker, id: %d\n", mt.workers[k].id)
+			if mt.stopIfNotRetryable(mt.workers[k]) || !mt.backoffElapsed(mt.workers[k], now) {
+				continue
+			}
+			downloaderVerbose.Debug("reset net error worker", "worker", mt.workers[k].id)
 			goto reset
 		case StatusCodeFailed:
-			pcsverbose.Verbosef("DEBUG: monitor: ResetFailedAndNetErrorWorkers: reset StatusCodeFailed worker, id: %d\n", mt.workers[k].id)
+			if mt.stopIfNotRetryable(mt.workers[k]) || !mt.backoffElapsed(mt.workers[k], now) {
+				continue
+			}
+			downloaderVerbose.Debug("reset failed worker", "worker", mt.workers[k].id)
 			goto reset
+		case StatusCodeDownloading:
+			// 恢复下载, 清除连续出错的次数
+			delete(mt.backoffs, mt.workers[k])
+			continue
 		default:
 			continue
 		}
 
 	reset:
+		if !mt.assignMirror(mt.workers[k]) {
+			// 镜像都已停用, 等待刷新下载链接, 不使用已失效的链接重试
+			downloaderVerbose.Debug("no mirror available, wait for refresh", "worker", mt.workers[k].id)
+			continue
+		}
+		mt.backoffs[mt.workers[k]].resetAt = time.Time{}
 		mt.workers[k].Reset()
 		mt.resetController.AddResetNum()
 	}
 }
 
+// backoffElapsed 出错的worker是否已等待足够的时间, 可以重设.
+// 第一次检测到出错时, 按连续出错的次数计算等待时间
+func (mt *Monitor) backoffElapsed(worker *Worker, now time.Time) bool {
+	b, ok := mt.backoffs[worker]
+	if !ok {
+		b = &workerBackoff{}
+		mt.backoffs[worker] = b
+	}
+	if b.resetAt.IsZero() {
+		b.failures++
+		b.resetAt = now.Add(WorkerRetryPolicy.Backoff(b.failures, WorkerRetryPolicy.ClassOf(worker.Err())))
+	}
+	return !now.Before(b.resetAt)
+}
+
+// stopIfNotRetryable worker的错误不可重试时, 设为内部错误, 停止下载
+func (mt *Monitor) stopIfNotRetryable(worker *Worker) bool {
+	class := WorkerRetryPolicy.ClassOf(worker.Err())
+	if class.Retryable() {
+		return false
+	}
+	downloaderVerbose.Warn("worker err not retryable, stop download", "worker", worker.id, "class", class, "err", worker.Err())
+	worker.status.SetStatusCode(StatusCodeInternalError)
+	return true
+}
+
 //RangeWorker 遍历worker
 func (mt *Monitor) RangeWorker(f RangeWorkerFunc) {
-	for k := range mt.workers {
-		if !f(k, mt.workers[k]) {
+	workers := mt.workersSnapshot()
+	for k := range workers {
+		if !f(k, workers[k]) {
 			break
 		}
 	}
@@ -241,7 +399,7 @@ func (mt *Monitor) TryAddNewWork() {
 		return
 	}
 
-	if !mt.resetController.CanReset() { //能否建立新连接
+	if !mt.resetController.CanReset() || !mt.canStartWorker() { //能否建立新连接
 		return
 	}
 
@@ -259,15 +417,16 @@ func (mt *Monitor) TryAddNewWork() {
 
 	availableWorker.SetRange(r)
 	availableWorker.ClearStatus()
+	mt.assignMirror(availableWorker)
 
 	mt.resetController.AddResetNum()
-	pcsverbose.Verbosef("MONITER: worker[%d] add new range: %s\n", availableWorker.ID(), r.ShowDetails())
+	downloaderVerbose.Debug("worker add new range", "worker", availableWorker.ID(), "range", r.ShowDetails())
 	go availableWorker.Execute()
 }
 
 // DynamicSplitWorker 动态分配线程
 func (mt *Monitor) DynamicSplitWorker(worker *Worker) {
-	if !mt.resetController.CanReset() {
+	if !mt.resetController.CanReset() || !mt.canStartWorker() {
 		return
 	}
 
@@ -300,9 +459,10 @@ func (mt *Monitor) DynamicSplitWorker(worker *Worker) {
 	availableWorker.ClearStatus()
 
 	workerRange.StoreEnd(middle)
+	mt.assignMirror(availableWorker)
 
 	mt.resetController.AddResetNum()
-	pcsverbose.Verbosef("MONITOR: worker duplicated: %d <- %d\n", availableWorker.ID(), worker.ID())
+	downloaderVerbose.Debug("worker duplicated", "worker", availableWorker.ID(), "from", worker.ID())
 	go availableWorker.Execute()
 }
 
@@ -312,7 +472,7 @@ func (mt *Monitor) ResetWorker(worker *Worker) {
 		return
 	}
 
-	if worker.Completed() {
+	if worker.Completed() || mt.isParked(worker) {
 		return
 	}
 
@@ -337,11 +497,24 @@ func (mt *Monitor) ResetWorker(worker *Worker) {
 	mt.resetController.AddResetNum()
 
 	// 重设连接
-	pcsverbose.Verbosef("MONITOR: worker[%d] reload\n", worker.ID())
+	downloaderVerbose.Debug("worker reload", "worker", worker.ID())
+	mt.assignMirror(worker)
 	worker.Reset()
 }
 
-//Execute 执行任务
+// saveInstanceState 保存断点信息到文件
+func (mt *Monitor) saveInstanceState() {
+	if mt.instanceState == nil {
+		return
+	}
+	mt.instanceState.Put(&transfer.DownloadInstanceInfo{
+		DownloadStatus: mt.status,
+		Ranges:         mt.instanceRanges(),
+		Checksums:      mt.checksums,
+	})
+}
+
+//Execute 执行任务, cancelCtx 取消时, 保存断点信息, Err 返回 cancelCtx.Err()
 func (mt *Monitor) Execute(cancelCtx context.Context) {
 	if len(mt.workers) == 0 {
 		mt.err = ErrNoWokers
@@ -358,6 +531,9 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 	ticker := time.NewTicker(990 * time.Millisecond)
 	defer ticker.Stop()
 
+	mt.metricsID = nextDownloadID()
+	defer mt.clearWorkerSpeedsMetrics()
+
 	//开始监控
 	for {
 		select {
@@ -365,9 +541,12 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 			for _, worker := range mt.workers {
 				err := worker.Cancel()
 				if err != nil {
-					pcsverbose.Verbosef("DEBUG: cancel failed, worker id: %d, err: %s\n", worker.ID(), err)
+					downloaderVerbose.Debug("cancel worker failed", "worker", worker.ID(), "err", err)
 				}
 			}
+			// 保存断点信息, 以便下次继续下载
+			mt.saveInstanceState()
+			mt.err = cancelCtx.Err()
 			return
 		case <-mt.completed:
 			return
@@ -376,14 +555,16 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 			mt.ResetFailedAndNetErrorWorkers()
 
 			mt.status.UpdateSpeeds() // 更新速度
+			if mt.mirrorPool != nil {
+				mt.mirrorPool.UpdateSpeeds()
+			}
+			mt.updateWorkerSpeedsMetrics()
 
 			// 保存断点信息到文件
-			if mt.instanceState != nil {
-				mt.instanceState.Put(&transfer.DownloadInstanceInfo{
-					DownloadStatus: mt.status,
-					Ranges:         mt.GetAllWorkersRange(),
-				})
-			}
+			mt.saveInstanceState()
+
+			// 调整并发量
+			mt.adjustParallel()
 
 			// 加入新range
 			mt.TryAddNewWork()
@@ -401,20 +582,22 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 			isLeftWorkersAllFailed := mt.IsLeftWorkersAllFailed()
 			if mt.status.SpeedsPerSecond() < mt.status.MaxSpeeds()/6 || isLeftWorkersAllFailed {
 				if isLeftWorkersAllFailed {
-					pcsverbose.Verbosef("DEBUG: monitor: All workers failed\n")
+					downloaderVerbose.Warn("all workers failed")
 				}
 				mt.status.ClearMaxSpeeds() //清空最大速度的统计
 
 				// 先进行动态分配线程
-				pcsverbose.Verbosef("DEBUG: monitor: start duplicate.\n")
+				downloaderVerbose.Debug("monitor start duplicate")
+				mt.workersMu.Lock()
 				sort.Sort(ByLeftDesc{mt.workers})
+				mt.workersMu.Unlock()
 				for _, worker := range mt.workers {
 					//动态分配线程
 					mt.DynamicSplitWorker(worker)
 				}
 
 				// 重设长时间无响应, 和下载速度为 0 的线程
-				pcsverbose.Verbosef("DEBUG: monitor: start reload.\n")
+				downloaderVerbose.Debug("monitor start reload")
 				for _, worker := range mt.workers {
 					mt.ResetWorker(worker)
 				}
@@ -0,0 +1,157 @@
+package downloader
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	// DefaultSequentialBufferSize 默认的重排缓冲区大小
+	DefaultSequentialBufferSize = 16 * 1024 * 1024
+)
+
+var (
+	// ErrSequentialWriterClosed SequentialWriter 已关闭
+	ErrSequentialWriterClosed = errors.New("sequential writer closed")
+)
+
+type (
+	// SequentialWriter 将多个线程乱序写入的数据, 按顺序输出到 io.Writer.
+	// 未能按顺序输出的数据暂存在缓冲区中, 缓冲区已满时, 阻塞非队首的写入,
+	// 队首的写入不会阻塞, 所以不会死锁.
+	SequentialWriter struct {
+		w           io.Writer
+		offset      int64            // 已输出数据的结束位置
+		pending     map[int64][]byte // 暂存的数据, key 为偏移量
+		buffered    int
+		maxBuffered int
+		err         error
+		cond        *sync.Cond
+		mu          sync.Mutex
+	}
+)
+
+// NewSequentialWriter 初始化 SequentialWriter, begin 为第一个字节的偏移量, maxBuffered 为重排缓冲区大小
+func NewSequentialWriter(w io.Writer, begin int64, maxBuffered int) *SequentialWriter {
+	if maxBuffered <= 0 {
+		maxBuffered = DefaultSequentialBufferSize
+	}
+	sw := &SequentialWriter{
+		w:           w,
+		offset:      begin,
+		pending:     map[int64][]byte{},
+		maxBuffered: maxBuffered,
+	}
+	sw.cond = sync.NewCond(&sw.mu)
+	return sw
+}
+
+// WriteAt 实现 io.WriterAt 接口
+func (sw *SequentialWriter) WriteAt(p []byte, off int64) (n int, err error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	n = len(p)
+	for {
+		if sw.err != nil {
+			return 0, sw.err
+		}
+
+		// 去掉已输出的部分
+		if off < sw.offset {
+			skip := sw.offset - off
+			if skip >= int64(len(p)) {
+				return n, nil
+			}
+			p, off = p[skip:], sw.offset
+		}
+
+		if off == sw.offset || sw.buffered+len(p) <= sw.maxBuffered {
+			break
+		}
+		sw.cond.Wait()
+	}
+
+	if off != sw.offset {
+		// p 会被调用者复用, 需要复制
+		if old, ok := sw.pending[off]; ok {
+			if len(old) >= len(p) {
+				return n, nil
+			}
+			sw.buffered -= len(old)
+		}
+		sw.pending[off] = append([]byte(nil), p...)
+		sw.buffered += len(p)
+		return n, nil
+	}
+
+	sw.output(p)
+	sw.flushPending()
+	sw.cond.Broadcast()
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	return n, nil
+}
+
+func (sw *SequentialWriter) output(p []byte) {
+	if sw.err != nil {
+		return
+	}
+	written, err := sw.w.Write(p)
+	sw.offset += int64(written)
+	if err == nil && written < len(p) {
+		err = io.ErrShortWrite
+	}
+	sw.err = err
+}
+
+// flushPending 输出缓冲区中可以连续输出的数据
+func (sw *SequentialWriter) flushPending() {
+	for found := true; found && sw.err == nil; {
+		found = false
+		for off, buf := range sw.pending {
+			end := off + int64(len(buf))
+			if off > sw.offset {
+				continue
+			}
+
+			delete(sw.pending, off)
+			sw.buffered -= len(buf)
+			if end > sw.offset {
+				sw.output(buf[sw.offset-off:])
+				found = true
+			}
+		}
+	}
+}
+
+// Offset 返回已输出数据的结束位置
+func (sw *SequentialWriter) Offset() int64 {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.offset
+}
+
+// CloseWithError 关闭, 之后的写入都返回 err, 并唤醒阻塞的写入
+func (sw *SequentialWriter) CloseWithError(err error) error {
+	if err == nil {
+		err = ErrSequentialWriterClosed
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.err == nil {
+		sw.err = err
+	}
+	sw.pending = map[int64][]byte{}
+	sw.buffered = 0
+	sw.cond.Broadcast()
+	return nil
+}
+
+// Close 关闭
+func (sw *SequentialWriter) Close() error {
+	return sw.CloseWithError(nil)
+}
@@ -0,0 +1,69 @@
+package downloader_test
+
+import (
+	"bytes"
+	"github.com/felixonmars/BaiduPCS-Go/requester/downloader"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestSequentialWriter(t *testing.T) {
+	data := make([]byte, 64*1024)
+	rand.Read(data)
+
+	var (
+		out   bytes.Buffer
+		sw    = downloader.NewSequentialWriter(&out, 1000, 4096)
+		wg    sync.WaitGroup
+		parts = 8
+		size  = (len(data) - 1000) / parts
+	)
+	for i := 0; i < parts; i++ {
+		begin, end := 1000+i*size, 1000+(i+1)*size
+		if i == parts-1 {
+			end = len(data)
+		}
+		wg.Add(1)
+		go func(begin, end int) {
+			defer wg.Done()
+			for begin < end {
+				n := rand.Intn(700) + 1
+				if begin+n > end {
+					n = end - begin
+				}
+				buf := append([]byte(nil), data[begin:begin+n]...)
+				_, err := sw.WriteAt(buf, int64(begin))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for k := range buf { // 模拟复用缓冲区
+					buf[k] = 0
+				}
+				begin += n
+			}
+		}(begin, end)
+	}
+	wg.Wait()
+
+	if !bytes.Equal(out.Bytes(), data[1000:]) {
+		t.Fatalf("output mismatch, got %d bytes", out.Len())
+	}
+	if sw.Offset() != int64(len(data)) {
+		t.Fatalf("offset: %d", sw.Offset())
+	}
+}
+
+func TestSequentialWriterClose(t *testing.T) {
+	sw := downloader.NewSequentialWriter(&bytes.Buffer{}, 0, 10)
+	errCh := make(chan error)
+	go func() {
+		_, err := sw.WriteAt(make([]byte, 20), 100) // 缓冲区已满, 阻塞
+		errCh <- err
+	}()
+	sw.Close()
+	if err := <-errCh; err != downloader.ErrSequentialWriterClosed {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -1,7 +1,6 @@
 package downloader
 
 import (
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	mathrand "math/rand"
 	"mime"
@@ -47,7 +46,7 @@ func GetFileName(uri string, client *requester.HTTPClient) (filename string, err
 
 	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
 	if err != nil {
-		pcsverbose.Verbosef("DEBUG: GetFileName ParseMediaType error: %s\n", err)
+		downloaderVerbose.Debug("GetFileName parse media type failed", "err", err)
 		return path.Base(uri), nil
 	}
 
@@ -5,13 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/cachepool"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"github.com/felixonmars/BaiduPCS-Go/requester/rio/speeds"
 	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
 	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
 )
 
 type (
@@ -23,12 +23,15 @@ type (
 		id           int    //id
 		url          string //下载地址
 		referer      string //来源地址
+		mirror       *Mirror
+		mirrorMu     sync.Mutex // 保护 url, referer, mirror, 监控切换镜像时, Execute 可能正在读取
 		acceptRanges string
 		client       *requester.HTTPClient
 		firstResp    *http.Response // 第一个响应
 		writerAt     io.WriterAt
 		writeMu      *sync.Mutex
 		execMu       sync.Mutex
+		checksums    *transfer.RangeChecksumList // 记录已写入数据的校验值
 
 		pauseChan              chan struct{}
 		workerCancelFunc       context.CancelFunc
@@ -111,7 +114,44 @@ func (wer *Worker) SetRange(r *transfer.Range) {
 
 //SetReferer 设置来源
 func (wer *Worker) SetReferer(referer string) {
+	wer.mirrorMu.Lock()
 	wer.referer = referer
+	wer.mirrorMu.Unlock()
+}
+
+//SetMirror 设置下载镜像, 同时设置下载地址和来源地址
+func (wer *Worker) SetMirror(m *Mirror) {
+	wer.mirrorMu.Lock()
+	defer wer.mirrorMu.Unlock()
+	if m == nil || m == wer.mirror {
+		return
+	}
+	if wer.mirror != nil {
+		atomic.AddInt32(&wer.mirror.workers, -1)
+	}
+	atomic.AddInt32(&m.workers, 1)
+	wer.mirror = m
+	wer.url = m.URL
+	wer.referer = m.Referer
+}
+
+//Mirror 返回正在使用的下载镜像
+func (wer *Worker) Mirror() *Mirror {
+	wer.mirrorMu.Lock()
+	defer wer.mirrorMu.Unlock()
+	return wer.mirror
+}
+
+// source 返回当前的下载地址, 来源地址和镜像, 执行任务期间使用该副本
+func (wer *Worker) source() (durl, referer string, mirror *Mirror) {
+	wer.mirrorMu.Lock()
+	defer wer.mirrorMu.Unlock()
+	return wer.url, wer.referer, wer.mirror
+}
+
+//SetRangeChecksumList 设置校验值列表, 记录已写入数据的校验值
+func (wer *Worker) SetRangeChecksumList(checksums *transfer.RangeChecksumList) {
+	wer.checksums = checksums
 }
 
 //SetWriteMutex 设置数据写锁
@@ -144,7 +184,7 @@ func (wer *Worker) GetSpeedsPerSecond() int64 {
 func (wer *Worker) Pause() {
 	wer.lazyInit()
 	if wer.acceptRanges == "" {
-		pcsverbose.Verbosef("WARNING: worker unsupport pause")
+		downloaderVerbose.Warn("worker unsupport pause", "worker", wer.id)
 		return
 	}
 
@@ -178,7 +218,7 @@ func (wer *Worker) Cancel() error {
 //Reset 重设连接
 func (wer *Worker) Reset() {
 	if wer.resetFunc == nil {
-		pcsverbose.Verbosef("DEBUG: worker: resetFunc not set")
+		downloaderVerbose.Debug("worker resetFunc not set", "worker", wer.id)
 		return
 	}
 	wer.resetFunc()
@@ -189,6 +229,16 @@ func (wer *Worker) Reset() {
 	go wer.Execute()
 }
 
+//Park 断开连接, 保留剩余的range, 不重新执行, 用于减少并发量
+func (wer *Worker) Park() {
+	if wer.resetFunc != nil {
+		wer.resetFunc()
+	}
+	if wer.readRespBodyCancelFunc != nil {
+		wer.readRespBodyCancelFunc()
+	}
+}
+
 // Canceled 是否已经取消
 func (wer *Worker) Canceled() bool {
 	return wer.status.statusCode == StatusCodeCanceled
@@ -224,6 +274,13 @@ func (wer *Worker) Err() error {
 	return wer.err
 }
 
+// mirrorFail 记录镜像出错
+func mirrorFail(mirror *Mirror, forbidden bool) {
+	if mirror != nil {
+		mirror.fail(forbidden)
+	}
+}
+
 //Execute 执行任务
 func (wer *Worker) Execute() {
 	wer.lazyInit()
@@ -243,7 +300,7 @@ func (wer *Worker) Execute() {
 		// 已完成
 		if rlen := wer.wrange.Len(); rlen <= 0 {
 			if rlen < 0 {
-				pcsverbose.Verbosef("DEBUG: RangeLen is negative at begin: %v, %d\n", wer.wrange, wer.wrange.Len())
+				downloaderVerbose.Debug("range len is negative at begin", "worker", wer.id, "range", wer.wrange, "len", wer.wrange.Len())
 			}
 			wer.status.statusCode = StatusCodeSuccessed
 			return
@@ -255,9 +312,10 @@ func (wer *Worker) Execute() {
 	resetCtx, resetFunc := context.WithCancel(context.Background())
 	wer.resetFunc = resetFunc
 
+	durl, referer, mirror := wer.source()
 	header := map[string]string{}
-	if wer.referer != "" {
-		header["Referer"] = wer.referer
+	if referer != "" {
+		header["Referer"] = referer
 	}
 	//检测是否支持range
 	if wer.acceptRanges != "" && wer.wrange.Len() >= 0 {
@@ -270,7 +328,7 @@ func (wer *Worker) Execute() {
 	if wer.firstResp != nil {
 		resp = wer.firstResp // 使用第一个连接
 	} else {
-		resp, wer.err = wer.client.Req(http.MethodGet, wer.url, nil, header)
+		resp, wer.err = wer.client.Req(http.MethodGet, durl, nil, header)
 	}
 	if resp != nil {
 		defer func() {
@@ -283,6 +341,7 @@ func (wer *Worker) Execute() {
 	}
 	if wer.err != nil {
 		wer.status.statusCode = StatusCodeNetError
+		mirrorFail(mirror, false)
 		return
 	}
 
@@ -290,13 +349,20 @@ func (wer *Worker) Execute() {
 	switch resp.StatusCode {
 	case 200, 206:
 		// do nothing, continue
+		if mirror != nil {
+			mirror.succeed()
+		}
+	case 403, 404, 410: // Forbidden, 链接可能已过期
+		wer.status.statusCode = StatusCodeNetError
+		wer.err = errors.New(resp.Status)
+		mirrorFail(mirror, true)
+		return
 	case 416: //Requested Range Not Satisfiable
 		fallthrough
-	case 403: // Forbidden
-		fallthrough
 	case 406: // Not Acceptable
 		wer.status.statusCode = StatusCodeNetError
 		wer.err = errors.New(resp.Status)
+		mirrorFail(mirror, false)
 		return
 	case 429, 509: // Too Many Requests
 		wer.status.SetStatusCode(StatusCodeTooManyConnections)
@@ -305,6 +371,7 @@ func (wer *Worker) Execute() {
 	default:
 		wer.status.statusCode = StatusCodeNetError
 		wer.err = fmt.Errorf("unexpected http status code, %d, %s", resp.StatusCode, resp.Status)
+		mirrorFail(mirror, false)
 		return
 	}
 
@@ -340,6 +407,12 @@ func (wer *Worker) Execute() {
 	)
 	defer cachepool.SyncPool.Put(buf)
 
+	var csum *rangeChecksummer
+	if wer.checksums != nil && wer.writerAt != nil {
+		csum = &rangeChecksummer{list: wer.checksums}
+		defer csum.commit()
+	}
+
 	for {
 		select {
 		case <-workerCancelCtx.Done(): //取消
@@ -367,6 +440,10 @@ func (wer *Worker) Execute() {
 					wer.downloadStatus.AddSpeedsDownloaded(nn64) // 限速在这里阻塞
 				}
 				wer.speedsStat.Add(nn64)
+				if mirror != nil {
+					mirror.addDownloaded(nn64)
+				}
+				downloadBytesTotal.Add(float64(nn64))
 				n += nn
 			}
 
@@ -413,6 +490,9 @@ func (wer *Worker) Execute() {
 				if wer.writeMu != nil {
 					wer.writeMu.Unlock() //解锁
 				}
+				if csum != nil {
+					csum.write(wer.wrange.LoadBegin(), buf[:n])
+				}
 				wer.status.statusCode = StatusCodeDownloading
 			}
 
@@ -438,7 +518,7 @@ func (wer *Worker) Execute() {
 					// 小于0可能是因为 worker 被 duplicate
 					wer.status.statusCode = StatusCodeSuccessed
 					if rlen < 0 {
-						pcsverbose.Verbosef("DEBUG: RangeLen is negative at end: %v, %d\n", wer.wrange, wer.wrange.Len())
+						downloaderVerbose.Debug("range len is negative at end", "worker", wer.id, "range", wer.wrange, "len", wer.wrange.Len())
 					}
 					return
 				default:
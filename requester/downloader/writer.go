@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"github.com/felixonmars/BaiduPCS-Go/requester/transfer"
 	"io"
 	"os"
 )
@@ -15,6 +16,14 @@ type (
 	Writer interface {
 		io.WriterAt
 	}
+
+	// PendingWriter 缓存写入数据的输出接口, 下载结束时须调用 Flush,
+	// 保存断点信息时, PendingRanges 中的数据视为未下载
+	PendingWriter interface {
+		Writer
+		Flush() error
+		PendingRanges() transfer.RangeList
+	}
 )
 
 // NewDownloaderWriterByFilename 创建下载器数据输出接口, 类似于os.OpenFile
@@ -12,6 +12,7 @@ type HTTPClient struct {
 	http.Client
 	transport *http.Transport
 	https     bool
+	proxyAddr string
 	UserAgent string
 }
 
@@ -31,7 +32,7 @@ func NewHTTPClient() *HTTPClient {
 func (h *HTTPClient) lazyInit() {
 	if h.transport == nil {
 		h.transport = &http.Transport{
-			Proxy:       proxyFunc,
+			Proxy:       h.proxyFunc,
 			DialContext: dialContext,
 			Dial:        dial,
 			// DialTLS:     h.dialTLSFunc(),
@@ -55,16 +56,12 @@ func (h *HTTPClient) SetUserAgent(ua string) {
 	h.UserAgent = ua
 }
 
-// SetProxy 设置代理
+// SetProxy 设置代理, 支持 http/https/socks5 代理及认证信息,
+// 为空则使用全局代理, DIRECT 代表直连
 func (h *HTTPClient) SetProxy(proxyAddr string) {
 	h.lazyInit()
-	u, err := checkProxyAddr(proxyAddr)
-	if err != nil {
-		h.transport.Proxy = http.ProxyFromEnvironment
-		return
-	}
-
-	h.transport.Proxy = http.ProxyURL(u)
+	h.proxyAddr = proxyAddr
+	h.transport.Proxy = h.proxyFunc
 }
 
 // SetCookiejar 设置 cookie
@@ -0,0 +1,59 @@
+package transfer
+
+import (
+	"sort"
+	"sync"
+)
+
+type (
+	// RangeChecksumList 已写入范围的校验值列表, 并发安全
+	RangeChecksumList struct {
+		checksums []*RangeChecksum
+		mu        sync.Mutex
+	}
+)
+
+// NewRangeChecksumList 初始化 RangeChecksumList, checksums 为断点信息中的校验值
+func NewRangeChecksumList(checksums []*RangeChecksum) *RangeChecksumList {
+	return &RangeChecksumList{
+		checksums: checksums,
+	}
+}
+
+// Add 增加校验值, [begin, end) 为已写入的范围
+func (rcl *RangeChecksumList) Add(begin, end int64, crc uint32) {
+	if end <= begin {
+		return
+	}
+
+	rcl.mu.Lock()
+	defer rcl.mu.Unlock()
+	rcl.checksums = append(rcl.checksums, &RangeChecksum{
+		Begin: begin,
+		End:   end,
+		Crc32: crc,
+	})
+}
+
+// List 返回按 Begin 排序的校验值列表副本
+func (rcl *RangeChecksumList) List() []*RangeChecksum {
+	if rcl == nil {
+		return nil
+	}
+
+	rcl.mu.Lock()
+	defer rcl.mu.Unlock()
+	list := make([]*RangeChecksum, len(rcl.checksums))
+	copy(list, rcl.checksums)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Begin < list[j].Begin
+	})
+	return list
+}
+
+// Len 校验值的数量
+func (rcl *RangeChecksumList) Len() int {
+	rcl.mu.Lock()
+	defer rcl.mu.Unlock()
+	return len(rcl.checksums)
+}
@@ -9,6 +9,7 @@ type (
 	DownloadInstanceInfo struct {
 		DownloadStatus *DownloadStatus
 		Ranges         RangeList
+		Checksums      *RangeChecksumList // 已写入范围的校验值
 	}
 
 	// DownloadInstanceInfoExporter 断点续传类型接口
@@ -21,7 +22,8 @@ type (
 // GetInstanceInfo 从断点信息获取下载状态
 func (m *DownloadInstanceInfoExport) GetInstanceInfo() (eii *DownloadInstanceInfo) {
 	eii = &DownloadInstanceInfo{
-		Ranges: m.Ranges,
+		Ranges:    m.Ranges,
+		Checksums: NewRangeChecksumList(m.Checksums),
 	}
 
 	var downloaded int64
@@ -63,4 +65,7 @@ func (m *DownloadInstanceInfoExport) SetInstanceInfo(eii *DownloadInstanceInfo)
 		}
 	}
 	m.Ranges = eii.Ranges
+	if eii.Checksums != nil {
+		m.Checksums = eii.Checksums.List()
+	}
 }
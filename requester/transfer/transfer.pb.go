@@ -96,23 +96,80 @@ func (m *Range) GetEnd() int64 {
 	return 0
 }
 
+// RangeChecksum 已写入范围的校验值, 用于检测和修复损坏的数据
+type RangeChecksum struct {
+	Begin                int64    `protobuf:"varint,1,opt,name=begin,proto3" json:"begin,omitempty"`
+	End                  int64    `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	Crc32                uint32   `protobuf:"varint,3,opt,name=crc32,proto3" json:"crc32,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RangeChecksum) Reset()         { *m = RangeChecksum{} }
+func (m *RangeChecksum) String() string { return proto.CompactTextString(m) }
+func (*RangeChecksum) ProtoMessage()    {}
+func (*RangeChecksum) Descriptor() ([]byte, []int) {
+	return fileDescriptor_44038b0c710d7f2f, []int{1}
+}
+
+func (m *RangeChecksum) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RangeChecksum.Unmarshal(m, b)
+}
+func (m *RangeChecksum) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RangeChecksum.Marshal(b, m, deterministic)
+}
+func (m *RangeChecksum) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RangeChecksum.Merge(m, src)
+}
+func (m *RangeChecksum) XXX_Size() int {
+	return xxx_messageInfo_RangeChecksum.Size(m)
+}
+func (m *RangeChecksum) XXX_DiscardUnknown() {
+	xxx_messageInfo_RangeChecksum.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RangeChecksum proto.InternalMessageInfo
+
+func (m *RangeChecksum) GetBegin() int64 {
+	if m != nil {
+		return m.Begin
+	}
+	return 0
+}
+
+func (m *RangeChecksum) GetEnd() int64 {
+	if m != nil {
+		return m.End
+	}
+	return 0
+}
+
+func (m *RangeChecksum) GetCrc32() uint32 {
+	if m != nil {
+		return m.Crc32
+	}
+	return 0
+}
+
 // DownloadInstanceInfoExport 断点续传
 type DownloadInstanceInfoExport struct {
-	RangeGenMode         RangeGenMode `protobuf:"varint,1,opt,name=range_gen_mode,json=rangeGenMode,proto3,enum=transfer.RangeGenMode" json:"range_gen_mode,omitempty"`
-	TotalSize            int64        `protobuf:"varint,2,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
-	GenBegin             int64        `protobuf:"varint,3,opt,name=gen_begin,json=genBegin,proto3" json:"gen_begin,omitempty"`
-	BlockSize            int64        `protobuf:"varint,4,opt,name=block_size,json=blockSize,proto3" json:"block_size,omitempty"`
-	Ranges               []*Range     `protobuf:"bytes,5,rep,name=ranges,proto3" json:"ranges,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
-	XXX_unrecognized     []byte       `json:"-"`
-	XXX_sizecache        int32        `json:"-"`
+	RangeGenMode         RangeGenMode     `protobuf:"varint,1,opt,name=range_gen_mode,json=rangeGenMode,proto3,enum=transfer.RangeGenMode" json:"range_gen_mode,omitempty"`
+	TotalSize            int64            `protobuf:"varint,2,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	GenBegin             int64            `protobuf:"varint,3,opt,name=gen_begin,json=genBegin,proto3" json:"gen_begin,omitempty"`
+	BlockSize            int64            `protobuf:"varint,4,opt,name=block_size,json=blockSize,proto3" json:"block_size,omitempty"`
+	Ranges               []*Range         `protobuf:"bytes,5,rep,name=ranges,proto3" json:"ranges,omitempty"`
+	Checksums            []*RangeChecksum `protobuf:"bytes,6,rep,name=checksums,proto3" json:"checksums,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
 }
 
 func (m *DownloadInstanceInfoExport) Reset()         { *m = DownloadInstanceInfoExport{} }
 func (m *DownloadInstanceInfoExport) String() string { return proto.CompactTextString(m) }
 func (*DownloadInstanceInfoExport) ProtoMessage()    {}
 func (*DownloadInstanceInfoExport) Descriptor() ([]byte, []int) {
-	return fileDescriptor_44038b0c710d7f2f, []int{1}
+	return fileDescriptor_44038b0c710d7f2f, []int{2}
 }
 
 func (m *DownloadInstanceInfoExport) XXX_Unmarshal(b []byte) error {
@@ -168,31 +225,42 @@ func (m *DownloadInstanceInfoExport) GetRanges() []*Range {
 	return nil
 }
 
+func (m *DownloadInstanceInfoExport) GetChecksums() []*RangeChecksum {
+	if m != nil {
+		return m.Checksums
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("transfer.RangeGenMode", RangeGenMode_name, RangeGenMode_value)
 	proto.RegisterType((*Range)(nil), "transfer.Range")
+	proto.RegisterType((*RangeChecksum)(nil), "transfer.RangeChecksum")
 	proto.RegisterType((*DownloadInstanceInfoExport)(nil), "transfer.DownloadInstanceInfoExport")
 }
 
 func init() { proto.RegisterFile("transfer/transfer.proto", fileDescriptor_44038b0c710d7f2f) }
 
 var fileDescriptor_44038b0c710d7f2f = []byte{
-	// 260 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x90, 0x41, 0x4b, 0xc3, 0x40,
-	0x10, 0x85, 0x8d, 0x31, 0xb5, 0x99, 0xd6, 0x1a, 0x16, 0xd1, 0xa0, 0x14, 0x4a, 0x2f, 0x96, 0x1e,
-	0x5a, 0xa8, 0x57, 0x4f, 0xa5, 0x22, 0x3d, 0x78, 0x89, 0x3f, 0x20, 0x6c, 0x9a, 0x49, 0x08, 0xc6,
-	0x99, 0xb2, 0x59, 0x51, 0xfa, 0x63, 0xfd, 0x2d, 0x92, 0x49, 0x23, 0xa1, 0xb7, 0x7d, 0x6f, 0x78,
-	0xef, 0x7d, 0x2c, 0xdc, 0x59, 0xa3, 0xa9, 0xca, 0xd0, 0x2c, 0xdb, 0xc7, 0x62, 0x6f, 0xd8, 0xb2,
-	0xea, 0xb7, 0x7a, 0xba, 0x04, 0x2f, 0xd2, 0x94, 0xa3, 0xba, 0x01, 0x2f, 0xc1, 0xbc, 0xa0, 0xd0,
-	0x99, 0x38, 0x33, 0x37, 0x6a, 0x84, 0x0a, 0xc0, 0x45, 0x4a, 0xc3, 0x73, 0xf1, 0xea, 0xe7, 0xf4,
-	0xd7, 0x81, 0xfb, 0x0d, 0x7f, 0x53, 0xc9, 0x3a, 0xdd, 0x52, 0x65, 0x35, 0xed, 0x70, 0x4b, 0x19,
-	0xbf, 0xfc, 0xec, 0xd9, 0x58, 0xf5, 0x0c, 0x23, 0x53, 0xf7, 0xc5, 0x39, 0x52, 0xfc, 0xc9, 0x29,
-	0x4a, 0xdf, 0x68, 0x75, 0xbb, 0xf8, 0x47, 0x90, 0xbd, 0x57, 0xa4, 0x37, 0x4e, 0x31, 0x1a, 0x9a,
-	0x8e, 0x52, 0x63, 0x00, 0xcb, 0x56, 0x97, 0x71, 0x55, 0x1c, 0xf0, 0xb8, 0xea, 0x8b, 0xf3, 0x5e,
-	0x1c, 0x50, 0x3d, 0x80, 0x5f, 0xd7, 0x36, 0x9c, 0xae, 0x5c, 0xfb, 0x39, 0xd2, 0x5a, 0x50, 0xc7,
-	0x00, 0x49, 0xc9, 0xbb, 0x8f, 0x26, 0x7b, 0xd1, 0x64, 0xc5, 0x91, 0xec, 0x23, 0xf4, 0x64, 0xaa,
-	0x0a, 0xbd, 0x89, 0x3b, 0x1b, 0xac, 0xae, 0x4f, 0x80, 0xa2, 0xe3, 0x79, 0x3e, 0x87, 0x61, 0x97,
-	0x50, 0x0d, 0xe0, 0x72, 0x83, 0x99, 0xfe, 0x2a, 0x6d, 0x70, 0xa6, 0xae, 0xc0, 0x5f, 0xb7, 0x95,
-	0x81, 0x93, 0xf4, 0xe4, 0x3b, 0x9f, 0xfe, 0x02, 0x00, 0x00, 0xff, 0xff, 0xe8, 0x5c, 0x09, 0xcc,
-	0x69, 0x01, 0x00, 0x00,
+	// 306 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x51, 0xcd, 0x6e, 0xf2, 0x30,
+	0x10, 0xfc, 0x42, 0x3e, 0x28, 0x59, 0x7e, 0x8a, 0xac, 0xaa, 0x44, 0xad, 0x90, 0x10, 0x97, 0x22,
+	0x0e, 0x20, 0x81, 0x7a, 0xeb, 0x89, 0x52, 0x55, 0x1c, 0xb8, 0xb8, 0x0f, 0x80, 0x8c, 0xb3, 0xa4,
+	0x11, 0xc1, 0x46, 0xb6, 0x51, 0x2b, 0xde, 0xa3, 0xef, 0x5b, 0x65, 0x93, 0xb4, 0x88, 0x53, 0x6f,
+	0x3b, 0xbb, 0x33, 0xde, 0x99, 0x35, 0x74, 0x9d, 0x11, 0xca, 0x6e, 0xd1, 0x4c, 0xca, 0x62, 0x7c,
+	0x30, 0xda, 0x69, 0x56, 0x2f, 0xf1, 0x60, 0x02, 0x55, 0x2e, 0x54, 0x8c, 0xec, 0x06, 0xaa, 0x1b,
+	0x8c, 0x13, 0x15, 0x7a, 0x7d, 0x6f, 0xe8, 0xf3, 0x1c, 0xb0, 0x0e, 0xf8, 0xa8, 0xa2, 0xb0, 0x42,
+	0xbd, 0xac, 0x1c, 0xac, 0xa0, 0x45, 0x82, 0xe7, 0x77, 0x94, 0x3b, 0x7b, 0xdc, 0xff, 0x55, 0x98,
+	0xf1, 0xa4, 0x91, 0xb3, 0x69, 0xe8, 0xf7, 0xbd, 0x61, 0x8b, 0xe7, 0x60, 0xf0, 0x55, 0x81, 0xbb,
+	0x85, 0xfe, 0x50, 0xa9, 0x16, 0xd1, 0x52, 0x59, 0x27, 0x94, 0xc4, 0xa5, 0xda, 0xea, 0x97, 0xcf,
+	0x83, 0x36, 0x8e, 0x3d, 0x41, 0xdb, 0x64, 0xdb, 0xd6, 0x31, 0xaa, 0xf5, 0x5e, 0x47, 0x48, 0x5b,
+	0xda, 0xd3, 0xdb, 0xf1, 0x4f, 0x22, 0x72, 0xf3, 0x8a, 0x6a, 0xa5, 0x23, 0xe4, 0x4d, 0x73, 0x86,
+	0x58, 0x0f, 0xc0, 0x69, 0x27, 0xd2, 0xb5, 0x4d, 0x4e, 0x58, 0x78, 0x09, 0xa8, 0xf3, 0x96, 0x9c,
+	0x90, 0xdd, 0x43, 0x90, 0x3d, 0x9b, 0xbb, 0xf7, 0x69, 0x5a, 0x8f, 0x51, 0xcd, 0x29, 0x40, 0x0f,
+	0x60, 0x93, 0x6a, 0xb9, 0xcb, 0xb5, 0xff, 0x73, 0x2d, 0x75, 0x48, 0xfb, 0x00, 0x35, 0x5a, 0x65,
+	0xc3, 0x6a, 0xdf, 0x1f, 0x36, 0xa6, 0xd7, 0x17, 0x86, 0x78, 0x31, 0x66, 0x8f, 0x10, 0xc8, 0xe2,
+	0x54, 0x36, 0xac, 0x11, 0xb7, 0x7b, 0xc1, 0x2d, 0x4f, 0xc9, 0x7f, 0x99, 0xa3, 0x11, 0x34, 0xcf,
+	0x83, 0xb1, 0x06, 0x5c, 0x2d, 0x70, 0x2b, 0x8e, 0xa9, 0xeb, 0xfc, 0x63, 0x2d, 0x08, 0xe6, 0xa5,
+	0x93, 0x8e, 0xb7, 0xa9, 0xd1, 0xa7, 0xce, 0xbe, 0x07, 0x00, 0x2a, 0x22, 0xbe, 0xbc, 0xef, 0x01,
+	0x00, 0x00,
 }
@@ -85,6 +85,7 @@ func (fb *fileBlock) Read(b []byte) (n int, err error) {
 
 	n64 := int64(n)
 	fb.readed += n64
+	uploadBytesTotal.Add(float64(n64))
 	if fb.rateLimit != nil {
 		fb.rateLimit.Add(n64) // 限速阻塞
 	}
@@ -0,0 +1,9 @@
+package uploader
+
+import (
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/metrics"
+)
+
+var (
+	uploadBytesTotal = metrics.NewCounter("baidupcs_upload_bytes_total", "上传的总字节数")
+)
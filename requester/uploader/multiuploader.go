@@ -49,6 +49,10 @@ type (
 		Parallel  int   // 上传并发量
 		BlockSize int64 // 上传分块
 		MaxRate   int64 // 限制最大上传速度
+
+		// FileChanged 检测本地文件自上次检测之后是否被修改,
+		// 被修改时, 校验已上传的分片, 只重新上传内容改变的分片
+		FileChanged func() bool
 	}
 )
 
@@ -101,9 +105,24 @@ func (muer *MultiUploader) check() {
 
 // Execute 执行上传
 func (muer *MultiUploader) Execute() {
+	muer.ExecuteContext(context.Background())
+}
+
+// ExecuteContext 执行上传, ctx 取消时停止上传, 并触发取消上传事件
+func (muer *MultiUploader) ExecuteContext(ctx context.Context) {
 	muer.check()
 	muer.lazyInit()
 
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			muer.Cancel()
+		case <-stopped:
+		}
+	}()
+
 	// 初始化限速
 	if muer.config.MaxRate > 0 {
 		muer.rateLimit = speeds.NewRateLimit(muer.config.MaxRate)
@@ -163,7 +182,9 @@ func (muer *MultiUploader) InstanceState() *InstanceState {
 
 // Cancel 取消上传
 func (muer *MultiUploader) Cancel() {
-	close(muer.canceled)
+	muer.closeCanceledOnce.Do(func() { // 只关闭一次
+		close(muer.canceled)
+	})
 }
 
 //OnExecute 设置开始上传事件
@@ -2,9 +2,23 @@ package uploader
 
 import (
 	"context"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/pcsutil/retry"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/waitgroup"
 	"github.com/oleiade/lane"
 	"os"
+	"time"
+)
+
+var (
+	// BlockRetryPolicy 分片上传失败后, 重新上传前等待的策略, 连续失败时等待时间按指数增长
+	// 按 pcserror 的错误类别判断, 不可重试的错误停止上传
+	BlockRetryPolicy = &retry.Policy{
+		BaseDelay: 1 * time.Second,
+		MaxDelay:  30 * time.Second,
+		Jitter:    0.5,
+		Classify:  pcserror.Classify,
+	}
 )
 
 type (
@@ -13,6 +27,8 @@ type (
 		partOffset int64
 		splitUnit  SplitUnit
 		checksum   string
+
+		failures int // 连续上传失败的次数
 	}
 
 	workerList []*worker
@@ -42,6 +58,53 @@ func (muer *MultiUploader) upload() (uperr error) {
 		return err
 	}
 
+	// 从断点续传恢复, 文件已被修改时, 校验已上传的分片
+	if muer.instanceState != nil && muer.fileChanged() {
+		_, err = muer.verifyBlocks()
+		if err != nil {
+			return err
+		}
+	}
+
+	for round := 0; ; round++ {
+		uperr = muer.uploadBlocks()
+
+		select {
+		case <-muer.canceled:
+			if uperr != nil {
+				return uperr
+			}
+			return context.Canceled
+		default:
+		}
+
+		// 上传过程中文件被修改, 只重新上传内容改变的分片
+		if !muer.fileChanged() {
+			break
+		}
+		if round >= MaxVerifyRounds {
+			return ErrFileKeepChanging
+		}
+		changed, err := muer.verifyBlocks()
+		if err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			break
+		}
+		uploaderVerbose.Infof("file changed during upload, re-upload %d blocks\n", len(changed))
+	}
+
+	cerr := muer.multiUpload.CreateSuperFile(muer.workers.CheckSumList()...)
+	if cerr != nil {
+		return cerr
+	}
+
+	return
+}
+
+// uploadBlocks 上传所有未完成的分片
+func (muer *MultiUploader) uploadBlocks() (uperr error) {
 	var (
 		uploadDeque = lane.NewDeque()
 	)
@@ -85,27 +148,45 @@ func (muer *MultiUploader) upload() (uperr error) {
 				}
 				cancel()
 				if terr != nil {
+					cerr := terr
 					if me, ok := terr.(*MultiError); ok {
 						if me.Terminated { // 终止
-							muer.closeCanceledOnce.Do(func() { // 只关闭一次
-								close(muer.canceled)
-							})
+							muer.Cancel()
 							uperr = me.Err
 							return
 						}
+						cerr = me.Err
+					}
+
+					// 不可重试的错误, 如登录状态失效, 网盘容量已满, 终止
+					class := BlockRetryPolicy.ClassOf(cerr)
+					if !class.Retryable() {
+						uploaderVerbose.Warn("upload err not retryable", "id", wer.id, "class", class, "err", terr)
+						muer.Cancel()
+						uperr = cerr
+						return
 					}
 
 					uploaderVerbose.Warnf("upload err: %s, id: %d\n", terr, wer.id)
 					wer.splitUnit.Seek(0, os.SEEK_SET)
+
+					// 等待一段时间再重新上传
+					wer.failures++
+					timer := time.NewTimer(BlockRetryPolicy.Backoff(wer.failures, class))
+					select {
+					case <-muer.canceled:
+						timer.Stop()
+						return
+					case <-timer.C:
+					}
 					uploadDeque.Append(wer)
 					return
 				}
+				wer.failures = 0
 				wer.checksum = checksum
 
 				// 通知更新
-				if muer.updateInstanceStateChan != nil && len(muer.updateInstanceStateChan) < cap(muer.updateInstanceStateChan) {
-					muer.updateInstanceStateChan <- struct{}{}
-				}
+				muer.notifyInstanceState()
 			}()
 		}
 		wg.Wait()
@@ -115,20 +196,12 @@ func (muer *MultiUploader) upload() (uperr error) {
 			break
 		}
 	}
+	return
+}
 
-	select {
-	case <-muer.canceled:
-		if uperr != nil {
-			return uperr
-		}
-		return context.Canceled
-	default:
-	}
-
-	cerr := muer.multiUpload.CreateSuperFile(muer.workers.CheckSumList()...)
-	if cerr != nil {
-		return cerr
+// notifyInstanceState 通知更新断点续传信息
+func (muer *MultiUploader) notifyInstanceState() {
+	if muer.updateInstanceStateChan != nil && len(muer.updateInstanceStateChan) < cap(muer.updateInstanceStateChan) {
+		muer.updateInstanceStateChan <- struct{}{}
 	}
-
-	return
 }
@@ -0,0 +1,65 @@
+package uploader_test
+
+import (
+	"context"
+	"github.com/felixonmars/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/felixonmars/BaiduPCS-Go/requester/rio"
+	"github.com/felixonmars/BaiduPCS-Go/requester/uploader"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+type failMultiUpload struct {
+	err   error
+	tries int32
+}
+
+func (mu *failMultiUpload) Precreate() error {
+	return nil
+}
+
+func (mu *failMultiUpload) TmpFile(ctx context.Context, partseq int, partOffset int64, r rio.ReaderLen64) (string, error) {
+	atomic.AddInt32(&mu.tries, 1)
+	return "", mu.err
+}
+
+func (mu *failMultiUpload) CreateSuperFile(checksumList ...string) error {
+	return nil
+}
+
+func TestMultiUploaderNotRetryable(t *testing.T) {
+	f, err := ioutil.TempFile("", "multiworker_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.Write(make([]byte, 1000))
+
+	// 网盘容量已满, 不再重试
+	errInfo := pcserror.NewPCSErrorInfo("test")
+	errInfo.ErrCode = 31112
+	errInfo.SetRemoteError()
+
+	var (
+		mu     = &failMultiUpload{err: errInfo}
+		gotErr error
+	)
+	muer := uploader.NewMultiUploader(mu, rio.NewFileReaderAtLen64(f), &uploader.MultiUploaderConfig{
+		Parallel:  1,
+		BlockSize: 1000,
+	})
+	muer.OnError(func(err error) {
+		gotErr = err
+	})
+	muer.Execute()
+
+	if gotErr != errInfo {
+		t.Fatalf("err: %v, want: %v", gotErr, errInfo)
+	}
+	if tries := atomic.LoadInt32(&mu.tries); tries != 1 {
+		t.Fatalf("tries: %d, want: 1", tries)
+	}
+}
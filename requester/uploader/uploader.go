@@ -2,9 +2,9 @@
 package uploader
 
 import (
+	"github.com/felixonmars/BaiduPCS-Go/pcslog"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil"
 	"github.com/felixonmars/BaiduPCS-Go/pcsutil/converter"
-	"github.com/felixonmars/BaiduPCS-Go/pcsverbose"
 	"github.com/felixonmars/BaiduPCS-Go/requester"
 	"github.com/felixonmars/BaiduPCS-Go/requester/rio"
 	"net/http"
@@ -39,7 +39,7 @@ type (
 )
 
 var (
-	uploaderVerbose = pcsverbose.New("UPLOADER")
+	uploaderVerbose = pcslog.New("UPLOADER")
 )
 
 // NewUploader 返回 uploader 对象, url: 上传地址, readerlen64: 实现 rio.ReaderLen64 接口的对象, 例如文件
@@ -0,0 +1,66 @@
+package uploader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	// MaxVerifyRounds 上传过程中文件被修改时, 最多重新上传的轮数
+	MaxVerifyRounds = 3
+)
+
+var (
+	// ErrFileSizeChanged 上传过程中文件大小被修改, 已上传的分片已失效
+	ErrFileSizeChanged = errors.New("file size changed during upload")
+	// ErrFileKeepChanging 上传过程中文件一直被修改
+	ErrFileKeepChanging = errors.New("file keeps changing during upload")
+)
+
+func (muer *MultiUploader) fileChanged() bool {
+	return muer.config.FileChanged != nil && muer.config.FileChanged()
+}
+
+// verifyBlocks 重新计算已上传的分片的 md5, 与服务器返回的 md5 不一致的分片,
+// 清除 checksum 以重新上传, 返回内容改变的分片
+func (muer *MultiUploader) verifyBlocks() (changed workerList, err error) {
+	var total int64
+	for _, wer := range muer.workers {
+		if end := wer.splitUnit.Range().End; end > total {
+			total = end
+		}
+	}
+	if muer.file.Len() != total {
+		return nil, ErrFileSizeChanged
+	}
+
+	buf := make([]byte, BufioReadSize)
+	for _, wer := range muer.workers {
+		if wer.checksum == "" {
+			continue
+		}
+
+		r := wer.splitUnit.Range()
+		h := md5.New()
+		_, err = io.CopyBuffer(h, io.NewSectionReader(muer.file, r.Begin, r.End-r.Begin), buf)
+		if err != nil {
+			return nil, err
+		}
+		if hex.EncodeToString(h.Sum(nil)) == strings.ToLower(wer.checksum) {
+			continue
+		}
+
+		uploaderVerbose.Warnf("block changed, id: %d, range: %s\n", wer.id, r.ShowDetails())
+		wer.checksum = ""
+		wer.splitUnit = NewBufioSplitUnit(muer.file, r, muer.speedsStat, muer.rateLimit)
+		changed = append(changed, wer)
+	}
+
+	if len(changed) > 0 {
+		muer.notifyInstanceState()
+	}
+	return changed, nil
+}
@@ -0,0 +1,92 @@
+package uploader_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/felixonmars/BaiduPCS-Go/requester/rio"
+	"github.com/felixonmars/BaiduPCS-Go/requester/uploader"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+type memMultiUpload struct {
+	uploaded map[int]int // 每个分片上传的次数
+	merged   []string
+	mu       sync.Mutex
+}
+
+func (mu *memMultiUpload) Precreate() error {
+	return nil
+}
+
+func (mu *memMultiUpload) TmpFile(ctx context.Context, partseq int, partOffset int64, r rio.ReaderLen64) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	mu.mu.Lock()
+	mu.uploaded[partseq]++
+	mu.mu.Unlock()
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (mu *memMultiUpload) CreateSuperFile(checksumList ...string) error {
+	mu.merged = checksumList
+	return nil
+}
+
+func TestMultiUploaderFileChanged(t *testing.T) {
+	f, err := ioutil.TempFile("", "verify_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	data := make([]byte, 4000)
+	f.Write(data)
+
+	var (
+		mu      = &memMultiUpload{uploaded: map[int]int{}}
+		checked int
+	)
+	muer := uploader.NewMultiUploader(mu, rio.NewFileReaderAtLen64(f), &uploader.MultiUploaderConfig{
+		Parallel:  2,
+		BlockSize: 1000,
+		FileChanged: func() bool {
+			checked++
+			if checked == 1 {
+				// 上传完成后, 修改第3个分片
+				f.WriteAt([]byte("changed"), 2500)
+				return true
+			}
+			return false
+		},
+	})
+	muer.OnError(func(err error) {
+		t.Fatal(err)
+	})
+	muer.Execute()
+
+	for id, n := range mu.uploaded {
+		want := 1
+		if id == 2 {
+			want = 2
+		}
+		if n != want {
+			t.Errorf("block %d uploaded %d times, want %d", id, n, want)
+		}
+	}
+
+	copy(data[2500:], "changed")
+	for i, checksum := range mu.merged {
+		sum := md5.Sum(data[i*1000 : (i+1)*1000])
+		if checksum != hex.EncodeToString(sum[:]) {
+			t.Errorf("block %d checksum mismatch", i)
+		}
+	}
+}